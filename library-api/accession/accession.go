@@ -0,0 +1,200 @@
+// Package accession 图书副本登录号（条码）的分配与校验
+//
+// 登录号由前缀、定长序号和可选的Luhn校验位组成，如 LIB000000127。
+// 序号保存在 accession_sequences 表中，在写事务内递增，因此全局唯一且连续。
+package accession
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/models"
+)
+
+// 校验位方案
+const (
+	CheckLuhn = "luhn"
+	CheckNone = "none"
+)
+
+// sequenceName 副本登录号使用的序列
+const sequenceName = "book_copy"
+
+var (
+	prefix      = "LIB"
+	digits      = 8
+	checkScheme = CheckLuhn
+)
+
+// Init 根据配置初始化登录号格式
+func Init(cfg *config.Config) {
+	prefix = cfg.AccessionPrefix
+	digits = cfg.AccessionDigits
+	checkScheme = cfg.AccessionCheckDigit
+}
+
+// Normalize 规范化扫描或输入的条码
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// LuhnDigit 计算字符串中数字部分的Luhn校验位，非数字字符不参与计算
+func LuhnDigit(s string) int {
+	sum := 0
+	double := true
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// Format 生成序号对应的登录号
+func Format(seq int64) string {
+	code := fmt.Sprintf("%s%0*d", prefix, digits, seq)
+	if checkScheme == CheckLuhn {
+		code += strconv.Itoa(LuhnDigit(code))
+	}
+	return code
+}
+
+// Parse 按当前格式校验登录号并返回其中的序号
+func Parse(code string) (int64, bool) {
+	code = Normalize(code)
+	if !strings.HasPrefix(code, prefix) {
+		return 0, false
+	}
+
+	body := code[len(prefix):]
+	if checkScheme == CheckLuhn {
+		if len(body) < 2 {
+			return 0, false
+		}
+		check := body[len(body)-1]
+		body = body[:len(body)-1]
+		if int(check-'0') != LuhnDigit(prefix+body) {
+			return 0, false
+		}
+	}
+
+	if len(body) < digits || strings.Trim(body, "0123456789") != "" {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(body, 10, 64)
+	if err != nil || seq < 1 {
+		return 0, false
+	}
+	return seq, true
+}
+
+// Valid 判断登录号是否符合当前格式
+func Valid(code string) bool {
+	_, ok := Parse(code)
+	return ok
+}
+
+// Next 在事务中分配n个连续的登录号
+func Next(tx *gorm.DB, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	if err := ensureSequence(tx, 1); err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.AccessionSequence{}).
+		Where("name = ?", sequenceName).
+		Update("value", gorm.Expr("value + ?", n)).Error; err != nil {
+		return nil, err
+	}
+
+	var seq models.AccessionSequence
+	if err := tx.Where("name = ?", sequenceName).First(&seq).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, n)
+	first := seq.Value - int64(n)
+	for i := range codes {
+		codes[i] = Format(first + int64(i))
+	}
+	return codes, nil
+}
+
+// ensureSequence 确保序列存在且下一个序号不小于 next
+func ensureSequence(tx *gorm.DB, next int64) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.AccessionSequence{Name: sequenceName, Value: next}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.AccessionSequence{}).
+		Where("name = ? AND value < ?", sequenceName, next).
+		Update("value", next).Error
+}
+
+// wellFormed 判断已有的副本编号能否继续作为条码使用：非空且只包含大写字母和数字
+func wellFormed(code string) bool {
+	return code != "" && code == Normalize(code) &&
+		strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") == ""
+}
+
+// MigrateCopies 为编号缺失、格式错误或重复的副本（包括已删除的副本）重新分配登录号，
+// 并为副本编号创建唯一索引，返回重新分配的副本数量。已贴条码的合法编号保持不变
+func MigrateCopies(db *gorm.DB) (int, error) {
+	var regenerated int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var copies []models.BookCopy
+		if err := tx.Unscoped().Select("id", "copy_number").Order("id ASC").Find(&copies).Error; err != nil {
+			return err
+		}
+
+		// 序列从现有最大序号之后继续，避免与已分配的登录号重复
+		var maxSeq int64
+		seen := make(map[string]bool, len(copies))
+		var stale []uint
+		for _, c := range copies {
+			if !wellFormed(c.CopyNumber) || seen[c.CopyNumber] {
+				stale = append(stale, c.ID)
+				continue
+			}
+			seen[c.CopyNumber] = true
+			if seq, ok := Parse(c.CopyNumber); ok && seq > maxSeq {
+				maxSeq = seq
+			}
+		}
+		if err := ensureSequence(tx, maxSeq+1); err != nil {
+			return err
+		}
+
+		codes, err := Next(tx, len(stale))
+		if err != nil {
+			return err
+		}
+		for i, id := range stale {
+			if err := tx.Unscoped().Model(&models.BookCopy{}).
+				Where("id = ?", id).
+				UpdateColumn("copy_number", codes[i]).Error; err != nil {
+				return err
+			}
+		}
+		regenerated = len(stale)
+
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_book_copies_copy_number ON book_copies(copy_number)").Error
+	})
+	return regenerated, err
+}
@@ -0,0 +1,164 @@
+package accession
+
+import (
+	"testing"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// useFormat 在测试期间使用指定的登录号格式
+func useFormat(t *testing.T, p string, d int, check string) {
+	t.Helper()
+
+	previousPrefix, previousDigits, previousCheck := prefix, digits, checkScheme
+	prefix, digits, checkScheme = p, d, check
+	t.Cleanup(func() { prefix, digits, checkScheme = previousPrefix, previousDigits, previousCheck })
+}
+
+func TestLuhnDigit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"7992739871", 3},
+		{"LIB00000001", 8}, // 前缀中的字母不参与计算
+		{"00000001", 8},
+		{"LIB00000127", 1},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := LuhnDigit(tt.in); got != tt.want {
+			t.Errorf("LuhnDigit(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	useFormat(t, "LIB", 8, CheckLuhn)
+	tests := []struct {
+		seq  int64
+		want string
+	}{
+		{1, "LIB000000018"},
+		{127, "LIB000001271"},
+		{123456789, "LIB1234567897"}, // 超过位数时不截断
+	}
+	for _, tt := range tests {
+		if got := Format(tt.seq); got != tt.want {
+			t.Errorf("Format(%d) = %q, want %q", tt.seq, got, tt.want)
+		}
+	}
+
+	useFormat(t, "BC", 4, CheckNone)
+	if got := Format(42); got != "BC0042" {
+		t.Errorf("Format without check digit = %q, want BC0042", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	useFormat(t, "LIB", 8, CheckLuhn)
+	tests := []struct {
+		code    string
+		wantSeq int64
+		wantOK  bool
+	}{
+		{"LIB000000018", 1, true},
+		{" lib000001271 ", 127, true}, // 扫描输入规范化
+		{"LIB1234567897", 123456789, true},
+		{"LIB000000017", 0, false}, // 校验位错误
+		{"LIB000001721", 0, false}, // 相邻数字互换
+		{"ABC000000018", 0, false}, // 前缀错误
+		{"LIB00000018", 0, false},  // 序号位数不足
+		{"LIB000000000", 0, false}, // 序号为0
+		{"LIB0000X0018", 0, false},
+		{"LIB8", 0, false},
+		{"LIB", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		seq, ok := Parse(tt.code)
+		if seq != tt.wantSeq || ok != tt.wantOK {
+			t.Errorf("Parse(%q) = %d, %v, want %d, %v", tt.code, seq, ok, tt.wantSeq, tt.wantOK)
+		}
+	}
+
+	// 每个序号的登录号都能解析回原序号
+	for seq := int64(1); seq <= 2000; seq++ {
+		if got, ok := Parse(Format(seq)); !ok || got != seq {
+			t.Fatalf("Parse(Format(%d)) = %d, %v", seq, got, ok)
+		}
+	}
+
+	useFormat(t, "BC", 4, CheckNone)
+	if seq, ok := Parse("BC0042"); !ok || seq != 42 {
+		t.Errorf("Parse without check digit = %d, %v, want 42", seq, ok)
+	}
+}
+
+func TestMigrateCopies(t *testing.T) {
+	useFormat(t, "LIB", 8, CheckLuhn)
+	db := databasetest.Open(t)
+
+	book := models.Book{Title: "Dune", ISBN: "9780441013593"}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	numbers := []string{
+		Format(5),    // 合法的登录号，保持不变
+		"",           // 缺失
+		"lib-7",      // 格式错误
+		Format(5),    // 重复
+		"OLD123",     // 旧条码，格式合法但不是登录号，保持不变
+		"with space", // 已删除的副本同样重新分配
+	}
+	copies := make([]models.BookCopy, len(numbers))
+	for i, number := range numbers {
+		copies[i] = models.BookCopy{BookID: book.ID, CopyNumber: number, Status: models.CopyAvailable}
+		if err := db.Create(&copies[i]).Error; err != nil {
+			t.Fatalf("create copy: %v", err)
+		}
+	}
+	if err := db.Delete(&copies[5]).Error; err != nil {
+		t.Fatalf("delete copy: %v", err)
+	}
+
+	n, err := MigrateCopies(db)
+	if err != nil {
+		t.Fatalf("MigrateCopies: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("regenerated = %d, want 4", n)
+	}
+
+	// 新登录号从已有的最大序号之后按副本ID顺序分配
+	want := []string{Format(5), Format(6), Format(7), Format(8), "OLD123", Format(9)}
+	for i, c := range copies {
+		var got models.BookCopy
+		if err := db.Unscoped().First(&got, c.ID).Error; err != nil {
+			t.Fatalf("load copy: %v", err)
+		}
+		if got.CopyNumber != want[i] {
+			t.Errorf("copy %d number = %q, want %q", c.ID, got.CopyNumber, want[i])
+		}
+	}
+
+	// 唯一索引阻止重复的登录号
+	err = db.Create(&models.BookCopy{BookID: book.ID, CopyNumber: Format(5), Status: models.CopyAvailable}).Error
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("duplicate copy number err = %v, want unique violation", err)
+	}
+
+	// 再次迁移不修改任何副本，序列继续递增
+	if n, err := MigrateCopies(db); err != nil || n != 0 {
+		t.Errorf("second MigrateCopies = %d, %v, want 0", n, err)
+	}
+	codes, err := Next(db, 2)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if len(codes) != 2 || codes[0] != Format(10) || codes[1] != Format(11) {
+		t.Errorf("Next = %v, want %s and %s", codes, Format(10), Format(11))
+	}
+}
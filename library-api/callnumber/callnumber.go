@@ -0,0 +1,92 @@
+// Package callnumber 索书号的校验与排架排序
+//
+// 索书号按字符串直接比较时顺序不正确（如 "PR9" 会排在 "PR10" 之后），
+// ShelfKey 将杜威十进分类法（Dewey）和美国国会图书馆分类法（LCC）的索书号转换为
+// 可直接按字符串排序的排架键，与书架上的实际顺序一致。
+package callnumber
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 分类法
+const (
+	Dewey = "dewey"
+	LCC   = "lcc"
+)
+
+// ErrInvalid 索书号不符合分类法格式
+var ErrInvalid = errors.New("invalid call number")
+
+// ErrUnknownScheme 不支持的分类法
+var ErrUnknownScheme = errors.New("call number scheme must be dewey or lcc")
+
+var (
+	// deweyPattern 分类号（3位整数和可选的小数）后接书次号等
+	deweyPattern = regexp.MustCompile(`^(\d{1,3})(?:\.(\d+))?(?:\s+(.*))?$`)
+	// lccPattern 类目字母、类号和可选的小数，后接 Cutter 号、年份等
+	lccPattern = regexp.MustCompile(`^([A-Z]{1,3})\s*(\d{1,4})(?:\.(\d+))?\s*(.*)$`)
+	// cutterPattern Cutter 号，如 .O32 或 L6
+	cutterPattern = regexp.MustCompile(`^\.?([A-Z])(\d+)$`)
+)
+
+// Normalize 规范化输入的索书号：去除首尾空白、合并空格并转换为大写
+func Normalize(raw string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(raw), " "))
+}
+
+// ShelfKey 校验索书号并返回排架键
+func ShelfKey(scheme, raw string) (string, error) {
+	callNumber := Normalize(raw)
+	switch scheme {
+	case Dewey:
+		return deweyKey(callNumber)
+	case LCC:
+		return lccKey(callNumber)
+	default:
+		return "", ErrUnknownScheme
+	}
+}
+
+// deweyKey 分类号整数部分补足3位，小数部分按字符串比较即为数值顺序
+func deweyKey(callNumber string) (string, error) {
+	m := deweyPattern.FindStringSubmatch(callNumber)
+	if m == nil {
+		return "", fmt.Errorf("%w: dewey call numbers look like 823.912 TOL", ErrInvalid)
+	}
+
+	key := padDigits(m[1], 3) + "." + m[2]
+	if m[3] != "" {
+		key += " " + m[3]
+	}
+	return key, nil
+}
+
+// lccKey 类目字母补足3位，类号整数部分补足4位，Cutter 号的数字按小数比较
+func lccKey(callNumber string) (string, error) {
+	m := lccPattern.FindStringSubmatch(callNumber)
+	if m == nil {
+		return "", fmt.Errorf("%w: lcc call numbers look like PR6039.O32 L6 1954", ErrInvalid)
+	}
+
+	key := fmt.Sprintf("%-3s", m[1]) + padDigits(m[2], 4) + "." + m[3]
+	for _, part := range strings.Fields(strings.ReplaceAll(m[4], ".", " .")) {
+		if c := cutterPattern.FindStringSubmatch(part); c != nil {
+			key += " " + c[1] + "." + c[2]
+		} else {
+			key += " " + strings.TrimPrefix(part, ".")
+		}
+	}
+	return key, nil
+}
+
+// padDigits 在数字前补0至指定宽度
+func padDigits(digits string, width int) string {
+	if len(digits) >= width {
+		return digits
+	}
+	return strings.Repeat("0", width-len(digits)) + digits
+}
@@ -0,0 +1,87 @@
+package callnumber
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestShelfKey(t *testing.T) {
+	tests := []struct {
+		scheme string
+		raw    string
+		want   string
+	}{
+		{Dewey, "823.912 TOL", "823.912 TOL"},
+		{Dewey, "  5  a ", "005. A"},
+		{Dewey, "82.3", "082.3"},
+		{LCC, "PR9", "PR 0009."},
+		{LCC, "pr6039.o32 l6 1954", "PR 6039. O.32 L.6 1954"},
+		{LCC, "QA76.73 .G63", "QA 0076.73 G.63"},
+		{LCC, "P35", "P  0035."},
+	}
+	for _, tt := range tests {
+		got, err := ShelfKey(tt.scheme, tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("ShelfKey(%s, %q) = %q, %v, want %q", tt.scheme, tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestShelfKeyInvalid(t *testing.T) {
+	tests := []struct {
+		scheme string
+		raw    string
+		want   error
+	}{
+		{Dewey, "", ErrInvalid},
+		{Dewey, "ABC", ErrInvalid},
+		{Dewey, "1234", ErrInvalid},
+		{Dewey, "823.", ErrInvalid},
+		{LCC, "", ErrInvalid},
+		{LCC, "123", ErrInvalid},
+		{LCC, "PR", ErrInvalid},
+		{LCC, "ABCD1", ErrInvalid},
+		{"udc", "823", ErrUnknownScheme},
+	}
+	for _, tt := range tests {
+		if _, err := ShelfKey(tt.scheme, tt.raw); !errors.Is(err, tt.want) {
+			t.Errorf("ShelfKey(%s, %q) err = %v, want %v", tt.scheme, tt.raw, err, tt.want)
+		}
+	}
+}
+
+// 按排架键排序后与书架上的顺序一致
+func TestShelfOrder(t *testing.T) {
+	tests := []struct {
+		scheme string
+		shelf  []string
+	}{
+		{Dewey, []string{"5", "5.1", "82.3", "823", "823.9", "823.912", "823.912 TOL", "823.92", "900"}},
+		{LCC, []string{"P35", "PR9", "PR10", "PR10.5", "PR6039.O32 L6 1954", "PR6039.O4", "PR6039.O4 1960", "PS1", "Q1", "QA76.73 .G63"}},
+	}
+	for _, tt := range tests {
+		shuffled := append([]string(nil), tt.shelf...)
+		rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+
+		keys := map[string]string{}
+		for _, raw := range shuffled {
+			key, err := ShelfKey(tt.scheme, raw)
+			if err != nil {
+				t.Fatalf("ShelfKey(%s, %q): %v", tt.scheme, raw, err)
+			}
+			keys[raw] = key
+		}
+		sort.Slice(shuffled, func(i, j int) bool { return keys[shuffled[i]] < keys[shuffled[j]] })
+
+		for i := range tt.shelf {
+			if shuffled[i] != tt.shelf[i] {
+				t.Errorf("%s order = %q, want %q", tt.scheme, shuffled, tt.shelf)
+				break
+			}
+		}
+	}
+}
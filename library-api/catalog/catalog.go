@@ -0,0 +1,24 @@
+// Package catalog 图书编目数据的批量导入与导出
+//
+// 导入文件为 CSV（带表头）、JSON Lines 或 MARC21（二进制或 MARCXML），每条记录一本图书，
+// 包含作者名称和副本数量。作者按名称匹配，不存在时自动创建；已存在的 ISBN 按导入模式跳过或更新。
+// 馆藏可以导出为 MARCXML。
+package catalog
+
+import "github.com/example/library-api/config"
+
+var (
+	batchSize            = 100
+	maxImportBytes int64 = 20 << 20
+)
+
+// Init 根据配置初始化导入参数
+func Init(cfg *config.Config) {
+	batchSize = cfg.ImportBatchSize
+	maxImportBytes = cfg.ImportMaxBytes
+}
+
+// MaxImportBytes 返回上传导入文件的最大字节数
+func MaxImportBytes() int64 {
+	return maxImportBytes
+}
@@ -0,0 +1,479 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/accession"
+	"github.com/example/library-api/callnumber"
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/isbn"
+	"github.com/example/library-api/models"
+)
+
+// 已存在ISBN的处理方式
+const (
+	ModeSkip   = "skip"   // 跳过已存在的图书
+	ModeUpdate = "update" // 用导入记录中的非空字段更新已存在的图书
+)
+
+// 每行导入结果
+const (
+	RowCreated  = "created"
+	RowUpdated  = "updated"
+	RowSkipped  = "skipped"
+	RowRejected = "rejected"
+)
+
+// ErrUnknownMode 不支持的导入模式
+var ErrUnknownMode = errors.New("import mode must be skip or update")
+
+// ErrUnreadable 导入文件无法继续读取（如 XML 语法错误或上传中断）
+var ErrUnreadable = errors.New("import file could not be read")
+
+// maxCopiesPerRow 单行记录最多创建的副本数量
+const maxCopiesPerRow = 1000
+
+// Options 导入选项
+type Options struct {
+	Mode      string
+	BatchSize int // 每个事务处理的行数，为0时使用配置值
+}
+
+// RowResult 单行记录的导入结果
+type RowResult struct {
+	Row            int    `json:"row"`
+	ISBN           string `json:"isbn,omitempty"`
+	Title          string `json:"title,omitempty"`
+	Status         string `json:"status"`
+	BookID         uint   `json:"book_id,omitempty"`
+	CopiesAdded    int    `json:"copies_added,omitempty"`
+	AuthorsCreated int    `json:"authors_created,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Report 导入报告
+type Report struct {
+	Total    int         `json:"total"`
+	Created  int         `json:"created"`
+	Updated  int         `json:"updated"`
+	Skipped  int         `json:"skipped"`
+	Rejected int         `json:"rejected"`
+	Rows     []RowResult `json:"rows"`
+}
+
+func (r *Report) add(result RowResult) {
+	r.Total++
+	switch result.Status {
+	case RowCreated:
+		r.Created++
+	case RowUpdated:
+		r.Updated++
+	case RowSkipped:
+		r.Skipped++
+	case RowRejected:
+		r.Rejected++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// pendingRow 等待写入的记录
+type pendingRow struct {
+	row    int
+	record *Record
+}
+
+// Import 分批导入图书。每批在一个事务中执行，单行校验失败只拒绝该行；
+// 文件无法继续读取（返回 ErrUnreadable）或数据库出错时中止导入，此前已提交的批次保留，报告中包含这些批次的结果
+func Import(db *gorm.DB, r RecordReader, opts Options) (*Report, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeSkip
+	}
+	if opts.Mode != ModeSkip && opts.Mode != ModeUpdate {
+		return nil, ErrUnknownMode
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = batchSize
+	}
+
+	report := &Report{Rows: []RowResult{}}
+	branches := map[string]*uint{}
+	batch := make([]pendingRow, 0, opts.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		results, err := importBatch(db, batch, opts.Mode, branches)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			report.add(result)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	var err error
+	for {
+		row, rec, readErr := r.Next()
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(readErr, &rowErr) {
+			report.add(RowResult{Row: rowErr.Row, Status: RowRejected, Error: rowErr.Err.Error()})
+			continue
+		}
+		if readErr != nil {
+			err = fmt.Errorf("%w: %w", ErrUnreadable, readErr)
+			break
+		}
+
+		batch = append(batch, pendingRow{row: row, record: rec})
+		if len(batch) >= opts.BatchSize {
+			if err = flush(); err != nil {
+				break
+			}
+		}
+	}
+	// 读取中断前已读到的行照常写入
+	if err == nil || errors.Is(err, ErrUnreadable) {
+		if flushErr := flush(); flushErr != nil {
+			err = flushErr
+		}
+	}
+
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		return report.Rows[i].Row < report.Rows[j].Row
+	})
+	return report, err
+}
+
+// importBatch 在一个事务中导入一批记录。每行先完成全部校验再写入，被拒绝的行不会留下部分数据
+func importBatch(db *gorm.DB, batch []pendingRow, mode string, branches map[string]*uint) ([]RowResult, error) {
+	results := make([]RowResult, 0, len(batch))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		authors := map[string]*models.Author{}
+		now := time.Now()
+		for _, p := range batch {
+			result, err := importRecord(tx, p.record, mode, authors, branches, now)
+			result.Row = p.row
+			var rowErr *RowError
+			if errors.As(err, &rowErr) {
+				result.Status = RowRejected
+				result.Error = rowErr.Err.Error()
+			} else if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// importRecord 导入单条记录，校验失败返回 *RowError
+func importRecord(tx *gorm.DB, rec *Record, mode string, authors map[string]*models.Author, branches map[string]*uint, now time.Time) (RowResult, error) {
+	result := RowResult{ISBN: strings.TrimSpace(rec.ISBN), Title: strings.TrimSpace(rec.Title)}
+	reject := func(format string, args ...interface{}) (RowResult, error) {
+		return result, &RowError{Err: fmt.Errorf(format, args...)}
+	}
+
+	if result.ISBN == "" {
+		return reject("isbn is required")
+	}
+	canonicalISBN, err := isbn.Normalize(result.ISBN)
+	if err != nil {
+		return reject("%v", err)
+	}
+	result.ISBN = canonicalISBN
+	if len(result.Title) > 200 {
+		return reject("title must be at most 200 characters")
+	}
+	publicationDate, err := parsePublicationDate(rec.PublicationDate)
+	if err != nil {
+		return reject("%v", err)
+	}
+	authorNames, err := normalizeAuthors(rec.Authors)
+	if err != nil {
+		return result, err
+	}
+	plans, err := planCopies(tx, rec, branches)
+	if err != nil {
+		return result, err
+	}
+
+	var book models.Book
+	err = tx.Unscoped().Where("isbn = ?", result.ISBN).First(&book).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+	if exists && book.DeletedAt.Valid {
+		return reject("isbn belongs to a deleted book")
+	}
+	if exists && mode == ModeSkip {
+		result.Status = RowSkipped
+		result.BookID = book.ID
+		return result, nil
+	}
+	if !exists && result.Title == "" {
+		return reject("title is required")
+	}
+
+	bookAuthors, created, err := resolveAuthors(tx, authorNames, authors)
+	if err != nil {
+		return result, err
+	}
+	result.AuthorsCreated = created
+
+	if exists {
+		if result.Title != "" {
+			book.Title = result.Title
+		}
+		if rec.Publisher != "" {
+			book.Publisher = strings.TrimSpace(rec.Publisher)
+		}
+		if rec.Description != "" {
+			book.Description = strings.TrimSpace(rec.Description)
+		}
+		if !publicationDate.IsZero() {
+			book.PublicationDate = publicationDate
+		}
+		if err := tx.Save(&book).Error; err != nil {
+			return result, err
+		}
+		if len(bookAuthors) > 0 {
+			if err := tx.Model(&book).Association("Authors").Replace(bookAuthors); err != nil {
+				return result, err
+			}
+		}
+
+		// 更新时 copies 表示应有的副本数量（指定分馆时为该分馆的数量），只补足缺少的部分
+		for i := range plans {
+			var existing int64
+			query := tx.Model(&models.BookCopy{}).Where("book_id = ? AND status <> ?", book.ID, models.CopyWithdrawn)
+			if plans[i].branchID != nil {
+				query = query.Where("branch_id = ?", *plans[i].branchID)
+			} else if len(plans) > 1 {
+				query = query.Where("branch_id IS NULL")
+			}
+			if err := query.Count(&existing).Error; err != nil {
+				return result, err
+			}
+			plans[i].copies -= int(existing)
+		}
+		result.Status = RowUpdated
+	} else {
+		book = models.Book{
+			Title:           result.Title,
+			ISBN:            result.ISBN,
+			Description:     strings.TrimSpace(rec.Description),
+			Publisher:       strings.TrimSpace(rec.Publisher),
+			PublicationDate: publicationDate,
+		}
+		if err := tx.Create(&book).Error; err != nil {
+			return result, err
+		}
+		if len(bookAuthors) > 0 {
+			if err := tx.Model(&book).Association("Authors").Append(bookAuthors); err != nil {
+				return result, err
+			}
+		}
+		result.Status = RowCreated
+	}
+	result.BookID = book.ID
+
+	for _, plan := range plans {
+		if plan.copies > 0 {
+			if err := addCopies(tx, book.ID, plan.copies, plan.branchID, plan.location, now); err != nil {
+				return result, err
+			}
+			result.CopiesAdded += plan.copies
+		}
+	}
+
+	return result, nil
+}
+
+// parsePublicationDate 解析 YYYY-MM-DD 或 YYYY 格式的出版日期，只有年份时取当年1月1日
+func parsePublicationDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid publication date format, should be YYYY-MM-DD or YYYY")
+}
+
+// resolveBranch 按代码查找分馆，结果在整个导入过程中缓存
+func resolveBranch(tx *gorm.DB, code string, branches map[string]*uint) (*uint, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, nil
+	}
+	if id, ok := branches[code]; ok {
+		if id == nil {
+			return nil, &RowError{Err: fmt.Errorf("branch %s not found", code)}
+		}
+		return id, nil
+	}
+
+	var branch models.Branch
+	err := tx.Where("code = ?", code).First(&branch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		branches[code] = nil
+		return nil, &RowError{Err: fmt.Errorf("branch %s not found", code)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	branches[code] = &branch.ID
+	return &branch.ID, nil
+}
+
+// normalizeAuthors 合并作者名称中的空白并去除重复（不区分大小写）
+func normalizeAuthors(names []string) ([]string, error) {
+	var result []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		if len(name) > 100 {
+			return nil, &RowError{Err: errors.New("author name must be at most 100 characters")}
+		}
+		seen[key] = true
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// resolveAuthors 按名称（不区分大小写）查找作者，不存在时创建，返回作者列表和新建数量。
+// 同一批次内的结果缓存在 cache 中
+func resolveAuthors(tx *gorm.DB, names []string, cache map[string]*models.Author) ([]models.Author, int, error) {
+	result := make([]models.Author, 0, len(names))
+	created := 0
+	for _, name := range names {
+		key := strings.ToLower(name)
+		author, ok := cache[key]
+		if !ok {
+			var found models.Author
+			err := tx.Where("LOWER(name) = ?", key).Order("id ASC").First(&found).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				found = models.Author{Name: name}
+				if err := tx.Create(&found).Error; err != nil {
+					return nil, 0, err
+				}
+				created++
+			} else if err != nil {
+				return nil, 0, err
+			}
+			author = &found
+			cache[key] = author
+		}
+		result = append(result, *author)
+	}
+	return result, created, nil
+}
+
+// copyPlan 导入时为一个分馆新增的副本
+type copyPlan struct {
+	copies   int
+	branchID *uint
+	location models.BookCopy
+}
+
+// planCopies 校验记录中的副本数量、分馆和索书号。MARC 记录按 Holdings 的分馆分组，其余记录只有一组
+func planCopies(tx *gorm.DB, rec *Record, branches map[string]*uint) ([]copyPlan, error) {
+	holdings := rec.Holdings
+	if len(holdings) == 0 {
+		holdings = []Holding{{Branch: rec.Branch, Copies: rec.Copies, CallNumber: rec.CallNumber, CallNumberScheme: rec.CallNumberScheme}}
+	}
+
+	total := 0
+	for _, h := range holdings {
+		if h.Copies < 0 || total+h.Copies > maxCopiesPerRow {
+			return nil, &RowError{Err: fmt.Errorf("copies must be between 0 and %d", maxCopiesPerRow)}
+		}
+		total += h.Copies
+	}
+
+	plans := make([]copyPlan, len(holdings))
+	for i, h := range holdings {
+		branchID, err := resolveBranch(tx, h.Branch, branches)
+		if err != nil {
+			return nil, err
+		}
+		location, err := parseLocation(h.CallNumber, h.CallNumberScheme)
+		if err != nil {
+			return nil, err
+		}
+		plans[i] = copyPlan{copies: h.Copies, branchID: branchID, location: location}
+	}
+	return plans, nil
+}
+
+// parseLocation 校验索书号，返回新副本的索书号字段
+func parseLocation(number, scheme string) (models.BookCopy, error) {
+	var location models.BookCopy
+	if strings.TrimSpace(number) == "" {
+		return location, nil
+	}
+
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+	key, err := callnumber.ShelfKey(scheme, number)
+	if err != nil {
+		return location, &RowError{Err: err}
+	}
+	location.CallNumber = callnumber.Normalize(number)
+	location.CallNumberScheme = scheme
+	location.ShelfKey = key
+	return location, nil
+}
+
+// addCopies 为图书创建可借副本并分配登录号，新副本优先分配给排队中的预约读者
+func addCopies(tx *gorm.DB, bookID uint, n int, branchID *uint, location models.BookCopy, now time.Time) error {
+	codes, err := accession.Next(tx, n)
+	if err != nil {
+		return err
+	}
+
+	copies := make([]models.BookCopy, n)
+	for i := range copies {
+		copies[i] = models.BookCopy{
+			BookID:           bookID,
+			CopyNumber:       codes[i],
+			Status:           models.CopyAvailable,
+			AcquisitionDate:  now,
+			BranchID:         branchID,
+			CallNumber:       location.CallNumber,
+			CallNumberScheme: location.CallNumberScheme,
+			ShelfKey:         location.ShelfKey,
+		}
+	}
+	if err := tx.Create(&copies).Error; err != nil {
+		return err
+	}
+
+	for i := range copies {
+		if _, err := circulation.AssignCopy(tx, &copies[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
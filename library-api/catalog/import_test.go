@@ -0,0 +1,268 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+	"github.com/example/library-api/search"
+)
+
+// runImport 读取内存中的导入文件并导入
+func runImport(t *testing.T, db *gorm.DB, format, input, mode string) *Report {
+	t.Helper()
+
+	r, err := NewReader(format, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	report, err := Import(db, r, Options{Mode: mode})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	return report
+}
+
+// createBranch 创建分馆
+func createBranch(t *testing.T, db *gorm.DB, code string) models.Branch {
+	t.Helper()
+
+	branch := models.Branch{Code: code, Name: code}
+	if err := db.Create(&branch).Error; err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+	return branch
+}
+
+// loadBook 按 ISBN 读取图书及其作者和副本
+func loadBook(t *testing.T, db *gorm.DB, isbn string) models.Book {
+	t.Helper()
+
+	var book models.Book
+	if err := db.Preload("Authors").Preload("Copies").Where("isbn = ?", isbn).First(&book).Error; err != nil {
+		t.Fatalf("load book %s: %v", isbn, err)
+	}
+	return book
+}
+
+// authorNames 返回图书的作者名称
+func authorNames(book models.Book) []string {
+	names := make([]string, len(book.Authors))
+	for i, a := range book.Authors {
+		names[i] = a.Name
+	}
+	return names
+}
+
+func TestImportCSV(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+
+	input := "title,isbn,authors,copies,branch,publication_date,call_number,call_number_scheme,shelf\n" +
+		"Dune,978-0-441-01359-3,Frank Herbert; Brian Herbert,2,main,1965,813.54 HER,dewey,A1\n" +
+		"Dune Messiah,0-306-40615-2,frank herbert,,,1969-10-15,,,\n" +
+		"Emma,9780141439587,Jane Austen,two,,,,,\n"
+	report := runImport(t, db, FormatCSV, input, ModeSkip)
+
+	if report.Total != 3 || report.Created != 2 || report.Rejected != 1 {
+		t.Fatalf("report = %+v, want 3 rows with 2 created and 1 rejected", report)
+	}
+	want := []RowResult{
+		{Row: 2, ISBN: "9780441013593", Title: "Dune", Status: RowCreated, CopiesAdded: 2, AuthorsCreated: 2},
+		{Row: 3, ISBN: "9780306406157", Title: "Dune Messiah", Status: RowCreated},
+		{Row: 4, Status: RowRejected, Error: "copies must be a whole number"},
+	}
+	for i, w := range want {
+		got := report.Rows[i]
+		got.BookID = 0
+		if got != w {
+			t.Errorf("row %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	dune := loadBook(t, db, "9780441013593")
+	if got := authorNames(dune); len(got) != 2 || got[0] != "Frank Herbert" || got[1] != "Brian Herbert" {
+		t.Errorf("dune authors = %v, want [Frank Herbert Brian Herbert]", got)
+	}
+	if dune.PublicationDate.Year() != 1965 {
+		t.Errorf("dune publication date = %v, want 1965", dune.PublicationDate)
+	}
+	if len(dune.Copies) != 2 {
+		t.Fatalf("dune copies = %d, want 2", len(dune.Copies))
+	}
+	for _, c := range dune.Copies {
+		if c.BranchID == nil || *c.BranchID != main.ID || c.CallNumber != "813.54 HER" || c.Status != models.CopyAvailable {
+			t.Errorf("copy = %+v, want available at MAIN with call number 813.54 HER", c)
+		}
+	}
+
+	// 作者名称不区分大小写匹配已有作者
+	messiah := loadBook(t, db, "9780306406157")
+	if got := authorNames(messiah); len(got) != 1 || got[0] != "Frank Herbert" {
+		t.Errorf("messiah authors = %v, want [Frank Herbert]", got)
+	}
+	if len(messiah.Copies) != 0 {
+		t.Errorf("messiah copies = %d, want 0", len(messiah.Copies))
+	}
+}
+
+func TestImportCSVRequiresColumns(t *testing.T) {
+	for _, input := range []string{"", "title,authors\nDune,Frank Herbert\n"} {
+		if _, err := NewReader(FormatCSV, strings.NewReader(input)); err == nil {
+			t.Errorf("NewReader(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestImportRejectReasons(t *testing.T) {
+	db := databasetest.Open(t)
+	createBranch(t, db, "MAIN")
+	deleted := models.Book{Title: "Gone", ISBN: "9780141439587"}
+	if err := db.Create(&deleted).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	if err := db.Delete(&deleted).Error; err != nil {
+		t.Fatalf("delete book: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"无效JSON", `{"title": "Dune"`, "invalid json: unexpected end of JSON input"},
+		{"缺少ISBN", `{"title": "Dune"}`, "isbn is required"},
+		{"ISBN校验位错误", `{"title": "Dune", "isbn": "9780441013594"}`, "invalid isbn: isbn-13 check digit does not match"},
+		{"标题过长", fmt.Sprintf(`{"title": %q, "isbn": "9780441013593"}`, strings.Repeat("a", 201)), "title must be at most 200 characters"},
+		{"出版日期格式错误", `{"title": "Dune", "isbn": "9780441013593", "publication_date": "1965/08"}`, "invalid publication date format, should be YYYY-MM-DD or YYYY"},
+		{"作者名称过长", fmt.Sprintf(`{"title": "Dune", "isbn": "9780441013593", "authors": [%q]}`, strings.Repeat("a", 101)), "author name must be at most 100 characters"},
+		{"副本数量为负", `{"title": "Dune", "isbn": "9780441013593", "copies": -1}`, "copies must be between 0 and 1000"},
+		{"副本数量过多", `{"title": "Dune", "isbn": "9780441013593", "copies": 1001}`, "copies must be between 0 and 1000"},
+		{"分馆不存在", `{"title": "Dune", "isbn": "9780441013593", "copies": 1, "branch": "east"}`, "branch EAST not found"},
+		{"索书号无效", `{"title": "Dune", "isbn": "9780441013593", "call_number": "HER", "call_number_scheme": "dewey"}`, "invalid call number: dewey call numbers look like 823.912 TOL"},
+		{"ISBN属于已删除图书", `{"title": "Emma", "isbn": "9780141439587"}`, "isbn belongs to a deleted book"},
+		{"新书缺少标题", `{"isbn": "9780441013593"}`, "title is required"},
+	}
+
+	// 每行之间留一个空行，行号仍按文件中的行计算
+	lines := make([]string, len(tests))
+	for i, tt := range tests {
+		lines[i] = tt.line
+	}
+	report := runImport(t, db, FormatJSONL, strings.Join(lines, "\n\n"), ModeUpdate)
+	if report.Total != len(tests) || report.Rejected != len(tests) {
+		t.Fatalf("report = %d rows, %d rejected, want %d rejected", report.Total, report.Rejected, len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := report.Rows[i]
+			if got.Row != 2*i+1 || got.Status != RowRejected || got.Error != tt.want {
+				t.Errorf("row = %d %s %q, want %d rejected %q", got.Row, got.Status, got.Error, 2*i+1, tt.want)
+			}
+		})
+	}
+
+	// 被拒绝的行不留下图书、作者或副本
+	var books, authors, copies int64
+	db.Model(&models.Book{}).Count(&books)
+	db.Model(&models.Author{}).Count(&authors)
+	db.Model(&models.BookCopy{}).Count(&copies)
+	if books != 0 || authors != 0 || copies != 0 {
+		t.Errorf("books, authors, copies = %d, %d, %d, want none", books, authors, copies)
+	}
+}
+
+func TestImportModes(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+	east := createBranch(t, db, "EAST")
+	first := runImport(t, db, FormatJSONL,
+		`{"title": "Dune", "isbn": "9780441013593", "authors": ["Frank Herbert"], "publisher": "Chilton", "copies": 1, "branch": "MAIN"}`, ModeSkip)
+	if first.Created != 1 {
+		t.Fatalf("first import = %+v, want 1 created", first)
+	}
+	bookID := first.Rows[0].BookID
+
+	update := `{"title": "Dune (Deluxe)", "isbn": "0441013597", "authors": ["FRANK HERBERT", "Brian Herbert"], "copies": 3, "branch": "MAIN"}` + "\n" +
+		`{"isbn": "9780441013593", "copies": 1, "branch": "EAST"}`
+
+	// 跳过模式不修改已有图书
+	report := runImport(t, db, FormatJSONL, update, ModeSkip)
+	if report.Skipped != 2 || report.Rows[0].BookID != bookID {
+		t.Fatalf("skip report = %+v, want 2 skipped for book %d", report, bookID)
+	}
+	book := loadBook(t, db, "9780441013593")
+	if book.Title != "Dune" || len(book.Copies) != 1 || len(book.Authors) != 1 {
+		t.Errorf("after skip = %q with %d copies and %d authors, want unchanged", book.Title, len(book.Copies), len(book.Authors))
+	}
+
+	// 更新模式只更新非空字段，副本数量补足到每个分馆的目标数量
+	report = runImport(t, db, FormatJSONL, update, ModeUpdate)
+	want := []RowResult{
+		{Row: 1, ISBN: "9780441013593", Title: "Dune (Deluxe)", Status: RowUpdated, BookID: bookID, CopiesAdded: 2, AuthorsCreated: 1},
+		{Row: 2, ISBN: "9780441013593", Status: RowUpdated, BookID: bookID, CopiesAdded: 1},
+	}
+	for i, w := range want {
+		if report.Rows[i] != w {
+			t.Errorf("row %d = %+v, want %+v", i, report.Rows[i], w)
+		}
+	}
+
+	book = loadBook(t, db, "9780441013593")
+	if book.Title != "Dune (Deluxe)" || book.Publisher != "Chilton" {
+		t.Errorf("book = %q by %q, want Dune (Deluxe) by Chilton", book.Title, book.Publisher)
+	}
+	if got := authorNames(book); len(got) != 2 || got[0] != "Frank Herbert" || got[1] != "Brian Herbert" {
+		t.Errorf("authors = %v, want [Frank Herbert Brian Herbert]", got)
+	}
+	perBranch := map[uint]int{}
+	for _, c := range book.Copies {
+		if c.BranchID != nil {
+			perBranch[*c.BranchID]++
+		}
+	}
+	if perBranch[main.ID] != 3 || perBranch[east.ID] != 1 {
+		t.Errorf("copies per branch = MAIN %d, EAST %d, want 3 and 1", perBranch[main.ID], perBranch[east.ID])
+	}
+
+	// 已达到目标数量时不再新增副本
+	report = runImport(t, db, FormatJSONL, update, ModeUpdate)
+	if report.Rows[0].CopiesAdded != 0 || report.Rows[1].CopiesAdded != 0 {
+		t.Errorf("repeated update added %d and %d copies, want none", report.Rows[0].CopiesAdded, report.Rows[1].CopiesAdded)
+	}
+}
+
+func TestImportUnknownMode(t *testing.T) {
+	db := databasetest.Open(t)
+	r, err := NewReader(FormatJSONL, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if _, err := Import(db, r, Options{Mode: "replace"}); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("Import error = %v, want ErrUnknownMode", err)
+	}
+}
+
+func TestImportAfterFullTextTriggers(t *testing.T) {
+	db := databasetest.Open(t)
+
+	// 模拟支持FTS5的构建创建的索引同步触发器
+	if err := db.Exec("CREATE TRIGGER book_search_books_ai AFTER INSERT ON books BEGIN INSERT INTO book_search(rowid, title) VALUES (new.id, new.title); END").Error; err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	// 导入命令与服务器一样先初始化全文检索
+	if err := search.Init(db); err != nil && !errors.Is(err, search.ErrUnavailable) {
+		t.Fatalf("search.Init: %v", err)
+	}
+
+	report := runImport(t, db, FormatCSV, "title,isbn\nDune,9780441013593\n", ModeSkip)
+	if report.Created != 1 || report.Rejected != 0 {
+		t.Fatalf("report = %+v, want 1 created", report)
+	}
+	loadBook(t, db, "9780441013593")
+}
@@ -0,0 +1,304 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/callnumber"
+	"github.com/example/library-api/marc"
+	"github.com/example/library-api/models"
+)
+
+// exportBatchSize 导出整个馆藏时每次查询的图书数量
+const exportBatchSize = 200
+
+var (
+	// yearPattern 出版日期字段中的四位年份，如 "c1965." 或 "[1990]"
+	yearPattern = regexp.MustCompile(`\d{4}`)
+	// isbnPattern 020 字段开头的 ISBN，其后可能带有装帧等限定说明
+	isbnPattern = regexp.MustCompile(`^[0-9Xx-]+`)
+)
+
+// marcSource 二进制 MARC 和 MARCXML 读取器的共同接口
+type marcSource interface {
+	Read() (*marc.Record, error)
+}
+
+// marcReader 将 MARC 记录转换为导入记录，行号为记录在文件中的序号
+type marcReader struct {
+	src   marcSource
+	index int
+}
+
+func newMARCReader(src marcSource) *marcReader {
+	return &marcReader{src: src}
+}
+
+func (m *marcReader) Next() (int, *Record, error) {
+	rec, err := m.src.Read()
+	if err == io.EOF {
+		return 0, nil, io.EOF
+	}
+	m.index++
+	if marc.IsRecordError(err) {
+		return m.index, nil, &RowError{Row: m.index, Err: err}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return m.index, FromMARC(rec), nil
+}
+
+// FromMARC 将 MARC 书目记录映射为导入记录：
+// 020 ISBN、100/700 作者、245 题名、264/260 出版者和出版年、520 摘要，
+// 每个 852 馆藏字段对应一个副本（$b 为分馆代码，$h$i 为索书号），副本按分馆分组放入 Holdings，
+// 852 没有索书号时取自 050 或 082
+func FromMARC(rec *marc.Record) *Record {
+	r := &Record{}
+
+	for _, f := range rec.Fields("020") {
+		if isbn := isbnPattern.FindString(strings.TrimSpace(f.Subfield("a"))); isbn != "" {
+			r.ISBN = strings.ReplaceAll(isbn, "-", "")
+			break
+		}
+	}
+
+	if f := rec.Fields("245"); len(f) > 0 {
+		title := trimPunctuation(f[0].Subfield("a"))
+		if subtitle := trimPunctuation(f[0].Subfield("b")); subtitle != "" {
+			title += ": " + subtitle
+		}
+		r.Title = title
+	}
+
+	for _, tag := range []string{"100", "700"} {
+		for _, f := range rec.Fields(tag) {
+			if name := personalName(f); name != "" {
+				r.Authors = append(r.Authors, name)
+			}
+		}
+	}
+
+	// RDA 记录使用 264（第二指示符1为出版），旧记录使用 260
+	var imprint *marc.DataField
+	for _, f := range rec.Fields("264") {
+		if f.Ind2 == "1" {
+			f := f
+			imprint = &f
+			break
+		}
+	}
+	if imprint == nil {
+		if f := rec.Fields("260"); len(f) > 0 {
+			imprint = &f[0]
+		}
+	}
+	if imprint != nil {
+		r.Publisher = trimPunctuation(imprint.Subfield("b"))
+		r.PublicationDate = yearPattern.FindString(imprint.Subfield("c"))
+	}
+
+	if f := rec.Fields("520"); len(f) > 0 {
+		r.Description = f[0].Subfield("a")
+	}
+
+	// 没有 852 索书号时使用 050 或 082
+	if f := rec.Fields("050"); len(f) > 0 {
+		r.CallNumber = strings.TrimSpace(f[0].Subfield("a") + " " + f[0].Subfield("b"))
+		r.CallNumberScheme = callnumber.LCC
+	} else if f := rec.Fields("082"); len(f) > 0 {
+		// 082 用斜线标记分类号的切分位置
+		r.CallNumber = strings.ReplaceAll(f[0].Subfield("a"), "/", "")
+		r.CallNumberScheme = callnumber.Dewey
+	}
+	r.CallNumber, r.CallNumberScheme = validCallNumber(r.CallNumber, r.CallNumberScheme)
+
+	// 同一分馆的 852 字段合并为一组副本，索书号取该分馆第一个带有效索书号的字段
+	byBranch := map[string]int{}
+	for _, f := range rec.Fields("852") {
+		code := strings.ToUpper(strings.TrimSpace(f.Subfield("b")))
+		var number, scheme string
+		switch f.Ind1 {
+		case "0":
+			scheme = callnumber.LCC
+		case "1":
+			scheme = callnumber.Dewey
+		}
+		if scheme != "" {
+			number, scheme = validCallNumber(strings.TrimSpace(f.Subfield("h")+" "+f.Subfield("i")), scheme)
+		}
+
+		i, ok := byBranch[code]
+		if !ok {
+			i = len(r.Holdings)
+			byBranch[code] = i
+			r.Holdings = append(r.Holdings, Holding{Branch: code})
+		}
+		h := &r.Holdings[i]
+		h.Copies++
+		if h.CallNumber == "" {
+			h.CallNumber, h.CallNumberScheme = number, scheme
+		}
+		r.Copies++
+	}
+	for i := range r.Holdings {
+		if r.Holdings[i].CallNumber == "" {
+			r.Holdings[i].CallNumber, r.Holdings[i].CallNumberScheme = r.CallNumber, r.CallNumberScheme
+		}
+	}
+
+	return r
+}
+
+// validCallNumber 不符合排架规则的索书号不影响书目导入，返回空值
+func validCallNumber(number, scheme string) (string, string) {
+	if _, err := callnumber.ShelfKey(scheme, number); err != nil {
+		return "", ""
+	}
+	return number, scheme
+}
+
+// personalName 读取 100/700 字段的个人名称，第一指示符为1（姓在前）时转换为 "名 姓" 的顺序
+func personalName(f marc.DataField) string {
+	name := trimPunctuation(f.Subfield("a"))
+	if f.Ind1 == "1" {
+		if surname, forename, ok := strings.Cut(name, ","); ok && strings.TrimSpace(forename) != "" {
+			name = strings.TrimSpace(forename) + " " + strings.TrimSpace(surname)
+		}
+	}
+	return name
+}
+
+// invertName 将 "名 姓" 转换为 MARC 使用的 "姓, 名"，没有空格的名称（如中文姓名）保持不变
+func invertName(name string) (string, string) {
+	i := strings.LastIndex(name, " ")
+	if i < 0 {
+		return name, "0"
+	}
+	return name[i+1:] + ", " + name[:i], "1"
+}
+
+// abbreviations 末尾句点属于缩写、不应去除的词
+var abbreviations = map[string]bool{"jr": true, "sr": true, "inc": true, "co": true, "ltd": true, "st": true}
+
+// trimPunctuation 去除 ISBD 标识符等字段末尾的标点，保留首字母缩写和常见缩写结尾的句点（如 "J.R.R."、"Jr."）
+func trimPunctuation(value string) string {
+	value = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(value), " /:;,="))
+	if !strings.HasSuffix(value, ".") {
+		return value
+	}
+
+	fields := strings.Fields(strings.TrimSuffix(value, "."))
+	if len(fields) == 0 {
+		return value
+	}
+	last := fields[len(fields)-1]
+	if i := strings.LastIndexAny(last, ".,"); i >= 0 {
+		last = last[i+1:]
+	}
+	if len([]rune(last)) <= 1 || abbreviations[strings.ToLower(last)] {
+		return value
+	}
+	return strings.TrimSuffix(value, ".")
+}
+
+// ToMARC 将图书及其副本映射为 MARC 书目记录，映射规则与 FromMARC 对应。
+// 需要预加载 Authors 和 Copies（含 Branch、ShelfLocation）
+func ToMARC(book *models.Book) *marc.Record {
+	rec := marc.NewRecord()
+	rec.AddControl("001", strconv.FormatUint(uint64(book.ID), 10))
+	rec.AddControl("005", book.UpdatedAt.UTC().Format("20060102150405")+".0")
+
+	rec.AddField("020", " ", " ", "a", book.ISBN)
+
+	for i, author := range book.Authors {
+		name, ind1 := invertName(author.Name)
+		tag := "700"
+		if i == 0 {
+			tag = "100"
+		}
+		rec.AddField(tag, ind1, " ", "a", name)
+	}
+
+	titleInd1 := "0"
+	if len(book.Authors) > 0 {
+		titleInd1 = "1"
+	}
+	rec.AddField("245", titleInd1, "0", "a", book.Title)
+
+	year := ""
+	if !book.PublicationDate.IsZero() {
+		year = strconv.Itoa(book.PublicationDate.Year())
+	}
+	rec.AddField("264", " ", "1", "b", book.Publisher, "c", year)
+
+	rec.AddField("520", " ", " ", "a", book.Description)
+
+	for _, c := range book.Copies {
+		if c.Status == models.CopyWithdrawn {
+			continue
+		}
+		ind1 := " "
+		switch c.CallNumberScheme {
+		case callnumber.LCC:
+			ind1 = "0"
+		case callnumber.Dewey:
+			ind1 = "1"
+		}
+		branch, shelf := "", ""
+		if c.Branch != nil {
+			branch = c.Branch.Code
+		}
+		if c.ShelfLocation != nil {
+			shelf = c.ShelfLocation.Code
+		}
+		rec.AddField("852", ind1, " ", "b", branch, "c", shelf, "h", c.CallNumber, "p", c.CopyNumber)
+	}
+
+	return rec
+}
+
+// marcPreloads 导出 MARC 时需要预加载的关联
+func marcPreloads(db *gorm.DB) *gorm.DB {
+	return db.Preload("Authors").
+		Preload("Copies", "status <> ?", models.CopyWithdrawn).
+		Preload("Copies.Branch").
+		Preload("Copies.ShelfLocation")
+}
+
+// ExportBookMARCXML 将单本图书导出为 MARCXML
+func ExportBookMARCXML(db *gorm.DB, bookID uint, w io.Writer) error {
+	var book models.Book
+	if err := marcPreloads(db).First(&book, bookID).Error; err != nil {
+		return err
+	}
+
+	xw := marc.NewXMLWriter(w)
+	if err := xw.Write(ToMARC(&book)); err != nil {
+		return err
+	}
+	return xw.Close()
+}
+
+// ExportMARCXML 分批查询并导出整个馆藏，按图书ID排序
+func ExportMARCXML(db *gorm.DB, w io.Writer) error {
+	xw := marc.NewXMLWriter(w)
+	var books []models.Book
+	result := marcPreloads(db).FindInBatches(&books, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range books {
+			if err := xw.Write(ToMARC(&books[i])); err != nil {
+				return fmt.Errorf("write book %d: %w", books[i].ID, err)
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return xw.Close()
+}
@@ -0,0 +1,176 @@
+package catalog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/callnumber"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/marc"
+	"github.com/example/library-api/models"
+)
+
+// duneRecord 创建 Dune 的 MARC 记录，holdings 每项为 852 的第一指示符和子字段
+func duneRecord(isbn string, holdings ...[]string) *marc.Record {
+	rec := marc.NewRecord()
+	rec.AddField("020", " ", " ", "a", isbn+" (pbk.)")
+	rec.AddField("082", "0", "4", "a", "813/.54")
+	rec.AddField("100", "1", " ", "a", "Herbert, Frank.")
+	rec.AddField("245", "1", "0", "a", "Dune /")
+	for _, h := range holdings {
+		rec.AddField("852", h[0], " ", h[1:]...)
+	}
+	return rec
+}
+
+// importMARC 将记录写为 MARCXML 后导入
+func importMARC(t *testing.T, db *gorm.DB, recs ...*marc.Record) *Report {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := marc.NewXMLWriter(&buf)
+	for _, rec := range recs {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("write record: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+	return runImport(t, db, FormatMARCXML, buf.String(), ModeSkip)
+}
+
+func TestFromMARC(t *testing.T) {
+	rec := duneRecord("9780441013593")
+	rec.AddField("700", "1", " ", "a", "Herbert, Brian,")
+	rec.AddField("264", " ", "1", "a", "New York :", "b", "Ace Books,", "c", "c1965.")
+	rec.AddField("520", " ", " ", "a", "Desert planet.")
+
+	got := FromMARC(rec)
+	want := &Record{
+		ISBN:             "9780441013593",
+		Title:            "Dune",
+		Authors:          []string{"Frank Herbert", "Brian Herbert"},
+		Publisher:        "Ace Books",
+		PublicationDate:  "1965",
+		Description:      "Desert planet.",
+		CallNumber:       "813.54",
+		CallNumberScheme: callnumber.Dewey,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("record = %+v, want %+v", got, want)
+	}
+}
+
+func TestToMARCRoundTrip(t *testing.T) {
+	book := models.Book{
+		ID:              7,
+		Title:           "Dune",
+		ISBN:            "9780441013593",
+		Publisher:       "Chilton",
+		Description:     "Desert planet",
+		PublicationDate: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+		Authors:         []models.Author{{Name: "Frank Herbert"}, {Name: "Brian Herbert"}},
+	}
+
+	got := FromMARC(ToMARC(&book))
+	if got.ISBN != book.ISBN || got.Title != book.Title || got.Publisher != book.Publisher ||
+		got.PublicationDate != "1965" || got.Description != book.Description {
+		t.Errorf("record = %+v, want the fields of %+v", got, book)
+	}
+	if want := []string{"Frank Herbert", "Brian Herbert"}; !reflect.DeepEqual(got.Authors, want) {
+		t.Errorf("authors = %v, want %v", got.Authors, want)
+	}
+}
+
+func TestImportMARCXML(t *testing.T) {
+	db := databasetest.Open(t)
+
+	report := importMARC(t, db, duneRecord("9780441013593"))
+	if report.Created != 1 {
+		t.Fatalf("report = %+v, want 1 created", report)
+	}
+	book := loadBook(t, db, "9780441013593")
+	if book.Title != "Dune" || len(book.Authors) != 1 || book.Authors[0].Name != "Frank Herbert" || len(book.Copies) != 0 {
+		t.Errorf("book = %q by %v with %d copies, want Dune by Frank Herbert without copies", book.Title, authorNames(book), len(book.Copies))
+	}
+}
+
+func TestFromMARCGroupsHoldingsByBranch(t *testing.T) {
+	rec := FromMARC(duneRecord("9780441013593",
+		[]string{"0", "b", "main", "h", "PS3558.E63", "i", "D8"},
+		[]string{"1", "b", "EAST", "h", "813.54", "i", "HER"},
+		[]string{" ", "b", "MAIN"},
+		[]string{"1", "b", "west", "h", "not a call number"},
+	))
+
+	want := []Holding{
+		{Branch: "MAIN", Copies: 2, CallNumber: "PS3558.E63 D8", CallNumberScheme: callnumber.LCC},
+		{Branch: "EAST", Copies: 1, CallNumber: "813.54 HER", CallNumberScheme: callnumber.Dewey},
+		// 无效的索书号改用 082 的分类号
+		{Branch: "WEST", Copies: 1, CallNumber: "813.54", CallNumberScheme: callnumber.Dewey},
+	}
+	if !reflect.DeepEqual(rec.Holdings, want) {
+		t.Errorf("holdings = %+v, want %+v", rec.Holdings, want)
+	}
+	if rec.Copies != 4 {
+		t.Errorf("copies = %d, want 4", rec.Copies)
+	}
+	if rec.ISBN != "9780441013593" || rec.Title != "Dune" {
+		t.Errorf("record = %q %q, want 9780441013593 Dune", rec.ISBN, rec.Title)
+	}
+}
+
+func TestImportMARCHoldingsIntoBranches(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+	east := createBranch(t, db, "EAST")
+
+	report := importMARC(t, db,
+		duneRecord("9780441013593",
+			[]string{"0", "b", "MAIN", "h", "PS3558.E63", "i", "D8"},
+			[]string{"1", "b", "east", "h", "813.54", "i", "HER"},
+			[]string{"0", "b", "MAIN"},
+		),
+		// 分馆不存在时整条记录被拒绝
+		duneRecord("9780306406157", []string{"0", "b", "MAIN"}, []string{"0", "b", "NORTH"}),
+	)
+	if report.Created != 1 || report.Rejected != 1 {
+		t.Fatalf("report = %+v, want 1 created and 1 rejected", report)
+	}
+	if got := report.Rows[0]; got.CopiesAdded != 3 {
+		t.Errorf("copies added = %d, want 3", got.CopiesAdded)
+	}
+	if got := report.Rows[1]; got.Row != 2 || got.Error != "branch NORTH not found" {
+		t.Errorf("rejected row = %d %q, want 2 %q", got.Row, got.Error, "branch NORTH not found")
+	}
+
+	type location struct {
+		branch uint
+		number string
+	}
+	count := map[location]int{}
+	for _, c := range loadBook(t, db, "9780441013593").Copies {
+		if c.BranchID == nil {
+			t.Fatalf("copy %s has no branch", c.CopyNumber)
+		}
+		count[location{*c.BranchID, c.CallNumber}]++
+	}
+	want := map[location]int{
+		{main.ID, "PS3558.E63 D8"}: 2,
+		{east.ID, "813.54 HER"}:    1,
+	}
+	if !reflect.DeepEqual(count, want) {
+		t.Errorf("copies by branch and call number = %v, want %v", count, want)
+	}
+
+	var rejected int64
+	db.Model(&models.Book{}).Where("isbn = ?", "9780306406157").Count(&rejected)
+	if rejected != 0 {
+		t.Errorf("rejected record created %d books, want none", rejected)
+	}
+}
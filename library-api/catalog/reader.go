@@ -0,0 +1,255 @@
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/example/library-api/marc"
+)
+
+// 导入文件格式
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatMARC    = "marc"    // ISO 2709 二进制 MARC21
+	FormatMARCXML = "marcxml" // MARCXML
+)
+
+// ErrUnknownFormat 不支持的导入格式
+var ErrUnknownFormat = errors.New("import format must be csv, jsonl, marc or marcxml")
+
+// maxLineBytes JSON Lines 单行的最大长度
+const maxLineBytes = 1 << 20
+
+// Record 导入文件中的一行图书记录
+type Record struct {
+	Title            string   `json:"title"`
+	ISBN             string   `json:"isbn"`
+	Authors          []string `json:"authors"`
+	Publisher        string   `json:"publisher"`
+	Description      string   `json:"description"`
+	PublicationDate  string   `json:"publication_date"` // YYYY-MM-DD 或 YYYY
+	Copies           int      `json:"copies"`
+	Branch           string   `json:"branch"`             // 新副本所在分馆的代码
+	CallNumber       string   `json:"call_number"`        // 新副本的索书号
+	CallNumberScheme string   `json:"call_number_scheme"` // dewey 或 lcc
+	// Holdings 按分馆分组的新副本，来自 MARC 852 字段；非空时取代 Copies、Branch 和索书号
+	Holdings []Holding `json:"-"`
+}
+
+// Holding 同一分馆的一组新副本
+type Holding struct {
+	Branch           string // 分馆代码，为空表示不分配分馆
+	Copies           int
+	CallNumber       string
+	CallNumberScheme string
+}
+
+// RowError 单行记录无法解析或校验失败，该行被拒绝，其余行继续导入
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// RecordReader 逐行读取导入记录。读完返回 io.EOF，单行错误返回 *RowError
+type RecordReader interface {
+	Next() (int, *Record, error)
+}
+
+// DetectFormat 根据显式指定的格式、Content-Type 或文件扩展名确定导入格式
+func DetectFormat(format, contentType, filename string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatJSONL, "ndjson":
+		return FormatJSONL, nil
+	case FormatMARC, "mrc", "marc21":
+		return FormatMARC, nil
+	case FormatMARCXML:
+		return FormatMARCXML, nil
+	case "":
+	default:
+		return "", ErrUnknownFormat
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV, nil
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatJSONL, nil
+	case "application/marc":
+		return FormatMARC, nil
+	case "application/marcxml+xml", "application/xml", "text/xml":
+		return FormatMARCXML, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".jsonl", ".ndjson":
+		return FormatJSONL, nil
+	case ".mrc", ".marc":
+		return FormatMARC, nil
+	case ".xml":
+		return FormatMARCXML, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// NewReader 创建指定格式的记录读取器
+func NewReader(format string, r io.Reader) (RecordReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return newJSONLReader(r), nil
+	case FormatMARC:
+		return newMARCReader(marc.NewReader(r)), nil
+	case FormatMARCXML:
+		return newMARCReader(marc.NewXMLReader(r)), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// csvColumns CSV 表头支持的列名，authors 列中多个作者用分号分隔
+var csvColumns = map[string]bool{
+	"title":              true,
+	"isbn":               true,
+	"authors":            true,
+	"publisher":          true,
+	"description":        true,
+	"publication_date":   true,
+	"copies":             true,
+	"branch":             true,
+	"call_number":        true,
+	"call_number_scheme": true,
+}
+
+// csvReader 按表头列名读取 CSV，未知的列被忽略
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv file is empty")
+		}
+		return nil, fmt.Errorf("invalid csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if csvColumns[name] {
+			columns[name] = i
+		}
+	}
+	for _, required := range []string{"title", "isbn"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header must include the %s column", required)
+		}
+	}
+
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReader) Next() (int, *Record, error) {
+	fields, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, nil, &RowError{Row: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	row, _ := c.r.FieldPos(0)
+
+	field := func(name string) string {
+		i, ok := c.columns[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	rec := &Record{
+		Title:            field("title"),
+		ISBN:             field("isbn"),
+		Publisher:        field("publisher"),
+		Description:      field("description"),
+		PublicationDate:  field("publication_date"),
+		Branch:           field("branch"),
+		CallNumber:       field("call_number"),
+		CallNumberScheme: field("call_number_scheme"),
+	}
+	for _, name := range strings.Split(field("authors"), ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			rec.Authors = append(rec.Authors, name)
+		}
+	}
+	if copies := field("copies"); copies != "" {
+		n, err := strconv.Atoi(copies)
+		if err != nil {
+			return row, nil, &RowError{Row: row, Err: errors.New("copies must be a whole number")}
+		}
+		rec.Copies = n
+	}
+
+	return row, rec, nil
+}
+
+// jsonlReader 每行一个 JSON 对象，空行被忽略
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	return &jsonlReader{scanner: scanner}
+}
+
+func (j *jsonlReader) Next() (int, *Record, error) {
+	for j.scanner.Scan() {
+		j.line++
+		line := strings.TrimSpace(j.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return j.line, nil, &RowError{Row: j.line, Err: fmt.Errorf("invalid json: %v", err)}
+		}
+		return j.line, &rec, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return 0, nil, err
+	}
+	return 0, nil, io.EOF
+}
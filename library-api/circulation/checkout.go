@@ -0,0 +1,176 @@
+package circulation
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// ErrNoCopyAvailable 没有可借的副本
+var ErrNoCopyAvailable = errors.New("no available copies of this book, place a hold to join the queue")
+
+// ErrNoCopyAtBranch 指定分馆没有可借的副本
+var ErrNoCopyAtBranch = errors.New("no available copies of this book at the requested branch")
+
+// ErrAlreadyBorrowed 读者已借阅此书且未归还
+var ErrAlreadyBorrowed = errors.New("you already have an active borrow for this book")
+
+// ErrCheckoutConflict 副本被其他请求同时借出
+var ErrCheckoutConflict = errors.New("the copy was borrowed by another request, please try again")
+
+// ErrDatabaseBusy 数据库持续繁忙，重试后仍未完成借阅
+var ErrDatabaseBusy = errors.New("the library is busy, please try again")
+
+// checkoutAttempts 数据库繁忙或抢占副本冲突时借阅事务的最多尝试次数
+const checkoutAttempts = 3
+
+// checkoutBackoff 每次重试前的等待时长，按尝试次数递增
+const checkoutBackoff = 50 * time.Millisecond
+
+// CheckoutRequest 借阅请求
+type CheckoutRequest struct {
+	UserID        uint
+	BookID        uint
+	BranchID      uint // 指定借出副本所在的分馆，0表示任意分馆
+	Policy        models.LoanPolicy
+	RequestedDays int // 0表示使用借阅规则的默认借期
+}
+
+// Checkout 为读者借出一本图书的副本，返回新建的借阅记录。
+// 读者有到馆待取的预约时借出预约书架上的副本（不受分馆限制），否则按状态条件抢占一个可借副本；
+// 数据库繁忙或副本被同时借出时整个事务会重试
+func Checkout(db *gorm.DB, req CheckoutRequest, now time.Time) (*models.Borrow, error) {
+	var err error
+	for attempt := 1; attempt <= checkoutAttempts; attempt++ {
+		var borrow *models.Borrow
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var txErr error
+			borrow, txErr = checkout(tx, req, now)
+			return txErr
+		})
+		if err == nil {
+			return borrow, nil
+		}
+		if !database.IsBusy(err) && !errors.Is(err, ErrCheckoutConflict) {
+			return nil, err
+		}
+		time.Sleep(time.Duration(attempt) * checkoutBackoff)
+	}
+
+	if database.IsBusy(err) {
+		return nil, ErrDatabaseBusy
+	}
+	return nil, err
+}
+
+// checkout 在事务中完成一次借阅
+func checkout(tx *gorm.DB, req CheckoutRequest, now time.Time) (*models.Borrow, error) {
+	// 过期未取的预约副本转给下一位读者
+	if _, err := ExpireHolds(tx, req.BookID, now); err != nil {
+		return nil, err
+	}
+
+	// 检查用户是否已借阅此书
+	var activeBorrowCount int64
+	if err := tx.Model(&models.Borrow{}).
+		Where("user_id = ? AND book_copy_id IN (SELECT id FROM book_copies WHERE book_id = ?) AND status IN ?",
+			req.UserID, req.BookID, OpenBorrowStatuses).
+		Count(&activeBorrowCount).Error; err != nil {
+		return nil, err
+	}
+	if activeBorrowCount > 0 {
+		return nil, ErrAlreadyBorrowed
+	}
+
+	// 按借阅规则检查借期和在借数量
+	days, err := CheckBorrow(tx, req.Policy, req.UserID, req.RequestedDays)
+	if err != nil {
+		return nil, err
+	}
+
+	copyID, err := claimForUser(tx, req, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建借阅记录，唯一索引保证副本不会同时存在两条未归还的借阅
+	borrow := models.Borrow{
+		UserID:     req.UserID,
+		BookCopyID: copyID,
+		BorrowDate: now,
+		DueDate:    now.AddDate(0, 0, days),
+		Status:     models.BorrowActive,
+	}
+	if err := tx.Create(&borrow).Error; err != nil {
+		if database.IsUniqueViolation(err) {
+			return nil, ErrCheckoutConflict
+		}
+		return nil, err
+	}
+
+	return &borrow, nil
+}
+
+// claimForUser 为读者抢占一个副本并返回副本ID，优先使用读者到馆待取的预约副本。
+// 预约由同一作品的其他版本满足时，按预约的图书或到馆副本的图书借阅均可取走该副本
+func claimForUser(tx *gorm.DB, req CheckoutRequest, now time.Time) (uint, error) {
+	var hold models.Hold
+	err := tx.Where("user_id = ? AND status = ? AND (book_id = ? OR book_copy_id IN (SELECT id FROM book_copies WHERE book_id = ?))",
+		req.UserID, models.HoldReady, req.BookID, req.BookID).First(&hold).Error
+	if err == nil && hold.BookCopyID != nil {
+		claimed, err := claimCopy(tx, *hold.BookCopyID, models.CopyOnHoldShelf)
+		if err != nil {
+			return 0, err
+		}
+		if !claimed {
+			return 0, ErrCheckoutConflict
+		}
+		if err := FulfillHold(tx, &hold, now); err != nil {
+			return 0, err
+		}
+		return *hold.BookCopyID, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	query := tx.Model(&models.BookCopy{}).Where("book_id = ? AND status = ?", req.BookID, models.CopyAvailable)
+	if req.BranchID != 0 {
+		query = query.Where("branch_id = ?", req.BranchID)
+	}
+
+	var candidates []uint
+	if err := query.Order("id ASC").Pluck("id", &candidates).Error; err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		if req.BranchID != 0 {
+			return 0, ErrNoCopyAtBranch
+		}
+		return 0, ErrNoCopyAvailable
+	}
+
+	// 副本可能在查询后被其他请求借出，依次尝试直到抢占成功
+	for _, id := range candidates {
+		claimed, err := claimCopy(tx, id, models.CopyAvailable)
+		if err != nil {
+			return 0, err
+		}
+		if claimed {
+			return id, nil
+		}
+	}
+	return 0, ErrCheckoutConflict
+}
+
+// claimCopy 以副本当前状态为条件将其标记为借出，副本状态已被其他请求修改时返回false
+func claimCopy(tx *gorm.DB, copyID uint, from models.BookCopyStatus) (bool, error) {
+	result := tx.Model(&models.BookCopy{}).
+		Where("id = ? AND status = ?", copyID, from).
+		Update("status", models.CopyBorrowed)
+	return result.RowsAffected == 1, result.Error
+}
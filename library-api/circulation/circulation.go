@@ -0,0 +1,27 @@
+// Package circulation 图书流通的业务规则，供控制器和后台任务在事务中复用
+package circulation
+
+import (
+	"time"
+
+	"github.com/example/library-api/config"
+)
+
+// pickupWindow 预约副本在预约书架上保留的时长
+var pickupWindow = 7 * 24 * time.Hour
+
+// Init 根据配置初始化流通参数
+func Init(cfg *config.Config) {
+	pickupWindow = time.Duration(cfg.HoldPickupDays) * 24 * time.Hour
+	maxRenewals = cfg.MaxRenewals
+	renewalPeriod = time.Duration(cfg.RenewalDays) * 24 * time.Hour
+	renewalGrace = time.Duration(cfg.RenewalGraceDays) * 24 * time.Hour
+	lostAfter = time.Duration(cfg.LostAfterDays) * 24 * time.Hour
+	fineDailyRate = cfg.FineDailyRateCents
+	fineGraceDays = cfg.FineGraceDays
+	fineMax = cfg.FineMaxCents
+	lostReplacementFee = cfg.LostReplacementFeeCents
+	defaultLoanDays = cfg.DefaultLoanDays
+	defaultMaxLoanDays = cfg.MaxLoanDays
+	defaultMaxBorrows = cfg.MaxBorrows
+}
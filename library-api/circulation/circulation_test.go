@@ -0,0 +1,118 @@
+package circulation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// testNow 测试使用的当前时间
+var testNow = time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+
+// createUser 创建普通用户
+func createUser(t *testing.T, db *gorm.DB, name string) models.User {
+	t.Helper()
+
+	user := models.User{Username: name, Email: name + "@example.com", Password: "secret", Role: models.RoleUser}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// createBook 创建图书
+func createBook(t *testing.T, db *gorm.DB, title string) models.Book {
+	t.Helper()
+
+	var count int64
+	db.Model(&models.Book{}).Unscoped().Count(&count)
+	book := models.Book{Title: title, ISBN: fmt.Sprintf("978%010d", count+1)}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	return book
+}
+
+// createBranch 创建分馆
+func createBranch(t *testing.T, db *gorm.DB, code string) models.Branch {
+	t.Helper()
+
+	branch := models.Branch{Code: code, Name: code}
+	if err := db.Create(&branch).Error; err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+	return branch
+}
+
+// createCopy 创建指定状态的副本，branch 为 nil 时不分配分馆
+func createCopy(t *testing.T, db *gorm.DB, book models.Book, status models.BookCopyStatus, branch *models.Branch) models.BookCopy {
+	t.Helper()
+
+	var count int64
+	db.Model(&models.BookCopy{}).Where("book_id = ?", book.ID).Count(&count)
+	bookCopy := models.BookCopy{
+		BookID:          book.ID,
+		CopyNumber:      fmt.Sprintf("C%03d", count+1),
+		Status:          status,
+		AcquisitionDate: testNow,
+	}
+	if branch != nil {
+		bookCopy.BranchID = &branch.ID
+	}
+	if err := db.Create(&bookCopy).Error; err != nil {
+		t.Fatalf("create copy: %v", err)
+	}
+	return bookCopy
+}
+
+// placeHold 为读者在队尾创建待处理预约，pickup 为 nil 时在副本所在分馆取书
+func placeHold(t *testing.T, db *gorm.DB, user models.User, book models.Book, pickup *models.Branch) models.Hold {
+	t.Helper()
+
+	position, err := NextPosition(db, book.ID)
+	if err != nil {
+		t.Fatalf("NextPosition: %v", err)
+	}
+	hold := models.Hold{UserID: user.ID, BookID: book.ID, Position: position, Status: models.HoldPending}
+	if pickup != nil {
+		hold.PickupBranchID = &pickup.ID
+	}
+	if err := db.Create(&hold).Error; err != nil {
+		t.Fatalf("create hold: %v", err)
+	}
+	return hold
+}
+
+// loadHold 重新读取预约
+func loadHold(t *testing.T, db *gorm.DB, id uint) models.Hold {
+	t.Helper()
+
+	var hold models.Hold
+	if err := db.First(&hold, id).Error; err != nil {
+		t.Fatalf("load hold: %v", err)
+	}
+	return hold
+}
+
+// loadCopy 重新读取副本
+func loadCopy(t *testing.T, db *gorm.DB, id uint) models.BookCopy {
+	t.Helper()
+
+	var bookCopy models.BookCopy
+	if err := db.First(&bookCopy, id).Error; err != nil {
+		t.Fatalf("load copy: %v", err)
+	}
+	return bookCopy
+}
+
+// holdCopy 返回预约占用的副本ID，没有时为0
+func holdCopy(hold models.Hold) uint {
+	if hold.BookCopyID == nil {
+		return 0
+	}
+	return *hold.BookCopyID
+}
@@ -0,0 +1,213 @@
+package circulation
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// ErrInvalidTransition 副本当前状态不允许转换到目标状态
+var ErrInvalidTransition = errors.New("invalid copy status transition")
+
+// ErrCopyStatusChanged 副本状态已被其他请求修改
+var ErrCopyStatusChanged = errors.New("copy status changed, please try again")
+
+// copyTransitions 管理员可执行的副本状态转换。借出和放上预约书架由借阅、预约流程完成，
+// 注销的副本不再流通
+var copyTransitions = map[models.BookCopyStatus][]models.BookCopyStatus{
+	models.CopyAvailable:   {models.CopyMaintenance, models.CopyLost, models.CopyWithdrawn},
+	models.CopyOnHoldShelf: {models.CopyMaintenance, models.CopyLost, models.CopyWithdrawn},
+	models.CopyBorrowed:    {models.CopyLost},
+	models.CopyMaintenance: {models.CopyAvailable, models.CopyLost, models.CopyWithdrawn},
+	models.CopyLost:        {models.CopyAvailable, models.CopyWithdrawn},
+	models.CopyInTransit:   {models.CopyLost},
+}
+
+// AllowedTransitions 返回副本从当前状态可以转换到的状态
+func AllowedTransitions(from models.BookCopyStatus) []models.BookCopyStatus {
+	allowed := copyTransitions[from]
+	if allowed == nil {
+		return []models.BookCopyStatus{}
+	}
+	return allowed
+}
+
+// CanTransition 判断副本能否从from转换到to
+func CanTransition(from, to models.BookCopyStatus) bool {
+	for _, s := range copyTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionCopy 在事务中按状态机转换副本状态并记录变更原因和操作人。
+// 预约书架上或调拨途中的副本离开流通时预约恢复排队，未完成的调拨取消；借出的副本标记丢失时借阅同时标记丢失并生成赔偿费用，
+// 丢失的副本找到时借阅标记为已归还并减免赔偿费用；副本恢复可借时优先分配给排队中的预约读者，因此最终状态可能是 on_hold_shelf
+func TransitionCopy(tx *gorm.DB, bookCopy *models.BookCopy, to models.BookCopyStatus, reason string, adminID uint, now time.Time) (*models.CopyStatusChange, error) {
+	from := bookCopy.Status
+	if !CanTransition(from, to) {
+		return nil, ErrInvalidTransition
+	}
+
+	switch from {
+	case models.CopyOnHoldShelf:
+		if err := requeueHold(tx, bookCopy.ID); err != nil {
+			return nil, err
+		}
+	case models.CopyInTransit:
+		if err := abandonTransfer(tx, bookCopy.ID, adminID, now); err != nil {
+			return nil, err
+		}
+		if err := requeueHold(tx, bookCopy.ID); err != nil {
+			return nil, err
+		}
+	case models.CopyBorrowed:
+		// 副本没有未归还的借阅说明已被同时归还
+		var borrow models.Borrow
+		if err := tx.Where("book_copy_id = ? AND status IN ?", bookCopy.ID, OpenBorrowStatuses).
+			First(&borrow).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrCopyStatusChanged
+			}
+			return nil, err
+		}
+		ok, err := markBorrowLost(tx, &borrow)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrCopyStatusChanged
+		}
+	case models.CopyLost:
+		// 丢失的副本找到后结束丢失的借阅，减免赔偿费用
+		if to == models.CopyAvailable {
+			var borrow models.Borrow
+			err := tx.Where("book_copy_id = ? AND status = ?", bookCopy.ID, models.BorrowLost).First(&borrow).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			if err == nil {
+				if _, err := ResolveLost(tx, &borrow, adminID, now); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	result := tx.Model(&models.BookCopy{}).
+		Where("id = ? AND status = ?", bookCopy.ID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	// 借出副本的状态已在标记借阅丢失时更新
+	if result.RowsAffected == 0 && from != models.CopyBorrowed {
+		return nil, ErrCopyStatusChanged
+	}
+	bookCopy.Status = to
+
+	if to == models.CopyAvailable {
+		if _, err := AssignCopy(tx, bookCopy, now); err != nil {
+			return nil, err
+		}
+	}
+
+	change := models.CopyStatusChange{
+		BookCopyID:  bookCopy.ID,
+		FromStatus:  from,
+		ToStatus:    bookCopy.Status,
+		Reason:      reason,
+		ChangedByID: adminID,
+		CreatedAt:   now,
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// requeueHold 副本离开预约书架或调拨途中丢失时，关联的预约恢复为排队状态，仍按原位置排在队首
+func requeueHold(tx *gorm.DB, copyID uint) error {
+	return tx.Model(&models.Hold{}).
+		Where("book_copy_id = ? AND status IN ?", copyID, []models.HoldStatus{models.HoldInTransit, models.HoldReady}).
+		Updates(map[string]interface{}{
+			"status":       models.HoldPending,
+			"book_copy_id": nil,
+			"ready_at":     nil,
+			"expires_at":   nil,
+		}).Error
+}
+
+// Availability 统计图书各状态的副本数量
+func Availability(db *gorm.DB, bookIDs []uint) (map[uint]*models.CopyAvailability, error) {
+	var rows []struct {
+		BookID uint
+		Status models.BookCopyStatus
+		Count  int64
+	}
+	if err := db.Model(&models.BookCopy{}).
+		Select("book_id, status, COUNT(*) AS count").
+		Where("book_id IN ?", bookIDs).
+		Group("book_id, status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]*models.CopyAvailability, len(bookIDs))
+	for _, id := range bookIDs {
+		counts[id] = &models.CopyAvailability{}
+	}
+	for _, r := range rows {
+		counts[r.BookID].Add(r.Status, r.Count)
+	}
+	return counts, nil
+}
+
+// BranchAvailability 按分馆统计图书各状态的副本数量，未分配分馆的副本排在最后
+func BranchAvailability(db *gorm.DB, bookID uint) ([]models.BranchAvailability, error) {
+	var rows []struct {
+		BranchID   *uint
+		BranchCode string
+		BranchName string
+		Status     models.BookCopyStatus
+		Count      int64
+	}
+	if err := db.Model(&models.BookCopy{}).
+		Select("book_copies.branch_id, branches.code AS branch_code, branches.name AS branch_name, book_copies.status, COUNT(*) AS count").
+		Joins("LEFT JOIN branches ON branches.id = book_copies.branch_id").
+		Where("book_copies.book_id = ? AND book_copies.status <> ?", bookID, models.CopyWithdrawn).
+		Group("book_copies.branch_id, branches.code, branches.name, book_copies.status").
+		Order("book_copies.branch_id IS NULL, branches.name, book_copies.branch_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := []models.BranchAvailability{}
+	for _, r := range rows {
+		last := len(result) - 1
+		if last < 0 || !sameBranch(result[last].BranchID, r.BranchID) {
+			result = append(result, models.BranchAvailability{
+				BranchID:   r.BranchID,
+				BranchCode: r.BranchCode,
+				BranchName: r.BranchName,
+			})
+			last++
+		}
+		result[last].Add(r.Status, r.Count)
+	}
+	return result, nil
+}
+
+// sameBranch 比较两个可为空的分馆ID
+func sameBranch(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,148 @@
+package circulation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []models.BookCopyStatus{
+		models.CopyAvailable, models.CopyBorrowed, models.CopyLost, models.CopyMaintenance,
+		models.CopyOnHoldShelf, models.CopyWithdrawn, models.CopyInTransit,
+	}
+	// 管理员可执行的转换，其余组合都应被拒绝
+	allowed := map[models.BookCopyStatus][]models.BookCopyStatus{
+		models.CopyAvailable:   {models.CopyMaintenance, models.CopyLost, models.CopyWithdrawn},
+		models.CopyOnHoldShelf: {models.CopyMaintenance, models.CopyLost, models.CopyWithdrawn},
+		models.CopyBorrowed:    {models.CopyLost},
+		models.CopyMaintenance: {models.CopyAvailable, models.CopyLost, models.CopyWithdrawn},
+		models.CopyLost:        {models.CopyAvailable, models.CopyWithdrawn},
+		models.CopyInTransit:   {models.CopyLost},
+		models.CopyWithdrawn:   {},
+	}
+
+	for _, from := range statuses {
+		want := map[models.BookCopyStatus]bool{}
+		for _, to := range allowed[from] {
+			want[to] = true
+		}
+		for _, to := range statuses {
+			if got := CanTransition(from, to); got != want[to] {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want[to])
+			}
+		}
+		if got := AllowedTransitions(from); got == nil || len(got) != len(allowed[from]) {
+			t.Errorf("AllowedTransitions(%s) = %v, want %v", from, got, allowed[from])
+		}
+	}
+}
+
+func TestTransitionCopyRejectsInvalidTransition(t *testing.T) {
+	db := databasetest.Open(t)
+	bookCopy := createCopy(t, db, createBook(t, db, "Dune"), models.CopyWithdrawn, nil)
+
+	if _, err := TransitionCopy(db, &bookCopy, models.CopyAvailable, "found", 1, testNow); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("TransitionCopy err = %v, want ErrInvalidTransition", err)
+	}
+	var changes int64
+	db.Model(&models.CopyStatusChange{}).Count(&changes)
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyWithdrawn || changes != 0 {
+		t.Errorf("copy = %s with %d changes, want withdrawn and no changes", got.Status, changes)
+	}
+}
+
+func TestTransitionCopyLostAndFound(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, nil)
+	borrow := createBorrow(t, db, createUser(t, db, "reader"), bookCopy, testNow)
+
+	// 借出的副本标记丢失时借阅同时丢失并生成赔偿费用
+	change, err := TransitionCopy(db, &bookCopy, models.CopyLost, "not returned", 1, testNow)
+	if err != nil {
+		t.Fatalf("TransitionCopy lost: %v", err)
+	}
+	if change.FromStatus != models.CopyBorrowed || change.ToStatus != models.CopyLost || change.Reason != "not returned" {
+		t.Errorf("change = %+v, want borrowed -> lost", change)
+	}
+	var lostBorrow models.Borrow
+	db.First(&lostBorrow, borrow.ID)
+	if lostBorrow.Status != models.BorrowLost {
+		t.Errorf("borrow = %s, want lost", lostBorrow.Status)
+	}
+	var fine models.Fine
+	if err := db.Where("borrow_id = ? AND type = ?", borrow.ID, models.FineLost).First(&fine).Error; err != nil {
+		t.Fatalf("load lost fee: %v", err)
+	}
+	if fine.Amount != lostReplacementFee || fine.Status != models.FineOpen {
+		t.Errorf("lost fee = %d %s, want %d open", fine.Amount, fine.Status, lostReplacementFee)
+	}
+
+	// 找到后借阅标记为已归还，减免赔偿费用，副本交给排队的读者
+	hold := placeHold(t, db, createUser(t, db, "waiting"), book, nil)
+	later := testNow.Add(pickupWindow)
+	change, err = TransitionCopy(db, &bookCopy, models.CopyAvailable, "found on the shelf", 1, later)
+	if err != nil {
+		t.Fatalf("TransitionCopy found: %v", err)
+	}
+	if change.FromStatus != models.CopyLost || change.ToStatus != models.CopyOnHoldShelf {
+		t.Errorf("change = %s -> %s, want lost -> on_hold_shelf", change.FromStatus, change.ToStatus)
+	}
+	var found models.Borrow
+	db.First(&found, borrow.ID)
+	if found.Status != models.BorrowReturned || found.ReturnDate == nil || !found.ReturnDate.Equal(later) {
+		t.Errorf("borrow = %s returned %v, want returned at %v", found.Status, found.ReturnDate, later)
+	}
+	if got := loadFine(t, db, fine.ID); got.Status != models.FineWaived || got.Balance() != 0 {
+		t.Errorf("lost fee = %s balance %d, want waived", got.Status, got.Balance())
+	}
+	if got := loadHold(t, db, hold.ID); got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID {
+		t.Errorf("hold = %s copy %d, want ready with copy %d", got.Status, holdCopy(got), bookCopy.ID)
+	}
+
+	var changes int64
+	db.Model(&models.CopyStatusChange{}).Where("book_copy_id = ?", bookCopy.ID).Count(&changes)
+	if changes != 2 {
+		t.Errorf("status changes = %d, want 2", changes)
+	}
+}
+
+func TestTransitionBorrowedCopyWithoutBorrow(t *testing.T) {
+	db := databasetest.Open(t)
+	bookCopy := createCopy(t, db, createBook(t, db, "Dune"), models.CopyBorrowed, nil)
+
+	// 借阅已被同时归还时视为状态冲突
+	if _, err := TransitionCopy(db, &bookCopy, models.CopyLost, "not returned", 1, testNow); !errors.Is(err, ErrCopyStatusChanged) {
+		t.Fatalf("TransitionCopy err = %v, want ErrCopyStatusChanged", err)
+	}
+}
+
+func TestTransitionHoldShelfCopyRequeuesHold(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, nil)
+	hold := placeHold(t, db, createUser(t, db, "reader"), book, nil)
+	if _, err := AssignCopy(db, &bookCopy, testNow); err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+
+	// 预约书架上的副本送修后预约恢复排队，仍在队首
+	if _, err := TransitionCopy(db, &bookCopy, models.CopyMaintenance, "torn cover", 1, testNow); err != nil {
+		t.Fatalf("TransitionCopy: %v", err)
+	}
+	got := loadHold(t, db, hold.ID)
+	if got.Status != models.HoldPending || got.BookCopyID != nil || got.ExpiresAt != nil || got.Position != hold.Position {
+		t.Errorf("hold = %+v, want pending without copy at position %d", got, hold.Position)
+	}
+
+	// 修好后重新分配给该预约
+	if _, err := TransitionCopy(db, &bookCopy, models.CopyAvailable, "repaired", 1, testNow); err != nil {
+		t.Fatalf("TransitionCopy: %v", err)
+	}
+	if got := loadHold(t, db, hold.ID); got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID {
+		t.Errorf("hold = %s copy %d, want ready with copy %d", got.Status, holdCopy(got), bookCopy.ID)
+	}
+}
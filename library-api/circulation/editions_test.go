@@ -0,0 +1,224 @@
+package circulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// createWork 创建作品并将图书归入其中作为各个版本
+func createWork(t *testing.T, db *gorm.DB, editions ...*models.Book) models.Work {
+	t.Helper()
+
+	work := models.Work{Title: editions[0].Title}
+	if err := db.Create(&work).Error; err != nil {
+		t.Fatalf("create work: %v", err)
+	}
+	for _, book := range editions {
+		book.WorkID = &work.ID
+		if err := db.Model(book).Update("work_id", work.ID).Error; err != nil {
+			t.Fatalf("update book: %v", err)
+		}
+	}
+	return work
+}
+
+// placeAnyEditionHold 创建接受任意版本的预约，预约时间为 at
+func placeAnyEditionHold(t *testing.T, db *gorm.DB, user models.User, book models.Book, at time.Time) models.Hold {
+	t.Helper()
+
+	hold := placeHold(t, db, user, book, nil)
+	if err := db.Model(&hold).UpdateColumns(map[string]interface{}{"any_edition": true, "created_at": at}).Error; err != nil {
+		t.Fatalf("update hold: %v", err)
+	}
+	hold.AnyEdition = true
+	hold.CreatedAt = at
+	return hold
+}
+
+func TestAssignCopyPrefersOwnQueueThenAnyEdition(t *testing.T) {
+	db := databasetest.Open(t)
+	hardcover := createBook(t, db, "Dune")
+	paperback := createBook(t, db, "Dune")
+	audiobook := createBook(t, db, "Dune")
+	createWork(t, db, &hardcover, &paperback, &audiobook)
+
+	// 其他版本上只接受该版本的预约不参与分配
+	placeHold(t, db, createUser(t, db, "strict"), paperback, nil)
+	late := placeAnyEditionHold(t, db, createUser(t, db, "late"), audiobook, testNow.Add(-time.Hour))
+	early := placeAnyEditionHold(t, db, createUser(t, db, "early"), paperback, testNow.Add(-2*time.Hour))
+	own := placeHold(t, db, createUser(t, db, "own"), hardcover, nil)
+
+	// 该版本的队列优先，其后按预约时间分配给其他版本的任意版本预约
+	for _, want := range []models.Hold{own, early, late} {
+		bookCopy := createCopy(t, db, hardcover, models.CopyBorrowed, nil)
+		hold, err := AssignCopy(db, &bookCopy, testNow)
+		if err != nil {
+			t.Fatalf("AssignCopy: %v", err)
+		}
+		if hold == nil || hold.ID != want.ID {
+			t.Fatalf("assigned hold = %+v, want hold %d", hold, want.ID)
+		}
+		if got := loadHold(t, db, want.ID); got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID {
+			t.Errorf("hold %d = %s copy %d, want ready with copy %d", want.ID, got.Status, holdCopy(got), bookCopy.ID)
+		}
+	}
+
+	bookCopy := createCopy(t, db, hardcover, models.CopyBorrowed, nil)
+	if hold, err := AssignCopy(db, &bookCopy, testNow); err != nil || hold != nil {
+		t.Errorf("AssignCopy = %+v, %v, want no hold", hold, err)
+	}
+}
+
+func TestAssignCopyWithoutWorkIgnoresAnyEditionHolds(t *testing.T) {
+	db := databasetest.Open(t)
+	dune := createBook(t, db, "Dune")
+	emma := createBook(t, db, "Emma")
+	other := createBook(t, db, "Emma")
+	createWork(t, db, &emma, &other)
+	placeAnyEditionHold(t, db, createUser(t, db, "reader"), emma, testNow)
+
+	// 不属于作品的图书没有其他版本
+	bookCopy := createCopy(t, db, dune, models.CopyBorrowed, nil)
+	if hold, err := AssignCopy(db, &bookCopy, testNow); err != nil || hold != nil {
+		t.Errorf("AssignCopy = %+v, %v, want no hold", hold, err)
+	}
+}
+
+func TestCheckoutOtherEditionCopy(t *testing.T) {
+	policy := DefaultPolicy(models.RoleUser)
+
+	for _, tt := range []struct {
+		name string
+		book func(hardcover, paperback models.Book) models.Book
+	}{
+		{"按预约的版本借阅", func(_, paperback models.Book) models.Book { return paperback }},
+		{"按副本所属版本借阅", func(hardcover, _ models.Book) models.Book { return hardcover }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := databasetest.Open(t)
+			hardcover := createBook(t, db, "Dune")
+			paperback := createBook(t, db, "Dune")
+			createWork(t, db, &hardcover, &paperback)
+			reader := createUser(t, db, "reader")
+			hold := placeAnyEditionHold(t, db, reader, paperback, testNow)
+			bookCopy := createCopy(t, db, hardcover, models.CopyBorrowed, nil)
+			if _, err := AssignCopy(db, &bookCopy, testNow); err != nil {
+				t.Fatalf("AssignCopy: %v", err)
+			}
+
+			// 其他读者不能借走预约书架上的副本
+			if _, err := Checkout(db, CheckoutRequest{UserID: createUser(t, db, "other").ID, BookID: hardcover.ID, Policy: policy}, testNow); !errors.Is(err, ErrNoCopyAvailable) {
+				t.Fatalf("other reader Checkout err = %v, want ErrNoCopyAvailable", err)
+			}
+
+			book := tt.book(hardcover, paperback)
+			borrow, err := Checkout(db, CheckoutRequest{UserID: reader.ID, BookID: book.ID, Policy: policy}, testNow)
+			if err != nil {
+				t.Fatalf("Checkout: %v", err)
+			}
+			if borrow.BookCopyID != bookCopy.ID {
+				t.Errorf("borrowed copy = %d, want %d", borrow.BookCopyID, bookCopy.ID)
+			}
+			if got := loadHold(t, db, hold.ID); got.Status != models.HoldFulfilled {
+				t.Errorf("hold = %s, want fulfilled", got.Status)
+			}
+			if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyBorrowed {
+				t.Errorf("copy = %s, want borrowed", got.Status)
+			}
+		})
+	}
+}
+
+func TestRenewBlockedByAnyEditionHold(t *testing.T) {
+	policy := models.LoanPolicy{MaxRenewals: 2}
+
+	tests := []struct {
+		name       string
+		anyEdition bool
+		sameWork   bool
+		wantErr    error
+	}{
+		{"其他版本接受任意版本的预约", true, true, ErrHoldsPending},
+		{"其他版本只接受该版本的预约", false, true, nil},
+		{"其他作品的任意版本预约", true, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := databasetest.Open(t)
+			hardcover := createBook(t, db, "Dune")
+			paperback := createBook(t, db, "Dune")
+			emma := createBook(t, db, "Emma")
+			createWork(t, db, &hardcover, &paperback)
+			other := createBook(t, db, "Emma")
+			createWork(t, db, &emma, &other)
+
+			book := emma
+			if tt.sameWork {
+				book = paperback
+			}
+			if tt.anyEdition {
+				placeAnyEditionHold(t, db, createUser(t, db, "waiting"), book, testNow)
+			} else {
+				placeHold(t, db, createUser(t, db, "waiting"), book, nil)
+			}
+
+			borrow := createBorrow(t, db, createUser(t, db, "reader"), createCopy(t, db, hardcover, models.CopyBorrowed, nil), testNow.Add(24*time.Hour))
+			if err := Renew(db, &borrow, policy, testNow); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Renew err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpireHoldsCoversOtherEditionHolds(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope func(hardcover, paperback models.Book) uint
+	}{
+		{"按副本所属版本", func(hardcover, _ models.Book) uint { return hardcover.ID }},
+		{"按预约的版本", func(_, paperback models.Book) uint { return paperback.ID }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := databasetest.Open(t)
+			hardcover := createBook(t, db, "Dune")
+			paperback := createBook(t, db, "Dune")
+			createWork(t, db, &hardcover, &paperback)
+			emma := createBook(t, db, "Emma")
+
+			// 任意版本预约占用精装版副本，精装版随后又有读者排队
+			anyHold := placeAnyEditionHold(t, db, createUser(t, db, "any"), paperback, testNow)
+			bookCopy := createCopy(t, db, hardcover, models.CopyBorrowed, nil)
+			if _, err := AssignCopy(db, &bookCopy, testNow); err != nil {
+				t.Fatalf("AssignCopy: %v", err)
+			}
+			next := placeHold(t, db, createUser(t, db, "next"), hardcover, nil)
+			emmaHold := placeHold(t, db, createUser(t, db, "emma"), emma, nil)
+			emmaCopy := createCopy(t, db, emma, models.CopyBorrowed, nil)
+			if _, err := AssignCopy(db, &emmaCopy, testNow); err != nil {
+				t.Fatalf("AssignCopy: %v", err)
+			}
+
+			// 其他图书的过期预约不受影响
+			later := testNow.Add(pickupWindow + time.Minute)
+			if n, err := ExpireHolds(db, tt.scope(hardcover, paperback), later); err != nil || n != 1 {
+				t.Fatalf("ExpireHolds = %d, %v, want 1", n, err)
+			}
+			if got := loadHold(t, db, anyHold.ID); got.Status != models.HoldExpired {
+				t.Errorf("any-edition hold = %s, want expired", got.Status)
+			}
+			if got := loadHold(t, db, next.ID); got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID {
+				t.Errorf("next hold = %s copy %d, want ready with copy %d", got.Status, holdCopy(got), bookCopy.ID)
+			}
+			if got := loadHold(t, db, emmaHold.ID); got.Status != models.HoldReady {
+				t.Errorf("emma hold = %s, want ready", got.Status)
+			}
+		})
+	}
+}
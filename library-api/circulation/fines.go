@@ -0,0 +1,143 @@
+package circulation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+var (
+	// ErrFineSettled 费用已结清
+	ErrFineSettled = errors.New("fine is already settled")
+	// ErrAmountExceedsBalance 金额超过未结清余额
+	ErrAmountExceedsBalance = errors.New("amount exceeds the outstanding balance")
+)
+
+// 罚款参数，金额单位为分，每日罚款为未配置借阅规则时的默认值
+var (
+	fineDailyRate      int64 = 50
+	fineGraceDays            = 1
+	fineMax            int64 = 2000
+	lostReplacementFee int64 = 5000
+)
+
+// OverdueDays 返回逾期天数，不足一天按一天计算
+func OverdueDays(dueDate, returnedAt time.Time) int {
+	if !returnedAt.After(dueDate) {
+		return 0
+	}
+	return int(math.Ceil(returnedAt.Sub(dueDate).Hours() / 24))
+}
+
+// OverdueFineAmount 计算逾期罚款：宽限期内归还不收费，超过宽限期按全部逾期天数计费，不超过上限
+func OverdueFineAmount(days int, dailyRate int64) int64 {
+	if days <= fineGraceDays {
+		return 0
+	}
+	amount := int64(days) * dailyRate
+	if fineMax > 0 && amount > fineMax {
+		amount = fineMax
+	}
+	return amount
+}
+
+// AssessOverdueFine 归还时按逾期天数和借阅规则的罚款标准生成罚款，未逾期或在宽限期内时返回nil
+func AssessOverdueFine(tx *gorm.DB, borrow *models.Borrow, policy models.LoanPolicy, returnedAt time.Time) (*models.Fine, error) {
+	days := OverdueDays(borrow.DueDate, returnedAt)
+	amount := OverdueFineAmount(days, policy.FineDailyRateCents)
+	if amount == 0 {
+		return nil, nil
+	}
+
+	fine := models.Fine{
+		UserID:      borrow.UserID,
+		BorrowID:    &borrow.ID,
+		Type:        models.FineOverdue,
+		Amount:      amount,
+		Status:      models.FineOpen,
+		Description: fmt.Sprintf("returned %d days overdue", days),
+	}
+	if err := tx.Create(&fine).Error; err != nil {
+		return nil, err
+	}
+	return &fine, nil
+}
+
+// AssessLostFee 借阅被标记为丢失时生成赔偿费用
+func AssessLostFee(tx *gorm.DB, borrow *models.Borrow) (*models.Fine, error) {
+	if lostReplacementFee == 0 {
+		return nil, nil
+	}
+
+	fine := models.Fine{
+		UserID:      borrow.UserID,
+		BorrowID:    &borrow.ID,
+		Type:        models.FineLost,
+		Amount:      lostReplacementFee,
+		Status:      models.FineOpen,
+		Description: "replacement fee for lost item",
+	}
+	if err := tx.Create(&fine).Error; err != nil {
+		return nil, err
+	}
+	return &fine, nil
+}
+
+// settle 记录缴费或减免流水并更新费用状态
+func settle(tx *gorm.DB, fine *models.Fine, kind models.FinePaymentKind, amount int64, method, note string, actorID uint) (*models.FinePayment, error) {
+	if fine.Status != models.FineOpen {
+		return nil, ErrFineSettled
+	}
+	if amount > fine.Balance() {
+		return nil, ErrAmountExceedsBalance
+	}
+
+	if kind == models.PaymentKindPayment {
+		fine.PaidAmount += amount
+	} else {
+		fine.WaivedAmount += amount
+	}
+
+	if fine.Balance() == 0 {
+		fine.Status = models.FineWaived
+		if fine.PaidAmount > 0 {
+			fine.Status = models.FinePaid
+		}
+	}
+
+	if err := tx.Save(fine).Error; err != nil {
+		return nil, err
+	}
+
+	payment := models.FinePayment{
+		FineID:       fine.ID,
+		UserID:       fine.UserID,
+		Kind:         kind,
+		Amount:       amount,
+		Method:       method,
+		Note:         note,
+		RecordedByID: actorID,
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// RecordPayment 记录一笔针对费用的缴费
+func RecordPayment(tx *gorm.DB, fine *models.Fine, amount int64, method, note string, actorID uint) (*models.FinePayment, error) {
+	return settle(tx, fine, models.PaymentKindPayment, amount, method, note, actorID)
+}
+
+// WaiveFine 减免费用，amount为0时减免全部未结清金额
+func WaiveFine(tx *gorm.DB, fine *models.Fine, amount int64, note string, actorID uint) (*models.FinePayment, error) {
+	if amount == 0 {
+		amount = fine.Balance()
+	}
+	return settle(tx, fine, models.PaymentKindWaiver, amount, "", note, actorID)
+}
@@ -0,0 +1,148 @@
+package circulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// createFine 为读者创建未结清的费用
+func createFine(t *testing.T, db *gorm.DB, user models.User, amount int64) models.Fine {
+	t.Helper()
+
+	fine := models.Fine{UserID: user.ID, Type: models.FineManual, Amount: amount, Status: models.FineOpen}
+	if err := db.Create(&fine).Error; err != nil {
+		t.Fatalf("create fine: %v", err)
+	}
+	return fine
+}
+
+// loadFine 重新读取费用
+func loadFine(t *testing.T, db *gorm.DB, id uint) models.Fine {
+	t.Helper()
+
+	var fine models.Fine
+	if err := db.Preload("Payments").First(&fine, id).Error; err != nil {
+		t.Fatalf("load fine: %v", err)
+	}
+	return fine
+}
+
+func TestOverdueFineAmount(t *testing.T) {
+	tests := []struct {
+		days int
+		want int64
+	}{
+		{0, 0},
+		{1, 0}, // 宽限期内
+		{2, 100},
+		{10, 500},
+		{100, 2000}, // 不超过上限
+	}
+	for _, tt := range tests {
+		if got := OverdueFineAmount(tt.days, 50); got != tt.want {
+			t.Errorf("OverdueFineAmount(%d) = %d, want %d", tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestOverdueDays(t *testing.T) {
+	due := testNow
+	tests := []struct {
+		returned time.Time
+		want     int
+	}{
+		{due.Add(-time.Hour), 0},
+		{due, 0},
+		{due.Add(time.Minute), 1},
+		{due.Add(24 * time.Hour), 1},
+		{due.Add(49 * time.Hour), 3},
+	}
+	for _, tt := range tests {
+		if got := OverdueDays(due, tt.returned); got != tt.want {
+			t.Errorf("OverdueDays(%v) = %d, want %d", tt.returned.Sub(due), got, tt.want)
+		}
+	}
+}
+
+func TestRecordPartialPayments(t *testing.T) {
+	db := databasetest.Open(t)
+	fine := createFine(t, db, createUser(t, db, "reader"), 1000)
+
+	if _, err := RecordPayment(db, &fine, 300, "cash", "", 1); err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+	got := loadFine(t, db, fine.ID)
+	if got.Status != models.FineOpen || got.PaidAmount != 300 || got.Balance() != 700 {
+		t.Errorf("after partial payment fine = %s paid %d balance %d, want open paid 300 balance 700", got.Status, got.PaidAmount, got.Balance())
+	}
+
+	// 超过余额的缴费被拒绝，不修改费用
+	if _, err := RecordPayment(db, &got, 701, "cash", "", 1); !errors.Is(err, ErrAmountExceedsBalance) {
+		t.Fatalf("overpayment err = %v, want ErrAmountExceedsBalance", err)
+	}
+	if got := loadFine(t, db, fine.ID); got.PaidAmount != 300 || len(got.Payments) != 1 {
+		t.Errorf("overpayment changed the fine: paid %d, %d payments", got.PaidAmount, len(got.Payments))
+	}
+
+	if _, err := RecordPayment(db, &got, 700, "card", "", 1); err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+	got = loadFine(t, db, fine.ID)
+	if got.Status != models.FinePaid || got.Balance() != 0 {
+		t.Errorf("after full payment fine = %s balance %d, want paid balance 0", got.Status, got.Balance())
+	}
+	if len(got.Payments) != 2 || got.Payments[0].Amount != 300 || got.Payments[1].Amount != 700 || got.Payments[1].Method != "card" {
+		t.Errorf("payments = %+v, want 300 cash and 700 card", got.Payments)
+	}
+
+	// 已结清的费用不能再缴费或减免
+	if _, err := RecordPayment(db, &got, 1, "cash", "", 1); !errors.Is(err, ErrFineSettled) {
+		t.Errorf("payment on settled fine err = %v, want ErrFineSettled", err)
+	}
+	if _, err := WaiveFine(db, &got, 0, "", 1); !errors.Is(err, ErrFineSettled) {
+		t.Errorf("waiver on settled fine err = %v, want ErrFineSettled", err)
+	}
+}
+
+func TestWaiveFine(t *testing.T) {
+	db := databasetest.Open(t)
+	reader := createUser(t, db, "reader")
+
+	// 部分减免后余额减少，缴清剩余部分后状态为已缴费
+	fine := createFine(t, db, reader, 1000)
+	if _, err := WaiveFine(db, &fine, 400, "first offence", 1); err != nil {
+		t.Fatalf("WaiveFine: %v", err)
+	}
+	got := loadFine(t, db, fine.ID)
+	if got.Status != models.FineOpen || got.WaivedAmount != 400 || got.Balance() != 600 {
+		t.Errorf("after partial waiver fine = %s waived %d balance %d, want open waived 400 balance 600", got.Status, got.WaivedAmount, got.Balance())
+	}
+	if _, err := WaiveFine(db, &got, 601, "", 1); !errors.Is(err, ErrAmountExceedsBalance) {
+		t.Errorf("waiver over balance err = %v, want ErrAmountExceedsBalance", err)
+	}
+	if _, err := RecordPayment(db, &got, 600, "cash", "", 1); err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+	if got := loadFine(t, db, fine.ID); got.Status != models.FinePaid || got.Balance() != 0 {
+		t.Errorf("after paying the rest fine = %s balance %d, want paid", got.Status, got.Balance())
+	}
+
+	// 金额为0时减免全部余额，没有缴费时状态为已减免
+	full := createFine(t, db, reader, 500)
+	payment, err := WaiveFine(db, &full, 0, "", 1)
+	if err != nil {
+		t.Fatalf("WaiveFine: %v", err)
+	}
+	if payment.Amount != 500 || payment.Kind != models.PaymentKindWaiver {
+		t.Errorf("waiver = %+v, want 500 waiver", payment)
+	}
+	if got := loadFine(t, db, full.ID); got.Status != models.FineWaived || got.Balance() != 0 || got.WaivedAmount != 500 {
+		t.Errorf("after full waiver fine = %s balance %d waived %d, want waived balance 0", got.Status, got.Balance(), got.WaivedAmount)
+	}
+}
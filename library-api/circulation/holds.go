@@ -0,0 +1,186 @@
+package circulation
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// ActiveHoldStatuses 尚未完成的预约状态
+var ActiveHoldStatuses = []models.HoldStatus{models.HoldPending, models.HoldInTransit, models.HoldReady}
+
+// AssignCopy 将归还或新增的副本分配给下一位排队的预约读者，没有预约时副本恢复可借。
+// 副本不在读者选择的取书分馆时发起调拨，预约进入 in_transit 状态
+func AssignCopy(tx *gorm.DB, bookCopy *models.BookCopy, now time.Time) (*models.Hold, error) {
+	hold, err := nextHold(tx, bookCopy.BookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bookCopy.Status = models.CopyAvailable
+		return nil, tx.Save(bookCopy).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hold.PickupBranchID != nil && !sameBranch(bookCopy.BranchID, hold.PickupBranchID) {
+		hold.Status = models.HoldInTransit
+		hold.BookCopyID = &bookCopy.ID
+		if err := tx.Save(&hold).Error; err != nil {
+			return nil, err
+		}
+		if _, err := startTransfer(tx, bookCopy, *hold.PickupBranchID, &hold.ID, nil, "", now); err != nil {
+			return nil, err
+		}
+		return &hold, nil
+	}
+
+	return &hold, readyHold(tx, &hold, bookCopy, now)
+}
+
+// nextHold 返回图书副本应分配给的预约：先按队列顺序分配给预约该版本的读者，
+// 该版本无人排队时按预约时间分配给同一作品其他版本上接受任意版本的预约。没有预约时返回 gorm.ErrRecordNotFound
+func nextHold(tx *gorm.DB, bookID uint) (models.Hold, error) {
+	var hold models.Hold
+	err := tx.Where("book_id = ? AND status = ?", bookID, models.HoldPending).
+		Order("position ASC, id ASC").
+		First(&hold).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return hold, err
+	}
+
+	err = tx.Where("any_edition = ? AND status = ? AND book_id IN (?)", true, models.HoldPending, otherEditions(tx, bookID)).
+		Order("created_at ASC, id ASC").
+		First(&hold).Error
+	return hold, err
+}
+
+// otherEditions 返回同一作品其他未删除版本ID的子查询，不属于任何作品的图书没有其他版本
+func otherEditions(tx *gorm.DB, bookID uint) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true}).Model(&models.Book{}).
+		Select("id").
+		Where("work_id = (?) AND id <> ?", tx.Session(&gorm.Session{NewDB: true}).Model(&models.Book{}).Select("work_id").Where("id = ?", bookID), bookID)
+}
+
+// readyHold 将副本放上预约书架，通知读者在取书期限内取书
+func readyHold(tx *gorm.DB, hold *models.Hold, bookCopy *models.BookCopy, now time.Time) error {
+	expiresAt := now.Add(pickupWindow)
+	hold.Status = models.HoldReady
+	hold.BookCopyID = &bookCopy.ID
+	hold.ReadyAt = &now
+	hold.ExpiresAt = &expiresAt
+	if err := tx.Save(hold).Error; err != nil {
+		return err
+	}
+
+	bookCopy.Status = models.CopyOnHoldShelf
+	return tx.Save(bookCopy).Error
+}
+
+// releaseCopy 释放预约占用的副本，转给下一位读者
+func releaseCopy(tx *gorm.DB, hold *models.Hold, now time.Time) error {
+	if hold.BookCopyID == nil {
+		return nil
+	}
+
+	var bookCopy models.BookCopy
+	if err := tx.First(&bookCopy, *hold.BookCopyID).Error; err != nil {
+		return err
+	}
+	if bookCopy.Status != models.CopyOnHoldShelf {
+		return nil
+	}
+
+	_, err := AssignCopy(tx, &bookCopy, now)
+	return err
+}
+
+// ExpireHolds 将超过取书期限的预约标记为过期，并把副本转给下一位读者；bookID为0时处理所有图书。
+// 指定图书时同时处理占用该书副本的其他版本预约
+func ExpireHolds(tx *gorm.DB, bookID uint, now time.Time) (int, error) {
+	query := tx.Where("status = ? AND expires_at < ?", models.HoldReady, now)
+	if bookID != 0 {
+		query = query.Where("(book_id = ? OR book_copy_id IN (SELECT id FROM book_copies WHERE book_id = ?))", bookID, bookID)
+	}
+
+	var holds []models.Hold
+	if err := query.Order("expires_at ASC").Find(&holds).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range holds {
+		holds[i].Status = models.HoldExpired
+		if err := tx.Save(&holds[i]).Error; err != nil {
+			return 0, err
+		}
+		if err := releaseCopy(tx, &holds[i], now); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(holds), nil
+}
+
+// CancelHold 取消预约，已放上预约书架的副本转给下一位读者；调拨途中的副本到达后再重新分配
+func CancelHold(tx *gorm.DB, hold *models.Hold, now time.Time) error {
+	hold.Status = models.HoldCancelled
+	if err := tx.Save(hold).Error; err != nil {
+		return err
+	}
+	return releaseCopy(tx, hold, now)
+}
+
+// FulfillHold 预约读者取书后标记预约完成
+func FulfillHold(tx *gorm.DB, hold *models.Hold, now time.Time) error {
+	hold.Status = models.HoldFulfilled
+	hold.FulfilledAt = &now
+	return tx.Save(hold).Error
+}
+
+// NextPosition 返回新预约在队列中的位置
+func NextPosition(tx *gorm.DB, bookID uint) (int, error) {
+	var max int
+	err := tx.Model(&models.Hold{}).
+		Where("book_id = ?", bookID).
+		Select("COALESCE(MAX(position), 0)").
+		Scan(&max).Error
+	return max + 1, err
+}
+
+// QueuePosition 返回待处理预约在队列中的名次，从1开始
+func QueuePosition(tx *gorm.DB, hold *models.Hold) (int64, error) {
+	var ahead int64
+	err := tx.Model(&models.Hold{}).
+		Where("book_id = ? AND status = ? AND (position < ? OR (position = ? AND id < ?))",
+			hold.BookID, models.HoldPending, hold.Position, hold.Position, hold.ID).
+		Count(&ahead).Error
+	return ahead + 1, err
+}
+
+// MoveHold 将待处理预约移动到队列中的指定名次，并重新编号整个队列
+func MoveHold(tx *gorm.DB, hold *models.Hold, position int) error {
+	var queue []models.Hold
+	if err := tx.Where("book_id = ? AND status = ? AND id <> ?", hold.BookID, models.HoldPending, hold.ID).
+		Order("position ASC, id ASC").
+		Find(&queue).Error; err != nil {
+		return err
+	}
+
+	if position > len(queue)+1 {
+		position = len(queue) + 1
+	}
+
+	ordered := make([]models.Hold, 0, len(queue)+1)
+	ordered = append(ordered, queue[:position-1]...)
+	ordered = append(ordered, *hold)
+	ordered = append(ordered, queue[position-1:]...)
+
+	for i := range ordered {
+		if err := tx.Model(&models.Hold{}).Where("id = ?", ordered[i].ID).Update("position", i+1).Error; err != nil {
+			return err
+		}
+	}
+	hold.Position = position
+	return nil
+}
@@ -0,0 +1,239 @@
+package circulation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+func TestAssignCopyFirstInFirstOut(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, nil)
+	first := placeHold(t, db, createUser(t, db, "first"), book, nil)
+	second := placeHold(t, db, createUser(t, db, "second"), book, nil)
+
+	hold, err := AssignCopy(db, &bookCopy, testNow)
+	if err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+	if hold == nil || hold.ID != first.ID {
+		t.Fatalf("assigned hold = %+v, want hold %d", hold, first.ID)
+	}
+
+	got := loadHold(t, db, first.ID)
+	if got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID {
+		t.Errorf("first hold = %s copy %d, want ready with copy %d", got.Status, holdCopy(got), bookCopy.ID)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(testNow.Add(pickupWindow)) {
+		t.Errorf("expires_at = %v, want %v", got.ExpiresAt, testNow.Add(pickupWindow))
+	}
+	if got := loadHold(t, db, second.ID); got.Status != models.HoldPending {
+		t.Errorf("second hold = %s, want pending", got.Status)
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyOnHoldShelf {
+		t.Errorf("copy = %s, want on_hold_shelf", got.Status)
+	}
+}
+
+func TestAssignCopyWithoutHolds(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, nil)
+
+	// 其他图书的预约不影响
+	placeHold(t, db, createUser(t, db, "reader"), createBook(t, db, "Emma"), nil)
+
+	hold, err := AssignCopy(db, &bookCopy, testNow)
+	if err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+	if hold != nil {
+		t.Errorf("assigned hold = %+v, want none", hold)
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyAvailable {
+		t.Errorf("copy = %s, want available", got.Status)
+	}
+}
+
+func TestMoveHold(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	a := placeHold(t, db, createUser(t, db, "a"), book, nil)
+	b := placeHold(t, db, createUser(t, db, "b"), book, nil)
+	c := placeHold(t, db, createUser(t, db, "c"), book, nil)
+
+	order := func() []uint {
+		var ids []uint
+		db.Model(&models.Hold{}).Where("book_id = ? AND status = ?", book.ID, models.HoldPending).
+			Order("position ASC, id ASC").Pluck("id", &ids)
+		return ids
+	}
+	check := func(step string, want ...uint) {
+		t.Helper()
+		got := order()
+		if len(got) != len(want) {
+			t.Fatalf("%s: queue = %v, want %v", step, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: queue = %v, want %v", step, got, want)
+			}
+			hold := loadHold(t, db, want[i])
+			if pos, err := QueuePosition(db, &hold); err != nil || pos != int64(i+1) {
+				t.Errorf("%s: hold %d queue position = %d, %v, want %d", step, want[i], pos, err, i+1)
+			}
+		}
+	}
+
+	if err := MoveHold(db, &c, 1); err != nil {
+		t.Fatalf("MoveHold: %v", err)
+	}
+	check("move to front", c.ID, a.ID, b.ID)
+	if c.Position != 1 {
+		t.Errorf("moved hold position = %d, want 1", c.Position)
+	}
+
+	// 超出队列长度时移到队尾
+	if err := MoveHold(db, &c, 10); err != nil {
+		t.Fatalf("MoveHold: %v", err)
+	}
+	check("move past the end", a.ID, b.ID, c.ID)
+	if c.Position != 3 {
+		t.Errorf("moved hold position = %d, want 3", c.Position)
+	}
+
+	// 新预约排在重新编号后的队尾
+	d := placeHold(t, db, createUser(t, db, "d"), book, nil)
+	check("new hold", a.ID, b.ID, c.ID, d.ID)
+
+	// 副本分配给移动后的队首
+	if err := MoveHold(db, &d, 1); err != nil {
+		t.Fatalf("MoveHold: %v", err)
+	}
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, nil)
+	hold, err := AssignCopy(db, &bookCopy, testNow)
+	if err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+	if hold == nil || hold.ID != d.ID {
+		t.Errorf("assigned hold = %+v, want hold %d", hold, d.ID)
+	}
+}
+
+func TestExpireHoldsPassesCopyOn(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, nil)
+	first := placeHold(t, db, createUser(t, db, "first"), book, nil)
+	second := placeHold(t, db, createUser(t, db, "second"), book, nil)
+
+	if _, err := AssignCopy(db, &bookCopy, testNow); err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+
+	// 取书期限内不过期
+	if n, err := ExpireHolds(db, book.ID, testNow.Add(pickupWindow-time.Minute)); err != nil || n != 0 {
+		t.Fatalf("ExpireHolds before the deadline = %d, %v, want 0", n, err)
+	}
+
+	later := testNow.Add(pickupWindow + time.Minute)
+	n, err := ExpireHolds(db, book.ID, later)
+	if err != nil || n != 1 {
+		t.Fatalf("ExpireHolds = %d, %v, want 1", n, err)
+	}
+	if got := loadHold(t, db, first.ID); got.Status != models.HoldExpired {
+		t.Errorf("first hold = %s, want expired", got.Status)
+	}
+	got := loadHold(t, db, second.ID)
+	if got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID {
+		t.Errorf("second hold = %s copy %d, want ready with copy %d", got.Status, holdCopy(got), bookCopy.ID)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(later.Add(pickupWindow)) {
+		t.Errorf("second hold expires_at = %v, want %v", got.ExpiresAt, later.Add(pickupWindow))
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyOnHoldShelf {
+		t.Errorf("copy = %s, want on_hold_shelf", got.Status)
+	}
+
+	// 最后一位读者也过期后副本恢复可借
+	if n, err := ExpireHolds(db, 0, later.Add(pickupWindow+time.Minute)); err != nil || n != 1 {
+		t.Fatalf("ExpireHolds = %d, %v, want 1", n, err)
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyAvailable {
+		t.Errorf("copy = %s, want available", got.Status)
+	}
+}
+
+func TestExpireHoldsScopedByBook(t *testing.T) {
+	db := databasetest.Open(t)
+	dune := createBook(t, db, "Dune")
+	emma := createBook(t, db, "Emma")
+	reader := createUser(t, db, "reader")
+	duneCopy := createCopy(t, db, dune, models.CopyBorrowed, nil)
+	emmaCopy := createCopy(t, db, emma, models.CopyBorrowed, nil)
+	duneHold := placeHold(t, db, reader, dune, nil)
+	emmaHold := placeHold(t, db, reader, emma, nil)
+	for _, c := range []*models.BookCopy{&duneCopy, &emmaCopy} {
+		if _, err := AssignCopy(db, c, testNow); err != nil {
+			t.Fatalf("AssignCopy: %v", err)
+		}
+	}
+
+	later := testNow.Add(pickupWindow + time.Minute)
+	if n, err := ExpireHolds(db, dune.ID, later); err != nil || n != 1 {
+		t.Fatalf("ExpireHolds = %d, %v, want 1", n, err)
+	}
+	if got := loadHold(t, db, duneHold.ID); got.Status != models.HoldExpired {
+		t.Errorf("dune hold = %s, want expired", got.Status)
+	}
+	if got := loadHold(t, db, emmaHold.ID); got.Status != models.HoldReady {
+		t.Errorf("emma hold = %s, want ready", got.Status)
+	}
+}
+
+func TestAssignCopyToPickupBranch(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+	east := createBranch(t, db, "EAST")
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, &main)
+	hold := placeHold(t, db, createUser(t, db, "reader"), book, &east)
+
+	// 副本不在取书分馆时调拨过去
+	assigned, err := AssignCopy(db, &bookCopy, testNow)
+	if err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+	if assigned == nil || assigned.ID != hold.ID {
+		t.Fatalf("assigned hold = %+v, want hold %d", assigned, hold.ID)
+	}
+	got := loadHold(t, db, hold.ID)
+	if got.Status != models.HoldInTransit || holdCopy(got) != bookCopy.ID || got.ExpiresAt != nil {
+		t.Errorf("hold = %s copy %d expires %v, want in_transit with copy %d and no deadline", got.Status, holdCopy(got), got.ExpiresAt, bookCopy.ID)
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyInTransit {
+		t.Errorf("copy = %s, want in_transit", got.Status)
+	}
+	var transfer models.Transfer
+	if err := db.Where("book_copy_id = ?", bookCopy.ID).First(&transfer).Error; err != nil {
+		t.Fatalf("load transfer: %v", err)
+	}
+	if transfer.Status != models.TransferRequested || transfer.ToBranchID != east.ID ||
+		transfer.FromBranchID == nil || *transfer.FromBranchID != main.ID ||
+		transfer.HoldID == nil || *transfer.HoldID != hold.ID || transfer.RequestedByID != nil {
+		t.Errorf("transfer = %+v, want requested MAIN -> EAST for hold %d", transfer, hold.ID)
+	}
+
+	// 副本已在取书分馆时直接放上预约书架
+	other := placeHold(t, db, createUser(t, db, "other"), book, &main)
+	local := createCopy(t, db, book, models.CopyBorrowed, &main)
+	if _, err := AssignCopy(db, &local, testNow); err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+	if got := loadHold(t, db, other.ID); got.Status != models.HoldReady || holdCopy(got) != local.ID {
+		t.Errorf("local hold = %s copy %d, want ready with copy %d", got.Status, holdCopy(got), local.ID)
+	}
+}
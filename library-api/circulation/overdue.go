@@ -0,0 +1,99 @@
+package circulation
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// OpenBorrowStatuses 图书仍在读者手中的借阅状态
+var OpenBorrowStatuses = []models.BorrowStatus{models.BorrowActive, models.BorrowOverdue}
+
+// ReturnableBorrowStatuses 读者可以归还的借阅状态，包括被标记为丢失后又找到的图书
+var ReturnableBorrowStatuses = []models.BorrowStatus{models.BorrowActive, models.BorrowOverdue, models.BorrowLost}
+
+// lostAfter 逾期多久后视为丢失
+var lostAfter = 60 * 24 * time.Hour
+
+// MarkOverdue 将超过应还日期的借阅标记为逾期，返回标记数量
+func MarkOverdue(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&models.Borrow{}).
+		Where("status = ? AND due_date < ?", models.BorrowActive, now).
+		Update("status", models.BorrowOverdue)
+	return result.RowsAffected, result.Error
+}
+
+// MarkLost 将逾期超过lostAfter的借阅标记为丢失，副本同时标记为丢失并生成赔偿费用，返回标记数量。
+// 每条借阅在独立事务中按状态条件更新，与并发的归还事务冲突时以先提交者为准
+func MarkLost(db *gorm.DB, now time.Time) (int64, error) {
+	var borrows []models.Borrow
+	if err := db.Where("status IN ? AND due_date < ?", OpenBorrowStatuses, now.Add(-lostAfter)).
+		Find(&borrows).Error; err != nil {
+		return 0, err
+	}
+
+	var marked int64
+	for _, borrow := range borrows {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			ok, err := markBorrowLost(tx, &borrow)
+			if ok {
+				marked++
+			}
+			return err
+		})
+		if err != nil {
+			return marked, err
+		}
+	}
+
+	return marked, nil
+}
+
+// markBorrowLost 将未归还的借阅及其副本标记为丢失并生成赔偿费用，借阅已被归还或处理时返回false
+func markBorrowLost(tx *gorm.DB, borrow *models.Borrow) (bool, error) {
+	result := tx.Model(&models.Borrow{}).
+		Where("id = ? AND status IN ?", borrow.ID, OpenBorrowStatuses).
+		Update("status", models.BorrowLost)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	borrow.Status = models.BorrowLost
+
+	if err := tx.Model(&models.BookCopy{}).
+		Where("id = ? AND status = ?", borrow.BookCopyID, models.CopyBorrowed).
+		Update("status", models.CopyLost).Error; err != nil {
+		return false, err
+	}
+
+	if _, err := AssessLostFee(tx, borrow); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResolveLost 丢失的图书被归还或找到时将借阅标记为已归还，并减免未结清的丢失赔偿费用，actorID为0表示系统处理。
+// 借阅已不是丢失状态时返回false
+func ResolveLost(tx *gorm.DB, borrow *models.Borrow, actorID uint, now time.Time) (bool, error) {
+	result := tx.Model(&models.Borrow{}).
+		Where("id = ? AND status = ?", borrow.ID, models.BorrowLost).
+		Updates(map[string]interface{}{"status": models.BorrowReturned, "return_date": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	borrow.Status = models.BorrowReturned
+	borrow.ReturnDate = &now
+
+	var fines []models.Fine
+	if err := tx.Where("borrow_id = ? AND type = ? AND status = ?", borrow.ID, models.FineLost, models.FineOpen).
+		Find(&fines).Error; err != nil {
+		return false, err
+	}
+	for i := range fines {
+		if _, err := WaiveFine(tx, &fines[i], 0, "lost item returned", actorID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
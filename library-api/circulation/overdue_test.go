@@ -0,0 +1,122 @@
+package circulation
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// loadBorrow 重新读取借阅
+func loadBorrow(t *testing.T, db *gorm.DB, id uint) models.Borrow {
+	t.Helper()
+
+	var borrow models.Borrow
+	if err := db.First(&borrow, id).Error; err != nil {
+		t.Fatalf("load borrow: %v", err)
+	}
+	return borrow
+}
+
+// lostFines 返回借阅的丢失赔偿费用
+func lostFines(t *testing.T, db *gorm.DB, borrowID uint) []models.Fine {
+	t.Helper()
+
+	var fines []models.Fine
+	if err := db.Where("borrow_id = ? AND type = ?", borrowID, models.FineLost).Find(&fines).Error; err != nil {
+		t.Fatalf("load fines: %v", err)
+	}
+	return fines
+}
+
+func TestMarkOverdueThenLost(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	reader := createUser(t, db, "reader")
+	day := 24 * time.Hour
+
+	notDue := createBorrow(t, db, reader, createCopy(t, db, book, models.CopyBorrowed, nil), testNow.Add(day))
+	overdue := createBorrow(t, db, reader, createCopy(t, db, book, models.CopyBorrowed, nil), testNow.Add(-day))
+	lostCopy := createCopy(t, db, book, models.CopyBorrowed, nil)
+	lost := createBorrow(t, db, reader, lostCopy, testNow.Add(-lostAfter-day))
+	returned := createBorrow(t, db, reader, createCopy(t, db, book, models.CopyAvailable, nil), testNow.Add(-lostAfter-day))
+	db.Model(&returned).Update("status", models.BorrowReturned)
+
+	if n, err := MarkOverdue(db, testNow); err != nil || n != 2 {
+		t.Fatalf("MarkOverdue = %d, %v, want 2", n, err)
+	}
+	if n, err := MarkLost(db, testNow); err != nil || n != 1 {
+		t.Fatalf("MarkLost = %d, %v, want 1", n, err)
+	}
+
+	want := map[uint]models.BorrowStatus{
+		notDue.ID:   models.BorrowActive,
+		overdue.ID:  models.BorrowOverdue,
+		lost.ID:     models.BorrowLost,
+		returned.ID: models.BorrowReturned,
+	}
+	for id, status := range want {
+		if got := loadBorrow(t, db, id); got.Status != status {
+			t.Errorf("borrow %d = %s, want %s", id, got.Status, status)
+		}
+	}
+	if got := loadCopy(t, db, lostCopy.ID); got.Status != models.CopyLost {
+		t.Errorf("lost copy = %s, want lost", got.Status)
+	}
+	if got := loadCopy(t, db, overdue.BookCopyID); got.Status != models.CopyBorrowed {
+		t.Errorf("overdue copy = %s, want borrowed", got.Status)
+	}
+
+	fines := lostFines(t, db, lost.ID)
+	if len(fines) != 1 || fines[0].UserID != reader.ID || fines[0].Amount != lostReplacementFee || fines[0].Status != models.FineOpen {
+		t.Fatalf("lost fines = %+v, want one open fine of %d", fines, lostReplacementFee)
+	}
+
+	// 再次运行不会重复标记或收费
+	if n, err := MarkOverdue(db, testNow); err != nil || n != 0 {
+		t.Errorf("second MarkOverdue = %d, %v, want 0", n, err)
+	}
+	if n, err := MarkLost(db, testNow); err != nil || n != 0 {
+		t.Errorf("second MarkLost = %d, %v, want 0", n, err)
+	}
+	if fines := lostFines(t, db, lost.ID); len(fines) != 1 {
+		t.Errorf("got %d lost fines after second run, want 1", len(fines))
+	}
+}
+
+func TestMarkLostSkipsBorrowReturnedMeanwhile(t *testing.T) {
+	db := databasetest.Open(t)
+	bookCopy := createCopy(t, db, createBook(t, db, "Dune"), models.CopyBorrowed, nil)
+	borrow := createBorrow(t, db, createUser(t, db, "reader"), bookCopy, testNow.Add(-lostAfter-time.Hour))
+
+	// 在查出候选借阅之后、标记之前归还图书
+	returned := false
+	db.Callback().Query().After("gorm:query").Register("test:return_borrow", func(tx *gorm.DB) {
+		if returned || tx.Statement.Table != "borrows" {
+			return
+		}
+		returned = true
+		session := tx.Session(&gorm.Session{NewDB: true})
+		session.Model(&models.Borrow{}).Where("id = ?", borrow.ID).Update("status", models.BorrowReturned)
+		session.Model(&models.BookCopy{}).Where("id = ?", bookCopy.ID).Update("status", models.CopyAvailable)
+	})
+
+	if n, err := MarkLost(db, testNow); err != nil || n != 0 {
+		t.Fatalf("MarkLost = %d, %v, want 0", n, err)
+	}
+	if !returned {
+		t.Fatal("borrow was not returned during MarkLost")
+	}
+	if got := loadBorrow(t, db, borrow.ID); got.Status != models.BorrowReturned {
+		t.Errorf("borrow = %s, want returned", got.Status)
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyAvailable {
+		t.Errorf("copy = %s, want available", got.Status)
+	}
+	if fines := lostFines(t, db, borrow.ID); len(fines) != 0 {
+		t.Errorf("got %d lost fines for a returned borrow, want none", len(fines))
+	}
+}
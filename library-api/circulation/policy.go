@@ -0,0 +1,96 @@
+package circulation
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// 未配置借阅规则时的默认值
+var (
+	defaultLoanDays    = 14
+	defaultMaxLoanDays = 30
+	defaultMaxBorrows  = 5
+)
+
+// PolicyViolation 借阅规则拒绝借阅时的原因
+type PolicyViolation struct {
+	Code    string
+	Message string
+	Policy  models.LoanPolicy
+}
+
+func (v *PolicyViolation) Error() string {
+	return v.Message
+}
+
+// DefaultPolicy 返回角色的默认借阅规则，管理员不限制在借数量
+func DefaultPolicy(role models.UserRole) models.LoanPolicy {
+	policy := models.LoanPolicy{
+		Role:                role,
+		LoanDays:            defaultLoanDays,
+		MaxLoanDays:         defaultMaxLoanDays,
+		AllowCustomDuration: true,
+		MaxBorrows:          defaultMaxBorrows,
+		MaxRenewals:         maxRenewals,
+		FineDailyRateCents:  fineDailyRate,
+	}
+	if role == models.RoleAdmin {
+		policy.MaxBorrows = 0
+	}
+	return policy
+}
+
+// ResolvePolicy 返回适用于角色的借阅规则，未配置时使用默认规则。
+// 以后按读者分组或图书分类区分规则时在此处按从具体到一般的顺序查找
+func ResolvePolicy(db *gorm.DB, role models.UserRole) (models.LoanPolicy, error) {
+	var policy models.LoanPolicy
+	err := db.Where("role = ?", role).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultPolicy(role), nil
+	}
+	return policy, err
+}
+
+// CheckBorrow 按借阅规则检查借阅请求，返回实际借期天数；requestedDays为0表示使用默认借期
+func CheckBorrow(tx *gorm.DB, policy models.LoanPolicy, userID uint, requestedDays int) (int, error) {
+	days := policy.LoanDays
+	if requestedDays != 0 {
+		if !policy.AllowCustomDuration {
+			return 0, &PolicyViolation{
+				Code:    "custom_duration_not_allowed",
+				Message: fmt.Sprintf("your loan policy does not allow choosing the loan period, books are lent for %d days", policy.LoanDays),
+				Policy:  policy,
+			}
+		}
+		if requestedDays > policy.MaxLoanDays {
+			return 0, &PolicyViolation{
+				Code:    "loan_period_too_long",
+				Message: fmt.Sprintf("your loan policy allows at most %d days", policy.MaxLoanDays),
+				Policy:  policy,
+			}
+		}
+		days = requestedDays
+	}
+
+	if policy.MaxBorrows > 0 {
+		var openBorrows int64
+		if err := tx.Model(&models.Borrow{}).
+			Where("user_id = ? AND status IN ?", userID, OpenBorrowStatuses).
+			Count(&openBorrows).Error; err != nil {
+			return 0, err
+		}
+		if openBorrows >= int64(policy.MaxBorrows) {
+			return 0, &PolicyViolation{
+				Code:    "max_borrows_reached",
+				Message: fmt.Sprintf("your loan policy allows at most %d books on loan at the same time", policy.MaxBorrows),
+				Policy:  policy,
+			}
+		}
+	}
+
+	return days, nil
+}
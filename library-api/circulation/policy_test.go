@@ -0,0 +1,103 @@
+package circulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+func TestResolvePolicy(t *testing.T) {
+	db := databasetest.Open(t)
+	configured := models.LoanPolicy{Role: models.RoleUser, LoanDays: 7, MaxLoanDays: 10, MaxBorrows: 2, MaxRenewals: 1, FineDailyRateCents: 20}
+	if err := db.Create(&configured).Error; err != nil {
+		t.Fatalf("create policy: %v", err)
+	}
+
+	user, err := ResolvePolicy(db, models.RoleUser)
+	if err != nil {
+		t.Fatalf("ResolvePolicy(user): %v", err)
+	}
+	if user.ID != configured.ID || user.LoanDays != 7 || user.MaxBorrows != 2 {
+		t.Errorf("user policy = %+v, want configured policy", user)
+	}
+
+	// 未配置的角色使用默认规则，管理员不限制在借数量
+	admin, err := ResolvePolicy(db, models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("ResolvePolicy(admin): %v", err)
+	}
+	if admin.ID != 0 || admin.Role != models.RoleAdmin || admin.LoanDays != defaultLoanDays || admin.MaxBorrows != 0 {
+		t.Errorf("admin policy = %+v, want default policy without borrow limit", admin)
+	}
+	if def := DefaultPolicy(models.RoleUser); def.MaxBorrows != defaultMaxBorrows || !def.AllowCustomDuration {
+		t.Errorf("default user policy = %+v, want %d borrows and custom duration", def, defaultMaxBorrows)
+	}
+}
+
+func TestCheckBorrow(t *testing.T) {
+	policy := models.LoanPolicy{LoanDays: 14, MaxLoanDays: 30, AllowCustomDuration: true, MaxBorrows: 2}
+	fixed := policy
+	fixed.AllowCustomDuration = false
+	unlimited := policy
+	unlimited.MaxBorrows = 0
+
+	tests := []struct {
+		name     string
+		policy   models.LoanPolicy
+		open     int
+		days     int
+		wantDays int
+		wantCode string
+	}{
+		{"默认借期", policy, 0, 0, 14, ""},
+		{"自选借期", policy, 0, 30, 30, ""},
+		{"超过最长借期", policy, 0, 31, 0, "loan_period_too_long"},
+		{"不允许自选借期", fixed, 0, 7, 0, "custom_duration_not_allowed"},
+		{"不允许自选时使用默认借期", fixed, 0, 0, 14, ""},
+		{"未达在借上限", policy, 1, 0, 14, ""},
+		{"达到在借上限", policy, 2, 0, 0, "max_borrows_reached"},
+		{"不限在借数量", unlimited, 5, 0, 14, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := databasetest.Open(t)
+			reader := createUser(t, db, "reader")
+			book := createBook(t, db, "Dune")
+			for i := 0; i < tt.open; i++ {
+				createBorrow(t, db, reader, createCopy(t, db, book, models.CopyBorrowed, nil), testNow)
+			}
+			// 已归还的借阅不计入在借数量
+			returned := createBorrow(t, db, reader, createCopy(t, db, book, models.CopyAvailable, nil), testNow)
+			db.Model(&returned).Update("status", models.BorrowReturned)
+
+			days, err := CheckBorrow(db, tt.policy, reader.ID, tt.days)
+			var violation *PolicyViolation
+			switch {
+			case tt.wantCode == "" && err != nil:
+				t.Fatalf("CheckBorrow: %v", err)
+			case tt.wantCode != "" && (!errors.As(err, &violation) || violation.Code != tt.wantCode):
+				t.Fatalf("CheckBorrow err = %v, want %s", err, tt.wantCode)
+			}
+			if days != tt.wantDays {
+				t.Errorf("days = %d, want %d", days, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestCheckBorrowCountsOverdueBorrows(t *testing.T) {
+	db := databasetest.Open(t)
+	reader := createUser(t, db, "reader")
+	book := createBook(t, db, "Dune")
+	overdue := createBorrow(t, db, reader, createCopy(t, db, book, models.CopyBorrowed, nil), testNow.Add(-24*time.Hour))
+	db.Model(&overdue).Update("status", models.BorrowOverdue)
+
+	policy := models.LoanPolicy{LoanDays: 14, MaxLoanDays: 14, MaxBorrows: 1}
+	var violation *PolicyViolation
+	if _, err := CheckBorrow(db, policy, reader.ID, 0); !errors.As(err, &violation) || violation.Policy.MaxBorrows != 1 {
+		t.Errorf("CheckBorrow err = %v, want max_borrows_reached with the policy", err)
+	}
+}
@@ -0,0 +1,64 @@
+package circulation
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+var (
+	// ErrRenewalLimitReached 已达到最大续借次数
+	ErrRenewalLimitReached = errors.New("maximum number of renewals reached")
+	// ErrHoldsPending 该书有其他读者在排队预约
+	ErrHoldsPending = errors.New("this book has pending holds and cannot be renewed")
+	// ErrTooOverdue 逾期超过宽限期
+	ErrTooOverdue = errors.New("borrow is overdue beyond the renewal grace period, please return the book")
+)
+
+// 续借参数，最大续借次数为未配置借阅规则时的默认值
+var (
+	maxRenewals   = 2
+	renewalPeriod = 14 * 24 * time.Hour
+	renewalGrace  = 3 * 24 * time.Hour
+)
+
+// Renew 续借：延长应还日期并累加续借次数。未逾期时从原应还日期顺延，逾期时从当前时间起算
+func Renew(tx *gorm.DB, borrow *models.Borrow, policy models.LoanPolicy, now time.Time) error {
+	if borrow.RenewalCount >= policy.MaxRenewals {
+		return ErrRenewalLimitReached
+	}
+
+	if now.After(borrow.DueDate.Add(renewalGrace)) {
+		return ErrTooOverdue
+	}
+
+	// 该版本的预约和同一作品其他版本上接受任意版本的预约都会等待此副本
+	var bookID uint
+	if err := tx.Model(&models.BookCopy{}).Select("book_id").Where("id = ?", borrow.BookCopyID).Scan(&bookID).Error; err != nil {
+		return err
+	}
+	var pendingHolds int64
+	if err := tx.Model(&models.Hold{}).
+		Where("status = ? AND (book_id = ? OR (any_edition = ? AND book_id IN (?)))",
+			models.HoldPending, bookID, true, otherEditions(tx, bookID)).
+		Count(&pendingHolds).Error; err != nil {
+		return err
+	}
+	if pendingHolds > 0 {
+		return ErrHoldsPending
+	}
+
+	base := borrow.DueDate
+	if now.After(base) {
+		base = now
+	}
+
+	borrow.DueDate = base.Add(renewalPeriod)
+	borrow.RenewalCount++
+	borrow.LastRenewedAt = &now
+	borrow.Status = models.BorrowActive
+	return tx.Save(borrow).Error
+}
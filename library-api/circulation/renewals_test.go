@@ -0,0 +1,99 @@
+package circulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// createBorrow 为读者借出副本，应还日期为 due
+func createBorrow(t *testing.T, db *gorm.DB, user models.User, bookCopy models.BookCopy, due time.Time) models.Borrow {
+	t.Helper()
+
+	borrow := models.Borrow{
+		UserID:     user.ID,
+		BookCopyID: bookCopy.ID,
+		BorrowDate: due.Add(-14 * 24 * time.Hour),
+		DueDate:    due,
+		Status:     models.BorrowActive,
+	}
+	if err := db.Create(&borrow).Error; err != nil {
+		t.Fatalf("create borrow: %v", err)
+	}
+	return borrow
+}
+
+func TestRenew(t *testing.T) {
+	policy := models.LoanPolicy{MaxRenewals: 2}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		due      time.Time
+		renewals int
+		wantErr  error
+		wantDue  time.Time
+	}{
+		{"未到期从原应还日期顺延", testNow.Add(2 * day), 0, nil, testNow.Add(2*day + renewalPeriod)},
+		{"宽限期内逾期从当前时间起算", testNow.Add(-2 * day), 1, nil, testNow.Add(renewalPeriod)},
+		{"达到续借次数上限", testNow.Add(2 * day), 2, ErrRenewalLimitReached, time.Time{}},
+		{"逾期超过宽限期", testNow.Add(-renewalGrace - time.Minute), 0, ErrTooOverdue, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := databasetest.Open(t)
+			book := createBook(t, db, "Dune")
+			borrow := createBorrow(t, db, createUser(t, db, "reader"), createCopy(t, db, book, models.CopyBorrowed, nil), tt.due)
+			borrow.RenewalCount = tt.renewals
+			borrow.Status = models.BorrowOverdue
+
+			err := Renew(db, &borrow, policy, testNow)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Renew err = %v, want %v", err, tt.wantErr)
+			}
+
+			var got models.Borrow
+			if err := db.First(&got, borrow.ID).Error; err != nil {
+				t.Fatalf("load borrow: %v", err)
+			}
+			if tt.wantErr != nil {
+				if got.RenewalCount != 0 || !got.DueDate.Equal(tt.due) {
+					t.Errorf("rejected renewal changed the borrow: %+v", got)
+				}
+				return
+			}
+			if !got.DueDate.Equal(tt.wantDue) || got.RenewalCount != tt.renewals+1 || got.Status != models.BorrowActive {
+				t.Errorf("borrow due %v renewals %d status %s, want due %v renewals %d active",
+					got.DueDate, got.RenewalCount, got.Status, tt.wantDue, tt.renewals+1)
+			}
+			if got.LastRenewedAt == nil || !got.LastRenewedAt.Equal(testNow) {
+				t.Errorf("last_renewed_at = %v, want %v", got.LastRenewedAt, testNow)
+			}
+		})
+	}
+}
+
+func TestRenewBlockedByPendingHolds(t *testing.T) {
+	db := databasetest.Open(t)
+	book := createBook(t, db, "Dune")
+	borrow := createBorrow(t, db, createUser(t, db, "reader"), createCopy(t, db, book, models.CopyBorrowed, nil), testNow.Add(24*time.Hour))
+	hold := placeHold(t, db, createUser(t, db, "waiting"), book, nil)
+	policy := models.LoanPolicy{MaxRenewals: 2}
+
+	if err := Renew(db, &borrow, policy, testNow); !errors.Is(err, ErrHoldsPending) {
+		t.Fatalf("Renew err = %v, want ErrHoldsPending", err)
+	}
+
+	// 预约取消后可以续借
+	if err := CancelHold(db, &hold, testNow); err != nil {
+		t.Fatalf("CancelHold: %v", err)
+	}
+	if err := Renew(db, &borrow, policy, testNow); err != nil {
+		t.Fatalf("Renew after cancel: %v", err)
+	}
+}
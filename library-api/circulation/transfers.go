@@ -0,0 +1,143 @@
+package circulation
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// ErrTransferState 调拨当前状态不允许执行该操作
+var ErrTransferState = errors.New("transfer is not in a valid state for this action")
+
+// ErrSameBranch 副本已在目的分馆
+var ErrSameBranch = errors.New("copy is already at the destination branch")
+
+// startTransfer 将副本标记为调拨中并创建调拨记录
+func startTransfer(tx *gorm.DB, bookCopy *models.BookCopy, toBranchID uint, holdID, requestedByID *uint, note string, now time.Time) (*models.Transfer, error) {
+	bookCopy.Status = models.CopyInTransit
+	if err := tx.Save(bookCopy).Error; err != nil {
+		return nil, err
+	}
+
+	transfer := models.Transfer{
+		BookCopyID:    bookCopy.ID,
+		FromBranchID:  bookCopy.BranchID,
+		ToBranchID:    toBranchID,
+		HoldID:        holdID,
+		Status:        models.TransferRequested,
+		Note:          note,
+		RequestedByID: requestedByID,
+		RequestedAt:   now,
+	}
+	if err := tx.Create(&transfer).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// RequestTransfer 管理员申请将可借副本调拨到其他分馆
+func RequestTransfer(tx *gorm.DB, bookCopy *models.BookCopy, toBranchID uint, note string, adminID uint, now time.Time) (*models.Transfer, error) {
+	if bookCopy.BranchID != nil && *bookCopy.BranchID == toBranchID {
+		return nil, ErrSameBranch
+	}
+	if bookCopy.Status != models.CopyAvailable {
+		return nil, ErrInvalidTransition
+	}
+
+	// 条件更新，防止副本同时被借出或分配给预约
+	result := tx.Model(&models.BookCopy{}).
+		Where("id = ? AND status = ?", bookCopy.ID, models.CopyAvailable).
+		Update("status", models.CopyInTransit)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCopyStatusChanged
+	}
+
+	return startTransfer(tx, bookCopy, toBranchID, nil, &adminID, note, now)
+}
+
+// ShipTransfer 原分馆发出副本
+func ShipTransfer(tx *gorm.DB, transfer *models.Transfer, adminID uint, now time.Time) error {
+	if transfer.Status != models.TransferRequested {
+		return ErrTransferState
+	}
+
+	transfer.Status = models.TransferInTransit
+	transfer.ShippedByID = &adminID
+	transfer.ShippedAt = &now
+	return tx.Save(transfer).Error
+}
+
+// ReceiveTransfer 目的分馆接收副本。副本归属目的分馆，为预约调拨的副本放上预约书架，
+// 预约已取消时副本转给下一位预约读者或恢复可借
+func ReceiveTransfer(tx *gorm.DB, transfer *models.Transfer, adminID uint, now time.Time) (*models.Hold, error) {
+	if transfer.Status != models.TransferInTransit {
+		return nil, ErrTransferState
+	}
+
+	transfer.Status = models.TransferReceived
+	transfer.ReceivedByID = &adminID
+	transfer.ReceivedAt = &now
+	if err := tx.Save(transfer).Error; err != nil {
+		return nil, err
+	}
+
+	var bookCopy models.BookCopy
+	if err := tx.First(&bookCopy, transfer.BookCopyID).Error; err != nil {
+		return nil, err
+	}
+	// 原分馆的排架位置不再适用，由目的分馆重新上架
+	bookCopy.BranchID = &transfer.ToBranchID
+	bookCopy.ShelfLocationID = nil
+
+	if transfer.HoldID != nil {
+		var hold models.Hold
+		err := tx.Where("id = ? AND status = ? AND book_copy_id = ?", *transfer.HoldID, models.HoldInTransit, bookCopy.ID).
+			First(&hold).Error
+		if err == nil {
+			return &hold, readyHold(tx, &hold, &bookCopy, now)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	return AssignCopy(tx, &bookCopy, now)
+}
+
+// CancelTransfer 取消尚未发出的调拨，副本留在原分馆。为预约发起的调拨由预约流程管理，不能手动取消
+func CancelTransfer(tx *gorm.DB, transfer *models.Transfer, adminID uint, now time.Time) error {
+	if transfer.Status != models.TransferRequested || transfer.HoldID != nil {
+		return ErrTransferState
+	}
+
+	transfer.Status = models.TransferCancelled
+	transfer.CancelledByID = &adminID
+	transfer.CancelledAt = &now
+	if err := tx.Save(transfer).Error; err != nil {
+		return err
+	}
+
+	var bookCopy models.BookCopy
+	if err := tx.First(&bookCopy, transfer.BookCopyID).Error; err != nil {
+		return err
+	}
+	_, err := AssignCopy(tx, &bookCopy, now)
+	return err
+}
+
+// abandonTransfer 副本在调拨途中丢失或下架时取消未完成的调拨
+func abandonTransfer(tx *gorm.DB, copyID uint, adminID uint, now time.Time) error {
+	return tx.Model(&models.Transfer{}).
+		Where("book_copy_id = ? AND status IN ?", copyID, []models.TransferStatus{models.TransferRequested, models.TransferInTransit}).
+		Updates(map[string]interface{}{
+			"status":          models.TransferCancelled,
+			"cancelled_by_id": adminID,
+			"cancelled_at":    now,
+		}).Error
+}
@@ -0,0 +1,194 @@
+package circulation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// loadTransfer 重新读取调拨
+func loadTransfer(t *testing.T, db *gorm.DB, id uint) models.Transfer {
+	t.Helper()
+
+	var transfer models.Transfer
+	if err := db.First(&transfer, id).Error; err != nil {
+		t.Fatalf("load transfer: %v", err)
+	}
+	return transfer
+}
+
+func TestTransferLifecycle(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+	east := createBranch(t, db, "EAST")
+	bookCopy := createCopy(t, db, createBook(t, db, "Dune"), models.CopyAvailable, &main)
+
+	if _, err := RequestTransfer(db, &bookCopy, main.ID, "", 1, testNow); !errors.Is(err, ErrSameBranch) {
+		t.Errorf("transfer to the same branch err = %v, want ErrSameBranch", err)
+	}
+
+	transfer, err := RequestTransfer(db, &bookCopy, east.ID, "rebalance", 1, testNow)
+	if err != nil {
+		t.Fatalf("RequestTransfer: %v", err)
+	}
+	if transfer.Status != models.TransferRequested || transfer.FromBranchID == nil || *transfer.FromBranchID != main.ID {
+		t.Errorf("transfer = %+v, want requested from MAIN", transfer)
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyInTransit {
+		t.Errorf("copy = %s, want in_transit", got.Status)
+	}
+
+	// 未发出的调拨不能接收
+	if _, err := ReceiveTransfer(db, transfer, 2, testNow); !errors.Is(err, ErrTransferState) {
+		t.Errorf("receive before shipping err = %v, want ErrTransferState", err)
+	}
+
+	shipped := testNow.Add(time.Hour)
+	if err := ShipTransfer(db, transfer, 1, shipped); err != nil {
+		t.Fatalf("ShipTransfer: %v", err)
+	}
+	if err := ShipTransfer(db, transfer, 1, shipped); !errors.Is(err, ErrTransferState) {
+		t.Errorf("ship twice err = %v, want ErrTransferState", err)
+	}
+	// 已发出的调拨不能取消
+	if err := CancelTransfer(db, transfer, 1, shipped); !errors.Is(err, ErrTransferState) {
+		t.Errorf("cancel after shipping err = %v, want ErrTransferState", err)
+	}
+
+	received := shipped.Add(time.Hour)
+	hold, err := ReceiveTransfer(db, transfer, 2, received)
+	if err != nil {
+		t.Fatalf("ReceiveTransfer: %v", err)
+	}
+	if hold != nil {
+		t.Errorf("received copy assigned to hold %+v, want none", hold)
+	}
+	got := loadTransfer(t, db, transfer.ID)
+	if got.Status != models.TransferReceived || got.ShippedAt == nil || !got.ShippedAt.Equal(shipped) ||
+		got.ReceivedAt == nil || !got.ReceivedAt.Equal(received) || got.ReceivedByID == nil || *got.ReceivedByID != 2 {
+		t.Errorf("transfer = %+v, want received by 2", got)
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyAvailable || got.BranchID == nil || *got.BranchID != east.ID {
+		t.Errorf("copy = %s at %v, want available at EAST", got.Status, got.BranchID)
+	}
+}
+
+func TestCancelTransfer(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+	east := createBranch(t, db, "EAST")
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyAvailable, &main)
+
+	transfer, err := RequestTransfer(db, &bookCopy, east.ID, "", 1, testNow)
+	if err != nil {
+		t.Fatalf("RequestTransfer: %v", err)
+	}
+	// 调拨期间排队的读者在取消后得到副本
+	hold := placeHold(t, db, createUser(t, db, "reader"), book, nil)
+
+	if err := CancelTransfer(db, transfer, 1, testNow); err != nil {
+		t.Fatalf("CancelTransfer: %v", err)
+	}
+	if got := loadTransfer(t, db, transfer.ID); got.Status != models.TransferCancelled || got.CancelledByID == nil {
+		t.Errorf("transfer = %+v, want cancelled", got)
+	}
+	got := loadCopy(t, db, bookCopy.ID)
+	if got.Status != models.CopyOnHoldShelf || got.BranchID == nil || *got.BranchID != main.ID {
+		t.Errorf("copy = %s at %v, want on_hold_shelf at MAIN", got.Status, got.BranchID)
+	}
+	if got := loadHold(t, db, hold.ID); got.Status != models.HoldReady {
+		t.Errorf("hold = %s, want ready", got.Status)
+	}
+
+	// 只有可借的副本可以申请调拨
+	if _, err := RequestTransfer(db, &got, east.ID, "", 1, testNow); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("transfer of a held copy err = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestReceiveTransferReadiesHold(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+	east := createBranch(t, db, "EAST")
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, &main)
+	hold := placeHold(t, db, createUser(t, db, "reader"), book, &east)
+
+	if _, err := AssignCopy(db, &bookCopy, testNow); err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+	var transfer models.Transfer
+	if err := db.Where("hold_id = ?", hold.ID).First(&transfer).Error; err != nil {
+		t.Fatalf("load transfer: %v", err)
+	}
+
+	// 为预约发起的调拨不能手动取消
+	if err := CancelTransfer(db, &transfer, 1, testNow); !errors.Is(err, ErrTransferState) {
+		t.Errorf("cancel hold transfer err = %v, want ErrTransferState", err)
+	}
+
+	if err := ShipTransfer(db, &transfer, 1, testNow); err != nil {
+		t.Fatalf("ShipTransfer: %v", err)
+	}
+	arrived := testNow.Add(24 * time.Hour)
+	ready, err := ReceiveTransfer(db, &transfer, 2, arrived)
+	if err != nil {
+		t.Fatalf("ReceiveTransfer: %v", err)
+	}
+	if ready == nil || ready.ID != hold.ID {
+		t.Fatalf("ready hold = %+v, want hold %d", ready, hold.ID)
+	}
+
+	// 到达取书分馆后放上预约书架，取书期限从到达时起算
+	got := loadHold(t, db, hold.ID)
+	if got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID || got.ExpiresAt == nil || !got.ExpiresAt.Equal(arrived.Add(pickupWindow)) {
+		t.Errorf("hold = %s copy %d expires %v, want ready with copy %d until %v",
+			got.Status, holdCopy(got), got.ExpiresAt, bookCopy.ID, arrived.Add(pickupWindow))
+	}
+	if got := loadCopy(t, db, bookCopy.ID); got.Status != models.CopyOnHoldShelf || got.BranchID == nil || *got.BranchID != east.ID {
+		t.Errorf("copy = %s at %v, want on_hold_shelf at EAST", got.Status, got.BranchID)
+	}
+}
+
+func TestReceiveTransferAfterHoldCancelled(t *testing.T) {
+	db := databasetest.Open(t)
+	main := createBranch(t, db, "MAIN")
+	east := createBranch(t, db, "EAST")
+	book := createBook(t, db, "Dune")
+	bookCopy := createCopy(t, db, book, models.CopyBorrowed, &main)
+	hold := placeHold(t, db, createUser(t, db, "reader"), book, &east)
+	next := placeHold(t, db, createUser(t, db, "next"), book, nil)
+
+	if _, err := AssignCopy(db, &bookCopy, testNow); err != nil {
+		t.Fatalf("AssignCopy: %v", err)
+	}
+	var transfer models.Transfer
+	if err := db.Where("hold_id = ?", hold.ID).First(&transfer).Error; err != nil {
+		t.Fatalf("load transfer: %v", err)
+	}
+
+	// 调拨途中取消预约，副本到达后转给下一位读者
+	held := loadHold(t, db, hold.ID)
+	if err := CancelHold(db, &held, testNow); err != nil {
+		t.Fatalf("CancelHold: %v", err)
+	}
+	if err := ShipTransfer(db, &transfer, 1, testNow); err != nil {
+		t.Fatalf("ShipTransfer: %v", err)
+	}
+	ready, err := ReceiveTransfer(db, &transfer, 2, testNow)
+	if err != nil {
+		t.Fatalf("ReceiveTransfer: %v", err)
+	}
+	if ready == nil || ready.ID != next.ID {
+		t.Fatalf("ready hold = %+v, want hold %d", ready, next.ID)
+	}
+	if got := loadHold(t, db, next.ID); got.Status != models.HoldReady || holdCopy(got) != bookCopy.ID {
+		t.Errorf("next hold = %s copy %d, want ready with copy %d", got.Status, holdCopy(got), bookCopy.ID)
+	}
+}
@@ -3,21 +3,85 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 // Config 应用配置结构
 type Config struct {
-	ServerPort         string
-	DBPath             string
-	JWTSecret          string
-	JWTExpiryHours     int
-	RateLimitRPS       int
-	RateLimitBurst     int
-	GoogleClientID     string
-	GoogleClientSecret string
-	GoogleRedirectURI  string
+	ServerPort                string
+	DBPath                    string
+	JWTKeyDir                 string
+	JWTKeyAlgorithm           string
+	JWTKeyRotationDays        int
+	JWTKeyOverlapHours        int
+	AccessTokenMinutes        int
+	RefreshTokenDays          int
+	RateLimitRPS              int
+	RateLimitBurst            int
+	OIDCProviders             []OIDCProvider
+	OAuthFrontendURL          string
+	HoldPickupDays            int
+	MaxRenewals               int
+	RenewalDays               int
+	RenewalGraceDays          int
+	LostAfterDays             int
+	OverdueJobIntervalMinutes int
+	FineDailyRateCents        int64
+	FineGraceDays             int
+	FineMaxCents              int64
+	LostReplacementFeeCents   int64
+	DefaultLoanDays           int
+	MaxLoanDays               int
+	MaxBorrows                int
+	DBBusyTimeoutMs           int
+	AccessionPrefix           string
+	AccessionDigits           int
+	AccessionCheckDigit       string
+	ImportBatchSize           int
+	ImportMaxBytes            int64
+	OAIRepositoryName         string
+	OAIRepositoryIdentifier   string
+	OAIAdminEmail             string
+	OAIBaseURL                string
+	OAIPageSize               int
+	MetadataProviders         []string
+	MetadataOpenLibraryURL    string
+	MetadataFilePath          string
+	MetadataTimeoutSeconds    int
+	MetadataCacheTTLHours     int
+	CoverStorage              string
+	CoverLocalDir             string
+	CoverPublicURL            string
+	CoverMaxBytes             int64
+	CoverS3Endpoint           string
+	CoverS3Region             string
+	CoverS3Bucket             string
+	CoverS3AccessKey          string
+	CoverS3SecretKey          string
+}
+
+// OIDCProvider OpenID Connect 身份提供方配置
+type OIDCProvider struct {
+	Name         string // 路由中的名称，如 /auth/{name}/login
+	DisplayName  string // 登录按钮上显示的名称
+	IssuerURL    string // 签发方地址，发现文档位于 {issuer}/.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+	Claims       ClaimMapping
+	TrustEmail   bool // 身份提供方不返回 email_verified 时视所有邮箱为已验证，只用于可信的机构内部身份提供方
+}
+
+// ClaimMapping 用户信息在 ID 令牌中的声明名称，可用点号访问嵌套声明，如 attributes.mail
+type ClaimMapping struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Name          string
+	Username      string
 }
 
 // LoadConfig 从环境变量和.env文件加载配置
@@ -25,12 +89,20 @@ func LoadConfig() (*Config, error) {
 	// 加载.env文件
 	_ = godotenv.Load()
 
-	// 获取JWT过期时间，默认72小时
-	jwtExpiryHours := 72
-	if os.Getenv("JWT_EXPIRY_HOURS") != "" {
-		val, err := strconv.Atoi(os.Getenv("JWT_EXPIRY_HOURS"))
-		if err == nil {
-			jwtExpiryHours = val
+	// 获取令牌有效期，访问令牌默认15分钟，刷新令牌默认30天
+	accessTokenMinutes := 15
+	if os.Getenv("ACCESS_TOKEN_MINUTES") != "" {
+		val, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_MINUTES"))
+		if err == nil && val > 0 {
+			accessTokenMinutes = val
+		}
+	}
+
+	refreshTokenDays := 30
+	if os.Getenv("REFRESH_TOKEN_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_DAYS"))
+		if err == nil && val > 0 {
+			refreshTokenDays = val
 		}
 	}
 
@@ -51,6 +123,261 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	// 获取数据库锁等待时间，默认5000毫秒
+	dbBusyTimeoutMs := 5000
+	if os.Getenv("DB_BUSY_TIMEOUT_MS") != "" {
+		val, err := strconv.Atoi(os.Getenv("DB_BUSY_TIMEOUT_MS"))
+		if err == nil && val >= 0 {
+			dbBusyTimeoutMs = val
+		}
+	}
+
+	// 获取副本登录号配置，默认格式为 LIB + 8位序号 + Luhn校验位，前缀为最长6个字符的字母或数字
+	accessionPrefix := "LIB"
+	if os.Getenv("ACCESSION_PREFIX") != "" {
+		val := strings.ToUpper(strings.TrimSpace(os.Getenv("ACCESSION_PREFIX")))
+		if len(val) <= 6 && strings.Trim(val, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") == "" {
+			accessionPrefix = val
+		}
+	}
+
+	accessionDigits := 8
+	if os.Getenv("ACCESSION_DIGITS") != "" {
+		val, err := strconv.Atoi(os.Getenv("ACCESSION_DIGITS"))
+		if err == nil && val >= 4 && val <= 12 {
+			accessionDigits = val
+		}
+	}
+
+	accessionCheckDigit := "luhn"
+	if os.Getenv("ACCESSION_CHECK_DIGIT") != "" {
+		val := strings.ToLower(os.Getenv("ACCESSION_CHECK_DIGIT"))
+		if val == "luhn" || val == "none" {
+			accessionCheckDigit = val
+		}
+	}
+
+	// 获取批量导入参数，默认每100行一个事务，上传文件最大20MB
+	importBatchSize := 100
+	if os.Getenv("IMPORT_BATCH_SIZE") != "" {
+		val, err := strconv.Atoi(os.Getenv("IMPORT_BATCH_SIZE"))
+		if err == nil && val > 0 {
+			importBatchSize = val
+		}
+	}
+
+	importMaxBytes := int64(20 << 20)
+	if os.Getenv("IMPORT_MAX_BYTES") != "" {
+		val, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64)
+		if err == nil && val > 0 {
+			importMaxBytes = val
+		}
+	}
+
+	// 获取 OAI-PMH 仓储信息，基础地址未配置时根据请求地址生成，每页默认100条记录
+	oaiRepositoryName := "Library API"
+	if os.Getenv("OAI_REPOSITORY_NAME") != "" {
+		oaiRepositoryName = os.Getenv("OAI_REPOSITORY_NAME")
+	}
+
+	oaiRepositoryIdentifier := "library.example.org"
+	if os.Getenv("OAI_REPOSITORY_IDENTIFIER") != "" {
+		oaiRepositoryIdentifier = strings.TrimSpace(os.Getenv("OAI_REPOSITORY_IDENTIFIER"))
+	}
+
+	oaiAdminEmail := "admin@example.com"
+	if os.Getenv("OAI_ADMIN_EMAIL") != "" {
+		oaiAdminEmail = os.Getenv("OAI_ADMIN_EMAIL")
+	}
+
+	oaiBaseURL := strings.TrimRight(os.Getenv("OAI_BASE_URL"), "/")
+
+	oaiPageSize := 100
+	if os.Getenv("OAI_PAGE_SIZE") != "" {
+		val, err := strconv.Atoi(os.Getenv("OAI_PAGE_SIZE"))
+		if err == nil && val > 0 {
+			oaiPageSize = val
+		}
+	}
+
+	// 获取书目数据源配置，按顺序查询，默认只使用 Open Library；设为 none 关闭查询
+	metadataProviders := []string{"openlibrary"}
+	if os.Getenv("METADATA_PROVIDERS") != "" {
+		metadataProviders = nil
+		for _, name := range strings.Split(os.Getenv("METADATA_PROVIDERS"), ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && name != "none" {
+				metadataProviders = append(metadataProviders, name)
+			}
+		}
+	}
+
+	metadataOpenLibraryURL := "https://openlibrary.org"
+	if os.Getenv("METADATA_OPENLIBRARY_URL") != "" {
+		metadataOpenLibraryURL = os.Getenv("METADATA_OPENLIBRARY_URL")
+	}
+
+	metadataFilePath := os.Getenv("METADATA_FILE_PATH")
+
+	// 获取外部查询超时和缓存时间，默认超时10秒，缓存168小时（7天），0表示不缓存
+	metadataTimeoutSeconds := 10
+	if os.Getenv("METADATA_TIMEOUT_SECONDS") != "" {
+		val, err := strconv.Atoi(os.Getenv("METADATA_TIMEOUT_SECONDS"))
+		if err == nil && val > 0 {
+			metadataTimeoutSeconds = val
+		}
+	}
+
+	metadataCacheTTLHours := 168
+	if os.Getenv("METADATA_CACHE_TTL_HOURS") != "" {
+		val, err := strconv.Atoi(os.Getenv("METADATA_CACHE_TTL_HOURS"))
+		if err == nil && val >= 0 {
+			metadataCacheTTLHours = val
+		}
+	}
+
+	// 获取封面存储配置，默认保存在本地 ./uploads 目录，单个文件最大5MB
+	coverStorage := "local"
+	if os.Getenv("COVER_STORAGE") != "" {
+		coverStorage = strings.ToLower(strings.TrimSpace(os.Getenv("COVER_STORAGE")))
+	}
+
+	coverLocalDir := "./uploads"
+	if os.Getenv("COVER_LOCAL_DIR") != "" {
+		coverLocalDir = os.Getenv("COVER_LOCAL_DIR")
+	}
+
+	// 封面访问地址为空时，本地存储使用 /uploads，S3 存储使用 endpoint/bucket
+	coverPublicURL := strings.TrimRight(os.Getenv("COVER_PUBLIC_URL"), "/")
+
+	coverMaxBytes := int64(5 << 20)
+	if os.Getenv("COVER_MAX_BYTES") != "" {
+		val, err := strconv.ParseInt(os.Getenv("COVER_MAX_BYTES"), 10, 64)
+		if err == nil && val > 0 {
+			coverMaxBytes = val
+		}
+	}
+
+	// 获取 S3 兼容存储配置，COVER_STORAGE=s3 时必须设置地址、存储桶和密钥
+	coverS3Endpoint := strings.TrimRight(os.Getenv("COVER_S3_ENDPOINT"), "/")
+	coverS3Bucket := os.Getenv("COVER_S3_BUCKET")
+	coverS3AccessKey := os.Getenv("COVER_S3_ACCESS_KEY")
+	coverS3SecretKey := os.Getenv("COVER_S3_SECRET_KEY")
+
+	coverS3Region := "us-east-1"
+	if os.Getenv("COVER_S3_REGION") != "" {
+		coverS3Region = os.Getenv("COVER_S3_REGION")
+	}
+
+	// 获取预约取书期限，默认7天
+	holdPickupDays := 7
+	if os.Getenv("HOLD_PICKUP_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("HOLD_PICKUP_DAYS"))
+		if err == nil && val > 0 {
+			holdPickupDays = val
+		}
+	}
+
+	// 获取默认借阅规则，未在管理端配置借阅规则时使用：默认借期14天，最长30天，最多同时借5本
+	defaultLoanDays := 14
+	if os.Getenv("DEFAULT_LOAN_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("DEFAULT_LOAN_DAYS"))
+		if err == nil && val > 0 {
+			defaultLoanDays = val
+		}
+	}
+
+	maxLoanDays := 30
+	if os.Getenv("MAX_LOAN_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("MAX_LOAN_DAYS"))
+		if err == nil && val > 0 {
+			maxLoanDays = val
+		}
+	}
+
+	maxBorrows := 5
+	if os.Getenv("MAX_BORROWS") != "" {
+		val, err := strconv.Atoi(os.Getenv("MAX_BORROWS"))
+		if err == nil && val >= 0 {
+			maxBorrows = val
+		}
+	}
+
+	// 获取续借配置，默认最多续借2次，每次14天，逾期3天内仍可续借
+	maxRenewals := 2
+	if os.Getenv("MAX_RENEWALS") != "" {
+		val, err := strconv.Atoi(os.Getenv("MAX_RENEWALS"))
+		if err == nil && val >= 0 {
+			maxRenewals = val
+		}
+	}
+
+	renewalDays := 14
+	if os.Getenv("RENEWAL_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("RENEWAL_DAYS"))
+		if err == nil && val > 0 {
+			renewalDays = val
+		}
+	}
+
+	renewalGraceDays := 3
+	if os.Getenv("RENEWAL_GRACE_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("RENEWAL_GRACE_DAYS"))
+		if err == nil && val >= 0 {
+			renewalGraceDays = val
+		}
+	}
+
+	// 获取逾期处理配置，默认逾期60天视为丢失，每60分钟检查一次
+	lostAfterDays := 60
+	if os.Getenv("LOST_AFTER_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("LOST_AFTER_DAYS"))
+		if err == nil && val > 0 {
+			lostAfterDays = val
+		}
+	}
+
+	overdueJobIntervalMinutes := 60
+	if os.Getenv("OVERDUE_JOB_INTERVAL_MINUTES") != "" {
+		val, err := strconv.Atoi(os.Getenv("OVERDUE_JOB_INTERVAL_MINUTES"))
+		if err == nil && val > 0 {
+			overdueJobIntervalMinutes = val
+		}
+	}
+
+	// 获取罚款配置（单位：分），默认每天0.5元，宽限1天，单次最多20元，丢失赔偿50元
+	fineDailyRateCents := int64(50)
+	if os.Getenv("FINE_DAILY_RATE_CENTS") != "" {
+		val, err := strconv.ParseInt(os.Getenv("FINE_DAILY_RATE_CENTS"), 10, 64)
+		if err == nil && val >= 0 {
+			fineDailyRateCents = val
+		}
+	}
+
+	fineGraceDays := 1
+	if os.Getenv("FINE_GRACE_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("FINE_GRACE_DAYS"))
+		if err == nil && val >= 0 {
+			fineGraceDays = val
+		}
+	}
+
+	fineMaxCents := int64(2000)
+	if os.Getenv("FINE_MAX_CENTS") != "" {
+		val, err := strconv.ParseInt(os.Getenv("FINE_MAX_CENTS"), 10, 64)
+		if err == nil && val >= 0 {
+			fineMaxCents = val
+		}
+	}
+
+	lostReplacementFeeCents := int64(5000)
+	if os.Getenv("LOST_REPLACEMENT_FEE_CENTS") != "" {
+		val, err := strconv.ParseInt(os.Getenv("LOST_REPLACEMENT_FEE_CENTS"), 10, 64)
+		if err == nil && val >= 0 {
+			lostReplacementFeeCents = val
+		}
+	}
+
 	// 获取服务器端口，默认8080
 	serverPort := "8080"
 	if os.Getenv("SERVER_PORT") != "" {
@@ -63,18 +390,169 @@ func LoadConfig() (*Config, error) {
 		dbPath = os.Getenv("DB_PATH")
 	}
 
-	// 获取JWT密钥，必须在环境变量中设置
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "default-dev-secret-change-in-production"
+	// 获取JWT签名密钥配置，默认在./keys中生成Ed25519密钥，每30天轮换，新旧密钥重叠24小时
+	jwtKeyDir := "./keys"
+	if os.Getenv("JWT_KEY_DIR") != "" {
+		jwtKeyDir = os.Getenv("JWT_KEY_DIR")
+	}
+
+	jwtKeyAlgorithm := "EdDSA"
+	if os.Getenv("JWT_KEY_ALGORITHM") != "" {
+		jwtKeyAlgorithm = os.Getenv("JWT_KEY_ALGORITHM")
+	}
+
+	jwtKeyRotationDays := 30
+	if os.Getenv("JWT_KEY_ROTATION_DAYS") != "" {
+		val, err := strconv.Atoi(os.Getenv("JWT_KEY_ROTATION_DAYS"))
+		if err == nil && val >= 0 {
+			jwtKeyRotationDays = val
+		}
+	}
+
+	jwtKeyOverlapHours := 24
+	if os.Getenv("JWT_KEY_OVERLAP_HOURS") != "" {
+		val, err := strconv.Atoi(os.Getenv("JWT_KEY_OVERLAP_HOURS"))
+		if err == nil && val >= 0 {
+			jwtKeyOverlapHours = val
+		}
+	}
+
+	// 获取第三方登录配置：GOOGLE_* 配置 Google 登录，未设置客户端ID时不启用；
+	// OIDC_PROVIDERS 列出其他身份提供方的名称，每个提供方通过 OIDC_{NAME}_* 配置
+	var oidcProviders []OIDCProvider
+	if os.Getenv("GOOGLE_CLIENT_ID") != "" {
+		google := loadOIDCProvider("google", serverPort)
+		google.DisplayName = "Google"
+		google.IssuerURL = "https://accounts.google.com"
+		google.ClientID = os.Getenv("GOOGLE_CLIENT_ID")
+		google.ClientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
+		if os.Getenv("GOOGLE_REDIRECT_URI") != "" {
+			google.RedirectURI = os.Getenv("GOOGLE_REDIRECT_URI")
+		}
+		// 签发方地址用于获取发现文档，本地开发时可指向模拟身份提供方
+		if os.Getenv("GOOGLE_ISSUER_URL") != "" {
+			google.IssuerURL = strings.TrimRight(os.Getenv("GOOGLE_ISSUER_URL"), "/")
+		}
+		oidcProviders = append(oidcProviders, google)
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			continue
+		}
+		provider := loadOIDCProvider(name, serverPort)
+		replaced := false
+		for i := range oidcProviders {
+			if oidcProviders[i].Name == name {
+				oidcProviders[i] = provider
+				replaced = true
+			}
+		}
+		if !replaced {
+			oidcProviders = append(oidcProviders, provider)
+		}
+	}
+
+	// 第三方登录完成后重定向到的前端页面，令牌或错误码放在 URL 片段中
+	oauthFrontendURL := "http://localhost:3000/oauth/callback"
+	if os.Getenv("OAUTH_FRONTEND_URL") != "" {
+		oauthFrontendURL = os.Getenv("OAUTH_FRONTEND_URL")
 	}
 
 	return &Config{
-		ServerPort:     serverPort,
-		DBPath:         dbPath,
-		JWTSecret:      jwtSecret,
-		JWTExpiryHours: jwtExpiryHours,
-		RateLimitRPS:   rateLimitRPS,
-		RateLimitBurst: rateLimitBurst,
+		ServerPort:                serverPort,
+		DBPath:                    dbPath,
+		JWTKeyDir:                 jwtKeyDir,
+		JWTKeyAlgorithm:           jwtKeyAlgorithm,
+		JWTKeyRotationDays:        jwtKeyRotationDays,
+		JWTKeyOverlapHours:        jwtKeyOverlapHours,
+		AccessTokenMinutes:        accessTokenMinutes,
+		RefreshTokenDays:          refreshTokenDays,
+		RateLimitRPS:              rateLimitRPS,
+		RateLimitBurst:            rateLimitBurst,
+		OIDCProviders:             oidcProviders,
+		OAuthFrontendURL:          oauthFrontendURL,
+		HoldPickupDays:            holdPickupDays,
+		MaxRenewals:               maxRenewals,
+		RenewalDays:               renewalDays,
+		RenewalGraceDays:          renewalGraceDays,
+		LostAfterDays:             lostAfterDays,
+		OverdueJobIntervalMinutes: overdueJobIntervalMinutes,
+		FineDailyRateCents:        fineDailyRateCents,
+		FineGraceDays:             fineGraceDays,
+		FineMaxCents:              fineMaxCents,
+		LostReplacementFeeCents:   lostReplacementFeeCents,
+		DefaultLoanDays:           defaultLoanDays,
+		MaxLoanDays:               maxLoanDays,
+		MaxBorrows:                maxBorrows,
+		DBBusyTimeoutMs:           dbBusyTimeoutMs,
+		AccessionPrefix:           accessionPrefix,
+		AccessionDigits:           accessionDigits,
+		AccessionCheckDigit:       accessionCheckDigit,
+		ImportBatchSize:           importBatchSize,
+		ImportMaxBytes:            importMaxBytes,
+		OAIRepositoryName:         oaiRepositoryName,
+		OAIRepositoryIdentifier:   oaiRepositoryIdentifier,
+		OAIAdminEmail:             oaiAdminEmail,
+		OAIBaseURL:                oaiBaseURL,
+		OAIPageSize:               oaiPageSize,
+		MetadataProviders:         metadataProviders,
+		MetadataOpenLibraryURL:    metadataOpenLibraryURL,
+		MetadataFilePath:          metadataFilePath,
+		MetadataTimeoutSeconds:    metadataTimeoutSeconds,
+		MetadataCacheTTLHours:     metadataCacheTTLHours,
+		CoverStorage:              coverStorage,
+		CoverLocalDir:             coverLocalDir,
+		CoverPublicURL:            coverPublicURL,
+		CoverMaxBytes:             coverMaxBytes,
+		CoverS3Endpoint:           coverS3Endpoint,
+		CoverS3Region:             coverS3Region,
+		CoverS3Bucket:             coverS3Bucket,
+		CoverS3AccessKey:          coverS3AccessKey,
+		CoverS3SecretKey:          coverS3SecretKey,
 	}, nil
 }
+
+// loadOIDCProvider 读取 OIDC_{NAME}_* 环境变量，名称中的连字符替换为下划线。
+// 默认回调地址为本机的 /auth/{name}/callback，默认请求 openid、email、profile 范围并使用标准声明
+func loadOIDCProvider(name, serverPort string) OIDCProvider {
+	prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	env := func(key, fallback string) string {
+		if os.Getenv(prefix+key) != "" {
+			return strings.TrimSpace(os.Getenv(prefix + key))
+		}
+		return fallback
+	}
+
+	scopes := strings.Fields(strings.ReplaceAll(env("SCOPES", "openid email profile"), ",", " "))
+	hasOpenID := false
+	for _, scope := range scopes {
+		if scope == "openid" {
+			hasOpenID = true
+		}
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	trustEmail, _ := strconv.ParseBool(env("TRUST_EMAIL", "false"))
+
+	return OIDCProvider{
+		Name:         name,
+		DisplayName:  env("DISPLAY_NAME", name),
+		IssuerURL:    strings.TrimRight(env("ISSUER_URL", ""), "/"),
+		ClientID:     env("CLIENT_ID", ""),
+		ClientSecret: env("CLIENT_SECRET", ""),
+		RedirectURI:  env("REDIRECT_URI", "http://localhost:"+serverPort+"/auth/"+name+"/callback"),
+		Scopes:       scopes,
+		Claims: ClaimMapping{
+			Subject:       env("SUBJECT_CLAIM", "sub"),
+			Email:         env("EMAIL_CLAIM", "email"),
+			EmailVerified: env("EMAIL_VERIFIED_CLAIM", "email_verified"),
+			Name:          env("NAME_CLAIM", "name"),
+			Username:      env("USERNAME_CLAIM", "preferred_username"),
+		},
+		TrustEmail: trustEmail,
+	}
+}
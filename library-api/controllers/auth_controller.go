@@ -1,118 +1,512 @@
 package controllers
 
 import (
-	"context"
-	"encoding/json"
-	"io"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
-	"github.com/example/library-api/config"
 	"github.com/example/library-api/database"
-	"github.com/example/library-api/middleware"
 	"github.com/example/library-api/models"
-	"github.com/gin-gonic/gin"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"github.com/example/library-api/oidc"
+	"github.com/example/library-api/sessions"
 )
 
-var googleOauthConfig *oauth2.Config
+// oauthFlowCookie 保存登录流程状态的 cookie 名称
+const oauthFlowCookie = "oauth_flow"
 
-func init() {
-	// 修复：添加配置加载错误处理
-	cfg, err := config.LoadConfig()
+// 重定向到前端时的错误码
+const (
+	oauthErrInvalidState     = "invalid_state"
+	oauthErrAccessDenied     = "access_denied"
+	oauthErrProvider         = "provider_error"
+	oauthErrLinkRequired     = "link_required"
+	oauthErrEmailNotVerified = "email_not_verified"
+	oauthErrServer           = "server_error"
+)
+
+// LinkAccountRequest 关联第三方账号请求结构
+type LinkAccountRequest struct {
+	LinkToken string `json:"link_token" binding:"required"`
+	Password  string `json:"password" binding:"required"`
+}
+
+// AuthProviderResponse 可用的第三方登录方式
+type AuthProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
+
+// GetAuthProviders 获取已配置的第三方登录方式，供前端显示登录按钮
+func GetAuthProviders(c *gin.Context) {
+	list := []AuthProviderResponse{}
+	for _, p := range oidc.Providers() {
+		list = append(list, AuthProviderResponse{
+			Name:        p.Name,
+			DisplayName: p.DisplayName,
+			LoginURL:    "/auth/" + p.Name + "/login",
+		})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// OAuthLogin 生成登录流程状态并写入签名 cookie，然后重定向到身份提供方的登录页面
+func OAuthLogin(c *gin.Context) {
+	name := c.Param("provider")
+	provider, err := oidc.Get(name)
 	if err != nil {
-		log.Fatalf("加载配置失败: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "login provider not configured"})
+		return
 	}
 
-	googleOauthConfig = &oauth2.Config{
-		ClientID:     cfg.GoogleClientID,
-		ClientSecret: cfg.GoogleClientSecret,
-		RedirectURL:  cfg.GoogleRedirectURI,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
+	flow, err := oidc.NewFlow(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+	sealed, err := flow.Seal(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), flow)
+	if err != nil {
+		log.Printf("%s 登录初始化失败: %v", name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "login provider unavailable"})
+		return
 	}
-}
 
-// GoogleLogin 重定向到Google登录页面
-func GoogleLogin(c *gin.Context) {
-	url := googleOauthConfig.AuthCodeURL("random-state")
-	c.Redirect(http.StatusTemporaryRedirect, url)
+	setFlowCookie(c, provider, sealed, int(oidc.FlowTTL/time.Second))
+	c.Redirect(http.StatusFound, authURL)
 }
 
-// GoogleLoginCallback 处理Google登录回调
-func GoogleLoginCallback(c *gin.Context) {
-	code := c.Query("code")
-	token, err := googleOauthConfig.Exchange(context.Background(), code)
+// OAuthCallback 处理身份提供方的登录回调：验证 state、换取并验证 ID 令牌，
+// 按外部身份登录或创建用户后重定向到前端。邮箱已被其他账号使用时不自动合并，返回关联令牌由用户输入密码确认
+func OAuthCallback(c *gin.Context) {
+	name := c.Param("provider")
+	provider, err := oidc.Get(name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange token: " + err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": "login provider not configured"})
 		return
 	}
 
-	// 修复：正确初始化OAuth2服务
-	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(token))
-	reqRes, err := client.Get("https://www.googleapis.com/oauth2/v3/userinfo")
+	// 登录流程状态只能使用一次
+	sealed, _ := c.Cookie(oauthFlowCookie)
+	setFlowCookie(c, provider, "", -1)
+
+	flow, err := oidc.OpenFlow(sealed, name, c.Query("state"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info: " + err.Error()})
+		redirectOAuthError(c, oauthErrInvalidState, nil)
 		return
 	}
-	// 修复：解析用户信息
-	userInfoBytes, err := io.ReadAll(reqRes.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read user info: " + err.Error()})
+
+	// 用户拒绝授权或身份提供方返回错误
+	if e := c.Query("error"); e != "" {
+		if e == oauthErrAccessDenied {
+			redirectOAuthError(c, oauthErrAccessDenied, nil)
+		} else {
+			redirectOAuthError(c, oauthErrProvider, nil)
+		}
 		return
 	}
-	// 定义临时的用户信息结构体，用于解析Google返回的用户信息
-	type UserInfo struct {
-		Id    string `json:"sub"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
+	code := c.Query("code")
+	if code == "" {
+		redirectOAuthError(c, oauthErrProvider, nil)
+		return
 	}
-	var userInfo UserInfo
-	if err := json.Unmarshal(userInfoBytes, &userInfo); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user info: " + err.Error()})
+
+	identity, err := provider.Exchange(c.Request.Context(), flow, code)
+	if err != nil {
+		log.Printf("%s 登录失败: %v", name, err)
+		redirectOAuthError(c, oauthErrProvider, nil)
 		return
 	}
 
-	// 查找或创建用户
+	now := time.Now()
 	var user models.User
-	result := database.DB.Where("google_id = ? OR email = ?", userInfo.Id, userInfo.Email).First(&user)
-
-	if result.Error != nil {
-		// 创建新用户
-		user = models.User{
-			Username: userInfo.Name,
-			Email:    userInfo.Email,
-			GoogleID: userInfo.Id,
-			Role:     models.RoleUser,
-		}
-		// 修复：添加数据库错误处理
-		if err := database.DB.Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + err.Error()})
+	var linked models.UserIdentity
+	err = database.DB.Where("provider = ? AND subject = ?", name, identity.Subject).First(&linked).Error
+	switch {
+	case err == nil:
+		// 已关联的外部身份，记录最近登录
+		if err := database.DB.First(&user, linked.UserID).Error; err != nil {
+			redirectOAuthError(c, oauthErrServer, nil)
+			return
+		}
+		database.DB.Model(&linked).Updates(map[string]interface{}{"email": identity.Email, "last_login_at": now})
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// 只接受身份提供方验证过的邮箱，否则可以用他人邮箱注册外部账号来冒领本地账号
+		if identity.Email == "" || !identity.EmailVerified {
+			redirectOAuthError(c, oauthErrEmailNotVerified, nil)
+			return
+		}
+
+		// 同一邮箱已有账号时需要用户输入密码确认关联
+		var existing models.User
+		err = database.DB.Where("email = ?", identity.Email).First(&existing).Error
+		if err == nil {
+			linkToken, err := oidc.SealLink(identity, now)
+			if err != nil {
+				redirectOAuthError(c, oauthErrServer, nil)
+				return
+			}
+			redirectOAuthError(c, oauthErrLinkRequired, url.Values{
+				"link_token": {linkToken},
+				"email":      {identity.Email},
+			})
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			redirectOAuthError(c, oauthErrServer, nil)
+			return
+		}
+
+		// 创建没有本地密码的新用户并关联外部身份
+		username, err := uniqueUsername(identity)
+		if err != nil {
+			redirectOAuthError(c, oauthErrServer, nil)
 			return
 		}
+		user, err = createOAuthUser(identity, username, now)
+		if err != nil {
+			log.Printf("%s 登录创建用户失败: %v", name, err)
+			redirectOAuthError(c, oauthErrServer, nil)
+			return
+		}
+
+	default:
+		redirectOAuthError(c, oauthErrServer, nil)
+		return
 	}
-	// 加载配置
-	cfg, err := config.LoadConfig()
 
-	// 生成JWT令牌
-	expirationTime := time.Now().Add(time.Duration(cfg.JWTExpiryHours) * time.Hour)
-	jwtToken, err := middleware.GenerateToken(user.ID, user.Role)
+	// 创建登录会话并签发令牌
+	tokens, err := sessions.Start(database.DB, &user, sessionClient(c), now)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token: " + err.Error()})
+		redirectOAuthError(c, oauthErrServer, nil)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":      jwtToken,
-		"user_id":    user.ID,
-		"username":   user.Username,
-		"email":      user.Email,
-		"role":       user.Role,
-		"expires_at": expirationTime,
+	// 令牌放在 URL 片段中，不会发送到前端服务器，也不会出现在访问日志和 Referer 中
+	redirectFrontend(c, url.Values{
+		"token":              {tokens.Token},
+		"expires_at":         {strconv.FormatInt(tokens.ExpiresAt, 10)},
+		"refresh_token":      {tokens.RefreshToken},
+		"refresh_expires_at": {strconv.FormatInt(tokens.RefreshExpiresAt, 10)},
 	})
 }
+
+// LinkOAuthAccount 用密码确认后将外部身份关联到同一邮箱的已有账号并登录
+func LinkOAuthAccount(c *gin.Context) {
+	var req LinkAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identity, err := oidc.OpenLink(req.LinkToken)
+	if err != nil || identity.Provider != c.Param("provider") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired link token"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", identity.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired link token"})
+		return
+	}
+
+	// 验证密码，只有账号所有者才能关联
+	if user.Password == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+		return
+	}
+
+	now := time.Now()
+	if status, err := linkIdentity(&user, identity, now); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 创建登录会话并签发令牌
+	tokens, err := sessions.Start(database.DB, &user, sessionClient(c), now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Tokens:   *tokens,
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	})
+}
+
+// GetMyIdentities 获取当前用户关联的外部身份
+func GetMyIdentities(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	list := []models.UserIdentity{}
+	if err := database.DB.Where("user_id = ?", userID).Order("id").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// LinkIdentityRequest 已登录用户关联外部身份请求结构
+type LinkIdentityRequest struct {
+	LinkToken string `json:"link_token" binding:"required"`
+}
+
+// LinkMyIdentity 已登录用户关联外部身份。第三方登录返回 link_required 时，
+// 已用其他方式登录的用户（包括没有本地密码的用户）可以用关联令牌代替密码确认
+func LinkMyIdentity(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req LinkIdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identity, err := oidc.OpenLink(req.LinkToken)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired link token"})
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, userID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if status, err := linkIdentity(&user, identity, time.Now()); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	var linked models.UserIdentity
+	database.DB.Where("user_id = ? AND provider = ?", user.ID, identity.Provider).First(&linked)
+	c.JSON(http.StatusOK, linked)
+}
+
+// UnlinkMyIdentity 取消关联当前用户的一个外部身份。没有本地密码的用户不能移除最后一个外部身份
+func UnlinkMyIdentity(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, userID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var linked models.UserIdentity
+	if result := tx.Where("user_id = ?", user.ID).First(&linked, c.Param("id")); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "identity not found"})
+		return
+	}
+
+	if user.Password == "" {
+		var count int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlink identity"})
+			return
+		}
+		if count <= 1 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "cannot remove the only sign-in method"})
+			return
+		}
+	}
+
+	if err := tx.Delete(&linked).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlink identity"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlink identity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "identity unlinked successfully"})
+}
+
+// linkIdentity 将外部身份关联到用户，已关联到该用户时不做修改。同一外部账号只能关联一个用户，
+// 每个用户在同一身份提供方只能关联一个账号，冲突时返回 409
+func linkIdentity(user *models.User, identity *oidc.Identity, now time.Time) (int, error) {
+	var existing models.UserIdentity
+	err := database.DB.Where("provider = ? AND (subject = ? OR user_id = ?)", identity.Provider, identity.Subject, user.ID).
+		First(&existing).Error
+	if err == nil {
+		if existing.UserID != user.ID {
+			return http.StatusConflict, errors.New("external account is already linked to another user")
+		}
+		if existing.Subject != identity.Subject {
+			return http.StatusConflict, errors.New("account is already linked to another account of this provider")
+		}
+		return http.StatusOK, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusInternalServerError, errors.New("failed to link account")
+	}
+
+	linked := models.UserIdentity{
+		UserID:      user.ID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}
+	if err := database.DB.Create(&linked).Error; err != nil {
+		if database.IsUniqueViolation(err) {
+			return http.StatusConflict, errors.New("external account is already linked")
+		}
+		return http.StatusInternalServerError, errors.New("failed to link account")
+	}
+	return http.StatusOK, nil
+}
+
+// createOAuthUser 创建没有本地密码的用户并关联外部身份
+func createOAuthUser(identity *oidc.Identity, username string, now time.Time) (models.User, error) {
+	user := models.User{
+		Username: username,
+		Email:    identity.Email,
+		Role:     models.RoleUser,
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		return user, err
+	}
+	linked := models.UserIdentity{
+		UserID:      user.ID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}
+	if err := tx.Create(&linked).Error; err != nil {
+		tx.Rollback()
+		return user, err
+	}
+
+	// 提交事务
+	return user, tx.Commit().Error
+}
+
+// setFlowCookie 写入或清除登录流程 cookie，cookie 只发送到该身份提供方的 /auth/{name}/ 路径。
+// 回调地址为 HTTPS 时只通过 HTTPS 发送，SameSite=Lax 允许身份提供方重定向回来时携带
+func setFlowCookie(c *gin.Context, provider *oidc.Provider, value string, maxAge int) {
+	secure := strings.HasPrefix(provider.RedirectURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthFlowCookie, value, maxAge, "/auth/"+provider.Name+"/", "", secure, true)
+}
+
+// redirectOAuthError 带错误码重定向到前端
+func redirectOAuthError(c *gin.Context, code string, extra url.Values) {
+	values := url.Values{"error": {code}}
+	for k, v := range extra {
+		values[k] = v
+	}
+	redirectFrontend(c, values)
+}
+
+// redirectFrontend 重定向到前端登录回调页面，参数放在 URL 片段中
+func redirectFrontend(c *gin.Context, values url.Values) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Redirect(http.StatusFound, oidc.FrontendURL()+"#"+values.Encode())
+}
+
+// uniqueUsername 根据身份提供方的用户名、姓名或邮箱前缀生成未被使用的用户名
+func uniqueUsername(identity *oidc.Identity) (string, error) {
+	base := sanitizeUsername(identity.Username)
+	if len(base) < 3 {
+		base = sanitizeUsername(identity.Name)
+	}
+	if len(base) < 3 {
+		base = sanitizeUsername(strings.SplitN(identity.Email, "@", 2)[0])
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	for i := 0; i < 100; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = base + strconv.Itoa(i+1)
+		}
+		var count int64
+		if err := database.DB.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return base + strconv.FormatInt(time.Now().UnixNano()%1000000, 10), nil
+}
+
+// sanitizeUsername 只保留字母、数字、下划线、点和连字符，空白替换为下划线
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)), r == '_', r == '.', r == '-':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
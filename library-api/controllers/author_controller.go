@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// AuthorRequest 作者创建/更新请求结构
+type AuthorRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Bio  string `json:"bio"`
+}
+
+// MergeAuthorsRequest 合并作者请求结构
+type MergeAuthorsRequest struct {
+	SourceID uint `json:"source_id" binding:"required"`
+}
+
+// CreateAuthor 添加新作者
+func CreateAuthor(c *gin.Context) {
+	var req AuthorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	author := models.Author{
+		Name: req.Name,
+		Bio:  req.Bio,
+	}
+
+	if err := database.DB.Create(&author).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create author"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, author)
+}
+
+// GetAuthors 获取作者列表，支持按姓名搜索和分页
+func GetAuthors(c *gin.Context) {
+	p := parsePagination(c)
+
+	query := database.DB.Model(&models.Author{})
+	if q := c.Query("q"); q != "" {
+		query = query.Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(q)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count authors"})
+		return
+	}
+
+	var authors []models.Author
+	if err := query.Order("name ASC, id ASC").Offset(p.Offset()).Limit(p.Limit).Find(&authors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch authors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(authors, total, p))
+}
+
+// GetAuthor 获取单个作者详情
+func GetAuthor(c *gin.Context) {
+	id := c.Param("id")
+
+	var author models.Author
+	if result := database.DB.First(&author, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, author)
+}
+
+// GetAuthorBooks 获取作者的著作列表
+func GetAuthorBooks(c *gin.Context) {
+	id := c.Param("id")
+	p := parsePagination(c)
+
+	var author models.Author
+	if result := database.DB.First(&author, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	}
+
+	query := database.DB.Model(&models.Book{}).
+		Joins("JOIN book_authors ON book_authors.book_id = books.id").
+		Where("book_authors.author_id = ?", author.ID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count books"})
+		return
+	}
+
+	var books []models.Book
+	if err := query.Preload("Authors").
+		Order("books.publication_date ASC, books.id ASC").
+		Offset(p.Offset()).Limit(p.Limit).
+		Find(&books).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch books"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(books, total, p))
+}
+
+// UpdateAuthor 更新作者信息
+func UpdateAuthor(c *gin.Context) {
+	id := c.Param("id")
+	var req AuthorRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var author models.Author
+	if result := database.DB.First(&author, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	}
+
+	renamed := author.Name != req.Name
+	author.Name = req.Name
+	author.Bio = req.Bio
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&author).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update author"})
+		return
+	}
+
+	if renamed {
+		if err := touchAuthorBooks(tx, author.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update author books"})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, author)
+}
+
+// touchAuthorBooks 更新作者关联图书的修改时间，使 OAI-PMH 增量采集能取到变更后的作者
+func touchAuthorBooks(tx *gorm.DB, authorID uint) error {
+	return tx.Model(&models.Book{}).
+		Where("id IN (?)", tx.Model(&models.BookAuthor{}).Select("book_id").Where("author_id = ?", authorID)).
+		Update("updated_at", time.Now()).Error
+}
+
+// DeleteAuthor 删除作者
+func DeleteAuthor(c *gin.Context) {
+	id := c.Param("id")
+
+	var author models.Author
+	if result := database.DB.First(&author, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	}
+
+	// 仍关联图书的作者不允许删除，应先合并或修改图书
+	var bookCount int64
+	if err := database.DB.Model(&models.BookAuthor{}).Where("author_id = ?", author.ID).Count(&bookCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check author books"})
+		return
+	}
+
+	if bookCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete author linked to books, merge the author instead"})
+		return
+	}
+
+	if err := database.DB.Delete(&author).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete author"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "author deleted successfully"})
+}
+
+// MergeAuthors 将重复作者合并到规范作者，所有图书关联改为指向规范作者
+func MergeAuthors(c *gin.Context) {
+	id := c.Param("id")
+	var req MergeAuthorsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var target models.Author
+	if result := database.DB.First(&target, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+		return
+	}
+
+	if req.SourceID == target.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot merge an author into itself"})
+		return
+	}
+
+	var source models.Author
+	if result := database.DB.First(&source, req.SourceID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "source author not found"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 重复作者的图书作者发生变化
+	if err := touchAuthorBooks(tx, source.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update author books"})
+		return
+	}
+
+	// 删除两位作者都关联的图书中重复作者的关联，避免主键冲突
+	if err := tx.Where("author_id = ? AND book_id IN (?)", source.ID,
+		tx.Model(&models.BookAuthor{}).Select("book_id").Where("author_id = ?", target.ID)).
+		Delete(&models.BookAuthor{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove duplicate book links"})
+		return
+	}
+
+	// 其余关联改为指向规范作者
+	moved := tx.Model(&models.BookAuthor{}).Where("author_id = ?", source.ID).Update("author_id", target.ID)
+	if moved.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to re-point book links"})
+		return
+	}
+
+	// 规范作者没有简介时沿用重复作者的简介
+	if target.Bio == "" && source.Bio != "" {
+		target.Bio = source.Bio
+		if err := tx.Save(&target).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update author"})
+			return
+		}
+	}
+
+	if err := tx.Delete(&source).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete merged author"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "authors merged successfully",
+		"author":      target,
+		"merged_id":   source.ID,
+		"books_moved": moved.RowsAffected,
+	})
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+func TestGetAuthorsSearchIsLiteral(t *testing.T) {
+	databasetest.Use(t)
+	for _, name := range []string{"100% Pure", "1000 Poets", "Under_Score", "UnderXScore", `Back\slash`} {
+		if err := database.DB.Create(&models.Author{Name: name}).Error; err != nil {
+			t.Fatalf("create author: %v", err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/authors", GetAuthors)
+
+	// % 和 _ 按字面匹配，不作为通配符
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"100%", []string{"100% Pure"}},
+		{"Under_", []string{"Under_Score"}},
+		{`k\s`, []string{`Back\slash`}},
+		{"poet", []string{"1000 Poets"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authors?q="+url.QueryEscape(tt.q), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("q=%q status = %d, body = %s", tt.q, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data []models.Author `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		var got []string
+		for _, a := range resp.Data {
+			got = append(got, a.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("q=%q authors = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,23 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
+	"github.com/example/library-api/accession"
+	"github.com/example/library-api/circulation"
 	"github.com/example/library-api/database"
+	"github.com/example/library-api/isbn"
 	"github.com/example/library-api/models"
+	"github.com/example/library-api/subjects"
 )
 
-// BookRequest 图书创建/更新请求结构
+// BookRequest 图书创建/更新请求结构。更新时省略 CategoryIDs 或 Tags 表示保持不变，传空数组表示清空
 type BookRequest struct {
 	Title           string    `json:"title" binding:"required"`
 	ISBN            string    `json:"isbn" binding:"required"`
@@ -18,18 +25,24 @@ type BookRequest struct {
 	Publisher       string    `json:"publisher"`
 	PublicationDate string    `json:"publication_date"`
 	AuthorIDs       []uint    `json:"author_ids" binding:"required"`
+	CategoryIDs     []uint    `json:"category_ids,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
+	Language        string    `json:"language" binding:"max=10"`
+	Format          string    `json:"format" binding:"omitempty,oneof=hardcover paperback ebook audiobook large_print other"`
+	Edition         string    `json:"edition" binding:"max=100"`
 }
 
-// BookCopyRequest 图书副本创建请求结构
+// BookCopyRequest 图书副本创建请求结构，馆藏位置可选，应用于本次添加的全部副本
 type BookCopyRequest struct {
-	BookID     uint `json:"book_id" binding:"required"`
 	CopiesCount int `json:"copies_count" binding:"required,min=1"`
+	CopyLocationRequest
 }
 
-// BorrowBookRequest 借阅图书请求结构
+// BorrowBookRequest 借阅图书请求结构，Days省略时使用借阅规则的默认借期，BranchID省略时不限分馆
 type BorrowBookRequest struct {
-	BookID uint `json:"book_id" binding:"required"`
-	Days   int  `json:"days" binding:"required,min=1,max=30"`
+	BookID   uint `json:"book_id" binding:"required"`
+	Days     int  `json:"days" binding:"omitempty,min=1"`
+	BranchID uint `json:"branch_id"`
 }
 
 // ReturnBookRequest 归还图书请求结构
@@ -37,6 +50,11 @@ type ReturnBookRequest struct {
 	BorrowID uint `json:"borrow_id" binding:"required"`
 }
 
+// RenewBookRequest 续借图书请求结构
+type RenewBookRequest struct {
+	BorrowID uint `json:"borrow_id" binding:"required"`
+}
+
 // CreateBook 添加新图书
 func CreateBook(c *gin.Context) {
 	var req BookRequest
@@ -52,20 +70,45 @@ func CreateBook(c *gin.Context) {
 		return
 	}
 
+	// 校验ISBN并转换为规范的ISBN-13
+	canonicalISBN, err := isbn.Normalize(req.ISBN)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 检查ISBN是否已存在
-	var existingBook models.Book
-	if result := database.DB.Where("isbn = ?", req.ISBN).First(&existingBook); result.Error == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "book with this ISBN already exists"})
+	owner, err := isbnOwner(canonicalISBN, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check isbn"})
+		return
+	}
+	if owner != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": isbnConflictMessage(owner)})
+		return
+	}
+
+	// 校验分类和标签
+	categories, tagNames, err := parseBookSubjects(req)
+	if err != nil {
+		if errors.Is(err, errInvalidCategory) || errors.Is(err, subjects.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find categories"})
 		return
 	}
 
 	// 创建图书
 	book := models.Book{
 		Title:           req.Title,
-		ISBN:            req.ISBN,
+		ISBN:            canonicalISBN,
 		Description:     req.Description,
 		Publisher:       req.Publisher,
 		PublicationDate: publicationDate,
+		Language:        normalizeLanguage(req.Language),
+		Format:          models.BookFormat(req.Format),
+		Edition:         req.Edition,
 	}
 
 	// 开始事务
@@ -103,6 +146,13 @@ func CreateBook(c *gin.Context) {
 		return
 	}
 
+	// 添加分类和标签
+	if err := replaceBookSubjects(tx, &book, categories, tagNames); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add subjects to book"})
+		return
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
@@ -110,22 +160,111 @@ func CreateBook(c *gin.Context) {
 		return
 	}
 
-	// 预加载作者信息并返回
-	tx.Preload("Authors").First(&book)
+	// 预加载作者、分类和标签并返回
+	database.DB.Preload("Authors").Preload("Categories").Preload("Tags").First(&book)
 	c.JSON(http.StatusCreated, book)
 }
 
-// GetBooks 获取图书列表
+// GetBooks 获取图书列表，支持过滤、多字段排序以及页码或游标分页
 func GetBooks(c *gin.Context) {
-	var books []models.Book
-	result := database.DB.Preload("Authors").Find(&books)
+	p := parsePagination(c)
 
-	if result.Error != nil {
+	rawSort := c.DefaultQuery("sort", "id")
+	sorts, err := parseBookSort(rawSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err := applyBookFilters(c, database.DB.Model(&models.Book{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 统计过滤后的总数
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count books"})
+		return
+	}
+
+	// 游标分页优先于页码分页
+	cursor := c.Query("cursor")
+	before := false
+	if cursor != "" {
+		var values []interface{}
+		values, before, err = decodeBookCursor(cursor, rawSort, sorts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = applyKeyset(query, sorts, values, before)
+	} else {
+		query = query.Offset(p.Offset())
+	}
+
+	// 多取一条用于判断是否还有更多数据
+	var books []models.Book
+	if err := query.Preload("Authors").
+		Preload("Categories").
+		Preload("Tags").
+		Order(orderClause(sorts, before)).
+		Limit(p.Limit + 1).
+		Find(&books).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch books"})
 		return
 	}
 
-	c.JSON(http.StatusOK, books)
+	hasMore := len(books) > p.Limit
+	if hasMore {
+		books = books[:p.Limit]
+	}
+	if before {
+		for i, j := 0, len(books)-1; i < j; i, j = i+1, j-1 {
+			books[i], books[j] = books[j], books[i]
+		}
+	}
+
+	if err := attachAvailability(books); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count copies"})
+		return
+	}
+	attachCovers(books)
+
+	resp := gin.H{
+		"data":  books,
+		"total": total,
+		"limit": p.Limit,
+	}
+
+	if cursor == "" {
+		resp["page"] = p.Page
+		if hasMore {
+			resp["next"] = pageLink(c, map[string]string{"page": strconv.Itoa(p.Page + 1)})
+		}
+		if p.Page > 1 {
+			resp["prev"] = pageLink(c, map[string]string{"page": strconv.Itoa(p.Page - 1)})
+		}
+	}
+
+	// 向后翻页时当前页之后必然还有数据，向前翻页时之前必然还有数据
+	if len(books) > 0 {
+		if before || hasMore {
+			next := encodeBookCursor(books[len(books)-1], rawSort, sorts, false)
+			resp["next_cursor"] = next
+			if cursor != "" {
+				resp["next"] = pageLink(c, map[string]string{"cursor": next}, "page")
+			}
+		}
+		if cursor != "" && (!before || hasMore) {
+			prev := encodeBookCursor(books[0], rawSort, sorts, true)
+			resp["prev_cursor"] = prev
+			resp["prev"] = pageLink(c, map[string]string{"cursor": prev}, "page")
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetBook 获取单本图书详情
@@ -133,13 +272,36 @@ func GetBook(c *gin.Context) {
 	id := c.Param("id")
 
 	var book models.Book
-	result := database.DB.Preload("Authors").Preload("Copies").First(&book, id)
+	result := database.DB.Preload("Authors").
+		Preload("Categories").
+		Preload("Tags").
+		Preload("Work").
+		Preload("Series").
+		Preload("Copies", "status <> ?", models.CopyWithdrawn).
+		Preload("Copies.Branch").
+		Preload("Copies.ShelfLocation").
+		First(&book, id)
 
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
 		return
 	}
 
+	books := []models.Book{book}
+	if err := attachAvailability(books); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count copies"})
+		return
+	}
+	attachCovers(books)
+	book = books[0]
+
+	branches, err := circulation.BranchAvailability(database.DB, book.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count copies"})
+		return
+	}
+	book.BranchAvailability = branches
+
 	c.JSON(http.StatusOK, book)
 }
 
@@ -167,13 +329,38 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
+	// 校验ISBN并转换为规范的ISBN-13。未修改的ISBN保持原值，迁移时无法规范化的旧数据仍可编辑
+	canonicalISBN := book.ISBN
+	if req.ISBN != book.ISBN {
+		canonicalISBN, err = isbn.Normalize(req.ISBN)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 检查ISBN是否已被其他图书使用
-	if book.ISBN != req.ISBN {
-		var existingBook models.Book
-		if result := database.DB.Where("isbn = ? AND id != ?", req.ISBN, id).First(&existingBook); result.Error == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "book with this ISBN already exists"})
+	if book.ISBN != canonicalISBN {
+		owner, err := isbnOwner(canonicalISBN, book.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check isbn"})
 			return
 		}
+		if owner != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": isbnConflictMessage(owner)})
+			return
+		}
+	}
+
+	// 校验分类和标签
+	categories, tagNames, err := parseBookSubjects(req)
+	if err != nil {
+		if errors.Is(err, errInvalidCategory) || errors.Is(err, subjects.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find categories"})
+		return
 	}
 
 	// 开始事务
@@ -186,10 +373,13 @@ func UpdateBook(c *gin.Context) {
 
 	// 更新图书信息
 	book.Title = req.Title
-	book.ISBN = req.ISBN
+	book.ISBN = canonicalISBN
 	book.Description = req.Description
 	book.Publisher = req.Publisher
 	book.PublicationDate = publicationDate
+	book.Language = normalizeLanguage(req.Language)
+	book.Format = models.BookFormat(req.Format)
+	book.Edition = req.Edition
 
 	if err := tx.Save(&book).Error; err != nil {
 		tx.Rollback()
@@ -218,6 +408,13 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
+	// 替换分类和标签
+	if err := replaceBookSubjects(tx, &book, categories, tagNames); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update subjects"})
+		return
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
@@ -225,11 +422,32 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
-	// 预加载作者信息并返回
-	database.DB.Preload("Authors").First(&book)
+	// 预加载作者、分类和标签并返回
+	database.DB.Preload("Authors").Preload("Categories").Preload("Tags").First(&book)
 	c.JSON(http.StatusOK, book)
 }
 
+// isbnOwner 查找使用该ISBN的其他图书。ISBN唯一约束同样覆盖已删除的图书，因此一并查找
+func isbnOwner(canonicalISBN string, excludeID uint) (*models.Book, error) {
+	var book models.Book
+	err := database.DB.Unscoped().Where("isbn = ? AND id <> ?", canonicalISBN, excludeID).First(&book).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// isbnConflictMessage ISBN已被占用时的错误信息
+func isbnConflictMessage(owner *models.Book) string {
+	if owner.DeletedAt.Valid {
+		return "isbn belongs to a deleted book"
+	}
+	return "book with this ISBN already exists"
+}
+
 // DeleteBook 删除图书
 func DeleteBook(c *gin.Context) {
 	id := c.Param("id")
@@ -245,7 +463,7 @@ func DeleteBook(c *gin.Context) {
 	var borrowCount int64
 	if err := database.DB.Model(&models.Borrow{}).
 		Joins("JOIN book_copies ON borrows.book_copy_id = book_copies.id").
-		Where("book_copies.book_id = ? AND borrows.status = ?", id, models.BorrowActive).
+		Where("book_copies.book_id = ? AND borrows.status IN ?", id, circulation.OpenBorrowStatuses).
 		Count(&borrowCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check active borrows"})
 		return
@@ -256,12 +474,37 @@ func DeleteBook(c *gin.Context) {
 		return
 	}
 
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 取消该书所有未完成的预约
+	if err := tx.Model(&models.Hold{}).
+		Where("book_id = ? AND status IN ?", book.ID, circulation.ActiveHoldStatuses).
+		Update("status", models.HoldCancelled).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel holds"})
+		return
+	}
+
 	// 删除图书
-	if err := database.DB.Delete(&book).Error; err != nil {
+	if err := tx.Delete(&book).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete book"})
 		return
 	}
 
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "book deleted successfully"})
 }
 
@@ -275,36 +518,76 @@ func AddBookCopies(c *gin.Context) {
 
 	// 检查图书是否存在
 	var book models.Book
-	if result := database.DB.First(&book, req.BookID); result.Error != nil {
+	if result := database.DB.First(&book, c.Param("id")); result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
 		return
 	}
 
-	// 获取当前副本数量
+	// 获取当前在册副本数量
 	var copyCount int64
-	database.DB.Model(&models.BookCopy{}).Where("book_id = ?", req.BookID).Count(&copyCount)
+	database.DB.Model(&models.BookCopy{}).Where("book_id = ? AND status <> ?", book.ID, models.CopyWithdrawn).Count(&copyCount)
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 为新副本分配登录号
+	codes, err := accession.Next(tx, req.CopiesCount)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to allocate accession numbers"})
+		return
+	}
 
 	// 创建新副本
 	copies := make([]models.BookCopy, req.CopiesCount)
 	for i := 0; i < req.CopiesCount; i++ {
 		copies[i] = models.BookCopy{
-			BookID:         req.BookID,
-			CopyNumber:     book.ISBN + "." + string(rune(i+1+int(copyCount))),
+			BookID:         book.ID,
+			CopyNumber:     codes[i],
 			Status:         models.CopyAvailable,
 			AcquisitionDate: time.Now(),
 		}
+		if err := applyCopyLocation(tx, &copies[i], req.CopyLocationRequest); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	if err := database.DB.Create(&copies).Error; err != nil {
+	if err := tx.Create(&copies).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create book copies"})
 		return
 	}
 
+	// 新副本优先分配给排队中的预约读者
+	now := time.Now()
+	for i := range copies {
+		if _, err := circulation.AssignCopy(tx, &copies[i], now); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign copies to holds"})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message":       "book copies added successfully",
-		"book_id":       req.BookID,
+		"book_id":       book.ID,
 		"copies_added":  req.CopiesCount,
 		"total_copies":  copyCount + int64(req.CopiesCount),
+		"copies":        copies,
 	})
 }
 
@@ -323,6 +606,59 @@ func BorrowBook(c *gin.Context) {
 		return
 	}
 
+	// 按借阅规则检查借期和在借数量
+	policy, err := currentPolicy(c, database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load loan policy"})
+		return
+	}
+
+	// 在事务中抢占副本并创建借阅记录
+	borrow, err := circulation.Checkout(database.DB, circulation.CheckoutRequest{
+		UserID:        userID.(uint),
+		BookID:        req.BookID,
+		BranchID:      req.BranchID,
+		Policy:        policy,
+		RequestedDays: req.Days,
+	}, time.Now())
+	if err != nil {
+		var violation *circulation.PolicyViolation
+		switch {
+		case errors.As(err, &violation):
+			policyViolationResponse(c, violation)
+		case errors.Is(err, circulation.ErrNoCopyAvailable), errors.Is(err, circulation.ErrNoCopyAtBranch):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, circulation.ErrAlreadyBorrowed), errors.Is(err, circulation.ErrCheckoutConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, circulation.ErrDatabaseBusy):
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to borrow book"})
+		}
+		return
+	}
+
+	// 预加载相关信息
+	database.DB.Preload("BookCopy").Preload("BookCopy.Book").Preload("BookCopy.Book.Authors").First(borrow)
+	c.JSON(http.StatusOK, borrow)
+}
+
+// ReturnBook 归还图书
+func ReturnBook(c *gin.Context) {
+	var req ReturnBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
 	// 开始事务
 	tx := database.DB.Begin()
 	defer func() {
@@ -331,51 +667,76 @@ func BorrowBook(c *gin.Context) {
 		}
 	}()
 
-	// 查找可用的图书副本
-	var bookCopy models.BookCopy
-	result := tx.Where("book_id = ? AND status = ?", req.BookID, models.CopyAvailable).First(&bookCopy)
+	// 查找借阅记录
+	var borrow models.Borrow
+	result := tx.Where("id = ? AND user_id = ? AND status IN ?", req.BorrowID, userID, circulation.ReturnableBorrowStatuses).First(&borrow)
 	if result.Error != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "no available copies of this book"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "active borrow record not found for this user"})
 		return
 	}
 
-	// 检查用户是否已借阅此书
-	var activeBorrowCount int64
-	tx.Model(&models.Borrow{}).
-		Where("user_id = ? AND book_copy_id IN (SELECT id FROM book_copies WHERE book_id = ?) AND status = ?",
-		userID, req.BookID, models.BorrowActive).
-		Count(&activeBorrowCount)
-
-	if activeBorrowCount > 0 {
+	// 按状态条件更新借阅记录，避免覆盖后台任务同时做出的状态变更；
+	// 已标记丢失的借阅归还后减免丢失赔偿费用
+	now := time.Now()
+	var updated bool
+	if borrow.Status == models.BorrowLost {
+		ok, err := circulation.ResolveLost(tx, &borrow, 0, now)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update borrow record"})
+			return
+		}
+		updated = ok
+	} else {
+		result = tx.Model(&borrow).
+			Where("status IN ?", circulation.OpenBorrowStatuses).
+			Updates(map[string]interface{}{"status": models.BorrowReturned, "return_date": now})
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update borrow record"})
+			return
+		}
+		updated = result.RowsAffected > 0
+	}
+	if !updated {
 		tx.Rollback()
-		c.JSON(http.StatusConflict, gin.H{"error": "you already have an active borrow for this book"})
+		c.JSON(http.StatusConflict, gin.H{"error": "borrow status changed, please try again"})
 		return
 	}
 
-	// 更新副本状态
-	bookCopy.Status = models.CopyBorrowed
-	if err := tx.Save(&bookCopy).Error; err != nil {
+	// 按逾期天数和借阅规则生成罚款
+	policy, err := currentPolicy(c, tx)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update book copy status"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load loan policy"})
 		return
 	}
 
-	// 创建借阅记录
-	borrow := models.Borrow{
-		UserID:     userID.(uint),
-		BookCopyID: bookCopy.ID,
-		BorrowDate: time.Now(),
-		DueDate:    time.Now().AddDate(0, 0, req.Days),
-		Status:     models.BorrowActive,
+	fine, err := circulation.AssessOverdueFine(tx, &borrow, policy, now)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assess overdue fine"})
+		return
 	}
 
-	if err := tx.Create(&borrow).Error; err != nil {
+	// 更新图书副本状态
+	var bookCopy models.BookCopy
+	if err := tx.First(&bookCopy, borrow.BookCopyID).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create borrow record"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find book copy"})
 		return
 	}
 
+	// 有预约时副本放上预约书架留给下一位读者，否则恢复可借；已被管理员注销等其他状态的副本保持不变
+	if bookCopy.Status == models.CopyBorrowed || bookCopy.Status == models.CopyLost {
+		if _, err := circulation.AssignCopy(tx, &bookCopy, now); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update book copy status"})
+			return
+		}
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
@@ -383,14 +744,16 @@ func BorrowBook(c *gin.Context) {
 		return
 	}
 
-	// 预加载相关信息
-	database.DB.Preload("BookCopy").Preload("BookCopy.Book").Preload("BookCopy.Book.Authors").First(&borrow)
-	c.JSON(http.StatusOK, borrow)
+	resp := gin.H{"message": "book returned successfully"}
+	if fine != nil {
+		resp["fine"] = fine
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
-// ReturnBook 归还图书
-func ReturnBook(c *gin.Context) {
-	var req ReturnBookRequest
+// RenewBook 续借图书
+func RenewBook(c *gin.Context) {
+	var req RenewBookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -413,35 +776,30 @@ func ReturnBook(c *gin.Context) {
 
 	// 查找借阅记录
 	var borrow models.Borrow
-	result := tx.Where("id = ? AND user_id = ? AND status = ?", req.BorrowID, userID, models.BorrowActive).First(&borrow)
+	result := tx.Where("id = ? AND user_id = ? AND status IN ?", req.BorrowID, userID, circulation.OpenBorrowStatuses).First(&borrow)
 	if result.Error != nil {
 		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "active borrow record not found for this user"})
 		return
 	}
 
-	// 更新借阅记录
-	now := time.Now()
-	borrow.ReturnDate = &now
-	borrow.Status = models.BorrowReturned
-	if err := tx.Save(&borrow).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update borrow record"})
-		return
-	}
-
-	// 更新图书副本状态
-	var bookCopy models.BookCopy
-	if err := tx.First(&bookCopy, borrow.BookCopyID).Error; err != nil {
+	policy, err := currentPolicy(c, tx)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find book copy"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load loan policy"})
 		return
 	}
 
-	bookCopy.Status = models.CopyAvailable
-	if err := tx.Save(&bookCopy).Error; err != nil {
+	if err := circulation.Renew(tx, &borrow, policy, time.Now()); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update book copy status"})
+		switch {
+		case errors.Is(err, circulation.ErrRenewalLimitReached),
+			errors.Is(err, circulation.ErrHoldsPending),
+			errors.Is(err, circulation.ErrTooOverdue):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to renew borrow"})
+		}
 		return
 	}
 
@@ -452,5 +810,7 @@ func ReturnBook(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "book returned successfully"})
-}
\ No newline at end of file
+	// 预加载相关信息
+	database.DB.Preload("BookCopy").Preload("BookCopy.Book").Preload("BookCopy.Book.Authors").First(&borrow)
+	c.JSON(http.StatusOK, borrow)
+}
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/isbn"
+	"github.com/example/library-api/models"
+	"github.com/example/library-api/subjects"
+)
+
+// sortKind 排序字段的值类型，用于编码和解码游标
+type sortKind int
+
+const (
+	sortString sortKind = iota
+	sortTime
+	sortUint
+)
+
+// bookSortFields 图书列表允许排序的字段
+var bookSortFields = map[string]struct {
+	column string
+	kind   sortKind
+}{
+	"id":               {"books.id", sortUint},
+	"title":            {"books.title", sortString},
+	"publisher":        {"books.publisher", sortString},
+	"publication_date": {"books.publication_date", sortTime},
+	"created_at":       {"books.created_at", sortTime},
+	"updated_at":       {"books.updated_at", sortTime},
+}
+
+// bookSort 单个排序条件
+type bookSort struct {
+	field string
+	desc  bool
+}
+
+// bookCursor 游标分页令牌内容
+type bookCursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	Before bool     `json:"b,omitempty"`
+}
+
+// parseBookSort 解析形如 "-publication_date,title" 的排序参数，并追加id作为唯一排序键
+func parseBookSort(raw string) ([]bookSort, error) {
+	var sorts []bookSort
+	hasID := false
+
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		s := bookSort{field: part}
+		if strings.HasPrefix(part, "-") {
+			s = bookSort{field: part[1:], desc: true}
+		}
+		if _, ok := bookSortFields[s.field]; !ok {
+			return nil, fmt.Errorf("unsupported sort field %q", s.field)
+		}
+		if s.field == "id" {
+			hasID = true
+		}
+		sorts = append(sorts, s)
+	}
+
+	if !hasID {
+		sorts = append(sorts, bookSort{field: "id"})
+	}
+	return sorts, nil
+}
+
+// orderClause 生成ORDER BY子句，reverse为true时反转所有方向
+func orderClause(sorts []bookSort, reverse bool) string {
+	parts := make([]string, len(sorts))
+	for i, s := range sorts {
+		dir := "ASC"
+		if s.desc != reverse {
+			dir = "DESC"
+		}
+		parts[i] = bookSortFields[s.field].column + " " + dir
+	}
+	return strings.Join(parts, ", ")
+}
+
+// applyBookFilters 根据查询参数添加图书过滤条件
+func applyBookFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if title := c.Query("title"); title != "" {
+		query = query.Where(`books.title LIKE ? ESCAPE '\'`, "%"+escapeLike(title)+"%")
+	}
+
+	if publisher := c.Query("publisher"); publisher != "" {
+		query = query.Where("books.publisher = ? COLLATE NOCASE", publisher)
+	}
+
+	if raw := c.Query("isbn"); raw != "" {
+		// 按规范形式匹配，ISBN-10 和带连字符的 ISBN 也能找到图书；无法规范化时按原值匹配旧数据
+		if canonical, err := isbn.Normalize(raw); err == nil {
+			raw = canonical
+		}
+		query = query.Where("books.isbn = ?", raw)
+	}
+
+	if raw := c.Query("author_id"); raw != "" {
+		authorID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("author_id must be a positive integer")
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM book_authors WHERE book_authors.book_id = books.id AND book_authors.author_id = ?)", authorID)
+	}
+
+	// 分类过滤包含全部后代分类
+	if raw := c.Query("category_id"); raw != "" {
+		categoryID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("category_id must be a positive integer")
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM book_categories WHERE book_categories.book_id = books.id AND book_categories.category_id IN ("+subjects.SubtreeSQL+"))", categoryID)
+	}
+
+	// 多个标签以逗号分隔，图书需要同时带有全部标签
+	if raw := c.Query("tag"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			tag, err := subjects.NormalizeTag(name)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("EXISTS (SELECT 1 FROM book_tags JOIN tags ON tags.id = book_tags.tag_id WHERE book_tags.book_id = books.id AND tags.name = ?)", tag)
+		}
+	}
+
+	if raw := c.Query("work_id"); raw != "" {
+		workID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("work_id must be a positive integer")
+		}
+		query = query.Where("books.work_id = ?", workID)
+	}
+
+	if raw := c.Query("series_id"); raw != "" {
+		seriesID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("series_id must be a positive integer")
+		}
+		query = query.Where("books.series_id = ?", seriesID)
+	}
+
+	if language := c.Query("language"); language != "" {
+		query = query.Where("books.language = ?", normalizeLanguage(language))
+	}
+
+	if format := c.Query("format"); format != "" {
+		query = query.Where("books.format = ?", format)
+	}
+
+	if raw := c.Query("year_from"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, errors.New("year_from must be a year such as 1990")
+		}
+		query = query.Where("books.publication_date >= ?", time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	if raw := c.Query("year_to"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, errors.New("year_to must be a year such as 2020")
+		}
+		query = query.Where("books.publication_date < ?", time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	// 副本条件，指定分馆时只看该分馆的副本
+	copyCond := "book_copies.book_id = books.id AND book_copies.deleted_at IS NULL"
+	var copyArgs []interface{}
+	branchID := c.Query("branch_id")
+	if branchID != "" {
+		id, err := strconv.ParseUint(branchID, 10, 64)
+		if err != nil {
+			return nil, errors.New("branch_id must be a positive integer")
+		}
+		copyCond += " AND book_copies.branch_id = ?"
+		copyArgs = append(copyArgs, id)
+	}
+
+	if raw := c.Query("available"); raw != "" {
+		available, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("available must be true or false")
+		}
+		exists := "EXISTS (SELECT 1 FROM book_copies WHERE " + copyCond + " AND book_copies.status = ?)"
+		if !available {
+			exists = "NOT " + exists
+		}
+		query = query.Where(exists, append(copyArgs, models.CopyAvailable)...)
+	} else if branchID != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM book_copies WHERE "+copyCond+" AND book_copies.status <> ?)",
+			append(copyArgs, models.CopyWithdrawn)...)
+	}
+
+	return query, nil
+}
+
+// sortValue 获取图书在排序字段上的值，编码为字符串
+func sortValue(book models.Book, field string) string {
+	switch field {
+	case "title":
+		return book.Title
+	case "publisher":
+		return book.Publisher
+	case "publication_date":
+		return book.PublicationDate.Format(time.RFC3339Nano)
+	case "created_at":
+		return book.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return book.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		return strconv.FormatUint(uint64(book.ID), 10)
+	}
+}
+
+// encodeBookCursor 根据图书的排序字段值生成游标
+func encodeBookCursor(book models.Book, rawSort string, sorts []bookSort, before bool) string {
+	cur := bookCursor{Sort: rawSort, Before: before}
+	for _, s := range sorts {
+		cur.Values = append(cur.Values, sortValue(book, s.field))
+	}
+
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeBookCursor 解析游标，返回与排序字段对应的值
+func decodeBookCursor(token, rawSort string, sorts []bookSort) ([]interface{}, bool, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, false, errors.New("invalid cursor")
+	}
+
+	var cur bookCursor
+	if err := json.Unmarshal(data, &cur); err != nil || len(cur.Values) != len(sorts) {
+		return nil, false, errors.New("invalid cursor")
+	}
+	if cur.Sort != rawSort {
+		return nil, false, errors.New("cursor was issued for a different sort order")
+	}
+
+	values := make([]interface{}, len(sorts))
+	for i, s := range sorts {
+		switch bookSortFields[s.field].kind {
+		case sortTime:
+			t, err := time.Parse(time.RFC3339Nano, cur.Values[i])
+			if err != nil {
+				return nil, false, errors.New("invalid cursor")
+			}
+			values[i] = t
+		case sortUint:
+			n, err := strconv.ParseUint(cur.Values[i], 10, 64)
+			if err != nil {
+				return nil, false, errors.New("invalid cursor")
+			}
+			values[i] = n
+		default:
+			values[i] = cur.Values[i]
+		}
+	}
+
+	return values, cur.Before, nil
+}
+
+// applyKeyset 添加游标分页条件：(f1 > v1) OR (f1 = v1 AND f2 > v2) ...
+func applyKeyset(query *gorm.DB, sorts []bookSort, values []interface{}, before bool) *gorm.DB {
+	var clauses []string
+	var args []interface{}
+
+	for i, s := range sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, bookSortFields[sorts[j].field].column+" = ?")
+			args = append(args, values[j])
+		}
+
+		op := ">"
+		if s.desc != before {
+			op = "<"
+		}
+		parts = append(parts, bookSortFields[s.field].column+" "+op+" ?")
+		args = append(args, values[i])
+
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return query.Where(strings.Join(clauses, " OR "), args...)
+}
+
+// pageLink 基于当前请求生成替换了分页参数的链接
+func pageLink(c *gin.Context, set map[string]string, del ...string) string {
+	q := c.Request.URL.Query()
+	for _, k := range del {
+		q.Del(k)
+	}
+	for k, v := range set {
+		q.Set(k, v)
+	}
+	return c.Request.URL.Path + "?" + q.Encode()
+}
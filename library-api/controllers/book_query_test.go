@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// bookList 图书列表接口的响应
+type bookList struct {
+	Data       []models.Book `json:"data"`
+	Total      int64         `json:"total"`
+	Page       int           `json:"page"`
+	Limit      int           `json:"limit"`
+	Next       string        `json:"next"`
+	Prev       string        `json:"prev"`
+	NextCursor string        `json:"next_cursor"`
+	PrevCursor string        `json:"prev_cursor"`
+}
+
+// titles 返回列表中图书的标题
+func (l bookList) titles() []string {
+	titles := make([]string, len(l.Data))
+	for i, book := range l.Data {
+		titles[i] = book.Title
+	}
+	return titles
+}
+
+// catalogue 图书列表测试使用的馆藏
+type catalogue struct {
+	router *gin.Engine
+	books  map[string]models.Book
+	austen models.Author
+}
+
+// testISBN 返回第 n 个校验位正确的 ISBN-13
+func testISBN(n int) string {
+	body := fmt.Sprintf("978%09d", n)
+	sum := 0
+	for i, d := range body {
+		w := 1
+		if i%2 == 1 {
+			w = 3
+		}
+		sum += int(d-'0') * w
+	}
+	return fmt.Sprintf("%s%d", body, (10-sum%10)%10)
+}
+
+// setupCatalogue 创建五本图书，Austen 的两本书由 Penguin 出版，Emma 的副本已借出，Persuasion 没有副本
+func setupCatalogue(t *testing.T) *catalogue {
+	t.Helper()
+
+	databasetest.Use(t)
+	authors := map[string]*models.Author{}
+	for _, name := range []string{"Frank Herbert", "Jane Austen", "William Gibson", "Isaac Asimov"} {
+		authors[name] = &models.Author{Name: name}
+		if err := database.DB.Create(authors[name]).Error; err != nil {
+			t.Fatalf("create author: %v", err)
+		}
+	}
+
+	fixtures := []struct {
+		title     string
+		publisher string
+		year      int
+		author    string
+		copies    []models.BookCopyStatus
+	}{
+		{"Dune", "Chilton", 1965, "Frank Herbert", []models.BookCopyStatus{models.CopyAvailable}},
+		{"Emma", "Penguin", 1815, "Jane Austen", []models.BookCopyStatus{models.CopyBorrowed}},
+		{"Persuasion", "Penguin", 1817, "Jane Austen", nil},
+		{"Neuromancer", "Ace", 1984, "William Gibson", []models.BookCopyStatus{models.CopyAvailable}},
+		{"Foundation", "Gnome", 1951, "Isaac Asimov", []models.BookCopyStatus{models.CopyBorrowed, models.CopyAvailable}},
+	}
+	books := map[string]models.Book{}
+	for i, f := range fixtures {
+		book := models.Book{
+			Title:           f.title,
+			ISBN:            testISBN(i + 1),
+			Publisher:       f.publisher,
+			PublicationDate: time.Date(f.year, time.June, 1, 0, 0, 0, 0, time.UTC),
+			Authors:         []models.Author{*authors[f.author]},
+		}
+		if err := database.DB.Create(&book).Error; err != nil {
+			t.Fatalf("create book: %v", err)
+		}
+		for j, status := range f.copies {
+			bookCopy := models.BookCopy{BookID: book.ID, CopyNumber: fmt.Sprintf("C%d-%d", i+1, j+1), Status: status, AcquisitionDate: time.Now()}
+			if err := database.DB.Create(&bookCopy).Error; err != nil {
+				t.Fatalf("create copy: %v", err)
+			}
+		}
+		books[f.title] = book
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/books", GetBooks)
+	return &catalogue{router: router, books: books, austen: *authors["Jane Austen"]}
+}
+
+// get 请求图书列表，状态码不是 200 时测试失败
+func (c *catalogue) get(t *testing.T, target string) bookList {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, body = %s", target, w.Code, w.Body.String())
+	}
+	var list bookList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return list
+}
+
+// getError 请求图书列表并返回状态码和错误信息
+func (c *catalogue) getError(target string) (int, string) {
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	var resp struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp.Error
+}
+
+func TestGetBooksFilters(t *testing.T) {
+	c := setupCatalogue(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"出版年份范围", "year_from=1900&year_to=1970", []string{"Dune", "Foundation"}},
+		{"截止年份包含当年", "year_to=1815", []string{"Emma"}},
+		{"起始年份", "year_from=1965", []string{"Dune", "Neuromancer"}},
+		{"ISBN", "isbn=" + c.books["Neuromancer"].ISBN, []string{"Neuromancer"}},
+		{"有可借副本", "available=true", []string{"Dune", "Neuromancer", "Foundation"}},
+		{"没有可借副本", "available=false", []string{"Emma", "Persuasion"}},
+		{"作者", fmt.Sprintf("author_id=%d", c.austen.ID), []string{"Emma", "Persuasion"}},
+		{"出版者不区分大小写", "publisher=penguin", []string{"Emma", "Persuasion"}},
+		{"组合条件", fmt.Sprintf("author_id=%d&year_from=1816&available=false", c.austen.ID), []string{"Persuasion"}},
+		{"没有匹配", "year_from=2000", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := c.get(t, "/books?"+tt.query)
+			if got := list.titles(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("titles = %v, want %v", got, tt.want)
+			}
+			if list.Total != int64(len(tt.want)) {
+				t.Errorf("total = %d, want %d", list.Total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetBooksRejectsInvalidParameters(t *testing.T) {
+	c := setupCatalogue(t)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"year_from=1990s", "year_from must be a year such as 1990"},
+		{"year_to=soon", "year_to must be a year such as 2020"},
+		{"available=maybe", "available must be true or false"},
+		{"author_id=-1", "author_id must be a positive integer"},
+		{"sort=price", `unsupported sort field "price"`},
+		{"sort=title,-rating", `unsupported sort field "rating"`},
+	}
+	for _, tt := range tests {
+		if code, msg := c.getError("/books?" + tt.query); code != http.StatusBadRequest || msg != tt.want {
+			t.Errorf("%s: status %d %q, want 400 %q", tt.query, code, msg, tt.want)
+		}
+	}
+}
+
+func TestGetBooksSort(t *testing.T) {
+	c := setupCatalogue(t)
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"id", []string{"Dune", "Emma", "Persuasion", "Neuromancer", "Foundation"}},
+		{"-publication_date", []string{"Neuromancer", "Dune", "Foundation", "Persuasion", "Emma"}},
+		{"title", []string{"Dune", "Emma", "Foundation", "Neuromancer", "Persuasion"}},
+		// 同一出版者内按标题倒序
+		{"publisher,-title", []string{"Neuromancer", "Dune", "Foundation", "Persuasion", "Emma"}},
+		// 排序值相同时按 id 排列
+		{"publisher", []string{"Neuromancer", "Dune", "Foundation", "Emma", "Persuasion"}},
+		{"-publisher,-id", []string{"Persuasion", "Emma", "Foundation", "Dune", "Neuromancer"}},
+	}
+	for _, tt := range tests {
+		if got := c.get(t, "/books?sort="+tt.sort).titles(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sort=%s titles = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestGetBooksPagePagination(t *testing.T) {
+	c := setupCatalogue(t)
+
+	tests := []struct {
+		query      string
+		want       []string
+		page       int
+		next, prev string
+	}{
+		{"limit=2", []string{"Dune", "Emma"}, 1, "/books?limit=2&page=2", ""},
+		{"limit=2&page=2", []string{"Persuasion", "Neuromancer"}, 2, "/books?limit=2&page=3", "/books?limit=2&page=1"},
+		{"limit=2&page=3", []string{"Foundation"}, 3, "", "/books?limit=2&page=2"},
+		{"limit=2&page=4", []string{}, 4, "", "/books?limit=2&page=3"},
+		// 过滤参数保留在翻页链接中
+		{"available=true&limit=2", []string{"Dune", "Neuromancer"}, 1, "/books?available=true&limit=2&page=2", ""},
+	}
+	for _, tt := range tests {
+		list := c.get(t, "/books?"+tt.query)
+		if got := list.titles(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: titles = %v, want %v", tt.query, got, tt.want)
+		}
+		if list.Page != tt.page || list.Limit != 2 || list.Next != tt.next || list.Prev != tt.prev {
+			t.Errorf("%s: page %d limit %d next %q prev %q, want page %d limit 2 next %q prev %q",
+				tt.query, list.Page, list.Limit, list.Next, list.Prev, tt.page, tt.next, tt.prev)
+		}
+		if list.PrevCursor != "" {
+			t.Errorf("%s: prev_cursor = %q, want none without a cursor", tt.query, list.PrevCursor)
+		}
+	}
+
+	total := c.get(t, "/books?available=true&limit=2").Total
+	if total != 3 {
+		t.Errorf("filtered total = %d, want 3", total)
+	}
+}
+
+func TestGetBooksCursorPagination(t *testing.T) {
+	c := setupCatalogue(t)
+
+	first := c.get(t, "/books?sort=-title&limit=2")
+	if got, want := first.titles(), []string{"Persuasion", "Neuromancer"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first page = %v, want %v", got, want)
+	}
+	if first.NextCursor == "" || first.PrevCursor != "" {
+		t.Fatalf("first page next_cursor %q prev_cursor %q, want only next_cursor", first.NextCursor, first.PrevCursor)
+	}
+
+	// 翻页期间新增排在当前页之前的图书，后续页面既不重复也不遗漏
+	if err := database.DB.Create(&models.Book{Title: "Zorba", ISBN: testISBN(99)}).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+
+	second := c.get(t, "/books?sort=-title&limit=2&cursor="+first.NextCursor)
+	if got, want := second.titles(), []string{"Foundation", "Emma"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("second page = %v, want %v", got, want)
+	}
+	if second.Page != 0 {
+		t.Errorf("cursor page has page = %d, want none", second.Page)
+	}
+	if want := "/books?cursor=" + second.NextCursor + "&limit=2&sort=-title"; second.Next != want {
+		t.Errorf("next = %q, want %q", second.Next, want)
+	}
+	if want := "/books?cursor=" + second.PrevCursor + "&limit=2&sort=-title"; second.Prev != want {
+		t.Errorf("prev = %q, want %q", second.Prev, want)
+	}
+
+	last := c.get(t, "/books?sort=-title&limit=2&cursor="+second.NextCursor)
+	if got, want := last.titles(), []string{"Dune"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("last page = %v, want %v", got, want)
+	}
+	if last.NextCursor != "" || last.Next != "" || last.PrevCursor == "" {
+		t.Errorf("last page next_cursor %q next %q prev_cursor %q, want only prev_cursor", last.NextCursor, last.Next, last.PrevCursor)
+	}
+
+	// 向前翻页按原顺序返回上一页
+	back := c.get(t, "/books?sort=-title&limit=2&cursor="+last.PrevCursor)
+	if got, want := back.titles(), []string{"Foundation", "Emma"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("previous page = %v, want %v", got, want)
+	}
+	if back.NextCursor == "" || back.PrevCursor == "" {
+		t.Errorf("previous page next_cursor %q prev_cursor %q, want both", back.NextCursor, back.PrevCursor)
+	}
+	// 新增的图书只出现在向前翻到的页面中
+	front := c.get(t, "/books?sort=-title&limit=2&cursor="+back.PrevCursor)
+	if got, want := front.titles(), []string{"Persuasion", "Neuromancer"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("page before = %v, want %v", got, want)
+	}
+	if front.PrevCursor == "" {
+		t.Fatal("page before has no prev_cursor")
+	}
+	front = c.get(t, "/books?sort=-title&limit=2&cursor="+front.PrevCursor)
+	if got, want := front.titles(), []string{"Zorba"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("front page = %v, want %v", got, want)
+	}
+	if front.PrevCursor != "" || front.NextCursor == "" {
+		t.Errorf("front page next_cursor %q prev_cursor %q, want only next_cursor", front.NextCursor, front.PrevCursor)
+	}
+}
+
+func TestGetBooksCursorWithTiedSortValues(t *testing.T) {
+	c := setupCatalogue(t)
+
+	// 出版者相同的图书跨页时按 id 继续，不重复也不遗漏
+	var got []string
+	target := "/books?sort=publisher&limit=2"
+	for i := 0; i < 5 && target != ""; i++ {
+		list := c.get(t, target)
+		got = append(got, list.titles()...)
+		target = ""
+		if list.NextCursor != "" {
+			target = "/books?sort=publisher&limit=2&cursor=" + list.NextCursor
+		}
+	}
+	if want := []string{"Neuromancer", "Dune", "Foundation", "Emma", "Persuasion"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("titles = %v, want %v", got, want)
+	}
+}
+
+func TestGetBooksRejectsInvalidCursors(t *testing.T) {
+	c := setupCatalogue(t)
+	encode := func(cur bookCursor) string {
+		data, _ := json.Marshal(cur)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	valid := c.get(t, "/books?sort=title&limit=2").NextCursor
+	if valid == "" {
+		t.Fatal("first page has no next_cursor")
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"不是base64", "sort=title&cursor=not*base64", "invalid cursor"},
+		{"不是JSON", "sort=title&cursor=" + base64.RawURLEncoding.EncodeToString([]byte("dune")), "invalid cursor"},
+		{"值的数量不符", "sort=title&cursor=" + encode(bookCursor{Sort: "title", Values: []string{"Dune"}}), "invalid cursor"},
+		{"id不是数字", "sort=title&cursor=" + encode(bookCursor{Sort: "title", Values: []string{"Dune", "one"}}), "invalid cursor"},
+		{"日期格式错误", "sort=publication_date&cursor=" + encode(bookCursor{Sort: "publication_date", Values: []string{"1965", "1"}}), "invalid cursor"},
+		{"排序方式不同", "sort=-title&cursor=" + valid, "cursor was issued for a different sort order"},
+		{"篡改排序字段", "sort=title&cursor=" + encode(bookCursor{Sort: "publisher", Values: []string{"Ace", "4"}}), "cursor was issued for a different sort order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, msg := c.getError("/books?" + tt.query); code != http.StatusBadRequest || msg != tt.want {
+				t.Errorf("status %d %q, want 400 %q", code, msg, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// setupBorrowTest 使用临时数据库创建只包含借阅接口的路由，请求头 X-User-ID 指定借阅人
+func setupBorrowTest(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	databasetest.Use(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/books/borrow", func(c *gin.Context) {
+		id, _ := strconv.Atoi(c.GetHeader("X-User-ID"))
+		c.Set("userID", uint(id))
+		c.Set("role", models.RoleUser)
+	}, BorrowBook)
+	return router
+}
+
+// createBookWithCopies 创建带指定数量可借副本的图书
+func createBookWithCopies(t *testing.T, copies int) models.Book {
+	t.Helper()
+
+	book := models.Book{Title: "Concurrency", ISBN: fmt.Sprintf("978%010d", time.Now().UnixNano()%1e10)}
+	if err := database.DB.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	for i := 1; i <= copies; i++ {
+		bookCopy := models.BookCopy{
+			BookID:          book.ID,
+			CopyNumber:      fmt.Sprintf("C%03d", i),
+			Status:          models.CopyAvailable,
+			AcquisitionDate: time.Now(),
+		}
+		if err := database.DB.Create(&bookCopy).Error; err != nil {
+			t.Fatalf("create copy: %v", err)
+		}
+	}
+	return book
+}
+
+// createUsers 创建指定数量的普通用户
+func createUsers(t *testing.T, n int) []models.User {
+	t.Helper()
+
+	users := make([]models.User, n)
+	for i := range users {
+		users[i] = models.User{
+			Username: fmt.Sprintf("reader%d", i),
+			Email:    fmt.Sprintf("reader%d@example.com", i),
+			Password: "secret",
+			Role:     models.RoleUser,
+		}
+		if err := database.DB.Create(&users[i]).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	return users
+}
+
+// borrowResult 一次借阅请求的结果
+type borrowResult struct {
+	status int
+	borrow models.Borrow
+}
+
+// borrowConcurrently 让每个用户同时发起借阅请求
+func borrowConcurrently(t *testing.T, router *gin.Engine, bookID uint, userIDs []uint) []borrowResult {
+	t.Helper()
+
+	body, _ := json.Marshal(BorrowBookRequest{BookID: bookID})
+	results := make([]borrowResult, len(userIDs))
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i, userID := range userIDs {
+		wg.Add(1)
+		go func(i int, userID uint) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/books/borrow", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-User-ID", strconv.Itoa(int(userID)))
+			w := httptest.NewRecorder()
+
+			<-start
+			router.ServeHTTP(w, req)
+
+			results[i].status = w.Code
+			if w.Code == http.StatusOK {
+				json.Unmarshal(w.Body.Bytes(), &results[i].borrow)
+			}
+		}(i, userID)
+	}
+	close(start)
+	wg.Wait()
+
+	return results
+}
+
+// countStatuses 按HTTP状态码统计结果，并确保没有意外的状态码
+func countStatuses(t *testing.T, results []borrowResult, allowed ...int) map[int]int {
+	t.Helper()
+
+	counts := map[int]int{}
+	for _, r := range results {
+		counts[r.status]++
+	}
+	for status, n := range counts {
+		ok := false
+		for _, a := range allowed {
+			if status == a {
+				ok = true
+			}
+		}
+		if !ok {
+			t.Errorf("unexpected status %d returned %d times", status, n)
+		}
+	}
+	return counts
+}
+
+// assertOpenBorrowsPerCopy 确保每个副本最多一条未归还的借阅，且借出副本的状态一致
+func assertOpenBorrowsPerCopy(t *testing.T, bookID uint, wantBorrowed int) {
+	t.Helper()
+
+	var rows []struct {
+		BookCopyID uint
+		Count      int
+	}
+	database.DB.Model(&models.Borrow{}).
+		Select("book_copy_id, COUNT(*) AS count").
+		Where("status IN ?", circulation.OpenBorrowStatuses).
+		Group("book_copy_id").
+		Scan(&rows)
+	for _, r := range rows {
+		if r.Count != 1 {
+			t.Errorf("copy %d has %d open borrows", r.BookCopyID, r.Count)
+		}
+	}
+	if len(rows) != wantBorrowed {
+		t.Errorf("got %d copies with open borrows, want %d", len(rows), wantBorrowed)
+	}
+
+	var borrowedCopies int64
+	database.DB.Model(&models.BookCopy{}).
+		Where("book_id = ? AND status = ?", bookID, models.CopyBorrowed).
+		Count(&borrowedCopies)
+	if borrowedCopies != int64(wantBorrowed) {
+		t.Errorf("got %d copies marked borrowed, want %d", borrowedCopies, wantBorrowed)
+	}
+}
+
+func TestBorrowBookConcurrentLastCopy(t *testing.T) {
+	router := setupBorrowTest(t)
+	book := createBookWithCopies(t, 1)
+	users := createUsers(t, 25)
+
+	userIDs := make([]uint, len(users))
+	for i, u := range users {
+		userIDs[i] = u.ID
+	}
+
+	results := borrowConcurrently(t, router, book.ID, userIDs)
+	counts := countStatuses(t, results, http.StatusOK, http.StatusNotFound, http.StatusConflict)
+	if counts[http.StatusOK] != 1 {
+		t.Fatalf("got %d successful borrows of the last copy, want 1", counts[http.StatusOK])
+	}
+
+	assertOpenBorrowsPerCopy(t, book.ID, 1)
+}
+
+func TestBorrowBookConcurrentCopiesAreDistinct(t *testing.T) {
+	router := setupBorrowTest(t)
+	const copies = 5
+	book := createBookWithCopies(t, copies)
+	users := createUsers(t, 30)
+
+	userIDs := make([]uint, len(users))
+	for i, u := range users {
+		userIDs[i] = u.ID
+	}
+
+	results := borrowConcurrently(t, router, book.ID, userIDs)
+	counts := countStatuses(t, results, http.StatusOK, http.StatusNotFound, http.StatusConflict)
+	if counts[http.StatusOK] != copies {
+		t.Fatalf("got %d successful borrows, want %d", counts[http.StatusOK], copies)
+	}
+
+	seen := map[uint]bool{}
+	for _, r := range results {
+		if r.status != http.StatusOK {
+			continue
+		}
+		if seen[r.borrow.BookCopyID] {
+			t.Errorf("copy %d lent twice", r.borrow.BookCopyID)
+		}
+		seen[r.borrow.BookCopyID] = true
+	}
+
+	assertOpenBorrowsPerCopy(t, book.ID, copies)
+}
+
+func TestBorrowBookConcurrentSameUser(t *testing.T) {
+	router := setupBorrowTest(t)
+	book := createBookWithCopies(t, 5)
+	user := createUsers(t, 1)[0]
+
+	userIDs := make([]uint, 20)
+	for i := range userIDs {
+		userIDs[i] = user.ID
+	}
+
+	results := borrowConcurrently(t, router, book.ID, userIDs)
+	counts := countStatuses(t, results, http.StatusOK, http.StatusConflict)
+	if counts[http.StatusOK] != 1 {
+		t.Fatalf("got %d successful borrows by the same user, want 1", counts[http.StatusOK])
+	}
+
+	assertOpenBorrowsPerCopy(t, book.ID, 1)
+}
+
+func TestOpenBorrowUniquePerCopy(t *testing.T) {
+	setupBorrowTest(t)
+	book := createBookWithCopies(t, 1)
+	users := createUsers(t, 2)
+
+	var bookCopy models.BookCopy
+	database.DB.Where("book_id = ?", book.ID).First(&bookCopy)
+
+	now := time.Now()
+	first := models.Borrow{UserID: users[0].ID, BookCopyID: bookCopy.ID, BorrowDate: now, DueDate: now, Status: models.BorrowActive}
+	if err := database.DB.Create(&first).Error; err != nil {
+		t.Fatalf("create first borrow: %v", err)
+	}
+
+	second := models.Borrow{UserID: users[1].ID, BookCopyID: bookCopy.ID, BorrowDate: now, DueDate: now, Status: models.BorrowOverdue}
+	err := database.DB.Create(&second).Error
+	if !database.IsUniqueViolation(err) {
+		t.Fatalf("second open borrow of the same copy: got %v, want unique violation", err)
+	}
+
+	// 归还后的借阅不占用唯一约束
+	database.DB.Model(&first).Update("status", models.BorrowReturned)
+	second.ID = 0
+	if err := database.DB.Create(&second).Error; err != nil {
+		t.Fatalf("borrow after return: %v", err)
+	}
+}
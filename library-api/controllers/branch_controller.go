@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/callnumber"
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// BranchRequest 分馆创建/更新请求结构
+type BranchRequest struct {
+	Code    string `json:"code" binding:"required,max=20"`
+	Name    string `json:"name" binding:"required,max=100"`
+	Address string `json:"address" binding:"max=255"`
+}
+
+// ShelfLocationRequest 书架位置创建/更新请求结构
+type ShelfLocationRequest struct {
+	Code     string `json:"code" binding:"required,max=30"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Position int    `json:"position" binding:"min=0"`
+}
+
+// CopyLocationRequest 副本馆藏位置请求结构，只指定书架时分馆取书架所在的分馆
+type CopyLocationRequest struct {
+	BranchID         *uint  `json:"branch_id"`
+	ShelfLocationID  *uint  `json:"shelf_location_id"`
+	CallNumber       string `json:"call_number" binding:"max=50"`
+	CallNumberScheme string `json:"call_number_scheme" binding:"omitempty,oneof=dewey lcc"`
+}
+
+// applyCopyLocation 校验馆藏位置并写入副本字段，返回的错误信息可直接返回给客户端
+func applyCopyLocation(db *gorm.DB, bookCopy *models.BookCopy, req CopyLocationRequest) error {
+	branchID := req.BranchID
+	if req.ShelfLocationID != nil {
+		var shelf models.ShelfLocation
+		if err := db.First(&shelf, *req.ShelfLocationID).Error; err != nil {
+			return errors.New("shelf location not found")
+		}
+		if branchID == nil {
+			branchID = &shelf.BranchID
+		} else if *branchID != shelf.BranchID {
+			return errors.New("shelf location does not belong to this branch")
+		}
+	}
+	if branchID != nil {
+		var branch models.Branch
+		if err := db.First(&branch, *branchID).Error; err != nil {
+			return errors.New("branch not found")
+		}
+	}
+
+	bookCopy.BranchID = branchID
+	bookCopy.ShelfLocationID = req.ShelfLocationID
+	bookCopy.CallNumber = ""
+	bookCopy.CallNumberScheme = ""
+	bookCopy.ShelfKey = ""
+
+	if strings.TrimSpace(req.CallNumber) != "" {
+		if req.CallNumberScheme == "" {
+			return errors.New("call_number_scheme is required with call_number")
+		}
+		key, err := callnumber.ShelfKey(req.CallNumberScheme, req.CallNumber)
+		if err != nil {
+			return err
+		}
+		bookCopy.CallNumber = callnumber.Normalize(req.CallNumber)
+		bookCopy.CallNumberScheme = req.CallNumberScheme
+		bookCopy.ShelfKey = key
+	}
+
+	return nil
+}
+
+// GetBranches 获取全部分馆
+func GetBranches(c *gin.Context) {
+	var branches []models.Branch
+	if err := database.DB.Order("name ASC").Find(&branches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch branches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, branches)
+}
+
+// GetBranch 获取分馆详情
+func GetBranch(c *gin.Context) {
+	var branch models.Branch
+	if result := database.DB.First(&branch, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, branch)
+}
+
+// CreateBranch 添加分馆
+func CreateBranch(c *gin.Context) {
+	var req BranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	branch := models.Branch{
+		Code:    strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:    req.Name,
+		Address: req.Address,
+	}
+	if err := database.DB.Create(&branch).Error; err != nil {
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "branch code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create branch"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, branch)
+}
+
+// UpdateBranch 更新分馆信息
+func UpdateBranch(c *gin.Context) {
+	var req BranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branch models.Branch
+	if result := database.DB.First(&branch, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		return
+	}
+
+	branch.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	branch.Name = req.Name
+	branch.Address = req.Address
+	if err := database.DB.Save(&branch).Error; err != nil {
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "branch code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update branch"})
+		return
+	}
+
+	c.JSON(http.StatusOK, branch)
+}
+
+// DeleteBranch 删除分馆，仍有副本的分馆不能删除
+func DeleteBranch(c *gin.Context) {
+	var branch models.Branch
+	if result := database.DB.First(&branch, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		return
+	}
+
+	var copyCount int64
+	database.DB.Model(&models.BookCopy{}).Where("branch_id = ?", branch.ID).Count(&copyCount)
+	if copyCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete branch that still holds copies"})
+		return
+	}
+
+	// 正在调往该分馆的副本或以该分馆为取书地点的预约仍需处理
+	var transferCount int64
+	database.DB.Model(&models.Transfer{}).
+		Where("to_branch_id = ? AND status IN ?", branch.ID, []models.TransferStatus{models.TransferRequested, models.TransferInTransit}).
+		Count(&transferCount)
+	var holdCount int64
+	database.DB.Model(&models.Hold{}).
+		Where("pickup_branch_id = ? AND status IN ?", branch.ID, circulation.ActiveHoldStatuses).
+		Count(&holdCount)
+	if transferCount > 0 || holdCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete branch with pending transfers or holds"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("branch_id = ?", branch.ID).Delete(&models.ShelfLocation{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete shelf locations"})
+		return
+	}
+
+	if err := tx.Delete(&branch).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete branch"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "branch deleted successfully"})
+}
+
+// GetShelfLocations 获取分馆的书架位置，按巡架顺序排列
+func GetShelfLocations(c *gin.Context) {
+	var branch models.Branch
+	if result := database.DB.First(&branch, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		return
+	}
+
+	var shelves []models.ShelfLocation
+	if err := database.DB.Where("branch_id = ?", branch.ID).
+		Order("position ASC, code ASC").
+		Find(&shelves).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shelf locations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shelves)
+}
+
+// CreateShelfLocation 为分馆添加书架位置
+func CreateShelfLocation(c *gin.Context) {
+	var req ShelfLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branch models.Branch
+	if result := database.DB.First(&branch, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		return
+	}
+
+	shelf := models.ShelfLocation{
+		BranchID: branch.ID,
+		Code:     strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:     req.Name,
+		Position: req.Position,
+	}
+	if err := database.DB.Create(&shelf).Error; err != nil {
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "shelf location code already exists in this branch"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create shelf location"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, shelf)
+}
+
+// UpdateShelfLocation 更新书架位置
+func UpdateShelfLocation(c *gin.Context) {
+	var req ShelfLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var shelf models.ShelfLocation
+	if result := database.DB.Where("id = ? AND branch_id = ?", c.Param("shelf_id"), c.Param("id")).First(&shelf); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shelf location not found"})
+		return
+	}
+
+	shelf.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	shelf.Name = req.Name
+	shelf.Position = req.Position
+	if err := database.DB.Save(&shelf).Error; err != nil {
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "shelf location code already exists in this branch"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shelf location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shelf)
+}
+
+// DeleteShelfLocation 删除书架位置，仍有副本的书架不能删除
+func DeleteShelfLocation(c *gin.Context) {
+	var shelf models.ShelfLocation
+	if result := database.DB.Where("id = ? AND branch_id = ?", c.Param("shelf_id"), c.Param("id")).First(&shelf); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shelf location not found"})
+		return
+	}
+
+	var copyCount int64
+	database.DB.Model(&models.BookCopy{}).Where("shelf_location_id = ?", shelf.ID).Count(&copyCount)
+	if copyCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete shelf location that still holds copies"})
+		return
+	}
+
+	if err := database.DB.Delete(&shelf).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete shelf location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shelf location deleted successfully"})
+}
+
+// GetShelfList 按书架顺序和索书号列出分馆的副本，供馆员巡架和清点。
+// 默认不包括已注销的副本，可按书架和状态过滤
+func GetShelfList(c *gin.Context) {
+	var branch models.Branch
+	if result := database.DB.First(&branch, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		return
+	}
+
+	p := parsePagination(c)
+
+	query := database.DB.Model(&models.BookCopy{}).
+		Joins("LEFT JOIN shelf_locations ON shelf_locations.id = book_copies.shelf_location_id").
+		Where("book_copies.branch_id = ?", branch.ID)
+	if shelfID := c.Query("shelf_id"); shelfID != "" {
+		query = query.Where("book_copies.shelf_location_id = ?", shelfID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("book_copies.status = ?", status)
+	} else {
+		query = query.Where("book_copies.status <> ?", models.CopyWithdrawn)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count copies"})
+		return
+	}
+
+	// 没有书架或索书号的副本排在最后
+	var copies []models.BookCopy
+	if err := query.Preload("Book").
+		Preload("ShelfLocation").
+		Order("book_copies.shelf_location_id IS NULL, shelf_locations.position, shelf_locations.code").
+		Order("book_copies.shelf_key = '', book_copies.shelf_key, book_copies.copy_number").
+		Offset(p.Offset()).Limit(p.Limit).
+		Find(&copies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch copies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(copies, total, p))
+}
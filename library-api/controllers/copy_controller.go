@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/accession"
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// CopyStatusRequest 副本状态变更请求结构
+type CopyStatusRequest struct {
+	Status models.BookCopyStatus `json:"status" binding:"required,oneof=available maintenance lost withdrawn"`
+	Reason string                `json:"reason" binding:"required,max=255"`
+}
+
+// CopyResponse 副本详情响应结构，附带当前未归还的借阅和可执行的状态转换
+type CopyResponse struct {
+	models.BookCopy
+	CurrentBorrow      *models.Borrow          `json:"current_borrow,omitempty"`
+	AllowedTransitions []models.BookCopyStatus `json:"allowed_transitions"`
+}
+
+// GetCopyByBarcode 通过登录号条码查找副本
+func GetCopyByBarcode(c *gin.Context) {
+	barcode := accession.Normalize(c.Param("barcode"))
+
+	var bookCopy models.BookCopy
+	if result := database.DB.Where("copy_number = ?", barcode).
+		Preload("Book").
+		Preload("Book.Authors").
+		First(&bookCopy); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book copy not found"})
+		return
+	}
+
+	resp, err := copyResponses([]models.BookCopy{bookCopy})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch current borrow"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp[0])
+}
+
+// attachAvailability 为图书附加各状态的副本数量
+func attachAvailability(books []models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(books))
+	for i := range books {
+		ids[i] = books[i].ID
+	}
+
+	counts, err := circulation.Availability(database.DB, ids)
+	if err != nil {
+		return err
+	}
+	for i := range books {
+		books[i].Availability = counts[books[i].ID]
+	}
+	return nil
+}
+
+// copyResponses 为副本附加当前未归还的借阅和可执行的状态转换
+func copyResponses(copies []models.BookCopy) ([]CopyResponse, error) {
+	resp := make([]CopyResponse, len(copies))
+	if len(copies) == 0 {
+		return resp, nil
+	}
+
+	ids := make([]uint, len(copies))
+	for i := range copies {
+		ids[i] = copies[i].ID
+	}
+
+	var borrows []models.Borrow
+	if err := database.DB.Where("book_copy_id IN ? AND status IN ?", ids, circulation.OpenBorrowStatuses).
+		Preload("User").
+		Find(&borrows).Error; err != nil {
+		return nil, err
+	}
+	byCopy := make(map[uint]*models.Borrow, len(borrows))
+	for i := range borrows {
+		byCopy[borrows[i].BookCopyID] = &borrows[i]
+	}
+
+	for i := range copies {
+		resp[i] = CopyResponse{
+			BookCopy:           copies[i],
+			CurrentBorrow:      byCopy[copies[i].ID],
+			AllowedTransitions: circulation.AllowedTransitions(copies[i].Status),
+		}
+	}
+	return resp, nil
+}
+
+// GetBookCopies 获取图书的全部副本及当前借阅人，支持按状态过滤
+func GetBookCopies(c *gin.Context) {
+	var book models.Book
+	if result := database.DB.First(&book, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	query := database.DB.Where("book_id = ?", book.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var copies []models.BookCopy
+	if err := query.Order("id ASC").Find(&copies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch book copies"})
+		return
+	}
+
+	resp, err := copyResponses(copies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch current borrows"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateCopyStatus 按状态机转换副本状态，如送修、标记丢失、找回或注销
+func UpdateCopyStatus(c *gin.Context) {
+	var req CopyStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var bookCopy models.BookCopy
+	if result := tx.First(&bookCopy, c.Param("id")); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "book copy not found"})
+		return
+	}
+
+	change, err := circulation.TransitionCopy(tx, &bookCopy, req.Status, req.Reason, adminID.(uint), time.Now())
+	if err != nil {
+		tx.Rollback()
+		switch {
+		case errors.Is(err, circulation.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   fmt.Sprintf("cannot change copy status from %s to %s", bookCopy.Status, req.Status),
+				"allowed": circulation.AllowedTransitions(bookCopy.Status),
+			})
+		case errors.Is(err, circulation.ErrCopyStatusChanged):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update copy status"})
+		}
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"copy":   bookCopy,
+		"change": change,
+	})
+}
+
+// GetCopyHistory 获取副本的状态变更记录
+func GetCopyHistory(c *gin.Context) {
+	var bookCopy models.BookCopy
+	if result := database.DB.First(&bookCopy, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book copy not found"})
+		return
+	}
+
+	var changes []models.CopyStatusChange
+	if err := database.DB.Where("book_copy_id = ?", bookCopy.ID).
+		Preload("ChangedBy").
+		Order("created_at DESC, id DESC").
+		Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch copy history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// UpdateCopyLocation 设置副本所在的分馆、书架和索书号
+func UpdateCopyLocation(c *gin.Context) {
+	var req CopyLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var bookCopy models.BookCopy
+	if result := database.DB.First(&bookCopy, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book copy not found"})
+		return
+	}
+
+	if err := applyCopyLocation(database.DB, &bookCopy, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Model(&bookCopy).
+		Select("branch_id", "shelf_location_id", "call_number", "call_number_scheme", "shelf_key").
+		Updates(&bookCopy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update copy location"})
+		return
+	}
+
+	database.DB.Preload("Branch").Preload("ShelfLocation").First(&bookCopy, bookCopy.ID)
+	c.JSON(http.StatusOK, bookCopy)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/covers"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// multipartOverhead multipart 表单中文件以外部分（分隔符、字段头）允许的字节数
+const multipartOverhead = 64 << 10
+
+// UploadBookCover 上传或更换图书封面。图片可以作为请求体直接上传，
+// 也可以通过 multipart 表单的 file 字段上传
+func UploadBookCover(c *gin.Context) {
+	var book models.Book
+	if err := database.DB.First(&book, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, covers.MaxBytes()+multipartOverhead)
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			coverReadError(c, err)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		coverReadError(c, err)
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cover image is required"})
+		return
+	}
+
+	key, contentType, err := covers.Store(c.Request.Context(), book.ID, data)
+	if err != nil {
+		coverError(c, err)
+		return
+	}
+
+	oldKey, oldType := book.CoverKey, book.CoverType
+	if err := database.DB.Model(&book).Updates(map[string]interface{}{
+		"cover_key":  key,
+		"cover_type": contentType,
+	}).Error; err != nil {
+		if err := covers.Remove(c.Request.Context(), key, contentType); err != nil {
+			log.Printf("删除未保存的封面 %s 失败: %v", key, err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update book cover"})
+		return
+	}
+
+	// 旧封面在新封面保存后删除，删除失败只留下无引用的文件
+	if oldKey != "" {
+		if err := covers.Remove(c.Request.Context(), oldKey, oldType); err != nil {
+			log.Printf("删除旧封面 %s 失败: %v", oldKey, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cover": covers.URLs(key, contentType)})
+}
+
+// DeleteBookCover 删除图书封面
+func DeleteBookCover(c *gin.Context) {
+	var book models.Book
+	if err := database.DB.First(&book, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+	if book.CoverKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book has no cover"})
+		return
+	}
+
+	key, contentType := book.CoverKey, book.CoverType
+	if err := database.DB.Model(&book).Updates(map[string]interface{}{
+		"cover_key":  "",
+		"cover_type": "",
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete book cover"})
+		return
+	}
+	if err := covers.Remove(c.Request.Context(), key, contentType); err != nil {
+		log.Printf("删除封面 %s 失败: %v", key, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "cover deleted successfully"})
+}
+
+// coverReadError 处理读取上传文件时的错误
+func coverReadError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": covers.ErrTooLarge.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// coverError 将保存封面时的错误转换为响应
+func coverError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, covers.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, covers.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, covers.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, covers.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("保存封面失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save book cover"})
+	}
+}
+
+// attachCovers 填充图书的封面地址
+func attachCovers(books []models.Book) {
+	for i := range books {
+		books[i].Cover = covers.URLs(books[i].CoverKey, books[i].CoverType)
+	}
+}
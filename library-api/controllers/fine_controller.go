@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// ChargeFineRequest 手动收费请求结构，金额单位为分
+type ChargeFineRequest struct {
+	Type        models.FineType `json:"type" binding:"required,oneof=damaged manual"`
+	Amount      int64           `json:"amount" binding:"required,min=1"`
+	Description string          `json:"description" binding:"max=255"`
+	BorrowID    *uint           `json:"borrow_id"`
+}
+
+// FinePaymentRequest 缴费请求结构，金额单位为分
+type FinePaymentRequest struct {
+	Amount int64  `json:"amount" binding:"required,min=1"`
+	Method string `json:"method" binding:"max=30"`
+	Note   string `json:"note" binding:"max=255"`
+}
+
+// WaiveFineRequest 减免请求结构，金额为0时减免全部余额
+type WaiveFineRequest struct {
+	Amount int64  `json:"amount" binding:"min=0"`
+	Note   string `json:"note" binding:"required,max=255"`
+}
+
+// fineLedger 返回读者的费用明细和未结清总额
+func fineLedger(c *gin.Context, userID interface{}) {
+	var fines []models.Fine
+	if err := database.DB.Where("user_id = ?", userID).
+		Preload("Payments").
+		Order("created_at DESC").
+		Find(&fines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch fines"})
+		return
+	}
+
+	var balance int64
+	for i := range fines {
+		if fines[i].Status == models.FineOpen {
+			balance += fines[i].Balance()
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fines":   fines,
+		"balance": balance,
+	})
+}
+
+// GetMyFines 获取当前用户的费用明细
+func GetMyFines(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	fineLedger(c, userID)
+}
+
+// GetUserFines 获取指定读者的费用明细
+func GetUserFines(c *gin.Context) {
+	var user models.User
+	if result := database.DB.First(&user, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	fineLedger(c, user.ID)
+}
+
+// GetFines 获取全部费用记录，支持按读者和状态过滤
+func GetFines(c *gin.Context) {
+	p := parsePagination(c)
+
+	query := database.DB.Model(&models.Fine{})
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if fineType := c.Query("type"); fineType != "" {
+		query = query.Where("type = ?", fineType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count fines"})
+		return
+	}
+
+	var fines []models.Fine
+	if err := query.Preload("Payments").
+		Order("created_at DESC, id DESC").
+		Offset(p.Offset()).Limit(p.Limit).
+		Find(&fines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch fines"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(fines, total, p))
+}
+
+// ChargeFine 管理员为读者手动添加费用，如损坏赔偿
+func ChargeFine(c *gin.Context) {
+	var req ChargeFineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+
+	var user models.User
+	if result := database.DB.First(&user, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if req.BorrowID != nil {
+		var borrow models.Borrow
+		if result := database.DB.Where("id = ? AND user_id = ?", *req.BorrowID, user.ID).First(&borrow); result.Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "borrow record not found for this user"})
+			return
+		}
+	}
+
+	createdByID := adminID.(uint)
+	fine := models.Fine{
+		UserID:      user.ID,
+		BorrowID:    req.BorrowID,
+		Type:        req.Type,
+		Amount:      req.Amount,
+		Status:      models.FineOpen,
+		Description: req.Description,
+		CreatedByID: &createdByID,
+	}
+
+	if err := database.DB.Create(&fine).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create fine"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, fine)
+}
+
+// settleFine 在事务中对费用执行缴费或减免
+func settleFine(c *gin.Context, apply func(tx *gorm.DB, fine *models.Fine, adminID uint) (*models.FinePayment, error)) {
+	adminID, _ := c.Get("userID")
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var fine models.Fine
+	if result := tx.First(&fine, c.Param("id")); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "fine not found"})
+		return
+	}
+
+	payment, err := apply(tx, &fine, adminID.(uint))
+	if err != nil {
+		tx.Rollback()
+		switch {
+		case errors.Is(err, circulation.ErrFineSettled), errors.Is(err, circulation.ErrAmountExceedsBalance):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update fine"})
+		}
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fine":    fine,
+		"payment": payment,
+	})
+}
+
+// PayFine 记录读者针对某笔费用的缴费
+func PayFine(c *gin.Context) {
+	var req FinePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settleFine(c, func(tx *gorm.DB, fine *models.Fine, adminID uint) (*models.FinePayment, error) {
+		return circulation.RecordPayment(tx, fine, req.Amount, req.Method, req.Note, adminID)
+	})
+}
+
+// WaiveFine 减免费用
+func WaiveFine(c *gin.Context) {
+	var req WaiveFineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settleFine(c, func(tx *gorm.DB, fine *models.Fine, adminID uint) (*models.FinePayment, error) {
+		return circulation.WaiveFine(tx, fine, req.Amount, req.Note, adminID)
+	})
+}
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// PlaceHoldRequest 预约请求结构，请求体可省略。AnyEdition 为 true 时同一作品的其他版本也可满足预约
+type PlaceHoldRequest struct {
+	PickupBranchID *uint `json:"pickup_branch_id"`
+	AnyEdition     bool  `json:"any_edition"`
+}
+
+// MoveHoldRequest 调整预约顺序请求结构
+type MoveHoldRequest struct {
+	Position int `json:"position" binding:"required,min=1"`
+}
+
+// HoldResponse 预约响应结构，待处理预约附带排队名次
+type HoldResponse struct {
+	models.Hold
+	QueuePosition int64 `json:"queue_position,omitempty"`
+}
+
+// PlaceHold 为当前无可借副本的图书加入预约队列。指定取书分馆时，
+// 只要该分馆没有可借副本即可预约，其他分馆的可借副本会调拨过来。
+// 接受任意版本时，同一作品的所有版本都没有可借副本才能预约
+func PlaceHold(c *gin.Context) {
+	id := c.Param("id")
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req PlaceHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var book models.Book
+	if result := database.DB.First(&book, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	if req.AnyEdition && book.WorkID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "book is not part of a work, any_edition is not available"})
+		return
+	}
+
+	if req.PickupBranchID != nil {
+		var branch models.Branch
+		if result := database.DB.First(&branch, *req.PickupBranchID); result.Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pickup branch not found"})
+			return
+		}
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	if _, err := circulation.ExpireHolds(tx, book.ID, now); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to expire holds"})
+		return
+	}
+
+	// 可满足预约的图书：预约的版本，接受任意版本时还包括同一作品的其他版本
+	bookIDs := []uint{book.ID}
+	if req.AnyEdition {
+		if err := tx.Model(&models.Book{}).Where("work_id = ?", *book.WorkID).Pluck("id", &bookIDs).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find editions"})
+			return
+		}
+	}
+
+	// 有可借副本（指定取书分馆时为该分馆的可借副本）时直接借阅即可
+	var availableCount int64
+	availableQuery := tx.Model(&models.BookCopy{}).Where("book_id IN ? AND status = ?", bookIDs, models.CopyAvailable)
+	if req.PickupBranchID != nil {
+		availableQuery = availableQuery.Where("branch_id = ?", *req.PickupBranchID)
+	}
+	availableQuery.Count(&availableCount)
+	if availableCount > 0 {
+		tx.Rollback()
+		if len(bookIDs) > 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "copies of this work are available, borrow one directly"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "copies of this book are available, borrow it directly"})
+		return
+	}
+
+	// 检查用户是否已借阅此书
+	var activeBorrowCount int64
+	tx.Model(&models.Borrow{}).
+		Where("user_id = ? AND book_copy_id IN (SELECT id FROM book_copies WHERE book_id = ?) AND status IN ?",
+			userID, book.ID, circulation.OpenBorrowStatuses).
+		Count(&activeBorrowCount)
+	if activeBorrowCount > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "you already have an active borrow for this book"})
+		return
+	}
+
+	// 检查用户是否已预约此书
+	var activeHoldCount int64
+	tx.Model(&models.Hold{}).
+		Where("user_id = ? AND book_id = ? AND status IN ?", userID, book.ID, circulation.ActiveHoldStatuses).
+		Count(&activeHoldCount)
+	if activeHoldCount > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "you already have a hold on this book"})
+		return
+	}
+
+	position, err := circulation.NextPosition(tx, book.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to determine queue position"})
+		return
+	}
+
+	hold := models.Hold{
+		UserID:         userID.(uint),
+		BookID:         book.ID,
+		PickupBranchID: req.PickupBranchID,
+		AnyEdition:     req.AnyEdition,
+		Position:       position,
+		Status:         models.HoldPending,
+	}
+	if err := tx.Create(&hold).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create hold"})
+		return
+	}
+
+	// 其他分馆有可借副本时立即调拨到取书分馆，预约的版本优先
+	var spare models.BookCopy
+	if result := tx.Where("book_id IN ? AND status = ?", bookIDs, models.CopyAvailable).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "book_id = ? DESC, id ASC", Vars: []interface{}{book.ID}, WithoutParentheses: true}}).
+		Limit(1).
+		Find(&spare); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to route copy"})
+		return
+	} else if result.RowsAffected > 0 {
+		if _, err := circulation.AssignCopy(tx, &spare, now); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to route copy"})
+			return
+		}
+		if err := tx.First(&hold, hold.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch hold"})
+			return
+		}
+	}
+
+	var queuePosition int64
+	if hold.Status == models.HoldPending {
+		queuePosition, err = circulation.QueuePosition(tx, &hold)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to determine queue position"})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, HoldResponse{Hold: hold, QueuePosition: queuePosition})
+}
+
+// GetBookHolds 获取图书的预约队列，过期的预约由定时任务处理
+func GetBookHolds(c *gin.Context) {
+	id := c.Param("id")
+
+	var book models.Book
+	if result := database.DB.First(&book, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	// 已到馆待取的预约排在最前，其次是调拨途中的预约，其余按队列顺序
+	var holds []models.Hold
+	if err := database.DB.Where("book_id = ? AND status IN ?", book.ID, circulation.ActiveHoldStatuses).
+		Preload("User").
+		Order("CASE status WHEN 'ready' THEN 0 WHEN 'in_transit' THEN 1 ELSE 2 END, position ASC, id ASC").
+		Find(&holds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch holds"})
+		return
+	}
+
+	resp := make([]HoldResponse, len(holds))
+	queuePosition := int64(0)
+	for i, h := range holds {
+		resp[i] = HoldResponse{Hold: h}
+		if h.Status == models.HoldPending {
+			queuePosition++
+			resp[i].QueuePosition = queuePosition
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetMyHolds 获取当前用户的预约记录，过期的预约由定时任务处理
+func GetMyHolds(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var holds []models.Hold
+	if err := database.DB.Where("user_id = ?", userID).
+		Preload("Book").
+		Preload("Book.Authors").
+		Preload("BookCopy").
+		Preload("PickupBranch").
+		Order("created_at DESC").
+		Find(&holds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch holds"})
+		return
+	}
+
+	resp := make([]HoldResponse, len(holds))
+	for i := range holds {
+		resp[i] = HoldResponse{Hold: holds[i]}
+		if holds[i].Status == models.HoldPending {
+			position, err := circulation.QueuePosition(database.DB, &holds[i])
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to determine queue position"})
+				return
+			}
+			resp[i].QueuePosition = position
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// CancelHold 取消预约，读者只能取消自己的预约，管理员可取消任意预约
+func CancelHold(c *gin.Context) {
+	bookID := c.Param("id")
+	holdID := c.Param("hold_id")
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	role, _ := c.Get("role")
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var hold models.Hold
+	if result := tx.Where("id = ? AND book_id = ?", holdID, bookID).First(&hold); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "hold not found"})
+		return
+	}
+
+	if hold.UserID != userID.(uint) && role != models.RoleAdmin {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only cancel your own holds"})
+		return
+	}
+
+	if !isActiveHold(hold.Status) {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "hold is no longer active"})
+		return
+	}
+
+	if err := circulation.CancelHold(tx, &hold, time.Now()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel hold"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "hold cancelled successfully"})
+}
+
+// MoveHold 调整待处理预约在队列中的位置
+func MoveHold(c *gin.Context) {
+	bookID := c.Param("id")
+	holdID := c.Param("hold_id")
+
+	var req MoveHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var hold models.Hold
+	if result := tx.Where("id = ? AND book_id = ?", holdID, bookID).First(&hold); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "hold not found"})
+		return
+	}
+
+	if hold.Status != models.HoldPending {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "only pending holds can be reordered"})
+		return
+	}
+
+	if err := circulation.MoveHold(tx, &hold, req.Position); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reorder holds"})
+		return
+	}
+
+	queuePosition, err := circulation.QueuePosition(tx, &hold)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to determine queue position"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, HoldResponse{Hold: hold, QueuePosition: queuePosition})
+}
+
+// isActiveHold 判断预约是否尚未完成
+func isActiveHold(status models.HoldStatus) bool {
+	for _, s := range circulation.ActiveHoldStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
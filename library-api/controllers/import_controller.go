@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/catalog"
+	"github.com/example/library-api/database"
+)
+
+// ImportBooks 从 CSV 或 JSON Lines 批量导入图书。文件可以作为请求体直接上传，
+// 也可以通过 multipart 表单的 file 字段上传
+func ImportBooks(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, catalog.MaxImportBytes())
+
+	var body io.Reader = c.Request.Body
+	filename := ""
+	if c.ContentType() == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			importError(c, err)
+			return
+		}
+		defer file.Close()
+		body = file
+		filename = header.Filename
+	}
+
+	format, err := catalog.DetectFormat(c.Query("format"), c.ContentType(), filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reader, err := catalog.NewReader(format, body)
+	if err != nil {
+		importError(c, err)
+		return
+	}
+
+	report, err := catalog.Import(database.DB, reader, catalog.Options{Mode: c.DefaultQuery("mode", catalog.ModeSkip)})
+	if errors.Is(err, catalog.ErrUnknownMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import file is too large, rows before the limit were saved", "report": report})
+		case errors.Is(err, catalog.ErrUnreadable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + ", rows before the error were saved", "report": report})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "import aborted, rows before the failed batch were saved", "report": report})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// importError 处理读取上传文件或表头时的错误
+func importError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import file is too large"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/jobs"
+)
+
+// GetJobs 获取所有定时任务的最近运行时间和结果
+func GetJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, jobs.Statuses())
+}
+
+// RunJob 立即运行指定的定时任务
+func RunJob(c *gin.Context) {
+	status, err := jobs.RunNow(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrUnknownJob):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, jobs.ErrJobRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/jobs"
+	"github.com/example/library-api/models"
+)
+
+// setupJobTest 使用临时数据库注册定时任务，创建任务接口和归还接口的路由，归还人固定为 reader
+func setupJobTest(t *testing.T, reader models.User) *gin.Engine {
+	t.Helper()
+
+	jobs.Init(database.DB, &config.Config{OverdueJobIntervalMinutes: 60})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/jobs", GetJobs)
+	router.POST("/jobs/:name/run", RunJob)
+	router.POST("/books/return", func(c *gin.Context) {
+		c.Set("userID", reader.ID)
+		c.Set("role", models.RoleUser)
+	}, ReturnBook)
+	return router
+}
+
+// createLongOverdueBorrow 创建已逾期超过丢失期限的借阅
+func createLongOverdueBorrow(t *testing.T) (models.User, models.Borrow) {
+	t.Helper()
+
+	reader := models.User{Username: "reader", Email: "reader@example.com", Password: "secret", Role: models.RoleUser}
+	book := models.Book{Title: "Dune", ISBN: "9780441013593"}
+	for _, v := range []interface{}{&reader, &book} {
+		if err := database.DB.Create(v).Error; err != nil {
+			t.Fatalf("create %T: %v", v, err)
+		}
+	}
+	bookCopy := models.BookCopy{BookID: book.ID, CopyNumber: "C001", Status: models.CopyBorrowed, AcquisitionDate: time.Now()}
+	if err := database.DB.Create(&bookCopy).Error; err != nil {
+		t.Fatalf("create copy: %v", err)
+	}
+	due := time.Now().Add(-90 * 24 * time.Hour)
+	borrow := models.Borrow{UserID: reader.ID, BookCopyID: bookCopy.ID, BorrowDate: due.Add(-14 * 24 * time.Hour), DueDate: due, Status: models.BorrowActive}
+	if err := database.DB.Create(&borrow).Error; err != nil {
+		t.Fatalf("create borrow: %v", err)
+	}
+	return reader, borrow
+}
+
+// serveJSON 发送 JSON 请求并返回响应
+func serveJSON(router *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// runJob 通过接口立即运行任务，返回状态码和任务状态
+func runJob(t *testing.T, router *gin.Engine, name string) (int, jobs.Status) {
+	t.Helper()
+
+	w := serveJSON(router, http.MethodPost, "/jobs/"+name+"/run", "")
+	var status jobs.Status
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+	}
+	return w.Code, status
+}
+
+// assertBorrowAndCopy 检查借阅和副本的状态
+func assertBorrowAndCopy(t *testing.T, borrow models.Borrow, wantBorrow models.BorrowStatus, wantCopy models.BookCopyStatus) {
+	t.Helper()
+
+	var gotBorrow models.Borrow
+	database.DB.First(&gotBorrow, borrow.ID)
+	var gotCopy models.BookCopy
+	database.DB.First(&gotCopy, borrow.BookCopyID)
+	if gotBorrow.Status != wantBorrow || gotCopy.Status != wantCopy {
+		t.Errorf("borrow = %s, copy = %s, want %s and %s", gotBorrow.Status, gotCopy.Status, wantBorrow, wantCopy)
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	databasetest.Use(t)
+	reader, borrow := createLongOverdueBorrow(t)
+	router := setupJobTest(t, reader)
+
+	code, status := runJob(t, router, "overdue")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	if status.Name != "overdue" || status.Runs != 1 || status.LastError != "" {
+		t.Errorf("job status = %+v, want one successful run", status)
+	}
+	if status.LastResult["marked_overdue"] != 1 || status.LastResult["marked_lost"] != 1 {
+		t.Errorf("result = %v, want 1 overdue and 1 lost", status.LastResult)
+	}
+	assertBorrowAndCopy(t, borrow, models.BorrowLost, models.CopyLost)
+
+	var lostFees int64
+	database.DB.Model(&models.Fine{}).Where("borrow_id = ? AND type = ?", borrow.ID, models.FineLost).Count(&lostFees)
+	if lostFees != 1 {
+		t.Errorf("got %d lost fees, want 1", lostFees)
+	}
+
+	if code, _ := runJob(t, router, "missing"); code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	w := serveJSON(router, http.MethodGet, "/jobs", "")
+	var statuses []jobs.Status
+	json.Unmarshal(w.Body.Bytes(), &statuses)
+	for _, s := range statuses {
+		if s.Name == "overdue" && s.Runs != 1 {
+			t.Errorf("listed overdue runs = %d, want 1", s.Runs)
+		}
+	}
+}
+
+func TestOverdueJobRacingReturn(t *testing.T) {
+	databasetest.Use(t)
+	reader, borrow := createLongOverdueBorrow(t)
+	router := setupJobTest(t, reader)
+
+	// 任务查出候选借阅之后、标记丢失之前读者归还图书
+	var returnCode int
+	database.DB.Callback().Query().After("gorm:query").Register("test:return_book", func(tx *gorm.DB) {
+		if returnCode != 0 || tx.Statement.Table != "borrows" {
+			return
+		}
+		returnCode = -1
+		w := serveJSON(router, http.MethodPost, "/books/return", fmt.Sprintf(`{"borrow_id": %d}`, borrow.ID))
+		returnCode = w.Code
+	})
+
+	code, status := runJob(t, router, "overdue")
+	if code != http.StatusOK || status.LastError != "" {
+		t.Fatalf("job = %d %+v, want successful run", code, status)
+	}
+	if returnCode != http.StatusOK {
+		t.Fatalf("return status = %d, want %d", returnCode, http.StatusOK)
+	}
+	if status.LastResult["marked_lost"] != 0 {
+		t.Errorf("marked lost = %d, want 0", status.LastResult["marked_lost"])
+	}
+	assertBorrowAndCopy(t, borrow, models.BorrowReturned, models.CopyAvailable)
+
+	var lostFees int64
+	database.DB.Model(&models.Fine{}).Where("borrow_id = ? AND type = ?", borrow.ID, models.FineLost).Count(&lostFees)
+	if lostFees != 0 {
+		t.Errorf("got %d lost fees for a returned borrow, want none", lostFees)
+	}
+}
+
+func TestReturnAfterOverdueJobMarkedLost(t *testing.T) {
+	databasetest.Use(t)
+	reader, borrow := createLongOverdueBorrow(t)
+	router := setupJobTest(t, reader)
+
+	if code, status := runJob(t, router, "overdue"); code != http.StatusOK || status.LastResult["marked_lost"] != 1 {
+		t.Fatalf("job = %d %+v, want one borrow marked lost", code, status)
+	}
+
+	// 标记丢失后找到的图书仍可归还，丢失赔偿费用被减免
+	w := serveJSON(router, http.MethodPost, "/books/return", fmt.Sprintf(`{"borrow_id": %d}`, borrow.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("return status = %d, body = %s", w.Code, w.Body.String())
+	}
+	assertBorrowAndCopy(t, borrow, models.BorrowReturned, models.CopyAvailable)
+
+	var fine models.Fine
+	database.DB.Where("borrow_id = ? AND type = ?", borrow.ID, models.FineLost).First(&fine)
+	if fine.Status != models.FineWaived {
+		t.Errorf("lost fee = %s, want waived", fine.Status)
+	}
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/keyring"
+)
+
+// GetJWKS 公开访问令牌的验证公钥（JWKS），其他服务据此按 kid 验证令牌，无需共享密钥
+func GetJWKS(c *gin.Context) {
+	// 新密钥公开满重叠期后才用于签名，缓存时间远短于重叠期
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keyring.JWKS())
+}
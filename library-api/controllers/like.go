@@ -0,0 +1,11 @@
+package controllers
+
+import "strings"
+
+// likeEscaper 转义 LIKE 模式中的通配符和转义字符本身
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike 转义用户输入，使 % 和 _ 在 LIKE 中按字面匹配，查询需加 ESCAPE '\'
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
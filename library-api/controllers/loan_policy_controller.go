@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// LoanPolicyRequest 借阅规则更新请求结构
+type LoanPolicyRequest struct {
+	LoanDays            int   `json:"loan_days" binding:"required,min=1"`
+	MaxLoanDays         int   `json:"max_loan_days" binding:"required,min=1,gtefield=LoanDays"`
+	AllowCustomDuration bool  `json:"allow_custom_duration"`
+	MaxBorrows          int   `json:"max_borrows" binding:"min=0"`
+	MaxRenewals         int   `json:"max_renewals" binding:"min=0"`
+	FineDailyRateCents  int64 `json:"fine_daily_rate_cents" binding:"min=0"`
+}
+
+// userRoles 可配置借阅规则的角色
+var userRoles = []models.UserRole{models.RoleUser, models.RoleAdmin}
+
+// currentPolicy 返回当前登录用户适用的借阅规则
+func currentPolicy(c *gin.Context, tx *gorm.DB) (models.LoanPolicy, error) {
+	role, _ := c.Get("role")
+	userRole, _ := role.(models.UserRole)
+	return circulation.ResolvePolicy(tx, userRole)
+}
+
+// policyViolationResponse 返回借阅规则拒绝的原因以及适用的规则
+func policyViolationResponse(c *gin.Context, violation *circulation.PolicyViolation) {
+	status := http.StatusBadRequest
+	if violation.Code == "max_borrows_reached" {
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error":  violation.Message,
+		"reason": violation.Code,
+		"policy": violation.Policy,
+	})
+}
+
+// GetLoanPolicies 获取各角色当前生效的借阅规则
+func GetLoanPolicies(c *gin.Context) {
+	policies := make([]models.LoanPolicy, 0, len(userRoles))
+	for _, role := range userRoles {
+		policy, err := circulation.ResolvePolicy(database.DB, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load loan policies"})
+			return
+		}
+		policies = append(policies, policy)
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+// UpdateLoanPolicy 设置角色的借阅规则
+func UpdateLoanPolicy(c *gin.Context) {
+	role := models.UserRole(c.Param("role"))
+	if role != models.RoleUser && role != models.RoleAdmin {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown role"})
+		return
+	}
+
+	var req LoanPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var policy models.LoanPolicy
+	if err := database.DB.Where("role = ?", role).First(&policy).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load loan policy"})
+		return
+	}
+
+	policy.Role = role
+	policy.LoanDays = req.LoanDays
+	policy.MaxLoanDays = req.MaxLoanDays
+	policy.AllowCustomDuration = req.AllowCustomDuration
+	policy.MaxBorrows = req.MaxBorrows
+	policy.MaxRenewals = req.MaxRenewals
+	policy.FineDailyRateCents = req.FineDailyRateCents
+
+	if err := database.DB.Save(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save loan policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
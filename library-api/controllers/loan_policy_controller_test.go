@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// setupPolicyTest 使用临时数据库创建借阅规则接口和借阅接口的路由，借阅人为 reader
+func setupPolicyTest(t *testing.T) (*gin.Engine, models.User) {
+	t.Helper()
+
+	databasetest.Use(t)
+	reader := models.User{Username: "reader", Email: "reader@example.com", Password: "secret", Role: models.RoleUser}
+	if err := database.DB.Create(&reader).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/loan-policies", GetLoanPolicies)
+	router.PUT("/loan-policies/:role", UpdateLoanPolicy)
+	router.POST("/books/borrow", func(c *gin.Context) {
+		c.Set("userID", reader.ID)
+		c.Set("role", reader.Role)
+	}, BorrowBook)
+	return router, reader
+}
+
+// createAvailableBook 创建带指定数量可借副本的图书
+func createAvailableBook(t *testing.T, isbn string, copies int) models.Book {
+	t.Helper()
+
+	book := models.Book{Title: isbn, ISBN: isbn}
+	if err := database.DB.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	for i := 1; i <= copies; i++ {
+		bookCopy := models.BookCopy{BookID: book.ID, CopyNumber: fmt.Sprintf("C%03d", i), Status: models.CopyAvailable, AcquisitionDate: time.Now()}
+		if err := database.DB.Create(&bookCopy).Error; err != nil {
+			t.Fatalf("create copy: %v", err)
+		}
+	}
+	return book
+}
+
+func TestUpdateLoanPolicyValidation(t *testing.T) {
+	router, _ := setupPolicyTest(t)
+
+	tests := []struct {
+		name string
+		role string
+		body string
+		want int
+	}{
+		{"未知角色", "guest", `{"loan_days": 14, "max_loan_days": 30}`, http.StatusNotFound},
+		{"缺少借期", "user", `{"max_loan_days": 30}`, http.StatusBadRequest},
+		{"借期为零", "user", `{"loan_days": 0, "max_loan_days": 30}`, http.StatusBadRequest},
+		{"最长借期小于默认借期", "user", `{"loan_days": 14, "max_loan_days": 7}`, http.StatusBadRequest},
+		{"在借数量为负", "user", `{"loan_days": 14, "max_loan_days": 30, "max_borrows": -1}`, http.StatusBadRequest},
+		{"续借次数为负", "user", `{"loan_days": 14, "max_loan_days": 30, "max_renewals": -1}`, http.StatusBadRequest},
+		{"罚款标准为负", "user", `{"loan_days": 14, "max_loan_days": 30, "fine_daily_rate_cents": -1}`, http.StatusBadRequest},
+		{"有效规则", "user", `{"loan_days": 7, "max_loan_days": 7, "max_borrows": 1, "max_renewals": 1, "fine_daily_rate_cents": 20}`, http.StatusOK},
+		{"更新已有规则", "user", `{"loan_days": 10, "max_loan_days": 20, "allow_custom_duration": true, "max_borrows": 3}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveJSON(router, http.MethodPut, "/loan-policies/"+tt.role, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// 每个角色只保存一条规则
+	var policies []models.LoanPolicy
+	database.DB.Find(&policies)
+	if len(policies) != 1 || policies[0].LoanDays != 10 || policies[0].MaxLoanDays != 20 || !policies[0].AllowCustomDuration || policies[0].MaxBorrows != 3 {
+		t.Fatalf("policies = %+v, want one updated user policy", policies)
+	}
+
+	w := serveJSON(router, http.MethodGet, "/loan-policies", "")
+	var effective []models.LoanPolicy
+	if err := json.Unmarshal(w.Body.Bytes(), &effective); err != nil {
+		t.Fatalf("decode policies: %v", err)
+	}
+	if len(effective) != 2 || effective[0].LoanDays != 10 || effective[1].Role != models.RoleAdmin || effective[1].MaxBorrows != 0 {
+		t.Errorf("effective policies = %+v, want configured user and default admin policy", effective)
+	}
+}
+
+func TestBorrowBookPolicyRefusal(t *testing.T) {
+	router, reader := setupPolicyTest(t)
+	if w := serveJSON(router, http.MethodPut, "/loan-policies/user", `{"loan_days": 7, "max_loan_days": 14, "allow_custom_duration": true, "max_borrows": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("update policy status = %d, body = %s", w.Code, w.Body.String())
+	}
+	dune := createAvailableBook(t, "9780441013593", 1)
+	emma := createAvailableBook(t, "9780141439587", 1)
+
+	type refusal struct {
+		Error  string            `json:"error"`
+		Reason string            `json:"reason"`
+		Policy models.LoanPolicy `json:"policy"`
+	}
+	borrow := func(body string) (int, refusal) {
+		w := serveJSON(router, http.MethodPost, "/books/borrow", body)
+		var resp refusal
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := borrow(fmt.Sprintf(`{"book_id": %d, "days": 15}`, dune.ID))
+	if code != http.StatusBadRequest || resp.Reason != "loan_period_too_long" || resp.Policy.MaxLoanDays != 14 {
+		t.Errorf("too long = %d %+v, want 400 loan_period_too_long with the policy", code, resp)
+	}
+
+	if code, _ := borrow(fmt.Sprintf(`{"book_id": %d, "days": 14}`, dune.ID)); code != http.StatusOK {
+		t.Fatalf("borrow status = %d, want %d", code, http.StatusOK)
+	}
+	var first models.Borrow
+	database.DB.Where("user_id = ?", reader.ID).First(&first)
+	if days := first.DueDate.Sub(first.BorrowDate).Hours() / 24; days != 14 {
+		t.Errorf("loan period = %v days, want 14", days)
+	}
+
+	code, resp = borrow(fmt.Sprintf(`{"book_id": %d}`, emma.ID))
+	if code != http.StatusConflict || resp.Reason != "max_borrows_reached" || resp.Policy.MaxBorrows != 1 || resp.Error == "" {
+		t.Errorf("second borrow = %d %+v, want 409 max_borrows_reached with the policy", code, resp)
+	}
+}
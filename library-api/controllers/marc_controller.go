@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/catalog"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// marcXMLContentType MARCXML 的媒体类型
+const marcXMLContentType = "application/marcxml+xml; charset=utf-8"
+
+// GetBookMARC 将图书导出为 MARCXML
+func GetBookMARC(c *gin.Context) {
+	var book models.Book
+	if result := database.DB.First(&book, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	c.Header("Content-Type", marcXMLContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="book-%d.xml"`, book.ID))
+	c.Status(http.StatusOK)
+	if err := catalog.ExportBookMARCXML(database.DB, book.ID, c.Writer); err != nil {
+		log.Printf("导出图书 %d 的MARC记录失败: %v", book.ID, err)
+	}
+}
+
+// ExportCatalogue 将整个馆藏导出为 MARCXML，记录按图书ID顺序分批写出
+func ExportCatalogue(c *gin.Context) {
+	if format := c.DefaultQuery("format", catalog.FormatMARCXML); format != catalog.FormatMARCXML {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "export format must be marcxml"})
+		return
+	}
+
+	c.Header("Content-Type", marcXMLContentType)
+	c.Header("Content-Disposition", `attachment; filename="catalogue.xml"`)
+	c.Status(http.StatusOK)
+	// 响应已开始写出，出错时只能记录日志，客户端会收到不完整的文档
+	if err := catalog.ExportMARCXML(database.DB, c.Writer); err != nil {
+		log.Printf("导出馆藏MARC记录失败: %v", err)
+	}
+}
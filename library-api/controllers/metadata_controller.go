@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/isbn"
+	"github.com/example/library-api/metadata"
+	"github.com/example/library-api/models"
+)
+
+// DraftAuthor 书目数据中的作者。Matched 为 true 时 ID 为馆藏中同名作者的ID，
+// 否则为建议添加的新作者，需要先通过添加作者接口创建
+type DraftAuthor struct {
+	ID      uint   `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Matched bool   `json:"matched"`
+}
+
+// BookDraft 按ISBN查询得到的图书草稿，Book 可以补充作者后直接提交给添加图书接口
+type BookDraft struct {
+	Book           BookRequest   `json:"book"`
+	Authors        []DraftAuthor `json:"authors"`
+	Source         string        `json:"source"`
+	Cached         bool          `json:"cached"`
+	ExistingBookID uint          `json:"existing_book_id,omitempty"`
+}
+
+// LookupBook 按ISBN从外部数据源查询书目数据，返回预填的图书草稿
+func LookupBook(c *gin.Context) {
+	if c.Query("isbn") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "isbn is required"})
+		return
+	}
+	canonicalISBN, err := isbn.Normalize(c.Query("isbn"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, cached, err := metadata.Lookup(c.Request.Context(), database.DB, canonicalISBN, c.Query("refresh") == "true")
+	if err != nil {
+		switch {
+		case errors.Is(err, metadata.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, metadata.ErrNoProviders):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			log.Printf("查询ISBN %s 的书目数据失败: %v", canonicalISBN, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "metadata provider unavailable"})
+		}
+		return
+	}
+
+	draft := BookDraft{
+		Book: BookRequest{
+			Title:           result.Title,
+			ISBN:            canonicalISBN,
+			Description:     result.Description,
+			Publisher:       result.Publisher,
+			PublicationDate: result.PublicationDate,
+			AuthorIDs:       []uint{},
+		},
+		Authors: []DraftAuthor{},
+		Source:  result.Source,
+		Cached:  cached,
+	}
+
+	// 作者按名称匹配馆藏中已有的作者（不区分大小写）
+	for _, name := range result.Authors {
+		var author models.Author
+		err := database.DB.Where("LOWER(name) = LOWER(?)", name).Order("id ASC").First(&author).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to match authors"})
+			return
+		}
+		if err == nil {
+			draft.Authors = append(draft.Authors, DraftAuthor{ID: author.ID, Name: author.Name, Matched: true})
+			draft.Book.AuthorIDs = append(draft.Book.AuthorIDs, author.ID)
+		} else {
+			draft.Authors = append(draft.Authors, DraftAuthor{Name: name})
+		}
+	}
+
+	// 馆藏中已有该ISBN时一并返回，避免重复编目
+	owner, err := isbnOwner(canonicalISBN, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check isbn"})
+		return
+	}
+	if owner != nil {
+		draft.ExistingBookID = owner.ID
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/oai"
+)
+
+// OAIPMH 处理 OAI-PMH 请求。协议错误按规范以 200 状态码写在响应文档中
+func OAIPMH(c *gin.Context) {
+	now := time.Now()
+	base := oaiBaseURL(c)
+
+	var resp *oai.Response
+	if err := c.Request.ParseForm(); err != nil {
+		resp = oai.ErrorResponse(base, oai.ErrBadArgument, "request arguments cannot be parsed", now)
+	} else {
+		resp, err = oai.Handle(database.DB, base, c.Request.Form, now)
+		if err != nil {
+			log.Printf("处理 OAI-PMH 请求失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process oai-pmh request"})
+			return
+		}
+	}
+
+	data, err := xml.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode oai-pmh response"})
+		return
+	}
+	c.Data(http.StatusOK, "text/xml; charset=utf-8", append([]byte(xml.Header), data...))
+}
+
+// oaiBaseURL 返回仓储基础地址，未配置时根据请求的协议、主机和路径生成
+func oaiBaseURL(c *gin.Context) string {
+	if base := oai.BaseURL(); base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.Path
+}
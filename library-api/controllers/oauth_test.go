@@ -0,0 +1,532 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/keyring/keyringtest"
+	"github.com/example/library-api/middleware"
+	"github.com/example/library-api/models"
+	"github.com/example/library-api/oidc"
+	"github.com/example/library-api/sessions"
+)
+
+const (
+	testClientID     = "library-test"
+	testClientSecret = "library-secret"
+	testRedirectURI  = "http://library.test/auth/mock/callback"
+	testFrontendURL  = "http://frontend.test/oauth/callback"
+)
+
+// idpUser 模拟身份提供方授权的用户
+type idpUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// idpGrant 已签发但尚未兑换的授权码
+type idpGrant struct {
+	challenge string
+	nonce     string
+	user      idpUser
+}
+
+// fakeIDP 测试用的 OpenID Connect 身份提供方，授权端点直接以 user 的身份授权，
+// 令牌端点要求 PKCE 校验值与挑战值一致，tamper 可以在签名前修改 ID 令牌的声明
+type fakeIDP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu          sync.Mutex
+	user        idpUser
+	deny        bool
+	tamper      func(jwt.MapClaims)
+	grants      map[string]*idpGrant
+	pkceFailure bool
+}
+
+// newFakeIDP 启动模拟身份提供方
+func newFakeIDP(t *testing.T) *fakeIDP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	idp := &fakeIDP{
+		key:    key,
+		user:   idpUser{Subject: "idp-user-1", Email: "reader@example.com", EmailVerified: true, Name: "Ada Reader"},
+		grants: map[string]*idpGrant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *fakeIDP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := idp.server.URL
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                 issuer,
+		"authorization_endpoint": issuer + "/authorize",
+		"token_endpoint":         issuer + "/token",
+		"jwks_uri":               issuer + "/jwks",
+	})
+}
+
+func (idp *fakeIDP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	target, _ := url.Parse(q.Get("redirect_uri"))
+	params := url.Values{"state": {q.Get("state")}}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	switch {
+	case q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURI:
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	case q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		params.Set("error", "invalid_request")
+	case idp.deny:
+		params.Set("error", "access_denied")
+	default:
+		code := base64.RawURLEncoding.EncodeToString([]byte(q.Get("state")))
+		idp.grants[code] = &idpGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), user: idp.user}
+		params.Set("code", code)
+	}
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (idp *fakeIDP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if id != testClientID || secret != testClientSecret {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	g := idp.grants[r.FormValue("code")]
+	delete(idp.grants, r.FormValue("code"))
+	if g == nil || r.FormValue("redirect_uri") != testRedirectURI {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		idp.pkceFailure = true
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            g.user.Subject,
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          g.nonce,
+		"email":          g.user.Email,
+		"email_verified": g.user.EmailVerified,
+		"name":           g.user.Name,
+	}
+	if idp.tamper != nil {
+		idp.tamper(claims)
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(idp.key)
+	if err != nil {
+		writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-" + r.FormValue("code"),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func (idp *fakeIDP) jwks(w http.ResponseWriter, r *http.Request) {
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+// writeTestJSON 写入 JSON 响应
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// setupOAuthTest 使用临时数据库、临时签名密钥和模拟身份提供方创建第三方登录路由
+func setupOAuthTest(t *testing.T) (*gin.Engine, *fakeIDP) {
+	t.Helper()
+
+	idp := newFakeIDP(t)
+	cfg := keyringtest.Init(t)
+	cfg.OAuthFrontendURL = testFrontendURL
+	cfg.OIDCProviders = []config.OIDCProvider{{
+		Name:         "mock",
+		DisplayName:  "Mock",
+		IssuerURL:    idp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURI:  testRedirectURI,
+		Scopes:       []string{"openid", "email", "profile"},
+		Claims:       config.ClaimMapping{Subject: "sub", Email: "email", EmailVerified: "email_verified", Name: "name"},
+	}}
+	sessions.Init(cfg)
+	if err := oidc.Init(cfg); err != nil {
+		t.Fatalf("init oidc: %v", err)
+	}
+	t.Cleanup(func() { oidc.Init(&config.Config{}) })
+
+	databasetest.Use(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/auth/:provider/login", OAuthLogin)
+	router.GET("/auth/:provider/callback", OAuthCallback)
+	router.POST("/auth/:provider/link", LinkOAuthAccount)
+	return router, idp
+}
+
+// oauthStart 请求登录地址，返回身份提供方的授权地址和登录流程 cookie
+func oauthStart(t *testing.T, router *gin.Engine) (*url.URL, *http.Cookie) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/mock/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d, body = %s", w.Code, w.Body.String())
+	}
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oauthFlowCookie {
+			return authURL, cookie
+		}
+	}
+	t.Fatal("login did not set the flow cookie")
+	return nil, nil
+}
+
+// oauthAuthorize 访问身份提供方的授权地址，返回重定向回本应用的回调参数
+func oauthAuthorize(t *testing.T, authURL *url.URL) url.Values {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL.String())
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d", resp.StatusCode)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(callback.String(), testRedirectURI) {
+		t.Fatalf("authorize redirected to %q", resp.Header.Get("Location"))
+	}
+	return callback.Query()
+}
+
+// oauthCallback 带 cookie 请求回调地址，返回重定向到前端时 URL 片段中的参数
+func oauthCallback(t *testing.T, router *gin.Engine, params url.Values, cookie *http.Cookie) url.Values {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/mock/callback?"+params.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("callback status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	location := w.Header().Get("Location")
+	target, fragment, _ := strings.Cut(location, "#")
+	if target != testFrontendURL {
+		t.Fatalf("callback redirected to %q, want the frontend", location)
+	}
+	values, err := url.ParseQuery(fragment)
+	if err != nil {
+		t.Fatalf("parse fragment: %v", err)
+	}
+	return values
+}
+
+// oauthLogin 完成一次完整的登录流程
+func oauthLogin(t *testing.T, router *gin.Engine) url.Values {
+	t.Helper()
+
+	authURL, cookie := oauthStart(t, router)
+	return oauthCallback(t, router, oauthAuthorize(t, authURL), cookie)
+}
+
+// tokenUser 解析访问令牌并返回用户ID
+func tokenUser(t *testing.T, token string) uint {
+	t.Helper()
+
+	claims, err := middleware.ParseToken(token)
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	return claims.UserID
+}
+
+// countUsers 返回用户数
+func countUsers(t *testing.T) int64 {
+	t.Helper()
+
+	var n int64
+	if err := database.DB.Model(&models.User{}).Count(&n).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	return n
+}
+
+func TestOAuthLoginCreatesUser(t *testing.T) {
+	router, _ := setupOAuthTest(t)
+
+	authURL, cookie := oauthStart(t, router)
+	q := authURL.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" || q.Get("state") == "" {
+		t.Errorf("authorization url %s is missing state, nonce or PKCE challenge", authURL)
+	}
+	if cookie.Path != "/auth/mock/" || !cookie.HttpOnly {
+		t.Errorf("cookie path = %q, httponly = %v, want /auth/mock/ and httponly", cookie.Path, cookie.HttpOnly)
+	}
+
+	result := oauthCallback(t, router, oauthAuthorize(t, authURL), cookie)
+	if result.Get("error") != "" || result.Get("token") == "" || result.Get("refresh_token") == "" {
+		t.Fatalf("result = %v, want tokens", result)
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, tokenUser(t, result.Get("token"))).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if user.Email != "reader@example.com" || user.Password != "" {
+		t.Errorf("user = %+v, want new user without a password", user)
+	}
+	var linked models.UserIdentity
+	if err := database.DB.Where("provider = ? AND subject = ?", "mock", "idp-user-1").First(&linked).Error; err != nil || linked.UserID != user.ID {
+		t.Errorf("identity = %+v, %v, want linked to user %d", linked, err, user.ID)
+	}
+
+	// 再次登录使用同一用户
+	again := oauthLogin(t, router)
+	if id := tokenUser(t, again.Get("token")); id != user.ID {
+		t.Errorf("second login user = %d, want %d", id, user.ID)
+	}
+	if n := countUsers(t); n != 1 {
+		t.Errorf("users = %d, want 1", n)
+	}
+}
+
+func TestOAuthCallbackStateMismatch(t *testing.T) {
+	router, _ := setupOAuthTest(t)
+
+	tests := []struct {
+		name   string
+		mutate func(params url.Values, cookie *http.Cookie) *http.Cookie
+	}{
+		{"state 不一致", func(params url.Values, cookie *http.Cookie) *http.Cookie {
+			params.Set("state", "forged-state")
+			return cookie
+		}},
+		{"缺少 state", func(params url.Values, cookie *http.Cookie) *http.Cookie {
+			params.Del("state")
+			return cookie
+		}},
+		{"没有 cookie", func(params url.Values, cookie *http.Cookie) *http.Cookie {
+			return nil
+		}},
+		{"cookie 被篡改", func(params url.Values, cookie *http.Cookie) *http.Cookie {
+			cookie.Value += "x"
+			return cookie
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authURL, cookie := oauthStart(t, router)
+			params := oauthAuthorize(t, authURL)
+			result := oauthCallback(t, router, params, tt.mutate(params, cookie))
+			if result.Get("error") != oauthErrInvalidState || result.Get("token") != "" {
+				t.Errorf("result = %v, want invalid_state", result)
+			}
+		})
+	}
+	if n := countUsers(t); n != 0 {
+		t.Errorf("users = %d, want 0", n)
+	}
+}
+
+func TestOAuthCallbackPKCE(t *testing.T) {
+	router, idp := setupOAuthTest(t)
+
+	// 用另一次登录流程的 cookie 和 state 兑换授权码，提交的 PKCE 校验值与挑战值不符
+	authURL, _ := oauthStart(t, router)
+	params := oauthAuthorize(t, authURL)
+	otherURL, otherCookie := oauthStart(t, router)
+	params.Set("state", otherURL.Query().Get("state"))
+
+	result := oauthCallback(t, router, params, otherCookie)
+	if result.Get("error") != oauthErrProvider {
+		t.Errorf("result = %v, want provider_error", result)
+	}
+	if !idp.pkceFailure {
+		t.Error("identity provider did not reject the code verifier")
+	}
+	if n := countUsers(t); n != 0 {
+		t.Errorf("users = %d, want 0", n)
+	}
+}
+
+func TestOAuthCallbackRejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(jwt.MapClaims)
+	}{
+		{"nonce 不一致", func(c jwt.MapClaims) { c["nonce"] = "other-nonce" }},
+		{"缺少 nonce", func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{"受众错误", func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{"多个受众且 azp 错误", func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "other-client"}
+			c["azp"] = "other-client"
+		}},
+		{"签发方错误", func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }},
+		{"已过期", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"缺少 sub", func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, idp := setupOAuthTest(t)
+			idp.tamper = tt.tamper
+
+			result := oauthLogin(t, router)
+			if result.Get("error") != oauthErrProvider || result.Get("token") != "" {
+				t.Errorf("result = %v, want provider_error", result)
+			}
+			if n := countUsers(t); n != 0 {
+				t.Errorf("users = %d, want 0", n)
+			}
+		})
+	}
+}
+
+func TestOAuthCallbackAccessDenied(t *testing.T) {
+	router, idp := setupOAuthTest(t)
+	idp.deny = true
+
+	if result := oauthLogin(t, router); result.Get("error") != oauthErrAccessDenied {
+		t.Errorf("result = %v, want access_denied", result)
+	}
+}
+
+func TestOAuthCallbackUnverifiedEmail(t *testing.T) {
+	router, idp := setupOAuthTest(t)
+	idp.user.EmailVerified = false
+
+	if result := oauthLogin(t, router); result.Get("error") != oauthErrEmailNotVerified || result.Get("token") != "" {
+		t.Errorf("result = %v, want email_not_verified", result)
+	}
+	if n := countUsers(t); n != 0 {
+		t.Errorf("users = %d, want 0", n)
+	}
+}
+
+func TestOAuthCallbackLinkRequired(t *testing.T) {
+	router, _ := setupOAuthTest(t)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	existing := models.User{Username: "ada", Email: "reader@example.com", Password: string(hash), Role: models.RoleUser}
+	if err := database.DB.Create(&existing).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// 同一邮箱已有账号时不自动登录，返回关联令牌
+	result := oauthLogin(t, router)
+	if result.Get("error") != oauthErrLinkRequired || result.Get("email") != existing.Email || result.Get("token") != "" {
+		t.Fatalf("result = %v, want link_required", result)
+	}
+	linkToken := result.Get("link_token")
+
+	link := func(provider, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(LinkAccountRequest{LinkToken: linkToken, Password: password})
+		req := httptest.NewRequest(http.MethodPost, "/auth/"+provider+"/link", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := link("mock", "wrong-password"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password status = %d, want 401", w.Code)
+	}
+	if w := link("other", "correct-password"); w.Code != http.StatusUnauthorized {
+		t.Errorf("other provider status = %d, want 401", w.Code)
+	}
+
+	w := link("mock", "correct-password")
+	if w.Code != http.StatusOK {
+		t.Fatalf("link status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.UserID != existing.ID || resp.Token == "" {
+		t.Fatalf("link response = %+v, %v, want tokens for user %d", resp, err, existing.ID)
+	}
+
+	// 关联后第三方登录直接进入已有账号
+	again := oauthLogin(t, router)
+	if id := tokenUser(t, again.Get("token")); id != existing.ID {
+		t.Errorf("login after link user = %d, want %d", id, existing.ID)
+	}
+	if n := countUsers(t); n != 1 {
+		t.Errorf("users = %d, want 1", n)
+	}
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Pagination 分页参数
+type Pagination struct {
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+}
+
+// Offset 返回当前页的偏移量
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// parsePagination 从查询参数page和limit解析分页参数，非法值回退为默认值
+func parsePagination(c *gin.Context) Pagination {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	return Pagination{Page: page, Limit: limit}
+}
+
+// pagedResponse 构造统一的分页响应
+func pagedResponse(data interface{}, total int64, p Pagination) gin.H {
+	return gin.H{
+		"data":  data,
+		"total": total,
+		"page":  p.Page,
+		"limit": p.Limit,
+	}
+}
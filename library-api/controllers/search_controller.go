@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/search"
+)
+
+// SearchBooks 全文检索图书，按相关度排序并返回高亮片段和分面统计
+func SearchBooks(c *gin.Context) {
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter q is required"})
+		return
+	}
+
+	p := parsePagination(c)
+	result, err := search.Search(database.DB, search.Params{
+		Query:     q,
+		Publisher: c.Query("publisher"),
+		Year:      c.Query("year"),
+		Offset:    p.Offset(),
+		Limit:     p.Limit,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, search.ErrUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		case errors.Is(err, search.ErrEmptyQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search books"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   result.Hits,
+		"total":  result.Total,
+		"page":   p.Page,
+		"limit":  p.Limit,
+		"facets": result.Facets,
+	})
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/middleware"
+	"github.com/example/library-api/models"
+	"github.com/example/library-api/sessions"
+)
+
+// RefreshRequest 刷新令牌请求结构
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 登出请求结构，请求体可省略，省略时按Authorization头中的访问令牌登出
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := sessions.Refresh(database.DB, req.RefreshToken, sessionClient(c), time.Now())
+	if err != nil {
+		if errors.Is(err, sessions.ErrInvalidRefreshToken) || errors.Is(err, sessions.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout 登出，撤销刷新令牌或访问令牌所属的会话。访问令牌已过期时可只提供刷新令牌
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	var sessionIDs []uint
+
+	// 有效的访问令牌除随会话失效外，还单独记录撤销
+	if tokenString := middleware.BearerToken(c); tokenString != "" {
+		if claims, err := middleware.ParseToken(tokenString); err == nil && claims.SessionID != 0 {
+			if err := sessions.RevokeAccessToken(database.DB, claims.ID, claims.ExpiresAt.Time); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke token"})
+				return
+			}
+			sessionIDs = append(sessionIDs, claims.SessionID)
+		}
+	}
+
+	if req.RefreshToken != "" {
+		sessionID, err := sessions.Lookup(database.DB, req.RefreshToken)
+		if err != nil && !errors.Is(err, sessions.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find session"})
+			return
+		}
+		if err == nil {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+
+	if len(sessionIDs) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "a valid refresh token or access token is required"})
+		return
+	}
+
+	for _, sessionID := range sessionIDs {
+		if _, err := sessions.Revoke(database.DB, sessionID, models.RevokeLogout, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
+
+// GetMySessions 获取当前用户仍然有效的登录会话，current 标记发起请求的会话
+func GetMySessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	list, err := activeSessions(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+	current := c.GetUint("sessionID")
+	for i := range list {
+		list[i].Current = list[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// RevokeMySession 撤销当前用户的一个登录会话，用于注销其他设备上的登录
+func RevokeMySession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var session models.Session
+	if result := database.DB.Where("user_id = ? AND revoked_at IS NULL", userID).First(&session, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	if _, err := sessions.Revoke(database.DB, session.ID, models.RevokeUser, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked successfully"})
+}
+
+// GetUserSessions 管理员查看用户仍然有效的登录会话
+func GetUserSessions(c *gin.Context) {
+	var user models.User
+	if result := database.DB.First(&user, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	list, err := activeSessions(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// RevokeUserSessions 管理员撤销用户的全部登录会话，用于令牌泄露或停用账号
+func RevokeUserSessions(c *gin.Context) {
+	var user models.User
+	if result := database.DB.First(&user, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	revoked, err := sessions.RevokeUser(database.DB, user.ID, models.RevokeUser, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "sessions revoked successfully", "revoked": revoked})
+}
+
+// activeSessions 查询用户未撤销且未过期的会话，最近使用的在前
+func activeSessions(userID uint) ([]models.Session, error) {
+	list := []models.Session{}
+	err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC, id DESC").
+		Find(&list).Error
+	return list, err
+}
+
+// sessionClient 从请求中取出客户端信息
+func sessionClient(c *gin.Context) sessions.Client {
+	return sessions.Client{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
@@ -0,0 +1,474 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+	"github.com/example/library-api/subjects"
+)
+
+// CategoryRequest 分类创建/更新请求结构，ParentID 为空表示顶级分类
+type CategoryRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	ParentID    *uint  `json:"parent_id"`
+	Description string `json:"description"`
+}
+
+// TagRequest 标签重命名请求结构
+type TagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeTagsRequest 合并标签请求结构
+type MergeTagsRequest struct {
+	SourceID uint `json:"source_id" binding:"required"`
+}
+
+// errInvalidCategory 图书请求中有不存在的分类
+var errInvalidCategory = errors.New("one or more category IDs are invalid")
+
+// CategoryDetail 分类详情，包含从顶级分类开始的祖先分类和子分类树
+type CategoryDetail struct {
+	*models.Category
+	Ancestors []models.Category `json:"ancestors"`
+}
+
+// GetCategories 获取完整的分类树，每个分类附带包含后代分类在内的图书数，用于浏览侧栏
+func GetCategories(c *gin.Context) {
+	roots, _, err := subjects.Tree(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roots)
+}
+
+// GetCategory 获取分类详情
+func GetCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	_, byID, err := subjects.Tree(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
+		return
+	}
+	node, ok := byID[uint(id)]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	detail := CategoryDetail{Category: node, Ancestors: []models.Category{}}
+	for parentID := node.ParentID; parentID != nil; {
+		parent, ok := byID[*parentID]
+		if !ok || len(detail.Ancestors) > len(byID) {
+			break
+		}
+		ancestor := *parent
+		ancestor.Children = nil
+		detail.Ancestors = append([]models.Category{ancestor}, detail.Ancestors...)
+		parentID = parent.ParentID
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// CreateCategory 添加分类
+func CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := models.Category{
+		ParentID:    req.ParentID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+	}
+	if status, err := checkCategory(&category); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create category"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory 更新分类，修改 parent_id 可将分类连同其子分类移动到其他分类下
+func UpdateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var category models.Category
+	if result := database.DB.First(&category, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	category.ParentID = req.ParentID
+	category.Name = strings.TrimSpace(req.Name)
+	category.Description = req.Description
+	if status, err := checkCategory(&category); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Save(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update category"})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// checkCategory 校验父分类和同级分类名称，返回的错误信息可直接返回给客户端
+func checkCategory(category *models.Category) (int, error) {
+	if category.Name == "" {
+		return http.StatusBadRequest, errors.New("name must not be blank")
+	}
+
+	if category.ParentID != nil {
+		var parent models.Category
+		if err := database.DB.First(&parent, *category.ParentID).Error; err != nil {
+			return http.StatusBadRequest, errors.New("parent category not found")
+		}
+		// 不能移动到自身或自身的后代分类下
+		if category.ID != 0 {
+			inSubtree, err := subjects.InSubtree(database.DB, category.ID, parent.ID)
+			if err != nil {
+				return http.StatusInternalServerError, errors.New("failed to check category tree")
+			}
+			if inSubtree {
+				return http.StatusBadRequest, errors.New("category cannot be moved under itself or its subcategories")
+			}
+		}
+	}
+
+	// 同一父分类下名称不能重复（不区分大小写）
+	query := database.DB.Model(&models.Category{}).Where("LOWER(name) = LOWER(?) AND id <> ?", category.Name, category.ID)
+	if category.ParentID != nil {
+		query = query.Where("parent_id = ?", *category.ParentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return http.StatusInternalServerError, errors.New("failed to check category name")
+	}
+	if count > 0 {
+		return http.StatusConflict, errors.New("category with this name already exists under the parent")
+	}
+
+	return 0, nil
+}
+
+// DeleteCategory 删除分类，有子分类时不能删除；图书与该分类的关联一并删除
+func DeleteCategory(c *gin.Context) {
+	var category models.Category
+	if result := database.DB.First(&category, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	var children int64
+	if err := database.DB.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check subcategories"})
+		return
+	}
+	if children > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete category with subcategories"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Exec("DELETE FROM book_categories WHERE category_id = ?", category.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove book links"})
+		return
+	}
+
+	if err := tx.Delete(&category).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete category"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully"})
+}
+
+// GetTags 获取标签列表及每个标签的图书数，按图书数从多到少排序，支持按名称前缀搜索和分页
+func GetTags(c *gin.Context) {
+	p := parsePagination(c)
+
+	query := database.DB.Model(&models.Tag{})
+	if q := c.Query("q"); q != "" {
+		query = query.Where(`tags.name LIKE ? ESCAPE '\'`, escapeLike(strings.ToLower(strings.TrimSpace(q)))+"%")
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count tags"})
+		return
+	}
+
+	var rows []struct {
+		models.Tag
+		Count int64
+	}
+	if err := query.Select("tags.*, COUNT(books.id) AS count").
+		Joins("LEFT JOIN book_tags ON book_tags.tag_id = tags.id").
+		Joins("LEFT JOIN books ON books.id = book_tags.book_id AND books.deleted_at IS NULL").
+		Group("tags.id").
+		Order("count DESC, tags.name ASC").
+		Offset(p.Offset()).Limit(p.Limit).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tags"})
+		return
+	}
+
+	tags := make([]models.Tag, len(rows))
+	for i, row := range rows {
+		tags[i] = row.Tag
+		tags[i].BookCount = &rows[i].Count
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(tags, total, p))
+}
+
+// UpdateTag 重命名标签，新名称已被其他标签使用时返回冲突，此时应使用合并接口
+func UpdateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, err := subjects.NormalizeTag(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tag models.Tag
+	if result := database.DB.First(&tag, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	tag.Name = name
+	if err := database.DB.Save(&tag).Error; err != nil {
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "tag with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// MergeTags 将重复标签合并到规范标签，所有图书关联改为指向规范标签
+func MergeTags(c *gin.Context) {
+	var req MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var target models.Tag
+	if result := database.DB.First(&target, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	if req.SourceID == target.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot merge a tag into itself"})
+		return
+	}
+
+	var source models.Tag
+	if result := database.DB.First(&source, req.SourceID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "source tag not found"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 删除两个标签都关联的图书中重复标签的关联，避免主键冲突
+	if err := tx.Exec(`DELETE FROM book_tags WHERE tag_id = ?
+		AND book_id IN (SELECT book_id FROM book_tags WHERE tag_id = ?)`, source.ID, target.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove duplicate book links"})
+		return
+	}
+
+	// 其余关联改为指向规范标签
+	moved := tx.Exec("UPDATE book_tags SET tag_id = ? WHERE tag_id = ?", target.ID, source.ID)
+	if moved.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to re-point book links"})
+		return
+	}
+
+	if err := tx.Delete(&source).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete merged tag"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "tags merged successfully",
+		"tag":         target,
+		"merged_id":   source.ID,
+		"books_moved": moved.RowsAffected,
+	})
+}
+
+// DeleteTag 删除标签及其图书关联
+func DeleteTag(c *gin.Context) {
+	var tag models.Tag
+	if result := database.DB.First(&tag, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Exec("DELETE FROM book_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove book links"})
+		return
+	}
+
+	if err := tx.Delete(&tag).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete tag"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag deleted successfully"})
+}
+
+// parseBookSubjects 校验图书请求中的分类和标签，请求省略的字段返回 nil
+func parseBookSubjects(req BookRequest) ([]models.Category, []string, error) {
+	var categories []models.Category
+	if req.CategoryIDs != nil {
+		var err error
+		if categories, err = resolveCategories(req.CategoryIDs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var tagNames []string
+	if req.Tags != nil {
+		var err error
+		if tagNames, err = subjects.NormalizeTags(req.Tags); err != nil {
+			return nil, nil, err
+		}
+	}
+	return categories, tagNames, nil
+}
+
+// resolveCategories 按ID查找分类，ID重复时只取一次，有分类不存在时返回错误
+func resolveCategories(ids []uint) ([]models.Category, error) {
+	unique := make([]uint, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	categories := []models.Category{}
+	if len(unique) == 0 {
+		return categories, nil
+	}
+	if err := database.DB.Find(&categories, unique).Error; err != nil {
+		return nil, err
+	}
+	if len(categories) != len(unique) {
+		return nil, errInvalidCategory
+	}
+	return categories, nil
+}
+
+// replaceBookSubjects 在事务中替换图书的分类和标签，为 nil 的字段保持不变
+func replaceBookSubjects(tx *gorm.DB, book *models.Book, categories []models.Category, tagNames []string) error {
+	if categories != nil {
+		if err := tx.Model(book).Association("Categories").Replace(categories); err != nil {
+			return err
+		}
+	}
+	if tagNames != nil {
+		tags, err := subjects.ResolveTags(tx, tagNames)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(book).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// subjectRouter 注册分类和标签接口，不经过鉴权中间件
+func subjectRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/categories/:id", UpdateCategory)
+	router.GET("/tags", GetTags)
+	router.POST("/tags/:id/merge", MergeTags)
+	return router
+}
+
+func TestUpdateCategoryPreventsCycles(t *testing.T) {
+	databasetest.Use(t)
+	fiction := models.Category{Name: "Fiction"}
+	database.DB.Create(&fiction)
+	scifi := models.Category{Name: "Science Fiction", ParentID: &fiction.ID}
+	database.DB.Create(&scifi)
+	space := models.Category{Name: "Space Opera", ParentID: &scifi.ID}
+	database.DB.Create(&space)
+	history := models.Category{Name: "History"}
+	database.DB.Create(&history)
+
+	router := subjectRouter()
+	tests := []struct {
+		name     string
+		id       uint
+		parentID uint
+		want     int
+	}{
+		{"移到自身下", fiction.ID, fiction.ID, http.StatusBadRequest},
+		{"移到子分类下", fiction.ID, scifi.ID, http.StatusBadRequest},
+		{"移到孙分类下", fiction.ID, space.ID, http.StatusBadRequest},
+		{"移到其他分类下", scifi.ID, history.ID, http.StatusOK},
+		// 移动后原来的父分类不再是祖先
+		{"移到原父分类下", fiction.ID, space.ID, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var category models.Category
+			database.DB.First(&category, tt.id)
+			body := fmt.Sprintf(`{"name": %q, "parent_id": %d}`, category.Name, tt.parentID)
+			w := serveJSON(router, http.MethodPut, fmt.Sprintf("/categories/%d", tt.id), body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body.String())
+			}
+
+			database.DB.First(&category, tt.id)
+			moved := category.ParentID != nil && *category.ParentID == tt.parentID
+			if moved != (tt.want == http.StatusOK) {
+				t.Errorf("parent_id = %v, moved %v, want moved %v", category.ParentID, moved, tt.want == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	databasetest.Use(t)
+	scifi := models.Tag{Name: "sci-fi"}
+	database.DB.Create(&scifi)
+	sf := models.Tag{Name: "sf"}
+	database.DB.Create(&sf)
+
+	both := models.Book{Title: "Dune", ISBN: "9780441013593", Tags: []models.Tag{scifi, sf}}
+	database.DB.Create(&both)
+	onlySource := models.Book{Title: "Hyperion", ISBN: "9780306406157", Tags: []models.Tag{sf}}
+	database.DB.Create(&onlySource)
+	onlyTarget := models.Book{Title: "Foundation", ISBN: "9780141439587", Tags: []models.Tag{scifi}}
+	database.DB.Create(&onlyTarget)
+
+	router := subjectRouter()
+	w := serveJSON(router, http.MethodPost, fmt.Sprintf("/tags/%d/merge", scifi.ID), fmt.Sprintf(`{"source_id": %d}`, sf.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		MergedID   uint  `json:"merged_id"`
+		BooksMoved int64 `json:"books_moved"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.MergedID != sf.ID || resp.BooksMoved != 1 {
+		t.Errorf("response = %+v, want merged %d with 1 book moved", resp, sf.ID)
+	}
+
+	// 两个标签都有的图书只保留一条关联
+	var links []uint
+	database.DB.Table("book_tags").Where("tag_id = ?", scifi.ID).Pluck("book_id", &links)
+	sort.Slice(links, func(i, j int) bool { return links[i] < links[j] })
+	if want := []uint{both.ID, onlySource.ID, onlyTarget.ID}; !reflect.DeepEqual(links, want) {
+		t.Errorf("sci-fi books = %v, want %v", links, want)
+	}
+	var remaining int64
+	database.DB.Table("book_tags").Where("tag_id = ?", sf.ID).Count(&remaining)
+	if err := database.DB.First(&models.Tag{}, sf.ID).Error; err == nil || remaining != 0 {
+		t.Errorf("source tag still exists with %d links", remaining)
+	}
+
+	// 不能合并到自身
+	w = serveJSON(router, http.MethodPost, fmt.Sprintf("/tags/%d/merge", scifi.ID), fmt.Sprintf(`{"source_id": %d}`, scifi.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("self merge status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetTagsSearchIsLiteral(t *testing.T) {
+	databasetest.Use(t)
+	for _, name := range []string{"100% cotton", "1000 words", "under_score", "underxscore"} {
+		database.DB.Create(&models.Tag{Name: name})
+	}
+
+	router := subjectRouter()
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"100%", []string{"100% cotton"}},
+		{"Under_", []string{"under_score"}},
+		{"under", []string{"under_score", "underxscore"}},
+	}
+	for _, tt := range tests {
+		w := serveJSON(router, http.MethodGet, "/tags?q="+url.QueryEscape(tt.q), "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("q=%q status = %d, body = %s", tt.q, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data []models.Tag `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		var got []string
+		for _, tag := range resp.Data {
+			got = append(got, tag.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("q=%q tags = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// TransferRequest 申请调拨请求结构
+type TransferRequest struct {
+	ToBranchID uint   `json:"to_branch_id" binding:"required"`
+	Note       string `json:"note" binding:"max=255"`
+}
+
+// GetTransfers 获取调拨记录，可按状态、原分馆和目的分馆筛选
+func GetTransfers(c *gin.Context) {
+	p := parsePagination(c)
+
+	query := database.DB.Model(&models.Transfer{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if fromBranchID := c.Query("from_branch_id"); fromBranchID != "" {
+		query = query.Where("from_branch_id = ?", fromBranchID)
+	}
+	if toBranchID := c.Query("to_branch_id"); toBranchID != "" {
+		query = query.Where("to_branch_id = ?", toBranchID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count transfers"})
+		return
+	}
+
+	var transfers []models.Transfer
+	if err := query.Preload("BookCopy").
+		Preload("BookCopy.Book").
+		Preload("FromBranch").
+		Preload("ToBranch").
+		Order("requested_at DESC, id DESC").
+		Offset(p.Offset()).Limit(p.Limit).
+		Find(&transfers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(transfers, total, p))
+}
+
+// GetTransfer 获取调拨详情
+func GetTransfer(c *gin.Context) {
+	var transfer models.Transfer
+	if result := database.DB.Preload("BookCopy").
+		Preload("BookCopy.Book").
+		Preload("FromBranch").
+		Preload("ToBranch").
+		First(&transfer, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// RequestTransfer 申请将可借副本调拨到其他分馆
+func RequestTransfer(c *gin.Context) {
+	var req TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+
+	var branch models.Branch
+	if result := database.DB.First(&branch, req.ToBranchID); result.Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "destination branch not found"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var bookCopy models.BookCopy
+	if result := tx.First(&bookCopy, c.Param("id")); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "book copy not found"})
+		return
+	}
+
+	transfer, err := circulation.RequestTransfer(tx, &bookCopy, req.ToBranchID, req.Note, adminID.(uint), time.Now())
+	if err != nil {
+		tx.Rollback()
+		switch {
+		case errors.Is(err, circulation.ErrSameBranch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, circulation.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "only available copies can be transferred"})
+		case errors.Is(err, circulation.ErrCopyStatusChanged):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to request transfer"})
+		}
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// ShipTransfer 原分馆发出调拨副本
+func ShipTransfer(c *gin.Context) {
+	updateTransfer(c, func(tx *gorm.DB, transfer *models.Transfer, adminID uint, now time.Time) error {
+		return circulation.ShipTransfer(tx, transfer, adminID, now)
+	})
+}
+
+// ReceiveTransfer 目的分馆接收调拨副本
+func ReceiveTransfer(c *gin.Context) {
+	updateTransfer(c, func(tx *gorm.DB, transfer *models.Transfer, adminID uint, now time.Time) error {
+		_, err := circulation.ReceiveTransfer(tx, transfer, adminID, now)
+		return err
+	})
+}
+
+// CancelTransfer 取消尚未发出的调拨
+func CancelTransfer(c *gin.Context) {
+	updateTransfer(c, func(tx *gorm.DB, transfer *models.Transfer, adminID uint, now time.Time) error {
+		return circulation.CancelTransfer(tx, transfer, adminID, now)
+	})
+}
+
+// updateTransfer 在事务中对调拨执行操作并返回更新后的调拨和副本
+func updateTransfer(c *gin.Context, action func(tx *gorm.DB, transfer *models.Transfer, adminID uint, now time.Time) error) {
+	adminID, _ := c.Get("userID")
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var transfer models.Transfer
+	if result := tx.First(&transfer, c.Param("id")); result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		return
+	}
+
+	if err := action(tx, &transfer, adminID.(uint), time.Now()); err != nil {
+		tx.Rollback()
+		if errors.Is(err, circulation.ErrTransferState) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": transfer.Status})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update transfer"})
+		return
+	}
+
+	if err := tx.Preload("BookCopy").
+		Preload("FromBranch").
+		Preload("ToBranch").
+		First(&transfer, transfer.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transfer"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
@@ -7,10 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 
-	"github.com/example/library-api/config"
 	"github.com/example/library-api/database"
-	"github.com/example/library-api/middleware"
 	"github.com/example/library-api/models"
+	"github.com/example/library-api/sessions"
 )
 
 // LoginRequest 登录请求结构
@@ -26,14 +25,13 @@ import (
 	Password string `json:"password" binding:"required,min=6"`
 }
 
-// LoginResponse 登录响应结构
+// LoginResponse 登录响应结构，包含访问令牌和刷新令牌
  type LoginResponse struct {
-	Token     string       `json:"token"`
+	sessions.Tokens
 	UserID    uint         `json:"user_id"`
 	Username  string       `json:"username"`
 	Email     string       `json:"email"`
 	Role      models.UserRole `json:"role"`
-	ExpiresAt int64        `json:"expires_at"`
 }
 
 // Register 用户注册
@@ -101,16 +99,8 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 加载配置
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load config"})
-		return
-	}
-
-	// 生成JWT令牌
-	expirationTime := time.Now().Add(time.Duration(cfg.JWTExpiryHours) * time.Hour)
-	tokenString, err := middleware.GenerateToken(user.ID, user.Role)
+	// 创建登录会话并签发令牌
+	tokens, err := sessions.Start(database.DB, &user, sessionClient(c), time.Now())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
@@ -118,12 +108,11 @@ func Login(c *gin.Context) {
 
 	// 返回响应
 	c.JSON(http.StatusOK, LoginResponse{
-		Token:     tokenString,
-		UserID:    user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		Role:      user.Role,
-		ExpiresAt: expirationTime.Unix(),
+		Tokens:   *tokens,
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
 	})
 }
 
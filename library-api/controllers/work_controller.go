@@ -0,0 +1,447 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/models"
+)
+
+// WorkRequest 作品创建/更新请求结构，BookIDs 仅在创建时使用，将这些图书作为作品的版本
+type WorkRequest struct {
+	Title            string `json:"title" binding:"required,max=200"`
+	OriginalLanguage string `json:"original_language" binding:"max=10"`
+	Description      string `json:"description"`
+	BookIDs          []uint `json:"book_ids"`
+}
+
+// WorkEditionsRequest 为作品添加版本请求结构
+type WorkEditionsRequest struct {
+	BookIDs []uint `json:"book_ids" binding:"required,min=1"`
+}
+
+// SeriesRequest 丛书创建/更新请求结构
+type SeriesRequest struct {
+	Name        string `json:"name" binding:"required,max=200"`
+	Description string `json:"description"`
+}
+
+// SeriesVolumeRequest 设置图书在丛书中的卷号请求结构，卷号可以是小数，如 2.5 表示第2卷和第3卷之间的外传
+type SeriesVolumeRequest struct {
+	Number *float64 `json:"number" binding:"required,gt=0"`
+}
+
+// GetWorks 获取作品列表，支持按标题搜索和分页
+func GetWorks(c *gin.Context) {
+	p := parsePagination(c)
+
+	query := database.DB.Model(&models.Work{})
+	if q := c.Query("q"); q != "" {
+		query = query.Where(`title LIKE ? ESCAPE '\'`, "%"+escapeLike(q)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count works"})
+		return
+	}
+
+	var works []models.Work
+	if err := query.Order("title ASC, id ASC").Offset(p.Offset()).Limit(p.Limit).Find(&works).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch works"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(works, total, p))
+}
+
+// GetWork 获取作品详情，版本按出版日期排列并附带各版本的副本数量
+func GetWork(c *gin.Context) {
+	var work models.Work
+	if result := database.DB.Preload("Editions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("books.publication_date ASC, books.id ASC")
+	}).Preload("Editions.Authors").First(&work, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "work not found"})
+		return
+	}
+
+	if work.Editions == nil {
+		work.Editions = []models.Book{}
+	}
+	if err := attachAvailability(work.Editions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count copies"})
+		return
+	}
+	attachCovers(work.Editions)
+
+	c.JSON(http.StatusOK, work)
+}
+
+// CreateWork 添加作品，可同时将已有图书归入该作品
+func CreateWork(c *gin.Context) {
+	var req WorkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	work := models.Work{
+		Title:            req.Title,
+		OriginalLanguage: normalizeLanguage(req.OriginalLanguage),
+		Description:      req.Description,
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&work).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create work"})
+		return
+	}
+
+	if len(req.BookIDs) > 0 {
+		status, msg := setBookWork(tx, req.BookIDs, work.ID)
+		if status != 0 {
+			tx.Rollback()
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, work)
+}
+
+// UpdateWork 更新作品信息
+func UpdateWork(c *gin.Context) {
+	var req WorkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var work models.Work
+	if result := database.DB.First(&work, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "work not found"})
+		return
+	}
+
+	work.Title = req.Title
+	work.OriginalLanguage = normalizeLanguage(req.OriginalLanguage)
+	work.Description = req.Description
+	if err := database.DB.Save(&work).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update work"})
+		return
+	}
+
+	c.JSON(http.StatusOK, work)
+}
+
+// DeleteWork 删除作品，仍有版本的作品不能删除，应先移除版本
+func DeleteWork(c *gin.Context) {
+	var work models.Work
+	if result := database.DB.First(&work, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "work not found"})
+		return
+	}
+
+	var editions int64
+	if err := database.DB.Model(&models.Book{}).Where("work_id = ?", work.ID).Count(&editions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check editions"})
+		return
+	}
+	if editions > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete work with editions"})
+		return
+	}
+
+	if err := database.DB.Delete(&work).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete work"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "work deleted successfully"})
+}
+
+// AddWorkEditions 将图书归入作品，已属于其他作品的图书会移动到该作品
+func AddWorkEditions(c *gin.Context) {
+	var req WorkEditionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var work models.Work
+	if result := database.DB.First(&work, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "work not found"})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	status, msg := setBookWork(tx, req.BookIDs, work.ID)
+	if status != 0 {
+		tx.Rollback()
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "editions added successfully"})
+}
+
+// RemoveWorkEdition 将图书移出作品。该书上接受任意版本的预约此后只由该书的副本满足
+func RemoveWorkEdition(c *gin.Context) {
+	var book models.Book
+	if result := database.DB.Where("work_id = ?", c.Param("id")).First(&book, c.Param("book_id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "edition not found"})
+		return
+	}
+
+	if err := database.DB.Model(&book).Update("work_id", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove edition"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "edition removed successfully"})
+}
+
+// setBookWork 在事务中将图书归入作品，返回非0状态码时附带错误信息
+func setBookWork(tx *gorm.DB, bookIDs []uint, workID uint) (int, string) {
+	var count int64
+	if err := tx.Model(&models.Book{}).Where("id IN ?", bookIDs).Count(&count).Error; err != nil {
+		return http.StatusInternalServerError, "failed to find books"
+	}
+	if count != int64(len(bookIDs)) {
+		return http.StatusBadRequest, "one or more book IDs are invalid"
+	}
+
+	if err := tx.Model(&models.Book{}).Where("id IN ?", bookIDs).Update("work_id", workID).Error; err != nil {
+		return http.StatusInternalServerError, "failed to add editions"
+	}
+	return 0, ""
+}
+
+// GetSeriesList 获取丛书列表，支持按名称搜索和分页
+func GetSeriesList(c *gin.Context) {
+	p := parsePagination(c)
+
+	query := database.DB.Model(&models.Series{})
+	if q := c.Query("q"); q != "" {
+		query = query.Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(q)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count series"})
+		return
+	}
+
+	var series []models.Series
+	if err := query.Order("name ASC, id ASC").Offset(p.Offset()).Limit(p.Limit).Find(&series).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagedResponse(series, total, p))
+}
+
+// GetSeries 获取丛书详情
+func GetSeries(c *gin.Context) {
+	var series models.Series
+	if result := database.DB.First(&series, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "series not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// GetSeriesBooks 按卷号顺序获取丛书中的图书，同一卷的不同版本按出版日期排列
+func GetSeriesBooks(c *gin.Context) {
+	p := parsePagination(c)
+
+	var series models.Series
+	if result := database.DB.First(&series, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "series not found"})
+		return
+	}
+
+	query := database.DB.Model(&models.Book{}).Where("series_id = ?", series.ID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count books"})
+		return
+	}
+
+	var books []models.Book
+	if err := query.Preload("Authors").
+		Order("books.series_number ASC, books.publication_date ASC, books.id ASC").
+		Offset(p.Offset()).Limit(p.Limit).
+		Find(&books).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch books"})
+		return
+	}
+
+	if err := attachAvailability(books); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count copies"})
+		return
+	}
+	attachCovers(books)
+
+	c.JSON(http.StatusOK, pagedResponse(books, total, p))
+}
+
+// CreateSeries 添加丛书
+func CreateSeries(c *gin.Context) {
+	var req SeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series := models.Series{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := database.DB.Create(&series).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create series"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, series)
+}
+
+// UpdateSeries 更新丛书信息
+func UpdateSeries(c *gin.Context) {
+	var req SeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var series models.Series
+	if result := database.DB.First(&series, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "series not found"})
+		return
+	}
+
+	series.Name = req.Name
+	series.Description = req.Description
+	if err := database.DB.Save(&series).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// DeleteSeries 删除丛书，丛书中仍有图书时不能删除
+func DeleteSeries(c *gin.Context) {
+	var series models.Series
+	if result := database.DB.First(&series, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "series not found"})
+		return
+	}
+
+	var books int64
+	if err := database.DB.Model(&models.Book{}).Where("series_id = ?", series.ID).Count(&books).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check series books"})
+		return
+	}
+	if books > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete series with books"})
+		return
+	}
+
+	if err := database.DB.Delete(&series).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "series deleted successfully"})
+}
+
+// SetSeriesVolume 将图书加入丛书或修改其卷号，已属于其他丛书的图书会移动到该丛书
+func SetSeriesVolume(c *gin.Context) {
+	var req SeriesVolumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var series models.Series
+	if result := database.DB.First(&series, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "series not found"})
+		return
+	}
+
+	var book models.Book
+	if result := database.DB.First(&book, c.Param("book_id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	if err := database.DB.Model(&book).Updates(map[string]interface{}{
+		"series_id":     series.ID,
+		"series_number": *req.Number,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update series volume"})
+		return
+	}
+
+	c.JSON(http.StatusOK, book)
+}
+
+// RemoveSeriesVolume 将图书移出丛书
+func RemoveSeriesVolume(c *gin.Context) {
+	var book models.Book
+	if result := database.DB.Where("series_id = ?", c.Param("id")).First(&book, c.Param("book_id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found in series"})
+		return
+	}
+
+	if err := database.DB.Model(&book).Updates(map[string]interface{}{
+		"series_id":     nil,
+		"series_number": nil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove book from series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "book removed from series successfully"})
+}
+
+// normalizeLanguage 规范化语言代码（如 en、zh-CN），统一为小写
+func normalizeLanguage(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
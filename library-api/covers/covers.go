@@ -0,0 +1,200 @@
+// Package covers 图书封面图片的校验、缩略图生成和存储
+//
+// 上传的图片按内容识别格式（JPEG、PNG、GIF），原图原样保存，另外按固定宽度生成 JPEG 缩略图。
+// 同一封面的所有文件共用一个随机前缀，如 covers/12/3f9a0c1b-small.jpg；更换封面时使用新前缀，
+// 浏览器和 CDN 缓存的旧地址不会显示为新图片。
+package covers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"strings"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/models"
+)
+
+// 存储后端
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+const (
+	// maxPixels 解码前检查的最大像素数，防止小文件解码出超大图片耗尽内存
+	maxPixels = 25_000_000
+	// thumbnailQuality 缩略图的 JPEG 质量
+	thumbnailQuality = 85
+)
+
+// 缩略图宽度（像素）
+const (
+	smallWidth  = 160
+	mediumWidth = 480
+)
+
+var (
+	// ErrTooLarge 图片文件或像素尺寸超过限制
+	ErrTooLarge = errors.New("cover image is too large")
+	// ErrUnsupportedType 不支持的图片格式
+	ErrUnsupportedType = errors.New("cover image must be jpeg, png or gif")
+	// ErrInvalidImage 图片内容无法解码
+	ErrInvalidImage = errors.New("cover image cannot be decoded")
+	// ErrNotConfigured 没有可用的存储后端
+	ErrNotConfigured = errors.New("cover storage is not configured")
+)
+
+// extensions 支持的图片格式及原图的扩展名
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+var (
+	storage   Storage
+	maxBytes  int64 = 5 << 20
+	mountPath string
+	mountDir  string
+)
+
+// Init 根据配置创建存储后端，配置错误时返回错误，此时封面上传不可用
+func Init(cfg *config.Config) error {
+	maxBytes = cfg.CoverMaxBytes
+	storage, mountPath, mountDir = nil, "", ""
+
+	switch cfg.CoverStorage {
+	case StorageLocal:
+		baseURL := cfg.CoverPublicURL
+		if baseURL == "" {
+			baseURL = "/uploads"
+		}
+		storage = NewLocalStorage(cfg.CoverLocalDir, baseURL)
+		// 访问地址是本服务的路径时由本服务提供静态文件，否则由外部 Web 服务器或 CDN 提供
+		if strings.HasPrefix(baseURL, "/") {
+			mountPath, mountDir = strings.TrimRight(baseURL, "/"), cfg.CoverLocalDir
+		}
+	case StorageS3:
+		if cfg.CoverS3Endpoint == "" || cfg.CoverS3Bucket == "" || cfg.CoverS3AccessKey == "" || cfg.CoverS3SecretKey == "" {
+			return errors.New("s3 cover storage requires COVER_S3_ENDPOINT, COVER_S3_BUCKET, COVER_S3_ACCESS_KEY and COVER_S3_SECRET_KEY")
+		}
+		storage = NewS3Storage(cfg.CoverS3Endpoint, cfg.CoverS3Region, cfg.CoverS3Bucket,
+			cfg.CoverS3AccessKey, cfg.CoverS3SecretKey, cfg.CoverPublicURL)
+	default:
+		return fmt.Errorf("unknown cover storage %q", cfg.CoverStorage)
+	}
+	return nil
+}
+
+// MaxBytes 返回封面图片文件的最大字节数
+func MaxBytes() int64 {
+	return maxBytes
+}
+
+// LocalMount 使用本地存储且访问地址为本服务的路径时，返回静态文件的访问路径和目录
+func LocalMount() (string, string, bool) {
+	return mountPath, mountDir, mountPath != ""
+}
+
+// Store 校验图片并保存原图和缩略图，返回封面前缀和原图的媒体类型。
+// 写入失败时删除本次已写入的文件
+func Store(ctx context.Context, bookID uint, data []byte) (string, string, error) {
+	if storage == nil {
+		return "", "", ErrNotConfigured
+	}
+	if int64(len(data)) > maxBytes {
+		return "", "", ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return "", "", ErrUnsupportedType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", "", ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return "", "", ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", "", ErrInvalidImage
+	}
+
+	key, err := newKey(bookID)
+	if err != nil {
+		return "", "", err
+	}
+
+	files := map[string][]byte{key + "-original" + ext: data}
+	flat := flatten(img)
+	for size, width := range map[string]int{"small": smallWidth, "medium": mediumWidth} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resize(flat, width), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+			return "", "", err
+		}
+		files[key+"-"+size+".jpg"] = buf.Bytes()
+	}
+
+	var written []string
+	for name, content := range files {
+		fileType := "image/jpeg"
+		if strings.HasSuffix(name, "-original"+ext) {
+			fileType = contentType
+		}
+		if err := storage.Put(ctx, name, content, fileType); err != nil {
+			for _, w := range written {
+				storage.Delete(ctx, w)
+			}
+			return "", "", err
+		}
+		written = append(written, name)
+	}
+	return key, contentType, nil
+}
+
+// Remove 删除封面的原图和缩略图，返回第一个删除错误，其余文件仍会尝试删除
+func Remove(ctx context.Context, key, contentType string) error {
+	if storage == nil {
+		return ErrNotConfigured
+	}
+	var first error
+	for _, name := range []string{key + "-original" + extensions[contentType], key + "-small.jpg", key + "-medium.jpg"} {
+		if err := storage.Delete(ctx, name); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// URLs 返回封面各尺寸的地址，没有封面或未配置存储时返回 nil
+func URLs(key, contentType string) *models.CoverURLs {
+	if key == "" || storage == nil {
+		return nil
+	}
+	return &models.CoverURLs{
+		Original: storage.URL(key + "-original" + extensions[contentType]),
+		Small:    storage.URL(key + "-small.jpg"),
+		Medium:   storage.URL(key + "-medium.jpg"),
+	}
+}
+
+// newKey 生成封面前缀 covers/{图书ID}/{随机串}
+func newKey(bookID uint) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("covers/%d/%s", bookID, hex.EncodeToString(b)), nil
+}
@@ -0,0 +1,442 @@
+package covers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testRegion    = "us-east-1"
+	testBucket    = "covers-bucket"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// authPattern Authorization 头的格式
+var authPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+// fakeS3 S3 兼容存储的测试替身，按 Signature Version 4 校验每个请求的签名，
+// 签名无效时返回 403。fail 返回 true 的请求返回 500，用于模拟写入失败
+type fakeS3 struct {
+	url      string
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	calls    []string
+	rejected []error
+	fail     func(method, key string) bool
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+// newFakeS3 启动测试替身并将其设为封面存储
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+
+	f := &fakeS3{objects: map[string]fakeObject{}}
+	server := httptest.NewServer(f)
+	f.url = server.URL
+	t.Cleanup(server.Close)
+
+	previous, previousMax := storage, maxBytes
+	storage = NewS3Storage(server.URL, testRegion, testBucket, testAccessKey, testSecretKey, "https://cdn.example.org/covers")
+	maxBytes = 5 << 20
+	t.Cleanup(func() { storage, maxBytes = previous, previousMax })
+	return f
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := f.verify(r, body); err != nil {
+		f.mu.Lock()
+		f.rejected = append(f.rejected, fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err))
+		f.mu.Unlock()
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, r.Method+" "+key)
+	if f.fail != nil && f.fail(r.Method, key) {
+		http.Error(w, "InternalError", http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify 按 Signature Version 4 重新计算签名并与请求中的签名比较
+func (f *fakeS3) verify(r *http.Request, body []byte) error {
+	m := authPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		return errors.New("malformed authorization header")
+	}
+	accessKey, day, region, signedHeaders, signature := m[1], m[2], m[3], m[4], m[5]
+	if accessKey != testAccessKey || region != testRegion {
+		return errors.New("unexpected credential scope")
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, day) {
+		return errors.New("x-amz-date does not match the credential scope")
+	}
+	if d := time.Since(signedAt); d < -5*time.Minute || d > 5*time.Minute {
+		return errors.New("request time is too skewed")
+	}
+
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	if r.Header.Get("X-Amz-Content-Sha256") != payloadHash {
+		return errors.New("x-amz-content-sha256 does not match the body")
+	}
+
+	names := strings.Split(signedHeaders, ";")
+	if !sort.StringsAreSorted(names) || !contains(names, "host") || !contains(names, "x-amz-date") || !contains(names, "x-amz-content-sha256") {
+		return errors.New("required headers are not signed")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{day, region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(key)), []byte(signature)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// checkSigned 确认所有请求的签名都通过了校验
+func (f *fakeS3) checkSigned(t *testing.T) {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, err := range f.rejected {
+		t.Errorf("rejected request: %v", err)
+	}
+}
+
+// keys 返回已保存对象的 key，按字母顺序排列
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) object(key string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[key]
+	return obj, ok
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// pngImage 生成指定尺寸的 PNG 图片
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// jpegSize 解码 JPEG 并返回尺寸
+func jpegSize(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	return img.Bounds().Dx(), img.Bounds().Dy()
+}
+
+func TestStoreUploadsSignedObjects(t *testing.T) {
+	s3 := newFakeS3(t)
+	data := pngImage(t, 800, 1200)
+
+	key, contentType, err := Store(context.Background(), 12, data)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if contentType != "image/png" {
+		t.Errorf("content type = %q, want image/png", contentType)
+	}
+	if !regexp.MustCompile(`^covers/12/[0-9a-f]{8}$`).MatchString(key) {
+		t.Errorf("key = %q, want covers/12/{random}", key)
+	}
+
+	want := []string{key + "-medium.jpg", key + "-original.png", key + "-small.jpg"}
+	if got := s3.keys(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("objects = %v, want %v", got, want)
+	}
+
+	original, _ := s3.object(key + "-original.png")
+	if !bytes.Equal(original.data, data) || original.contentType != "image/png" {
+		t.Errorf("original stored as %s with %d bytes, want the uploaded png", original.contentType, len(original.data))
+	}
+	for _, size := range []struct {
+		name          string
+		width, height int
+	}{{"small", 160, 240}, {"medium", 480, 720}} {
+		obj, _ := s3.object(key + "-" + size.name + ".jpg")
+		if obj.contentType != "image/jpeg" {
+			t.Errorf("%s content type = %q, want image/jpeg", size.name, obj.contentType)
+		}
+		if w, h := jpegSize(t, obj.data); w != size.width || h != size.height {
+			t.Errorf("%s thumbnail = %dx%d, want %dx%d", size.name, w, h, size.width, size.height)
+		}
+	}
+
+	urls := URLs(key, contentType)
+	if urls.Small != "https://cdn.example.org/covers/"+key+"-small.jpg" || urls.Original != "https://cdn.example.org/covers/"+key+"-original.png" {
+		t.Errorf("urls = %+v", urls)
+	}
+	s3.checkSigned(t)
+}
+
+func TestStoreThumbnailDimensions(t *testing.T) {
+	tests := []struct {
+		name             string
+		width, height    int
+		smallW, smallH   int
+		mediumW, mediumH int
+	}{
+		{"纵向", 800, 1200, 160, 240, 480, 720},
+		{"横向", 1000, 500, 160, 80, 480, 240},
+		// 不放大窄于缩略图宽度的图片
+		{"窄于中等缩略图", 300, 450, 160, 240, 300, 450},
+		{"窄于小缩略图", 100, 150, 100, 150, 100, 150},
+		// 高度至少为1像素
+		{"极扁", 2000, 4, 160, 1, 480, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := newFakeS3(t)
+			key, _, err := Store(context.Background(), 1, pngImage(t, tt.width, tt.height))
+			if err != nil {
+				t.Fatalf("Store: %v", err)
+			}
+			small, _ := s3.object(key + "-small.jpg")
+			medium, _ := s3.object(key + "-medium.jpg")
+			if w, h := jpegSize(t, small.data); w != tt.smallW || h != tt.smallH {
+				t.Errorf("small = %dx%d, want %dx%d", w, h, tt.smallW, tt.smallH)
+			}
+			if w, h := jpegSize(t, medium.data); w != tt.mediumW || h != tt.mediumH {
+				t.Errorf("medium = %dx%d, want %dx%d", w, h, tt.mediumW, tt.mediumH)
+			}
+		})
+	}
+}
+
+func TestStoreRejectsInvalidImages(t *testing.T) {
+	// 逻辑屏幕为 6000x6000 的 GIF 头，像素数超过限制
+	hugeGIF := []byte("GIF89a\x70\x17\x70\x17\x00\x00\x00")
+
+	tests := []struct {
+		name     string
+		data     []byte
+		maxBytes int64
+		want     error
+	}{
+		{"文件过大", pngImage(t, 64, 64), 100, ErrTooLarge},
+		{"像素过多", hugeGIF, 5 << 20, ErrTooLarge},
+		{"文本", []byte("<html><body>not an image</body></html>"), 5 << 20, ErrUnsupportedType},
+		{"PDF", []byte("%PDF-1.4\n%âãÏÓ\n"), 5 << 20, ErrUnsupportedType},
+		{"BMP", append([]byte("BM"), make([]byte, 64)...), 5 << 20, ErrUnsupportedType},
+		{"截断的 PNG", pngImage(t, 64, 64)[:40], 5 << 20, ErrInvalidImage},
+		{"只有签名的 JPEG", []byte("\xff\xd8\xff\xe0garbage"), 5 << 20, ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := newFakeS3(t)
+			maxBytes = tt.maxBytes
+
+			if _, _, err := Store(context.Background(), 1, tt.data); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if len(s3.calls) != 0 {
+				t.Errorf("storage calls = %v, want none", s3.calls)
+			}
+		})
+	}
+}
+
+func TestStoreRollsBackOnFailure(t *testing.T) {
+	s3 := newFakeS3(t)
+	s3.fail = func(method, key string) bool {
+		return method == http.MethodPut && strings.HasSuffix(key, "-medium.jpg")
+	}
+
+	_, _, err := Store(context.Background(), 7, pngImage(t, 600, 900))
+	if err == nil {
+		t.Fatal("Store succeeded, want error")
+	}
+	if keys := s3.keys(); len(keys) != 0 {
+		t.Errorf("objects left after failure = %v, want none", keys)
+	}
+
+	// 每个写入成功的文件都被删除
+	puts, deletes := map[string]bool{}, map[string]bool{}
+	for _, call := range s3.calls {
+		method, key, _ := strings.Cut(call, " ")
+		if method == http.MethodPut && !strings.HasSuffix(key, "-medium.jpg") {
+			puts[key] = true
+		}
+		if method == http.MethodDelete {
+			deletes[key] = true
+		}
+	}
+	for key := range puts {
+		if !deletes[key] {
+			t.Errorf("%s was written but not deleted", key)
+		}
+	}
+	s3.checkSigned(t)
+}
+
+func TestStoreRejectedSignature(t *testing.T) {
+	s3 := newFakeS3(t)
+	storage = NewS3Storage(s3.url, testRegion, testBucket, testAccessKey, "wrong-secret", "")
+
+	if _, _, err := Store(context.Background(), 1, pngImage(t, 64, 64)); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Fatalf("err = %v, want 403 from storage", err)
+	}
+	if keys := s3.keys(); len(keys) != 0 {
+		t.Errorf("objects = %v, want none", keys)
+	}
+	if len(s3.rejected) == 0 {
+		t.Error("storage accepted a request signed with the wrong secret")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s3 := newFakeS3(t)
+
+	key, contentType, err := Store(context.Background(), 3, pngImage(t, 200, 300))
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if err := Remove(context.Background(), key, contentType); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if keys := s3.keys(); len(keys) != 0 {
+		t.Errorf("objects after Remove = %v, want none", keys)
+	}
+	s3.checkSigned(t)
+}
+
+func TestRemoveContinuesAfterError(t *testing.T) {
+	s3 := newFakeS3(t)
+
+	key, contentType, err := Store(context.Background(), 3, pngImage(t, 200, 300))
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	s3.fail = func(method, key string) bool {
+		return method == http.MethodDelete && strings.HasSuffix(key, "-original.png")
+	}
+
+	if err := Remove(context.Background(), key, contentType); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Fatalf("err = %v, want the failed delete", err)
+	}
+	if keys := s3.keys(); len(keys) != 1 || keys[0] != key+"-original.png" {
+		t.Errorf("objects = %v, want only the original that failed to delete", keys)
+	}
+	s3.checkSigned(t)
+}
+
+func TestNotConfigured(t *testing.T) {
+	previous := storage
+	storage = nil
+	t.Cleanup(func() { storage = previous })
+
+	if _, _, err := Store(context.Background(), 1, pngImage(t, 8, 8)); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Store err = %v, want ErrNotConfigured", err)
+	}
+	if err := Remove(context.Background(), "covers/1/x", "image/png"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Remove err = %v, want ErrNotConfigured", err)
+	}
+	if urls := URLs("covers/1/x", "image/png"); urls != nil {
+		t.Errorf("URLs = %+v, want nil", urls)
+	}
+}
@@ -0,0 +1,43 @@
+// Package databasetest 测试用的临时数据库
+package databasetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/example/library-api/database"
+)
+
+// Open 在测试的临时目录中创建数据库并完成迁移，关闭 SQL 日志，测试结束时关闭连接
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "library.db"), 5000)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// Use 创建临时数据库并设为 database.DB，供直接使用全局连接的控制器和中间件测试，测试结束时恢复
+func Use(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db := Open(t)
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
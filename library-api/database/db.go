@@ -1,7 +1,9 @@
 package database
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"github.com/example/library-api/models"
 	"github.com/example/library-api/config"
 	"gorm.io/driver/sqlite"
@@ -20,25 +22,142 @@ func InitDB() {
 	}
 
 	// 连接SQLite数据库
-	DB, err = gorm.Open(sqlite.Open(cfg.DBPath), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	DB, err = Open(cfg.DBPath, cfg.DBBusyTimeoutMs)
 	if err != nil {
 		log.Fatalf("无法连接数据库: %v", err)
 	}
 
 	// 自动迁移数据表
-	err = DB.AutoMigrate(
+	if err := Migrate(DB); err != nil {
+		log.Fatalf("数据库迁移失败: %v", err)
+	}
+
+	log.Println("数据库连接和迁移成功")
+}
+
+// Open 打开SQLite数据库。事务以 BEGIN IMMEDIATE 开始，在事务开始时即获取写锁，
+// 避免并发事务读取后再升级写锁时互相冲突；其他连接持有写锁时最多等待 busyTimeoutMs 毫秒
+func Open(path string, busyTimeoutMs int) (*gorm.DB, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	dsn := fmt.Sprintf("%s%s_busy_timeout=%d&_txlock=immediate", path, sep, busyTimeoutMs)
+
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+	})
+}
+
+// Migrate 自动迁移数据表并创建迁移无法表达的约束
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Book{},
 		&models.Author{},
 		&models.BookAuthor{},
+		&models.Branch{},
+		&models.ShelfLocation{},
 		&models.BookCopy{},
+		&models.CopyStatusChange{},
 		&models.Borrow{},
+		&models.Hold{},
+		&models.Transfer{},
+		&models.Fine{},
+		&models.FinePayment{},
+		&models.LoanPolicy{},
+		&models.AccessionSequence{},
+		&models.MetadataCache{},
+		&models.Category{},
+		&models.Tag{},
+		&models.Work{},
+		&models.Series{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.UserIdentity{},
 	)
 	if err != nil {
-		log.Fatalf("数据库迁移失败: %v", err)
+		return err
 	}
 
-	log.Println("数据库连接和迁移成功")
+	// 每个副本最多只有一条未归还的借阅记录
+	err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_borrows_open_copy
+		ON borrows(book_copy_id)
+		WHERE status IN ('active', 'overdue') AND deleted_at IS NULL`).Error
+	if err != nil {
+		return fmt.Errorf("create index idx_borrows_open_copy (check for copies with more than one open borrow): %w", err)
+	}
+
+	// Google账号ID只在非空时唯一，多个密码注册的用户不会因空值冲突
+	if err := db.Exec(`DROP INDEX IF EXISTS idx_users_google_id`).Error; err != nil {
+		return err
+	}
+	err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_google_id_nonempty
+		ON users(google_id)
+		WHERE google_id <> ''`).Error
+	if err != nil {
+		return fmt.Errorf("create index idx_users_google_id_nonempty: %w", err)
+	}
+
+	// 分馆代码只在未删除的分馆中唯一，删除分馆后可以重新使用其代码
+	if err := db.Exec(`DROP INDEX IF EXISTS idx_branches_code`).Error; err != nil {
+		return err
+	}
+	err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_branches_code_active
+		ON branches(code)
+		WHERE deleted_at IS NULL`).Error
+	if err != nil {
+		return fmt.Errorf("create index idx_branches_code_active: %w", err)
+	}
+
+	// 书架代码只在分馆内未删除的书架中唯一，删除书架后可以重新使用其代码
+	if err := db.Exec(`DROP INDEX IF EXISTS idx_shelf_locations_branch_code`).Error; err != nil {
+		return err
+	}
+	err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_shelf_locations_branch_code_active
+		ON shelf_locations(branch_id, code)
+		WHERE deleted_at IS NULL`).Error
+	if err != nil {
+		return fmt.Errorf("create index idx_shelf_locations_branch_code_active: %w", err)
+	}
+
+	// 将 users.google_id 迁移为 Google 外部身份，之后只使用 user_identities。
+	// 只清空已有对应外部身份的 google_id，与其他用户的外部身份冲突的保留原值，由管理员处理
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO user_identities (user_id, provider, subject, created_at, updated_at)
+			SELECT id, 'google', google_id, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP FROM users
+			WHERE google_id <> ''
+			AND NOT EXISTS (SELECT 1 FROM user_identities ui
+				WHERE ui.provider = 'google' AND (ui.subject = users.google_id OR ui.user_id = users.id))`).Error
+		if err != nil {
+			return err
+		}
+
+		var skipped []struct {
+			ID       uint
+			GoogleID string
+		}
+		err = tx.Raw(`SELECT id, google_id FROM users
+			WHERE google_id <> ''
+			AND NOT EXISTS (SELECT 1 FROM user_identities ui
+				WHERE ui.provider = 'google' AND ui.subject = users.google_id AND ui.user_id = users.id)`).
+			Scan(&skipped).Error
+		if err != nil {
+			return err
+		}
+		for _, u := range skipped {
+			log.Printf("用户 %d 的 google_id %s 与已有的 Google 外部身份冲突，未迁移", u.ID, u.GoogleID)
+		}
+
+		return tx.Exec(`UPDATE users SET google_id = NULL
+			WHERE google_id <> ''
+			AND EXISTS (SELECT 1 FROM user_identities ui
+				WHERE ui.provider = 'google' AND ui.subject = users.google_id AND ui.user_id = users.id)`).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate users.google_id to user_identities: %w", err)
+	}
+
+	return nil
 }
\ No newline at end of file
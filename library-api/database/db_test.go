@@ -0,0 +1,304 @@
+package database
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/example/library-api/models"
+)
+
+// baselineUser 引入外部身份之前的用户表结构，google_id 上有普通唯一索引 idx_users_google_id
+type baselineUser struct {
+	ID        uint   `gorm:"primaryKey"`
+	GoogleID  string `gorm:"size:100;uniqueIndex"`
+	Username  string `gorm:"size:50;not null;unique"`
+	Email     string `gorm:"size:100;not null;unique"`
+	Password  string `gorm:"size:100;not null"`
+	Role      string `gorm:"size:20;not null;default:user"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+// userRow 迁移后用户表中的 google_id，NULL 表示已清空
+type userRow struct {
+	ID        uint
+	GoogleID  *string
+	UpdatedAt time.Time
+}
+
+// identityRow 外部身份表中的记录
+type identityRow struct {
+	ID       uint
+	UserID   uint
+	Provider string
+	Subject  string
+}
+
+// snapshot 读取全部用户的 google_id 和全部外部身份
+func snapshot(t *testing.T, db *gorm.DB) ([]userRow, []identityRow) {
+	t.Helper()
+
+	var users []userRow
+	if err := db.Raw(`SELECT id, google_id, updated_at FROM users ORDER BY id`).Scan(&users).Error; err != nil {
+		t.Fatalf("load users: %v", err)
+	}
+	var identities []identityRow
+	if err := db.Raw(`SELECT id, user_id, provider, subject FROM user_identities ORDER BY id`).Scan(&identities).Error; err != nil {
+		t.Fatalf("load identities: %v", err)
+	}
+	return users, identities
+}
+
+func TestMigrateGoogleIDs(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "library.db"), 5000)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// 旧版本的用户表，外部身份表中已有通过第三方登录关联的身份
+	if err := db.AutoMigrate(&baselineUser{}, &models.UserIdentity{}); err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	users := []baselineUser{
+		{Username: "alice", GoogleID: "g-alice"}, // 需要迁移
+		{Username: "bob", GoogleID: "g-shared"},  // 与 carol 已有的身份冲突
+		{Username: "carol"},                      // 已通过第三方登录关联 g-shared，google_id 为 NULL
+		{Username: "dave", GoogleID: "g-dave"},   // 已关联了另一个 Google 账号
+		{Username: "erin"},                       // 只有密码，google_id 为空字符串
+		{Username: "frank", GoogleID: "g-frank"}, // 已迁移过身份，google_id 未清空
+	}
+	for i := range users {
+		users[i].Email = users[i].Username + "@example.com"
+		users[i].Password = "secret"
+		users[i].Role = "user"
+		users[i].UpdatedAt = past
+		// 普通唯一索引下只能有一个用户的 google_id 为空字符串
+		tx := db
+		if users[i].Username == "carol" {
+			tx = db.Omit("GoogleID")
+		}
+		if err := tx.Create(&users[i]).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	alice, bob, carol, dave, erin, frank := users[0].ID, users[1].ID, users[2].ID, users[3].ID, users[4].ID, users[5].ID
+	for _, identity := range []models.UserIdentity{
+		{UserID: carol, Provider: "google", Subject: "g-shared"},
+		{UserID: dave, Provider: "google", Subject: "g-dave-2"},
+		{UserID: frank, Provider: "google", Subject: "g-frank"},
+	} {
+		if err := db.Create(&identity).Error; err != nil {
+			t.Fatalf("create identity: %v", err)
+		}
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// 只有对应外部身份属于本人的 google_id 被清空，冲突的保留原值
+	gotUsers, gotIdentities := snapshot(t, db)
+	wantGoogleIDs := map[uint]*string{
+		alice: nil,
+		bob:   ptr("g-shared"),
+		carol: nil,
+		dave:  ptr("g-dave"),
+		erin:  ptr(""),
+		frank: nil,
+	}
+	for _, u := range gotUsers {
+		if want := wantGoogleIDs[u.ID]; !reflect.DeepEqual(u.GoogleID, want) {
+			t.Errorf("user %d google_id = %v, want %v", u.ID, deref(u.GoogleID), deref(want))
+		}
+	}
+
+	subjects := map[uint][]string{}
+	for _, identity := range gotIdentities {
+		if identity.Provider != "google" {
+			t.Errorf("identity %+v has unexpected provider", identity)
+		}
+		subjects[identity.UserID] = append(subjects[identity.UserID], identity.Subject)
+	}
+	wantSubjects := map[uint][]string{
+		alice: {"g-alice"},
+		carol: {"g-shared"},
+		dave:  {"g-dave-2"},
+		frank: {"g-frank"},
+	}
+	if !reflect.DeepEqual(subjects, wantSubjects) {
+		t.Errorf("identities = %v, want %v", subjects, wantSubjects)
+	}
+
+	var oldIndex int64
+	db.Raw(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_users_google_id'`).Scan(&oldIndex)
+	if oldIndex != 0 {
+		t.Error("idx_users_google_id was not dropped")
+	}
+
+	// 再次迁移不做任何修改
+	if err := Migrate(db); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	againUsers, againIdentities := snapshot(t, db)
+	if !reflect.DeepEqual(againUsers, gotUsers) || !reflect.DeepEqual(againIdentities, gotIdentities) {
+		t.Errorf("second Migrate changed data:\nusers %+v -> %+v\nidentities %+v -> %+v", gotUsers, againUsers, gotIdentities, againIdentities)
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+// deref 便于在错误信息中显示可能为 NULL 的值
+func deref(s *string) string {
+	if s == nil {
+		return "NULL"
+	}
+	return *s
+}
+
+// baselineBranch 分馆代码上有普通唯一索引 idx_branches_code 的旧分馆表结构
+type baselineBranch struct {
+	ID        uint   `gorm:"primaryKey"`
+	Code      string `gorm:"size:20;not null;uniqueIndex"`
+	Name      string `gorm:"size:100;not null"`
+	Address   string `gorm:"size:255"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineBranch) TableName() string {
+	return "branches"
+}
+
+func TestMigrateBranchCodes(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "library.db"), 5000)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// 旧版本的分馆表中有一个已删除的分馆
+	if err := db.AutoMigrate(&baselineBranch{}); err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	old := baselineBranch{Code: "MAIN", Name: "旧总馆"}
+	if err := db.Create(&old).Error; err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+	if err := db.Delete(&old).Error; err != nil {
+		t.Fatalf("delete branch: %v", err)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// 已删除分馆的代码可以重新使用，未删除的分馆之间代码仍然唯一
+	if err := db.Create(&models.Branch{Code: "MAIN", Name: "总馆"}).Error; err != nil {
+		t.Fatalf("reuse code of deleted branch: %v", err)
+	}
+	err = db.Create(&models.Branch{Code: "MAIN", Name: "重复的总馆"}).Error
+	if !IsUniqueViolation(err) {
+		t.Errorf("duplicate active code err = %v, want unique violation", err)
+	}
+
+	var oldIndex int64
+	db.Raw(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_branches_code'`).Scan(&oldIndex)
+	if oldIndex != 0 {
+		t.Error("idx_branches_code was not dropped")
+	}
+}
+
+// baselineShelfLocation 分馆和书架代码上有普通唯一索引 idx_shelf_locations_branch_code 的旧书架表结构
+type baselineShelfLocation struct {
+	ID        uint   `gorm:"primaryKey"`
+	BranchID  uint   `gorm:"not null;uniqueIndex:idx_shelf_locations_branch_code"`
+	Code      string `gorm:"size:30;not null;uniqueIndex:idx_shelf_locations_branch_code"`
+	Name      string `gorm:"size:100;not null"`
+	Position  int    `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineShelfLocation) TableName() string {
+	return "shelf_locations"
+}
+
+func TestMigrateShelfLocationCodes(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "library.db"), 5000)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// 旧版本的书架表中总馆有一个已删除的书架
+	if err := db.AutoMigrate(&baselineShelfLocation{}); err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	old := baselineShelfLocation{BranchID: 1, Code: "A1", Name: "旧A1"}
+	if err := db.Create(&old).Error; err != nil {
+		t.Fatalf("create shelf: %v", err)
+	}
+	if err := db.Delete(&old).Error; err != nil {
+		t.Fatalf("delete shelf: %v", err)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	main := models.Branch{Code: "MAIN", Name: "总馆"}
+	east := models.Branch{Code: "EAST", Name: "东馆"}
+	for _, branch := range []*models.Branch{&main, &east} {
+		if err := db.Create(branch).Error; err != nil {
+			t.Fatalf("create branch: %v", err)
+		}
+	}
+
+	// 已删除书架的代码可以在同一分馆重新使用，未删除的书架之间代码仍在分馆内唯一
+	if err := db.Create(&models.ShelfLocation{BranchID: main.ID, Code: "A1", Name: "A1"}).Error; err != nil {
+		t.Fatalf("reuse code of deleted shelf: %v", err)
+	}
+	err = db.Create(&models.ShelfLocation{BranchID: main.ID, Code: "A1", Name: "重复的A1"}).Error
+	if !IsUniqueViolation(err) {
+		t.Errorf("duplicate active code err = %v, want unique violation", err)
+	}
+	if err := db.Create(&models.ShelfLocation{BranchID: east.ID, Code: "A1", Name: "东馆A1"}).Error; err != nil {
+		t.Errorf("same code in another branch: %v", err)
+	}
+
+	var oldIndex int64
+	db.Raw(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_shelf_locations_branch_code'`).Scan(&oldIndex)
+	if oldIndex != 0 {
+		t.Error("idx_shelf_locations_branch_code was not dropped")
+	}
+}
@@ -0,0 +1,172 @@
+// Package isbn 国际标准书号的校验与规范化
+//
+// 同一本书的 ISBN 可能带或不带连字符，也可能是旧的10位格式，如 "0-14-044913-2"、
+// "978-0-14-044913-6" 和 "9780140449136"。Normalize 校验校验位后统一转换为不带连字符的
+// ISBN-13，数据库中只保存这一规范形式，因此等价的 ISBN 会被唯一性检查识别为同一本书。
+package isbn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// ErrInvalid ISBN 格式或校验位错误
+var ErrInvalid = errors.New("invalid isbn")
+
+// Normalize 校验 ISBN 并返回规范的 ISBN-13。输入可以带 "ISBN" 前缀、空格和连字符，
+// ISBN-10 的校验位可以是小写 x
+func Normalize(raw string) (string, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	if rest, ok := strings.CutPrefix(s, "ISBN"); ok {
+		// "ISBN-13: 978..."、"ISBN-10 0..."、"ISBN: ..." 等标签
+		if strings.HasPrefix(rest, "-10") || strings.HasPrefix(rest, "-13") {
+			rest = rest[3:]
+		}
+		s = strings.TrimLeft(rest, ": ")
+	}
+	s = strings.NewReplacer("-", "", " ", "").Replace(s)
+
+	switch len(s) {
+	case 10:
+		if strings.Trim(s[:9], "0123456789") != "" || strings.Trim(s[9:], "0123456789X") != "" {
+			return "", fmt.Errorf("%w: isbn-10 must be 9 digits followed by a digit or X", ErrInvalid)
+		}
+		if s[9] != check10(s[:9]) {
+			return "", fmt.Errorf("%w: isbn-10 check digit does not match", ErrInvalid)
+		}
+		body := "978" + s[:9]
+		return body + string(check13(body)), nil
+	case 13:
+		if strings.Trim(s, "0123456789") != "" {
+			return "", fmt.Errorf("%w: isbn-13 must be 13 digits", ErrInvalid)
+		}
+		if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+			return "", fmt.Errorf("%w: isbn-13 must start with 978 or 979", ErrInvalid)
+		}
+		if s[12] != check13(s[:12]) {
+			return "", fmt.Errorf("%w: isbn-13 check digit does not match", ErrInvalid)
+		}
+		return s, nil
+	default:
+		return "", fmt.Errorf("%w: isbn must have 10 or 13 digits", ErrInvalid)
+	}
+}
+
+// To10 将 978 开头的 ISBN-13 转换为 ISBN-10，979 开头的 ISBN 没有对应的10位格式
+func To10(isbn13 string) (string, bool) {
+	if len(isbn13) != 13 || !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+	body := isbn13[3:12]
+	return body + string(check10(body)), true
+}
+
+// check10 计算 ISBN-10 的校验位（模11加权，10记为X）
+func check10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	switch d := (11 - sum%11) % 11; d {
+	case 10:
+		return 'X'
+	default:
+		return byte('0' + d)
+	}
+}
+
+// check13 计算 ISBN-13 的校验位（模10，权重交替为1和3）
+func check13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(body[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// Invalid 迁移时无法规范化的 ISBN，保持原值
+type Invalid struct {
+	BookID uint   `json:"book_id"`
+	ISBN   string `json:"isbn"`
+	Error  string `json:"error"`
+}
+
+// Conflict 迁移时规范化后相同的多本图书，保持原值等待人工合并
+type Conflict struct {
+	ISBN    string   `json:"isbn"`
+	BookIDs []uint   `json:"book_ids"`
+	Values  []string `json:"values"`
+}
+
+// MigrationReport ISBN 迁移结果
+type MigrationReport struct {
+	Normalized int        `json:"normalized"`
+	Invalid    []Invalid  `json:"invalid,omitempty"`
+	Conflicts  []Conflict `json:"conflicts,omitempty"`
+}
+
+// MigrateBooks 将所有图书（包括已删除的图书）的 ISBN 改写为规范形式。
+// 校验失败的 ISBN 保持原值；规范化后与其他图书相同的 ISBN 全部保持原值并报告为冲突，
+// 因为无法判断应保留哪一本
+func MigrateBooks(db *gorm.DB) (*MigrationReport, error) {
+	report := &MigrationReport{}
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var books []models.Book
+		if err := tx.Unscoped().Select("id", "isbn").Order("id ASC").Find(&books).Error; err != nil {
+			return err
+		}
+
+		groups := make(map[string][]models.Book)
+		var order []string
+		for _, b := range books {
+			canonical, err := Normalize(b.ISBN)
+			if err != nil {
+				report.Invalid = append(report.Invalid, Invalid{BookID: b.ID, ISBN: b.ISBN, Error: err.Error()})
+				continue
+			}
+			if _, ok := groups[canonical]; !ok {
+				order = append(order, canonical)
+			}
+			groups[canonical] = append(groups[canonical], b)
+		}
+
+		for _, canonical := range order {
+			group := groups[canonical]
+			if len(group) > 1 {
+				conflict := Conflict{ISBN: canonical}
+				for _, b := range group {
+					conflict.BookIDs = append(conflict.BookIDs, b.ID)
+					conflict.Values = append(conflict.Values, b.ISBN)
+				}
+				report.Conflicts = append(report.Conflicts, conflict)
+				continue
+			}
+			if group[0].ISBN == canonical {
+				continue
+			}
+			// 同时更新修改时间，使 OAI-PMH 增量采集能发现 ISBN 的变化
+			if err := tx.Unscoped().Model(&models.Book{}).
+				Where("id = ?", group[0].ID).
+				UpdateColumns(map[string]interface{}{"isbn": canonical, "updated_at": now}).Error; err != nil {
+				return err
+			}
+			report.Normalized++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
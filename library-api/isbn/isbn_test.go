@@ -0,0 +1,210 @@
+package isbn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"9780306406157", "9780306406157"},
+		{"978-0-306-40615-7", "9780306406157"},
+		{" 978 0 306 40615 7 ", "9780306406157"},
+		{"0306406152", "9780306406157"},
+		{"0-306-40615-2", "9780306406157"},
+		{"ISBN 0-306-40615-2", "9780306406157"},
+		{"ISBN: 978-0-306-40615-7", "9780306406157"},
+		{"ISBN-10: 0-306-40615-2", "9780306406157"},
+		{"isbn-13 9780306406157", "9780306406157"},
+		// 校验位为 X 的 ISBN-10，大小写均可
+		{"080442957X", "9780804429573"},
+		{"0-8044-2957-x", "9780804429573"},
+		{"043942089X", "9780439420891"},
+		// 979 开头的 ISBN-13 没有10位格式，原样保留
+		{"979-10-90636-07-1", "9791090636071"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := Normalize(tt.raw)
+			if err != nil {
+				t.Fatalf("Normalize(%q): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"ISBN",
+		"030640615",       // 位数不足
+		"03064061521",     // 11位
+		"0306406153",      // ISBN-10 校验位错误
+		"030640615X",      // 校验位应为2而不是X
+		"X306406152",      // X 只能出现在校验位
+		"03064O6152",      // 字母O
+		"9780306406158",   // ISBN-13 校验位错误
+		"978030640615X",   // ISBN-13 没有 X 校验位
+		"9770306406157",   // 前缀不是 978 或 979
+		"978-0-306-40615", // 12位
+	}
+	for _, raw := range tests {
+		t.Run(raw, func(t *testing.T) {
+			if got, err := Normalize(raw); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Normalize(%q) = %q, %v, want ErrInvalid", raw, got, err)
+			}
+		})
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		isbn13 string
+		want   string
+		ok     bool
+	}{
+		{"9780306406157", "0306406152", true},
+		{"9780804429573", "080442957X", true},
+		{"9780140449136", "0140449132", true},
+		{"9791090636071", "", false},
+		{"978030640615", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := To10(tt.isbn13)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("To10(%q) = %q, %v, want %q, %v", tt.isbn13, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	for _, isbn10 := range []string{"0306406152", "080442957X", "0140449132", "043942089X", "0000000000"} {
+		isbn13, err := Normalize(isbn10)
+		if err != nil {
+			t.Fatalf("Normalize(%q): %v", isbn10, err)
+		}
+		if back, ok := To10(isbn13); !ok || back != isbn10 {
+			t.Errorf("To10(Normalize(%q)) = %q, %v", isbn10, back, ok)
+		}
+	}
+}
+
+func TestCheckDigits(t *testing.T) {
+	check10Tests := map[string]byte{
+		"030640615": '2',
+		"080442957": 'X',
+		"000000000": '0',
+		"123456789": 'X',
+		"999999999": '9',
+	}
+	for body, want := range check10Tests {
+		if got := check10(body); got != want {
+			t.Errorf("check10(%q) = %c, want %c", body, got, want)
+		}
+	}
+
+	check13Tests := map[string]byte{
+		"978030640615": '7',
+		"978080442957": '3',
+		"979109063607": '1',
+		"978000000000": '2',
+	}
+	for body, want := range check13Tests {
+		if got := check13(body); got != want {
+			t.Errorf("check13(%q) = %c, want %c", body, got, want)
+		}
+	}
+}
+
+func TestMigrateBooks(t *testing.T) {
+	db := databasetest.Open(t)
+
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	raw := []string{
+		"0-306-40615-2",     // 需要规范化
+		"9780804429573",     // 已是规范形式
+		"0140449132",        // 与下一本规范化后相同
+		"978-0-14-044913-6", // 冲突
+		"not an isbn",       // 无效
+		"080442957X",        // 与第二本冲突
+		"043942089x",        // 已删除的图书同样规范化
+	}
+	books := make([]models.Book, len(raw))
+	for i, value := range raw {
+		books[i] = models.Book{Title: value, ISBN: value}
+		if err := db.Create(&books[i]).Error; err != nil {
+			t.Fatalf("create book: %v", err)
+		}
+		if err := db.Model(&books[i]).UpdateColumn("updated_at", past).Error; err != nil {
+			t.Fatalf("set updated_at: %v", err)
+		}
+	}
+	if err := db.Delete(&books[6]).Error; err != nil {
+		t.Fatalf("delete book: %v", err)
+	}
+
+	report, err := MigrateBooks(db)
+	if err != nil {
+		t.Fatalf("MigrateBooks: %v", err)
+	}
+
+	if report.Normalized != 2 {
+		t.Errorf("normalized = %d, want 2", report.Normalized)
+	}
+	if len(report.Invalid) != 1 || report.Invalid[0].BookID != books[4].ID || report.Invalid[0].ISBN != "not an isbn" {
+		t.Errorf("invalid = %+v, want book %d", report.Invalid, books[4].ID)
+	}
+	wantConflicts := []Conflict{
+		{ISBN: "9780804429573", BookIDs: []uint{books[1].ID, books[5].ID}, Values: []string{"9780804429573", "080442957X"}},
+		{ISBN: "9780140449136", BookIDs: []uint{books[2].ID, books[3].ID}, Values: []string{"0140449132", "978-0-14-044913-6"}},
+	}
+	if !reflect.DeepEqual(report.Conflicts, wantConflicts) {
+		t.Errorf("conflicts = %+v, want %+v", report.Conflicts, wantConflicts)
+	}
+
+	// 只有规范化的图书被改写并更新修改时间，冲突和无效的图书保持原值
+	want := []struct {
+		isbn    string
+		updated bool
+	}{
+		{"9780306406157", true},
+		{"9780804429573", false},
+		{"0140449132", false},
+		{"978-0-14-044913-6", false},
+		{"not an isbn", false},
+		{"080442957X", false},
+		{"9780439420891", true},
+	}
+	for i, w := range want {
+		var got models.Book
+		if err := db.Unscoped().First(&got, books[i].ID).Error; err != nil {
+			t.Fatalf("load book: %v", err)
+		}
+		if got.ISBN != w.isbn {
+			t.Errorf("book %d isbn = %q, want %q", got.ID, got.ISBN, w.isbn)
+		}
+		if updated := got.UpdatedAt.After(past); updated != w.updated {
+			t.Errorf("book %d updated_at = %v, want changed %v", got.ID, got.UpdatedAt, w.updated)
+		}
+	}
+
+	// 再次迁移没有需要改写的图书
+	again, err := MigrateBooks(db)
+	if err != nil {
+		t.Fatalf("MigrateBooks again: %v", err)
+	}
+	if again.Normalized != 0 || len(again.Conflicts) != 2 {
+		t.Errorf("second run = %+v, want no changes and the same conflicts", again)
+	}
+}
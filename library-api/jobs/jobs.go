@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/keyring"
+	"github.com/example/library-api/sessions"
+)
+
+var scheduler = NewScheduler()
+
+// Init 注册流通相关的定时任务
+func Init(db *gorm.DB, cfg *config.Config) {
+	interval := time.Duration(cfg.OverdueJobIntervalMinutes) * time.Minute
+
+	// 将逾期的借阅标记为overdue，长期逾期的标记为丢失
+	scheduler.Register("overdue", interval, func(ctx context.Context, now time.Time) (Result, error) {
+		overdue, err := circulation.MarkOverdue(db.WithContext(ctx), now)
+		if err != nil {
+			return nil, err
+		}
+		lost, err := circulation.MarkLost(db.WithContext(ctx), now)
+		return Result{"marked_overdue": overdue, "marked_lost": lost}, err
+	})
+
+	// 将超过取书期限的预约顺延给下一位读者
+	scheduler.Register("expire_holds", interval, func(ctx context.Context, now time.Time) (Result, error) {
+		var expired int
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			expired, err = circulation.ExpireHolds(tx, 0, now)
+			return err
+		})
+		return Result{"expired_holds": int64(expired)}, err
+	})
+
+	// 轮换令牌签名密钥并删除重叠期已过的旧密钥，同时读取其他实例生成的密钥
+	scheduler.Register("rotate_keys", time.Hour, func(ctx context.Context, now time.Time) (Result, error) {
+		result, err := keyring.Rotate(now)
+		generated := int64(0)
+		if result.Generated != "" {
+			generated = 1
+		}
+		return Result{"generated": generated, "removed": int64(len(result.Removed)), "keys": int64(len(keyring.Keys()))}, err
+	})
+
+	// 清理已过期的会话、刷新令牌和访问令牌撤销记录
+	scheduler.Register("purge_sessions", time.Hour, func(ctx context.Context, now time.Time) (Result, error) {
+		purged, err := sessions.Purge(db.WithContext(ctx), now)
+		return Result{"purged": purged}, err
+	})
+}
+
+// Start 启动所有定时任务
+func Start(ctx context.Context) {
+	scheduler.Start(ctx)
+}
+
+// Statuses 返回所有定时任务的状态
+func Statuses() []Status {
+	return scheduler.Statuses()
+}
+
+// RunNow 立即运行指定任务
+func RunNow(ctx context.Context, name string) (Status, error) {
+	return scheduler.RunNow(ctx, name)
+}
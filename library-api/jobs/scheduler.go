@@ -0,0 +1,166 @@
+// Package jobs 服务进程内的定时任务调度
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownJob 任务不存在
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobRunning 任务正在运行
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// Result 任务运行结果，键为统计项名称
+type Result map[string]int64
+
+// Func 任务函数
+type Func func(ctx context.Context, now time.Time) (Result, error)
+
+// Status 任务运行状态
+type Status struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	Running      bool       `json:"running"`
+	Runs         int64      `json:"runs"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastResult   Result     `json:"last_result,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"`
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	fn       Func
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Scheduler 定时任务调度器，同一任务不会并发运行
+type Scheduler struct {
+	mu   sync.RWMutex
+	jobs map[string]*job
+}
+
+// NewScheduler 创建调度器
+func NewScheduler() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*job)}
+}
+
+// Register 注册任务
+func (s *Scheduler) Register(name string, interval time.Duration, fn Func) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[name] = &job{
+		name:     name,
+		interval: interval,
+		fn:       fn,
+		status:   Status{Name: name, Interval: interval.String()},
+	}
+}
+
+// Start 启动所有任务，启动时立即运行一次，之后按间隔运行，ctx取消时停止
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.run(ctx, j); err != nil && !errors.Is(err, ErrJobRunning) {
+			log.Printf("定时任务 %s 运行失败: %v", j.name, err)
+		}
+
+		next := time.Now().Add(j.interval)
+		j.mu.Lock()
+		j.status.NextRunAt = &next
+		j.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run 运行一次任务并记录结果
+func (s *Scheduler) run(ctx context.Context, j *job) error {
+	j.mu.Lock()
+	if j.status.Running {
+		j.mu.Unlock()
+		return ErrJobRunning
+	}
+	j.status.Running = true
+	j.mu.Unlock()
+
+	start := time.Now()
+	result, err := j.fn(ctx, start)
+	end := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRunAt = &start
+	j.status.LastDuration = end.Sub(start).String()
+	j.status.LastResult = result
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+	return err
+}
+
+// RunNow 立即运行指定任务并返回运行后的状态
+func (s *Scheduler) RunNow(ctx context.Context, name string) (Status, error) {
+	s.mu.RLock()
+	j, ok := s.jobs[name]
+	s.mu.RUnlock()
+	if !ok {
+		return Status{}, ErrUnknownJob
+	}
+
+	err := s.run(ctx, j)
+	if errors.Is(err, ErrJobRunning) {
+		return Status{}, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status, nil
+}
+
+// Statuses 返回所有任务的状态，按名称排序
+func (s *Scheduler) Statuses() []Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		statuses = append(statuses, j.status)
+		j.mu.Unlock()
+	}
+
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses
+}
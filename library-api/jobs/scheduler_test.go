@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunNowRecordsResult(t *testing.T) {
+	s := NewScheduler()
+	fail := errors.New("database is locked")
+	calls := 0
+	s.Register("overdue", time.Hour, func(ctx context.Context, now time.Time) (Result, error) {
+		calls++
+		if calls == 2 {
+			return Result{"marked_overdue": 1}, fail
+		}
+		return Result{"marked_overdue": 3, "marked_lost": 1}, nil
+	})
+
+	status, err := s.RunNow(context.Background(), "overdue")
+	if err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if status.Runs != 1 || status.Running || status.LastRunAt == nil || status.LastError != "" {
+		t.Errorf("status = %+v, want one finished run", status)
+	}
+	if want := (Result{"marked_overdue": 3, "marked_lost": 1}); !reflect.DeepEqual(status.LastResult, want) {
+		t.Errorf("result = %v, want %v", status.LastResult, want)
+	}
+
+	// 任务失败时记录错误，不作为 RunNow 的错误返回
+	status, err = s.RunNow(context.Background(), "overdue")
+	if err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if status.Runs != 2 || status.LastError != fail.Error() {
+		t.Errorf("status = %+v, want second run with error %q", status, fail)
+	}
+
+	if _, err := s.RunNow(context.Background(), "missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow(missing) err = %v, want ErrUnknownJob", err)
+	}
+}
+
+func TestRunNowWhileRunning(t *testing.T) {
+	s := NewScheduler()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.Register("overdue", time.Hour, func(ctx context.Context, now time.Time) (Result, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := s.RunNow(context.Background(), "overdue")
+		done <- err
+	}()
+	<-started
+
+	if statuses := s.Statuses(); len(statuses) != 1 || !statuses[0].Running {
+		t.Errorf("statuses = %+v, want overdue running", statuses)
+	}
+	if _, err := s.RunNow(context.Background(), "overdue"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("concurrent RunNow err = %v, want ErrJobRunning", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if statuses := s.Statuses(); statuses[0].Running || statuses[0].Runs != 1 {
+		t.Errorf("status = %+v, want one finished run", statuses[0])
+	}
+}
+
+func TestStatusesSortedByName(t *testing.T) {
+	s := NewScheduler()
+	noop := func(ctx context.Context, now time.Time) (Result, error) { return nil, nil }
+	s.Register("overdue", time.Hour, noop)
+	s.Register("expire_holds", 30*time.Minute, noop)
+
+	statuses := s.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "expire_holds" || statuses[1].Name != "overdue" {
+		t.Fatalf("statuses = %+v, want expire_holds then overdue", statuses)
+	}
+	if statuses[0].Interval != "30m0s" || statuses[0].Runs != 0 || statuses[0].LastRunAt != nil {
+		t.Errorf("status = %+v, want 30m0s interval and no runs", statuses[0])
+	}
+}
@@ -0,0 +1,352 @@
+// Package keyring 访问令牌的签名密钥管理
+//
+// 私钥以 PKCS#8（或 PKCS#1 RSA）PEM 文件保存在密钥目录中，文件名（不含 .pem）即 JWT 头部的 kid，
+// 文件修改时间视为密钥的创建时间。Ed25519 密钥使用 EdDSA 签名，RSA 密钥使用 RS256 签名。
+//
+// 目录中的所有密钥都通过 JWKS 公开用于验证，但新密钥要在公开满一个重叠期后才用于签名，
+// 使其他服务在缓存的 JWKS 过期前不会遇到未知的 kid；旧密钥在被取代后继续公开一个重叠期，
+// 保证用它签名的令牌在过期前都能通过验证，之后由轮换任务删除。
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/example/library-api/config"
+)
+
+// 新生成密钥的算法
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+const (
+	// rsaBits 生成的 RSA 密钥长度，加载的 RSA 密钥也不能短于此长度
+	rsaBits = 2048
+	// reloadInterval 遇到未知 kid 时重新读取密钥目录的最小间隔，其他实例轮换后无需重启即可验证
+	reloadInterval = 10 * time.Second
+)
+
+var (
+	// ErrNoKeys 密钥目录中没有可用的密钥
+	ErrNoKeys = errors.New("no signing keys available")
+	// ErrUnknownKey 令牌的 kid 不在密钥目录中，或签名算法与密钥不符
+	ErrUnknownKey = errors.New("unknown signing key")
+)
+
+// Key 签名密钥
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	CreatedAt time.Time
+	private   crypto.Signer
+}
+
+// Public 返回用于验证签名的公钥
+func (k *Key) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// Private 返回用于签名的私钥
+func (k *Key) Private() crypto.Signer {
+	return k.private
+}
+
+var (
+	mu         sync.RWMutex
+	dir        = "./keys"
+	algorithm  = AlgorithmEdDSA
+	rotation   = 30 * 24 * time.Hour
+	overlap    = 24 * time.Hour
+	keys       []*Key // 按创建时间从旧到新排列
+	lastReload time.Time
+)
+
+// Init 根据配置读取密钥目录，目录中没有密钥时生成一个。重叠期不短于访问令牌有效期
+func Init(cfg *config.Config) error {
+	switch cfg.JWTKeyAlgorithm {
+	case AlgorithmEdDSA, AlgorithmRS256:
+	default:
+		return fmt.Errorf("unsupported JWT key algorithm %q, use %s or %s", cfg.JWTKeyAlgorithm, AlgorithmEdDSA, AlgorithmRS256)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	dir = cfg.JWTKeyDir
+	algorithm = cfg.JWTKeyAlgorithm
+	rotation = time.Duration(cfg.JWTKeyRotationDays) * 24 * time.Hour
+	overlap = time.Duration(cfg.JWTKeyOverlapHours) * time.Hour
+	if accessTTL := time.Duration(cfg.AccessTokenMinutes) * time.Minute; overlap < accessTTL {
+		overlap = accessTTL
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := load(time.Now()); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		if _, err := generate(time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Current 返回当前用于签名的密钥：已公开满重叠期的密钥中最新的一个。
+// 所有密钥都未满重叠期时（如首次启动）使用最旧的密钥
+func Current() (*Key, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return signingKey(time.Now()), nil
+}
+
+// Keyfunc 按令牌头部的 kid 查找验证公钥，供 jwt.Parse 使用
+func Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	key := find(kid)
+	if key == nil {
+		// 其他实例可能刚刚生成了新密钥
+		mu.Lock()
+		if time.Since(lastReload) >= reloadInterval {
+			_ = load(time.Now())
+		}
+		mu.Unlock()
+		key = find(kid)
+	}
+	if key == nil || key.Method.Alg() != token.Method.Alg() {
+		return nil, ErrUnknownKey
+	}
+	return key.Public(), nil
+}
+
+// Methods 返回可接受的签名算法，拒绝 none 和 HMAC 等其他算法
+func Methods() []string {
+	return []string{AlgorithmEdDSA, AlgorithmRS256}
+}
+
+// Keys 返回目录中的全部密钥，按创建时间从旧到新排列
+func Keys() []*Key {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return append([]*Key(nil), keys...)
+}
+
+// RotateResult 一次轮换的结果
+type RotateResult struct {
+	Generated string   // 新生成的密钥ID
+	Removed   []string // 删除的密钥ID
+}
+
+// Rotate 重新读取密钥目录；最新的密钥超过轮换周期时生成新密钥，
+// 被取代超过重叠期的旧密钥从目录中删除。轮换周期为0时只重新读取
+func Rotate(now time.Time) (RotateResult, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var result RotateResult
+	if err := load(now); err != nil {
+		return result, err
+	}
+	if rotation <= 0 {
+		return result, nil
+	}
+
+	if len(keys) == 0 || now.Sub(keys[len(keys)-1].CreatedAt) >= rotation {
+		key, err := generate(now)
+		if err != nil {
+			return result, err
+		}
+		result.Generated = key.ID
+	}
+
+	// 第 i 个密钥在第 i+1 个密钥开始签名时被取代
+	var kept []*Key
+	for i, key := range keys {
+		if i+1 < len(keys) && now.Sub(keys[i+1].CreatedAt) >= 2*overlap {
+			if err := os.Remove(filepath.Join(dir, key.ID+".pem")); err != nil && !os.IsNotExist(err) {
+				return result, err
+			}
+			result.Removed = append(result.Removed, key.ID)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	keys = kept
+	return result, nil
+}
+
+// signingKey 返回签名密钥，调用方需持有锁
+func signingKey(now time.Time) *Key {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if now.Sub(keys[i].CreatedAt) >= overlap {
+			return keys[i]
+		}
+	}
+	return keys[0]
+}
+
+// find 按 kid 查找密钥
+func find(kid string) *Key {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, key := range keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// load 读取密钥目录中的全部 .pem 文件，任一文件无法解析时保留原有密钥并返回错误。调用方需持有锁
+func load(now time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var loaded []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		key, err := parseKey(data)
+		if err != nil {
+			return fmt.Errorf("key file %s: %w", entry.Name(), err)
+		}
+		key.ID = strings.TrimSuffix(entry.Name(), ".pem")
+		key.CreatedAt = info.ModTime()
+		loaded = append(loaded, key)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		if loaded[i].CreatedAt.Equal(loaded[j].CreatedAt) {
+			return loaded[i].ID < loaded[j].ID
+		}
+		return loaded[i].CreatedAt.Before(loaded[j].CreatedAt)
+	})
+	keys = loaded
+	lastReload = now
+	return nil
+}
+
+// parseKey 解析 PEM 格式的私钥
+func parseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q, expected a private key", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		return &Key{Method: jwt.SigningMethodEdDSA, private: k}, nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < rsaBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", rsaBits)
+		}
+		return &Key{Method: jwt.SigningMethodRS256, private: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use Ed25519 or RSA", parsed)
+	}
+}
+
+// generate 按配置的算法生成新密钥并写入密钥目录。调用方需持有锁
+func generate(now time.Time) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaBits)
+	default:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	kid := now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	// 先写临时文件再改名，其他实例不会读到写了一半的密钥
+	path := filepath.Join(dir, kid+".pem")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, encoded, 0o600); err != nil {
+		return nil, err
+	}
+	if err := os.Chtimes(tmp, now, now); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	key, err := parseKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = kid
+	key.CreatedAt = now
+	keys = append(keys, key)
+	return key, nil
+}
@@ -0,0 +1,273 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/example/library-api/config"
+)
+
+// setup 使用临时密钥目录初始化，返回目录。Init 生成的第一个密钥创建于当前时间
+func setup(t *testing.T, algorithm string) string {
+	t.Helper()
+
+	keyDir := t.TempDir()
+	err := Init(&config.Config{
+		JWTKeyDir:          keyDir,
+		JWTKeyAlgorithm:    algorithm,
+		JWTKeyRotationDays: 30,
+		JWTKeyOverlapHours: 24,
+		AccessTokenMinutes: 15,
+	})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return keyDir
+}
+
+// writeKey 模拟其他实例向密钥目录写入密钥，修改时间即创建时间
+func writeKey(t *testing.T, keyDir, kid string, private crypto.Signer, createdAt time.Time) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	path := filepath.Join(keyDir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if err := os.Chtimes(path, createdAt, createdAt); err != nil {
+		t.Fatalf("set key time: %v", err)
+	}
+}
+
+// signWith 使用指定算法、kid 和私钥签名测试令牌
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, private crypto.Signer) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(private)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+// parseWithKeyring 按密钥目录验证令牌
+func parseWithKeyring(signed string) error {
+	_, err := jwt.Parse(signed, Keyfunc, jwt.WithValidMethods(Methods()))
+	return err
+}
+
+// keyIDs 返回当前全部密钥的ID
+func keyIDs() []string {
+	var ids []string
+	for _, key := range Keys() {
+		ids = append(ids, key.ID)
+	}
+	return ids
+}
+
+func TestRotate(t *testing.T) {
+	keyDir := setup(t, AlgorithmEdDSA)
+	first := Keys()[0]
+	start := first.CreatedAt
+	rotated := start.Add(30 * 24 * time.Hour)
+
+	// 每一步依次执行，generated 为本步是否生成新密钥，signing 为签名密钥是第几个生成的密钥
+	steps := []struct {
+		name      string
+		now       time.Time
+		generated bool
+		removed   int
+		keys      int
+		signing   int
+	}{
+		{"轮换周期内不生成", start.Add(29 * 24 * time.Hour), false, 0, 1, 0},
+		{"超过轮换周期生成新密钥", rotated, true, 0, 2, 0},
+		{"新密钥公开未满重叠期不用于签名", rotated.Add(23 * time.Hour), false, 0, 2, 0},
+		{"新密钥公开满重叠期后用于签名", rotated.Add(24 * time.Hour), false, 0, 2, 1},
+		{"旧密钥被取代未满重叠期保留", rotated.Add(47 * time.Hour), false, 0, 2, 1},
+		{"新密钥公开满两个重叠期后删除旧密钥", rotated.Add(48 * time.Hour), false, 1, 1, 1},
+	}
+
+	generated := []string{first.ID}
+	for _, step := range steps {
+		result, err := Rotate(step.now)
+		if err != nil {
+			t.Fatalf("%s: Rotate: %v", step.name, err)
+		}
+		if (result.Generated != "") != step.generated {
+			t.Errorf("%s: generated = %q, want new key %v", step.name, result.Generated, step.generated)
+		}
+		if result.Generated != "" {
+			generated = append(generated, result.Generated)
+		}
+		if len(result.Removed) != step.removed {
+			t.Errorf("%s: removed = %v, want %d keys", step.name, result.Removed, step.removed)
+		}
+		if ids := keyIDs(); len(ids) != step.keys {
+			t.Errorf("%s: keys = %v, want %d", step.name, ids, step.keys)
+		}
+
+		mu.RLock()
+		signing := signingKey(step.now)
+		mu.RUnlock()
+		if want := generated[step.signing]; signing.ID != want {
+			t.Errorf("%s: signing key = %s, want %s", step.name, signing.ID, want)
+		}
+	}
+
+	// 删除的密钥文件已从目录中移除，重新读取后不会恢复
+	if _, err := os.Stat(filepath.Join(keyDir, first.ID+".pem")); !os.IsNotExist(err) {
+		t.Errorf("old key file still exists: %v", err)
+	}
+	if _, err := Rotate(rotated.Add(49 * time.Hour)); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if ids := keyIDs(); len(ids) != 1 || ids[0] != generated[1] {
+		t.Errorf("keys after reload = %v, want only %s", ids, generated[1])
+	}
+}
+
+func TestCurrentWithOnlyNewKey(t *testing.T) {
+	setup(t, AlgorithmEdDSA)
+
+	// 首次启动时唯一的密钥尚未公开满重叠期，仍用于签名
+	key, err := Current()
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if key.ID != Keys()[0].ID {
+		t.Errorf("signing key = %s, want the only key", key.ID)
+	}
+}
+
+func TestKeyfunc(t *testing.T) {
+	keyDir := setup(t, AlgorithmRS256)
+	rsaKey := Keys()[0]
+
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	writeKey(t, keyDir, "ed-key", edPrivate, time.Now())
+	mu.Lock()
+	lastReload = time.Time{}
+	mu.Unlock()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"RSA 密钥签名", signWith(t, jwt.SigningMethodRS256, rsaKey.ID, rsaKey.Private()), false},
+		// 其他实例写入的新密钥在重新读取目录后可以验证
+		{"重新读取后找到新密钥", signWith(t, jwt.SigningMethodEdDSA, "ed-key", edPrivate), false},
+		{"kid 指向 RSA 密钥但算法为 EdDSA", signWith(t, jwt.SigningMethodEdDSA, rsaKey.ID, edPrivate), true},
+		{"kid 指向 Ed25519 密钥但算法为 RS256", signWith(t, jwt.SigningMethodRS256, "ed-key", rsaKey.Private()), true},
+		{"未知 kid", signWith(t, jwt.SigningMethodEdDSA, "missing", edPrivate), true},
+		{"没有 kid", signWith(t, jwt.SigningMethodEdDSA, "", edPrivate), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseWithKeyring(tt.token)
+			if tt.wantErr && !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("err = %v, want ErrUnknownKey", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("err = %v, want valid token", err)
+			}
+		})
+	}
+}
+
+func TestKeyfuncReloadInterval(t *testing.T) {
+	keyDir := setup(t, AlgorithmEdDSA)
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	writeKey(t, keyDir, "late-key", private, time.Now())
+	token := signWith(t, jwt.SigningMethodEdDSA, "late-key", private)
+
+	// 距上次读取未满间隔时不重新读取目录，伪造的 kid 不会导致频繁读取
+	if err := parseWithKeyring(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey before the reload interval", err)
+	}
+
+	mu.Lock()
+	lastReload = lastReload.Add(-reloadInterval)
+	mu.Unlock()
+	if err := parseWithKeyring(token); err != nil {
+		t.Errorf("err = %v, want valid token after reload", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			setup(t, algorithm)
+			key, err := Current()
+			if err != nil {
+				t.Fatalf("Current: %v", err)
+			}
+			token := signWith(t, key.Method, key.ID, key.Private())
+
+			set := JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("keys = %+v, want 1", set.Keys)
+			}
+			jwk := set.Keys[0]
+			if jwk.Kid != key.ID || jwk.Use != "sig" || jwk.Alg != algorithm {
+				t.Errorf("jwk = %+v", jwk)
+			}
+
+			// 只用 JWK 中公开的值还原公钥并验证令牌
+			decode := func(s string) []byte {
+				b, err := base64.RawURLEncoding.DecodeString(s)
+				if err != nil {
+					t.Fatalf("decode %q: %v", s, err)
+				}
+				return b
+			}
+			var public interface{}
+			switch algorithm {
+			case AlgorithmEdDSA:
+				if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.N != "" {
+					t.Errorf("jwk = %+v, want OKP Ed25519", jwk)
+				}
+				public = ed25519.PublicKey(decode(jwk.X))
+			case AlgorithmRS256:
+				if jwk.Kty != "RSA" || jwk.X != "" {
+					t.Errorf("jwk = %+v, want RSA", jwk)
+				}
+				public = &rsa.PublicKey{
+					N: new(big.Int).SetBytes(decode(jwk.N)),
+					E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64()),
+				}
+			}
+			_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return public, nil },
+				jwt.WithValidMethods([]string{algorithm}))
+			if err != nil {
+				t.Errorf("verify with published key: %v", err)
+			}
+		})
+	}
+}
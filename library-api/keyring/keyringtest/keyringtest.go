@@ -0,0 +1,34 @@
+// Package keyringtest 测试用的签名密钥
+package keyringtest
+
+import (
+	"testing"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/keyring"
+)
+
+// Config 返回使用临时密钥目录的配置，密钥轮换和令牌有效期与默认配置相同
+func Config(t testing.TB) *config.Config {
+	t.Helper()
+
+	return &config.Config{
+		JWTKeyDir:          t.TempDir(),
+		JWTKeyAlgorithm:    keyring.AlgorithmEdDSA,
+		JWTKeyRotationDays: 30,
+		JWTKeyOverlapHours: 24,
+		AccessTokenMinutes: 15,
+		RefreshTokenDays:   30,
+	}
+}
+
+// Init 按 Config 初始化签名密钥并返回配置，调用方可以用同一配置初始化会话管理等模块
+func Init(t testing.TB) *config.Config {
+	t.Helper()
+
+	cfg := Config(t)
+	if err := keyring.Init(cfg); err != nil {
+		t.Fatalf("init keyring: %v", err)
+	}
+	return cfg
+}
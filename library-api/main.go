@@ -1,14 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/example/library-api/accession"
+	"github.com/example/library-api/catalog"
+	"github.com/example/library-api/circulation"
 	"github.com/example/library-api/config"
+	"github.com/example/library-api/covers"
 	"github.com/example/library-api/database"
+	"github.com/example/library-api/isbn"
+	"github.com/example/library-api/jobs"
+	"github.com/example/library-api/keyring"
+	"github.com/example/library-api/metadata"
 	"github.com/example/library-api/middleware"
+	"github.com/example/library-api/oai"
+	"github.com/example/library-api/oidc"
 	"github.com/example/library-api/routes"
+	"github.com/example/library-api/search"
+	"github.com/example/library-api/sessions"
 )
 
 func main() {
@@ -21,6 +39,72 @@ func main() {
 	// 初始化数据库
 	database.InitDB()
 
+	// 为编号不合法的图书副本重新分配登录号
+	accession.Init(cfg)
+	if n, err := accession.MigrateCopies(database.DB); err != nil {
+		log.Fatalf("副本登录号迁移失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已为 %d 个图书副本重新分配登录号", n)
+	}
+
+	// 将图书ISBN统一为规范的ISBN-13，无法处理的记录需要人工核对
+	if report, err := isbn.MigrateBooks(database.DB); err != nil {
+		log.Fatalf("ISBN规范化迁移失败: %v", err)
+	} else {
+		if report.Normalized > 0 {
+			log.Printf("已将 %d 本图书的ISBN规范化为ISBN-13", report.Normalized)
+		}
+		for _, inv := range report.Invalid {
+			log.Printf("图书 %d 的ISBN %q 无效，保持原值: %s", inv.BookID, inv.ISBN, inv.Error)
+		}
+		for _, conflict := range report.Conflicts {
+			log.Printf("图书 %v 的ISBN %q 规范化后均为 %s，保持原值，请合并重复图书", conflict.BookIDs, conflict.Values, conflict.ISBN)
+		}
+	}
+
+	// 初始化全文检索索引
+	if err := search.Init(database.DB); err != nil {
+		log.Printf("全文检索初始化失败: %v", err)
+	}
+
+	// 初始化令牌有效期和签名密钥，没有可用密钥时无法签发令牌
+	sessions.Init(cfg)
+	if err := keyring.Init(cfg); err != nil {
+		log.Fatalf("签名密钥初始化失败: %v", err)
+	}
+
+	// 初始化第三方登录的身份提供方，配置不完整的提供方不可用
+	if err := oidc.Init(cfg); err != nil {
+		log.Printf("身份提供方初始化失败: %v", err)
+	}
+
+	// 初始化流通参数
+	circulation.Init(cfg)
+
+	// 初始化批量导入参数
+	catalog.Init(cfg)
+
+	// 初始化 OAI-PMH 仓储信息
+	oai.Init(cfg)
+
+	// 初始化书目数据源，配置错误时关闭书目查询
+	if err := metadata.Init(cfg); err != nil {
+		log.Printf("书目数据源初始化失败: %v", err)
+	}
+
+	// 初始化封面存储，配置错误时封面上传不可用
+	if err := covers.Init(cfg); err != nil {
+		log.Printf("封面存储初始化失败: %v", err)
+	}
+
+	// 收到 SIGINT 或 SIGTERM 时停止定时任务并关闭服务器
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 启动定时任务
+	jobs.Init(database.DB, cfg)
+	jobs.Start(ctx)
+
 	// 初始化限流中间件
 	middleware.InitRateLimiter(cfg)
 
@@ -34,13 +118,26 @@ func main() {
 	routes.RegisterRoutes(router)
 
 	// 打印所有注册的路由
-for _, route := range router.Routes() {
-	log.Printf("已注册路由: %s %s\n", route.Method, route.Path)
-}
+	for _, route := range router.Routes() {
+		log.Printf("已注册路由: %s %s\n", route.Method, route.Path)
+	}
 
-// 启动服务器
-log.Printf("服务器启动在端口 %s", cfg.ServerPort)
-if err := http.ListenAndServe(":"+cfg.ServerPort, router); err != nil {
-	log.Fatalf("服务器启动失败: %v", err)
+	// 启动服务器
+	server := &http.Server{Addr: ":" + cfg.ServerPort, Handler: router}
+	go func() {
+		log.Printf("服务器启动在端口 %s", cfg.ServerPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("服务器启动失败: %v", err)
+		}
+	}()
+
+	// 等待退出信号，给进行中的请求留出处理时间
+	<-ctx.Done()
+	stop()
+	log.Printf("正在关闭服务器")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("服务器关闭失败: %v", err)
+	}
 }
-}
\ No newline at end of file
@@ -0,0 +1,129 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ISO 2709 分隔符
+const (
+	subfieldDelimiter = 0x1f
+	fieldTerminator   = 0x1e
+	recordTerminator  = 0x1d
+)
+
+const (
+	leaderLength         = 24
+	directoryEntryLength = 12
+)
+
+// Reader 读取 ISO 2709 二进制 MARC 记录
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader 创建二进制 MARC 读取器
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read 读取下一条记录，读完返回 io.EOF。记录格式错误时返回包装 ErrInvalidRecord 的错误，
+// 读取器按记录结束符定位下一条记录，可以继续读取
+func (m *Reader) Read() (*Record, error) {
+	data, err := m.r.ReadBytes(recordTerminator)
+	if errors.Is(err, io.EOF) {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, io.EOF
+		}
+		return nil, invalid("missing record terminator")
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 记录之间可能夹有换行等空白
+	data = bytes.TrimLeft(data, "\r\n\t ")
+	return parseBinary(data)
+}
+
+// parseBinary 解析一条以记录结束符结尾的二进制记录
+func parseBinary(data []byte) (*Record, error) {
+	if len(data) < leaderLength+1 {
+		return nil, invalid("record is shorter than the leader")
+	}
+	leader := string(data[:leaderLength])
+
+	length, err := strconv.Atoi(leader[0:5])
+	if err != nil || length != len(data) {
+		return nil, invalid("record length %q does not match the data", leader[0:5])
+	}
+	baseAddress, err := strconv.Atoi(leader[12:17])
+	if err != nil || baseAddress <= leaderLength || baseAddress > len(data) {
+		return nil, invalid("invalid base address %q", leader[12:17])
+	}
+	// 只支持 UTF-8 记录；未声明 UTF-8 的记录按 MARC-8 处理，只接受其中的 ASCII 字符
+	if !utf8.Valid(data) {
+		return nil, invalid("only UTF-8 encoded records are supported")
+	}
+	if leader[9] != 'a' && !isASCII(data) {
+		return nil, invalid("MARC-8 records with non-ASCII characters are not supported")
+	}
+
+	directory := data[leaderLength : baseAddress-1]
+	if data[baseAddress-1] != fieldTerminator || len(directory)%directoryEntryLength != 0 {
+		return nil, invalid("malformed directory")
+	}
+
+	rec := &Record{Leader: leader}
+	for i := 0; i < len(directory); i += directoryEntryLength {
+		entry := string(directory[i : i+directoryEntryLength])
+		tag := entry[0:3]
+		fieldLength, err1 := strconv.Atoi(entry[3:7])
+		start, err2 := strconv.Atoi(entry[7:12])
+		if err1 != nil || err2 != nil || fieldLength < 0 || start < 0 {
+			return nil, invalid("malformed directory entry for tag %s", tag)
+		}
+		begin := baseAddress + start
+		end := begin + fieldLength
+		if fieldLength < 1 || end > len(data) || data[end-1] != fieldTerminator {
+			return nil, invalid("field %s is out of range", tag)
+		}
+		value := data[begin : end-1]
+
+		if isControlTag(tag) {
+			rec.AddControl(tag, string(value))
+			continue
+		}
+		if len(value) < 2 {
+			return nil, invalid("field %s is missing indicators", tag)
+		}
+		field := DataField{Tag: tag, Ind1: string(value[0]), Ind2: string(value[1])}
+		for _, part := range bytes.Split(value[2:], []byte{subfieldDelimiter}) {
+			if len(part) == 0 {
+				continue
+			}
+			_, size := utf8.DecodeRune(part)
+			field.Subfields = append(field.Subfields, Subfield{
+				Code:  string(part[:size]),
+				Value: strings.TrimSpace(string(part[size:])),
+			})
+		}
+		rec.DataFields = append(rec.DataFields, field)
+	}
+
+	return rec, nil
+}
+
+// isASCII 判断数据是否只包含 ASCII 字符
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b > 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+// Package marc MARC21 书目记录的读写
+//
+// 支持 ISO 2709 二进制格式（.mrc）和 MARCXML 的读取，以及 MARCXML 的写出。
+// 本包只处理记录结构（头标、控制字段、数据字段和子字段），
+// 与图书模型之间的映射由 catalog 包完成。二进制记录须为 UTF-8 编码（头标第9位为 a），
+// 未声明 UTF-8 的记录按 MARC-8 处理，只能包含 ASCII 字符。
+package marc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidRecord 记录结构不符合 MARC21 格式
+var ErrInvalidRecord = errors.New("invalid marc record")
+
+// MARCXML 命名空间
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+// defaultLeader 新建记录使用的头标：新记录、语言资料、专著、UTF-8
+const defaultLeader = "00000nam a2200000 i 4500"
+
+// Subfield 子字段
+type Subfield struct {
+	Code  string
+	Value string
+}
+
+// ControlField 控制字段（001-009）
+type ControlField struct {
+	Tag   string
+	Value string
+}
+
+// DataField 数据字段
+type DataField struct {
+	Tag       string
+	Ind1      string
+	Ind2      string
+	Subfields []Subfield
+}
+
+// Record MARC 书目记录
+type Record struct {
+	Leader        string
+	ControlFields []ControlField
+	DataFields    []DataField
+}
+
+// NewRecord 创建使用默认头标的空记录
+func NewRecord() *Record {
+	return &Record{Leader: defaultLeader}
+}
+
+// Control 返回第一个指定标签的控制字段值
+func (r *Record) Control(tag string) string {
+	for _, f := range r.ControlFields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Fields 返回指定标签的全部数据字段
+func (r *Record) Fields(tag string) []DataField {
+	var fields []DataField
+	for _, f := range r.DataFields {
+		if f.Tag == tag {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// AddControl 添加控制字段
+func (r *Record) AddControl(tag, value string) {
+	r.ControlFields = append(r.ControlFields, ControlField{Tag: tag, Value: value})
+}
+
+// AddField 添加数据字段，subfields 按子字段代码和值依次给出，值为空的子字段被忽略
+func (r *Record) AddField(tag, ind1, ind2 string, subfields ...string) {
+	field := DataField{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for i := 0; i+1 < len(subfields); i += 2 {
+		if subfields[i+1] != "" {
+			field.Subfields = append(field.Subfields, Subfield{Code: subfields[i], Value: subfields[i+1]})
+		}
+	}
+	if len(field.Subfields) > 0 {
+		r.DataFields = append(r.DataFields, field)
+	}
+}
+
+// Subfield 返回第一个指定代码的子字段值
+func (f DataField) Subfield(code string) string {
+	for _, s := range f.Subfields {
+		if s.Code == code {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// isControlTag 判断标签是否为控制字段
+func isControlTag(tag string) bool {
+	return len(tag) == 3 && tag[0] == '0' && tag[1] == '0'
+}
+
+// invalid 构造记录格式错误
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRecord, fmt.Sprintf(format, args...))
+}
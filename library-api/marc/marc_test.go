@@ -0,0 +1,253 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// rawField 测试用的原始字段，数据字段的值包含指示符和子字段分隔符
+type rawField struct {
+	tag   string
+	value string
+}
+
+// dataValue 拼接指示符和子字段，subfields 依次为子字段代码和值
+func dataValue(ind1, ind2 string, subfields ...string) string {
+	var b strings.Builder
+	b.WriteString(ind1 + ind2)
+	for i := 0; i+1 < len(subfields); i += 2 {
+		b.WriteByte(subfieldDelimiter)
+		b.WriteString(subfields[i] + subfields[i+1])
+	}
+	return b.String()
+}
+
+// buildBinary 按 ISO 2709 格式组装一条记录，coding 为头标第9位的字符编码
+func buildBinary(coding byte, fields ...rawField) []byte {
+	var directory, body bytes.Buffer
+	for _, f := range fields {
+		value := f.value + string(rune(fieldTerminator))
+		fmt.Fprintf(&directory, "%s%04d%05d", f.tag, len(value), body.Len())
+		body.WriteString(value)
+	}
+	directory.WriteByte(fieldTerminator)
+
+	baseAddress := leaderLength + directory.Len()
+	length := baseAddress + body.Len() + 1
+	leader := []byte(fmt.Sprintf("%05dnam %c22%05d i 4500", length, coding, baseAddress))
+
+	var rec bytes.Buffer
+	rec.Write(leader)
+	rec.Write(directory.Bytes())
+	rec.Write(body.Bytes())
+	rec.WriteByte(recordTerminator)
+	return rec.Bytes()
+}
+
+// sampleFields 一条包含控制字段和数据字段的记录
+func sampleFields(title string) []rawField {
+	return []rawField{
+		{"001", "rec-1"},
+		{"020", dataValue(" ", " ", "a", "9780134190440")},
+		{"245", dataValue("1", "0", "a", title, "c", "Donovan")},
+	}
+}
+
+func TestReadBinary(t *testing.T) {
+	data := buildBinary('a', sampleFields("Go 程序设计语言")...)
+
+	rec, err := NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := rec.Control("001"); got != "rec-1" {
+		t.Errorf("001 = %q, want rec-1", got)
+	}
+	title := rec.Fields("245")
+	if len(title) != 1 || title[0].Ind1 != "1" || title[0].Subfield("a") != "Go 程序设计语言" || title[0].Subfield("c") != "Donovan" {
+		t.Errorf("245 = %+v", title)
+	}
+}
+
+func TestReadBinaryEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		coding  byte
+		title   string
+		wantErr bool
+	}{
+		{"UTF-8", 'a', "Café", false},
+		{"MARC-8 ASCII", ' ', "Cafe", false},
+		{"MARC-8 非 ASCII", ' ', "Caf\xe9", true},
+		{"声明 UTF-8 但编码无效", 'a', "Caf\xe9", true},
+		{"MARC-8 中的 UTF-8 字符", ' ', "Café", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildBinary(tt.coding, sampleFields(tt.title)...)
+			rec, err := NewReader(bytes.NewReader(data)).Read()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRecord) {
+					t.Fatalf("err = %v, want ErrInvalidRecord", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if got := rec.Fields("245")[0].Subfield("a"); got != tt.title {
+				t.Errorf("title = %q, want %q", got, tt.title)
+			}
+		})
+	}
+}
+
+func TestReadBinaryMalformed(t *testing.T) {
+	valid := buildBinary('a', sampleFields("Go")...)
+	baseAddress := leaderLength + 3*directoryEntryLength + 1
+
+	// mutate 复制合法记录后修改指定位置的内容
+	mutate := func(offset int, value string) []byte {
+		data := append([]byte(nil), valid...)
+		copy(data[offset:], value)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"短于头标", []byte("00010nam" + string(rune(recordTerminator)))},
+		{"记录长度不符", mutate(0, "99999")},
+		{"记录长度不是数字", mutate(0, "0x123")},
+		{"基地址超出记录", mutate(12, "99999")},
+		{"基地址在头标内", mutate(12, "00010")},
+		{"目录长度不是条目的整数倍", mutate(12, fmt.Sprintf("%05d", baseAddress-1))},
+		{"目录条目不是数字", mutate(leaderLength+3, "abcd")},
+		{"字段长度为负数", mutate(leaderLength+3, "-001")},
+		{"字段起始位置为负数", mutate(leaderLength+7, "-0001")},
+		{"字段超出记录", mutate(leaderLength+directoryEntryLength+7, "09999")},
+		{"字段长度越过字段结束符", mutate(leaderLength+3, "0004")},
+		{"数据字段缺少指示符", buildBinary('a', rawField{"245", "1"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(tt.data)).Read()
+			if !errors.Is(err, ErrInvalidRecord) {
+				t.Fatalf("err = %v, want ErrInvalidRecord", err)
+			}
+		})
+	}
+}
+
+func TestReadBinaryContinuesAfterBadRecord(t *testing.T) {
+	bad := buildBinary('a', sampleFields("Bad")...)
+	copy(bad, "99999")
+
+	var stream bytes.Buffer
+	stream.Write(bad)
+	stream.WriteString("\r\n")
+	stream.Write(buildBinary('a', sampleFields("Good")...))
+
+	r := NewReader(&stream)
+	if _, err := r.Read(); !IsRecordError(err) {
+		t.Fatalf("first record: err = %v, want record error", err)
+	}
+	rec, err := r.Read()
+	if err != nil {
+		t.Fatalf("second record: %v", err)
+	}
+	if got := rec.Fields("245")[0].Subfield("a"); got != "Good" {
+		t.Errorf("title = %q, want Good", got)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
+
+func TestReadBinaryMissingTerminator(t *testing.T) {
+	data := buildBinary('a', sampleFields("Go")...)
+	_, err := NewReader(bytes.NewReader(data[:len(data)-1])).Read()
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("err = %v, want ErrInvalidRecord", err)
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	rec := NewRecord()
+	rec.AddControl("001", "rec-1")
+	rec.AddField("245", "1", "0", "a", "Go & <XML>", "c", "Donovan")
+
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf)
+	if err := w.Write(rec); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	got, err := NewXMLReader(&buf).Read()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got.Control("001") != "rec-1" {
+		t.Errorf("001 = %q, want rec-1", got.Control("001"))
+	}
+	if title := got.Fields("245"); len(title) != 1 || title[0].Subfield("a") != "Go & <XML>" {
+		t.Errorf("245 = %+v", title)
+	}
+}
+
+func TestReadXMLMalformed(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		wantRecord bool // 只影响单条记录的错误
+	}{
+		{"控制字段标签无效", `<record><controlfield tag="245">x</controlfield></record>`, true},
+		{"数据字段标签是控制字段", `<record><datafield tag="001"><subfield code="a">x</subfield></datafield></record>`, true},
+		{"数据字段标签长度错误", `<record><datafield tag="24"><subfield code="a">x</subfield></datafield></record>`, true},
+		{"元素未闭合", `<collection><record><leader>00000nam a2200000 i 4500</leader></collection>`, false},
+		{"文档被截断", `<collection><record><datafield tag="245"><subfield code="a">Go`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewXMLReader(strings.NewReader(tt.doc)).Read()
+			if err == nil {
+				t.Fatal("err = nil, want error")
+			}
+			if IsRecordError(err) != tt.wantRecord {
+				t.Errorf("IsRecordError(%v) = %v, want %v", err, !tt.wantRecord, tt.wantRecord)
+			}
+		})
+	}
+}
+
+func TestReadXMLSkipsUnknownElements(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<marc:collection xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:note>ignored</marc:note>
+  <marc:record>
+    <marc:leader>00000nam a2200000 i 4500</marc:leader>
+    <marc:datafield tag="245" ind1="1"><marc:subfield code="a"> Go </marc:subfield></marc:datafield>
+  </marc:record>
+</marc:collection>`
+
+	r := NewXMLReader(strings.NewReader(doc))
+	rec, err := r.Read()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	title := rec.Fields("245")[0]
+	if title.Ind1 != "1" || title.Ind2 != " " || title.Subfield("a") != "Go" {
+		t.Errorf("245 = %+v", title)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
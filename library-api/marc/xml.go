@@ -0,0 +1,163 @@
+package marc
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// xmlRecord MARCXML 记录元素，标签不带命名空间因此同时匹配带前缀和不带前缀的文档
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// XMLReader 读取 MARCXML 文档中的记录，文档根元素可以是 collection 或单个 record
+type XMLReader struct {
+	d *xml.Decoder
+}
+
+// NewXMLReader 创建 MARCXML 读取器
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{d: xml.NewDecoder(r)}
+}
+
+// Read 读取下一条记录，读完返回 io.EOF。单条记录内容错误时返回包装 ErrInvalidRecord 的错误；
+// XML 语法错误无法恢复，直接返回解析错误
+func (x *XMLReader) Read() (*Record, error) {
+	for {
+		token, err := x.d.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var xr xmlRecord
+		if err := x.d.DecodeElement(&xr, &start); err != nil {
+			return nil, err
+		}
+		return xr.toRecord()
+	}
+}
+
+func (xr *xmlRecord) toRecord() (*Record, error) {
+	rec := &Record{Leader: xr.Leader}
+	for _, cf := range xr.ControlFields {
+		if !isControlTag(cf.Tag) {
+			return nil, invalid("controlfield has invalid tag %q", cf.Tag)
+		}
+		rec.AddControl(cf.Tag, cf.Value)
+	}
+	for _, df := range xr.DataFields {
+		if len(df.Tag) != 3 || isControlTag(df.Tag) {
+			return nil, invalid("datafield has invalid tag %q", df.Tag)
+		}
+		field := DataField{Tag: df.Tag, Ind1: indicator(df.Ind1), Ind2: indicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			field.Subfields = append(field.Subfields, Subfield{Code: sf.Code, Value: strings.TrimSpace(sf.Value)})
+		}
+		rec.DataFields = append(rec.DataFields, field)
+	}
+	return rec, nil
+}
+
+// indicator 缺省的指示符为空格
+func indicator(value string) string {
+	if value == "" {
+		return " "
+	}
+	return value
+}
+
+// XMLWriter 将记录写为 MARCXML collection 文档
+type XMLWriter struct {
+	w       io.Writer
+	e       *xml.Encoder
+	started bool
+}
+
+// NewXMLWriter 创建 MARCXML 写入器，写完全部记录后必须调用 Close
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	return &XMLWriter{w: w, e: e}
+}
+
+var collectionStart = xml.StartElement{
+	Name: xml.Name{Local: "collection"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}},
+}
+
+func (x *XMLWriter) start() error {
+	if x.started {
+		return nil
+	}
+	x.started = true
+	if _, err := io.WriteString(x.w, xml.Header); err != nil {
+		return err
+	}
+	return x.e.EncodeToken(collectionStart)
+}
+
+// Write 写入一条记录
+func (x *XMLWriter) Write(rec *Record) error {
+	if err := x.start(); err != nil {
+		return err
+	}
+
+	xr := xmlRecord{Leader: rec.Leader}
+	for _, cf := range rec.ControlFields {
+		xr.ControlFields = append(xr.ControlFields, xmlControlField{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range rec.DataFields {
+		field := xmlDataField{Tag: df.Tag, Ind1: indicator(df.Ind1), Ind2: indicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			field.Subfields = append(field.Subfields, xmlSubfield{Code: sf.Code, Value: sf.Value})
+		}
+		xr.DataFields = append(xr.DataFields, field)
+	}
+	return x.e.Encode(xr)
+}
+
+// Close 结束 collection 元素并刷新输出
+func (x *XMLWriter) Close() error {
+	if err := x.start(); err != nil {
+		return err
+	}
+	if err := x.e.EncodeToken(collectionStart.End()); err != nil {
+		return err
+	}
+	if err := x.e.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, "\n")
+	return err
+}
+
+// IsRecordError 判断错误是否只影响单条记录，读取器可以继续读取后续记录
+func IsRecordError(err error) bool {
+	return errors.Is(err, ErrInvalidRecord)
+}
@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/example/library-api/isbn"
+)
+
+// FileProvider 从本地 JSON 文件读取书目数据，用于离线环境和测试，不访问外部服务。
+// 文件内容为以 ISBN 为键的对象，值的字段与 Result 相同：
+//
+//	{"9780441013593": {"title": "Dune", "authors": ["Frank Herbert"], "publisher": "Ace", "publication_date": "1965"}}
+//
+// 键可以是 ISBN-10 或带连字符的 ISBN；每次查询都重新读取文件，修改后无需重启
+type FileProvider struct {
+	path string
+}
+
+// NewFileProvider 创建本地文件数据源
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+// Name 数据源名称
+func (f *FileProvider) Name() string {
+	return ProviderFile
+}
+
+// Lookup 在文件中查找 ISBN
+func (f *FileProvider) Lookup(ctx context.Context, canonical string) (*Result, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]Result
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", f.path, err)
+	}
+
+	for key, entry := range entries {
+		if normalized, err := isbn.Normalize(key); err != nil || normalized != canonical {
+			continue
+		}
+		entry.PublicationDate = normalizeDate(entry.PublicationDate)
+		return &entry, nil
+	}
+	return nil, ErrNotFound
+}
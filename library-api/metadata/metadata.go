@@ -0,0 +1,170 @@
+// Package metadata 按 ISBN 从外部数据源查询书目数据，供编目时预填图书信息
+//
+// 数据源实现 Provider 接口，按配置的顺序依次查询，第一个找到记录的数据源的结果被采用。
+// 查询结果（包括所有数据源都没有记录的情况）缓存在 metadata_caches 表中，
+// 在 METADATA_CACHE_TTL_HOURS 内再次查询同一 ISBN 不访问外部服务。
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/models"
+)
+
+// 数据源名称
+const (
+	ProviderOpenLibrary = "openlibrary"
+	ProviderFile        = "file"
+)
+
+var (
+	// ErrNotFound 数据源没有该 ISBN 的记录
+	ErrNotFound = errors.New("no metadata found for isbn")
+	// ErrNoProviders 没有配置数据源
+	ErrNoProviders = errors.New("metadata lookup is not configured")
+)
+
+// Result 查询到的书目数据，出版日期为 YYYY-MM-DD 格式，只知道年份时为当年1月1日
+type Result struct {
+	ISBN            string   `json:"isbn"`
+	Title           string   `json:"title"`
+	Authors         []string `json:"authors"`
+	Publisher       string   `json:"publisher,omitempty"`
+	PublicationDate string   `json:"publication_date,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Source          string   `json:"source"`
+}
+
+// Provider 书目数据源。isbn 为规范的 ISBN-13，没有记录时返回 ErrNotFound
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, isbn string) (*Result, error)
+}
+
+var (
+	providers []Provider
+	cacheTTL  = 7 * 24 * time.Hour
+)
+
+// Init 根据配置创建数据源，数据源名称未知或缺少必需的配置时返回错误，此时不启用任何数据源
+func Init(cfg *config.Config) error {
+	cacheTTL = time.Duration(cfg.MetadataCacheTTLHours) * time.Hour
+	timeout := time.Duration(cfg.MetadataTimeoutSeconds) * time.Second
+
+	providers = nil
+	var configured []Provider
+	for _, name := range cfg.MetadataProviders {
+		switch name {
+		case ProviderOpenLibrary:
+			configured = append(configured, NewOpenLibrary(cfg.MetadataOpenLibraryURL, timeout))
+		case ProviderFile:
+			if cfg.MetadataFilePath == "" {
+				return errors.New("metadata provider file requires METADATA_FILE_PATH")
+			}
+			configured = append(configured, NewFileProvider(cfg.MetadataFilePath))
+		default:
+			return fmt.Errorf("unknown metadata provider %q", name)
+		}
+	}
+	providers = configured
+	return nil
+}
+
+// Lookup 查询 ISBN 的书目数据，返回结果以及结果是否来自缓存。refresh 为 true 时忽略缓存。
+// 有数据源出错时不缓存"未找到"，以免把临时故障当作没有记录
+func Lookup(ctx context.Context, db *gorm.DB, isbn string, refresh bool) (*Result, bool, error) {
+	if len(providers) == 0 {
+		return nil, false, ErrNoProviders
+	}
+
+	if !refresh && cacheTTL > 0 {
+		var cached models.MetadataCache
+		err := db.Where("isbn = ? AND fetched_at > ?", isbn, time.Now().Add(-cacheTTL)).First(&cached).Error
+		if err == nil {
+			if !cached.Found {
+				return nil, true, ErrNotFound
+			}
+			var result Result
+			if err := json.Unmarshal([]byte(cached.Data), &result); err == nil {
+				return &result, true, nil
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+	}
+
+	var failed error
+	for _, p := range providers {
+		result, err := p.Lookup(ctx, isbn)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			failed = fmt.Errorf("%s: %w", p.Name(), err)
+			continue
+		}
+		result.ISBN = isbn
+		result.Source = p.Name()
+		store(db, isbn, result)
+		return result, false, nil
+	}
+	if failed != nil {
+		return nil, false, failed
+	}
+	store(db, isbn, nil)
+	return nil, false, ErrNotFound
+}
+
+// store 写入缓存，result 为 nil 表示未找到。缓存写入失败不影响本次查询
+func store(db *gorm.DB, isbn string, result *Result) {
+	if cacheTTL <= 0 {
+		return
+	}
+	entry := models.MetadataCache{ISBN: isbn, FetchedAt: time.Now()}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return
+		}
+		entry.Source = result.Source
+		entry.Found = true
+		entry.Data = string(data)
+	}
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error; err != nil {
+		log.Printf("写入书目数据缓存失败: %v", err)
+	}
+}
+
+// dateLayouts 外部数据中常见的出版日期格式
+var dateLayouts = []string{"2006-01-02", "January 2, 2006", "Jan 2, 2006", "January 2006", "Jan 2006", "2006-01", "2006"}
+
+// yearPattern 无法按已知格式解析时，取日期中的四位年份，如 "c1965." 或 "[1990?]"，不匹配更长数字的一部分
+var yearPattern = regexp.MustCompile(`(?:^|\D)(\d{4})(?:\D|$)`)
+
+// normalizeDate 将出版日期转换为 YYYY-MM-DD，无法识别时返回空字符串
+func normalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	if m := yearPattern.FindStringSubmatch(value); m != nil {
+		return m[1] + "-01-01"
+	}
+	return ""
+}
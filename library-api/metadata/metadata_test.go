@@ -0,0 +1,233 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+const duneISBN = "9780441013593"
+
+// countingProvider 记录查询次数，并以指定名称区分多个文件数据源
+type countingProvider struct {
+	Provider
+	name  string
+	calls int
+}
+
+func (c *countingProvider) Name() string {
+	return c.name
+}
+
+func (c *countingProvider) Lookup(ctx context.Context, isbn string) (*Result, error) {
+	c.calls++
+	return c.Provider.Lookup(ctx, isbn)
+}
+
+// fileSource 将内容写入临时文件并创建文件数据源，content 为空时文件不存在，查询返回错误
+func fileSource(t *testing.T, name, content string) *countingProvider {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name+".json")
+	if content != "" {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	return &countingProvider{Provider: NewFileProvider(path), name: name}
+}
+
+// useProviders 临时替换数据源和缓存有效期
+func useProviders(t *testing.T, list ...*countingProvider) {
+	t.Helper()
+
+	previous, previousTTL := providers, cacheTTL
+	providers = nil
+	for _, p := range list {
+		providers = append(providers, p)
+	}
+	cacheTTL = time.Hour
+	t.Cleanup(func() { providers, cacheTTL = previous, previousTTL })
+}
+
+// cacheEntry 读取 ISBN 的缓存记录，不存在时返回 nil
+func cacheEntry(t *testing.T, db *gorm.DB, isbn string) *models.MetadataCache {
+	t.Helper()
+
+	var entry models.MetadataCache
+	err := db.Where("isbn = ?", isbn).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("load cache: %v", err)
+	}
+	return &entry
+}
+
+func TestLookupProviderOrder(t *testing.T) {
+	db := databasetest.Open(t)
+	empty := fileSource(t, "empty", `{}`)
+	first := fileSource(t, "first", `{"0-441-01359-7": {"title": "Dune", "authors": ["Frank Herbert"], "publication_date": "August 1965"}}`)
+	second := fileSource(t, "second", `{"9780441013593": {"title": "Dune (second)"}}`)
+	useProviders(t, empty, first, second)
+
+	result, cached, err := Lookup(context.Background(), db, duneISBN, false)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if cached {
+		t.Error("first lookup reported a cached result")
+	}
+	if result.Title != "Dune" || result.Source != "first" || result.ISBN != duneISBN || result.PublicationDate != "1965-08-01" {
+		t.Errorf("result = %+v, want Dune from first with ISBN %s", result, duneISBN)
+	}
+	if empty.calls != 1 || first.calls != 1 || second.calls != 0 {
+		t.Errorf("calls = %d, %d, %d, want 1, 1, 0", empty.calls, first.calls, second.calls)
+	}
+}
+
+func TestLookupCachesResults(t *testing.T) {
+	db := databasetest.Open(t)
+	source := fileSource(t, "source", `{"9780441013593": {"title": "Dune", "authors": ["Frank Herbert"]}}`)
+	useProviders(t, source)
+
+	if _, _, err := Lookup(context.Background(), db, duneISBN, false); err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	result, cached, err := Lookup(context.Background(), db, duneISBN, false)
+	if err != nil {
+		t.Fatalf("cached Lookup: %v", err)
+	}
+	if !cached || result.Title != "Dune" || result.Source != "source" || len(result.Authors) != 1 {
+		t.Errorf("cached result = %+v, %v", result, cached)
+	}
+	if source.calls != 1 {
+		t.Errorf("provider calls = %d, want 1", source.calls)
+	}
+
+	// refresh 忽略缓存并重新查询
+	if _, cached, err := Lookup(context.Background(), db, duneISBN, true); err != nil || cached {
+		t.Errorf("refresh = %v, %v, want a fresh result", cached, err)
+	}
+	if source.calls != 2 {
+		t.Errorf("provider calls after refresh = %d, want 2", source.calls)
+	}
+
+	// 缓存过期后重新查询
+	if err := db.Model(&models.MetadataCache{}).Where("isbn = ?", duneISBN).
+		Update("fetched_at", time.Now().Add(-2*time.Hour)).Error; err != nil {
+		t.Fatalf("expire cache: %v", err)
+	}
+	if _, cached, err := Lookup(context.Background(), db, duneISBN, false); err != nil || cached {
+		t.Errorf("expired lookup = %v, %v, want a fresh result", cached, err)
+	}
+	if source.calls != 3 {
+		t.Errorf("provider calls after expiry = %d, want 3", source.calls)
+	}
+}
+
+func TestLookupCachesNotFound(t *testing.T) {
+	db := databasetest.Open(t)
+	a := fileSource(t, "a", `{}`)
+	b := fileSource(t, "b", `{"9780140449136": {"title": "The Odyssey"}}`)
+	useProviders(t, a, b)
+
+	if _, _, err := Lookup(context.Background(), db, duneISBN, false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if entry := cacheEntry(t, db, duneISBN); entry == nil || entry.Found {
+		t.Fatalf("cache entry = %+v, want not-found entry", entry)
+	}
+
+	_, cached, err := Lookup(context.Background(), db, duneISBN, false)
+	if !errors.Is(err, ErrNotFound) || !cached {
+		t.Errorf("second lookup = %v, %v, want cached ErrNotFound", cached, err)
+	}
+	if a.calls != 1 || b.calls != 1 {
+		t.Errorf("calls = %d, %d, want 1, 1", a.calls, b.calls)
+	}
+}
+
+func TestLookupDoesNotCacheNotFoundAfterError(t *testing.T) {
+	db := databasetest.Open(t)
+	broken := fileSource(t, "broken", "")
+	empty := fileSource(t, "empty", `{}`)
+	useProviders(t, broken, empty)
+
+	_, _, err := Lookup(context.Background(), db, duneISBN, false)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want provider error", err)
+	}
+	if entry := cacheEntry(t, db, duneISBN); entry != nil {
+		t.Fatalf("cache entry = %+v, want none after a provider error", entry)
+	}
+
+	// 下次查询仍访问数据源
+	if _, cached, _ := Lookup(context.Background(), db, duneISBN, false); cached {
+		t.Error("second lookup was served from the cache")
+	}
+	if broken.calls != 2 || empty.calls != 2 {
+		t.Errorf("calls = %d, %d, want 2, 2", broken.calls, empty.calls)
+	}
+}
+
+func TestLookupFallsBackAfterError(t *testing.T) {
+	db := databasetest.Open(t)
+	broken := fileSource(t, "broken", `not json`)
+	source := fileSource(t, "source", `{"9780441013593": {"title": "Dune"}}`)
+	useProviders(t, broken, source)
+
+	result, _, err := Lookup(context.Background(), db, duneISBN, false)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if result.Source != "source" {
+		t.Errorf("source = %q, want source", result.Source)
+	}
+	if entry := cacheEntry(t, db, duneISBN); entry == nil || !entry.Found || entry.Source != "source" {
+		t.Errorf("cache entry = %+v, want found entry from source", entry)
+	}
+}
+
+func TestLookupWithoutProviders(t *testing.T) {
+	db := databasetest.Open(t)
+	useProviders(t)
+
+	if _, _, err := Lookup(context.Background(), db, duneISBN, false); !errors.Is(err, ErrNoProviders) {
+		t.Errorf("err = %v, want ErrNoProviders", err)
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := map[string]string{
+		"":                   "",
+		"1965-08-01":         "1965-08-01",
+		"August 1, 1965":     "1965-08-01",
+		"Aug 1, 1965":        "1965-08-01",
+		"August 1965":        "1965-08-01",
+		"Aug 1965":           "1965-08-01",
+		"1965-08":            "1965-08-01",
+		"1965":               "1965-01-01",
+		"  1965  ":           "1965-01-01",
+		"c1965.":             "1965-01-01",
+		"[1990?]":            "1990-01-01",
+		"Spring 2001":        "2001-01-01",
+		"printed 1965, 1999": "1965-01-01",
+		"unknown":            "",
+		"12345":              "",
+	}
+	for value, want := range tests {
+		if got := normalizeDate(value); got != want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxResponseBytes 外部服务响应的最大字节数
+const maxResponseBytes = 1 << 20
+
+// OpenLibrary 通过 Open Library Books API（https://openlibrary.org/dev/docs/api/books）查询书目数据，
+// 兼容该接口的其他服务可以通过 METADATA_OPENLIBRARY_URL 指定地址
+type OpenLibrary struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOpenLibrary 创建 Open Library 数据源
+func NewOpenLibrary(baseURL string, timeout time.Duration) *OpenLibrary {
+	return &OpenLibrary{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Name 数据源名称
+func (o *OpenLibrary) Name() string {
+	return ProviderOpenLibrary
+}
+
+// openLibraryBook jscmd=data 格式的图书数据
+type openLibraryBook struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	Authors  []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+	PublishDate string `json:"publish_date"`
+	// Notes 可能是字符串，也可能是 {"type": "/type/text", "value": "..."}
+	Notes json.RawMessage `json:"notes"`
+}
+
+// Lookup 查询 ISBN，响应中没有该 ISBN 的条目时返回 ErrNotFound
+func (o *OpenLibrary) Lookup(ctx context.Context, isbn string) (*Result, error) {
+	key := "ISBN:" + isbn
+	query := url.Values{"bibkeys": {key}, "format": {"json"}, "jscmd": {"data"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/api/books?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "library-api")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var books map[string]openLibraryBook
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&books); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	book, ok := books[key]
+	if !ok || book.Title == "" {
+		return nil, ErrNotFound
+	}
+
+	result := &Result{
+		Title:           strings.TrimSpace(book.Title),
+		PublicationDate: normalizeDate(book.PublishDate),
+		Description:     notesText(book.Notes),
+	}
+	if subtitle := strings.TrimSpace(book.Subtitle); subtitle != "" {
+		result.Title += ": " + subtitle
+	}
+	for _, a := range book.Authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			result.Authors = append(result.Authors, name)
+		}
+	}
+	if len(book.Publishers) > 0 {
+		result.Publisher = strings.TrimSpace(book.Publishers[0].Name)
+	}
+	return result, nil
+}
+
+// notesText 读取字符串或文本对象形式的附注
+func notesText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+	var typed struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &typed); err == nil {
+		return strings.TrimSpace(typed.Value)
+	}
+	return ""
+}
@@ -1,35 +1,42 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/keyring"
 	"github.com/example/library-api/models"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
-// Claims 定义JWT声明
+// Claims 定义JWT声明，SessionID 为签发令牌的登录会话，jti 用于单独撤销令牌
 type Claims struct {
-	UserID uint         `json:"user_id"`
-	Role   models.UserRole `json:"role"`
+	UserID    uint         `json:"user_id"`
+	Role      models.UserRole `json:"role"`
+	SessionID uint         `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT令牌
-func GenerateToken(userID uint, role models.UserRole) (string, error) {
-	// 设置令牌过期时间为7天
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
+// GenerateToken 为登录会话生成访问令牌，有效期由会话管理决定
+func GenerateToken(userID uint, role models.UserRole, sessionID uint, expirationTime time.Time) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -38,29 +45,28 @@ func GenerateToken(userID uint, role models.UserRole) (string, error) {
 		},
 	}
 
-	// 创建令牌
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// 使用当前签名密钥创建令牌，kid 标识验证所用的公钥
+	key, err := keyring.Current()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
 	// 签名令牌
-	tokenString, err := token.SignedString(jwtSecret)
-
-	return tokenString, err
+	return token.SignedString(key.Private())
 }
 
-// ParseToken 解析JWT令牌
+// ParseToken 解析JWT令牌，只接受密钥目录中的密钥签名的令牌
 func ParseToken(tokenString string) (*Claims, error) {
 	// 解析令牌
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keyring.Keyfunc, jwt.WithValidMethods(keyring.Methods()))
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	// 访问令牌没有受众，带受众的是同一密钥签名的登录流程状态等其他令牌
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && len(claims.Audience) == 0 {
 		return claims, nil
 	}
 
@@ -79,8 +85,7 @@ func JWTMiddleware() gin.HandlerFunc {
 		}
 
 		// 检查令牌格式
-		var tokenString string
-		fmt.Sscanf(authHeader, "Bearer %s", &tokenString)
+		tokenString := BearerToken(c)
 		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
 			c.Abort()
@@ -95,14 +100,50 @@ func JWTMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 会话已登出或被撤销、令牌已单独撤销时拒绝访问
+		revoked, err := tokenRevoked(claims)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储在上下文中
 		c.Set("userID", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("sessionID", claims.SessionID)
 
 		c.Next()
 	}
 }
 
+// BearerToken 从Authorization头中取出Bearer令牌，格式不正确时返回空字符串
+func BearerToken(c *gin.Context) string {
+	var tokenString string
+	fmt.Sscanf(c.GetHeader("Authorization"), "Bearer %s", &tokenString)
+	return tokenString
+}
+
+// tokenRevoked 检查令牌所属的会话是否仍然有效、令牌本身是否已被撤销。
+// 没有会话的令牌（引入会话之前签发的令牌）视为已撤销
+func tokenRevoked(claims *Claims) (bool, error) {
+	if claims.SessionID == 0 {
+		return true, nil
+	}
+
+	var active int64
+	err := database.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", claims.SessionID, claims.UserID).
+		Where("NOT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)", claims.ID).
+		Count(&active).Error
+	return active == 0, err
+}
+
 // AdminRequired 管理员权限中间件
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,7 @@
+package models
+
+// AccessionSequence 登录号序列，Value 为下一个待分配的序号
+type AccessionSequence struct {
+	Name  string `gorm:"primaryKey;size:50" json:"name"`
+	Value int64  `gorm:"not null" json:"value"`
+}
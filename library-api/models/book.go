@@ -23,9 +23,31 @@ type Book struct {
 	Description     string         `gorm:"type:text" json:"description,omitempty"`
 	Publisher       string         `gorm:"size:100" json:"publisher,omitempty"`
 	PublicationDate time.Time      `json:"publication_date,omitempty"`
+	CoverKey        string         `gorm:"size:100" json:"-"`
+	CoverType       string         `gorm:"size:30" json:"-"`
+	Language        string         `gorm:"size:10" json:"language,omitempty"`
+	Format          BookFormat     `gorm:"size:20" json:"format,omitempty"`
+	Edition         string         `gorm:"size:100" json:"edition,omitempty"`
+	WorkID          *uint          `gorm:"index" json:"work_id,omitempty"`
+	SeriesID        *uint          `gorm:"index" json:"series_id,omitempty"`
+	SeriesNumber    *float64       `json:"series_number,omitempty"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 	Authors         []Author       `gorm:"many2many:book_authors" json:"authors,omitempty"`
+	Categories      []Category     `gorm:"many2many:book_categories" json:"categories,omitempty"`
+	Tags            []Tag          `gorm:"many2many:book_tags" json:"tags,omitempty"`
+	Work            *Work          `gorm:"foreignKey:WorkID" json:"work,omitempty"`
+	Series          *Series        `gorm:"foreignKey:SeriesID" json:"series,omitempty"`
 	Copies          []BookCopy     `gorm:"foreignKey:BookID" json:"copies,omitempty"`
+	Availability    *CopyAvailability `gorm:"-" json:"availability,omitempty"`
+	BranchAvailability []BranchAvailability `gorm:"-" json:"branch_availability,omitempty"`
+	Cover           *CoverURLs     `gorm:"-" json:"cover,omitempty"`
+}
+
+// CoverURLs 封面图片的访问地址，Small 和 Medium 为缩略图
+type CoverURLs struct {
+	Original string `json:"original"`
+	Small    string `json:"small"`
+	Medium   string `json:"medium"`
 }
\ No newline at end of file
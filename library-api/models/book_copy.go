@@ -10,22 +10,76 @@ import (
 type BookCopyStatus string
 
 const (
-	CopyAvailable BookCopyStatus = "available"
-	CopyBorrowed  BookCopyStatus = "borrowed"
-	CopyLost      BookCopyStatus = "lost"
+	CopyAvailable   BookCopyStatus = "available"
+	CopyBorrowed    BookCopyStatus = "borrowed"
+	CopyLost        BookCopyStatus = "lost"
 	CopyMaintenance BookCopyStatus = "maintenance"
+	CopyOnHoldShelf BookCopyStatus = "on_hold_shelf" // 已分配给预约读者，等待取书
+	CopyWithdrawn   BookCopyStatus = "withdrawn"     // 已剔旧注销，不再流通
+	CopyInTransit   BookCopyStatus = "in_transit"    // 正在分馆之间调拨
 )
 
 // BookCopy 图书副本模型
 type BookCopy struct {
-	ID             uint           `gorm:"primaryKey" json:"id"`
-	BookID         uint           `gorm:"not null" json:"book_id"`
-	CopyNumber     string         `gorm:"size:20;not null" json:"copy_number"`
-	Status         BookCopyStatus `gorm:"size:20;not null;default:available" json:"status"`
-	AcquisitionDate time.Time      `json:"acquisition_date"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
-	Book           Book           `gorm:"foreignKey:BookID" json:"book,omitempty"`
-	Borrows        []Borrow       `gorm:"foreignKey:BookCopyID" json:"borrows,omitempty"`
-}
\ No newline at end of file
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	BookID           uint           `gorm:"not null" json:"book_id"`
+	CopyNumber       string         `gorm:"size:20;not null" json:"copy_number"`
+	Status           BookCopyStatus `gorm:"size:20;not null;default:available" json:"status"`
+	AcquisitionDate  time.Time      `json:"acquisition_date"`
+	BranchID         *uint          `gorm:"index" json:"branch_id,omitempty"`
+	ShelfLocationID  *uint          `gorm:"index" json:"shelf_location_id,omitempty"`
+	CallNumber       string         `gorm:"size:50" json:"call_number,omitempty"`
+	CallNumberScheme string         `gorm:"size:10" json:"call_number_scheme,omitempty"` // dewey 或 lcc
+	ShelfKey         string         `gorm:"size:100;index" json:"-"`                     // 索书号排架键
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	Book             Book           `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Branch           *Branch        `gorm:"foreignKey:BranchID" json:"branch,omitempty"`
+	ShelfLocation    *ShelfLocation `gorm:"foreignKey:ShelfLocationID" json:"shelf_location,omitempty"`
+	Borrows          []Borrow       `gorm:"foreignKey:BookCopyID" json:"borrows,omitempty"`
+}
+
+// CopyAvailability 图书各状态副本数量，Total 不包括已注销的副本
+type CopyAvailability struct {
+	Total       int64 `json:"total"`
+	Available   int64 `json:"available"`
+	Borrowed    int64 `json:"borrowed"`
+	OnHoldShelf int64 `json:"on_hold_shelf"`
+	Maintenance int64 `json:"maintenance"`
+	Lost        int64 `json:"lost"`
+	InTransit   int64 `json:"in_transit"`
+}
+
+// Add 累加某状态的副本数量，已注销的副本不计入
+func (a *CopyAvailability) Add(status BookCopyStatus, count int64) {
+	switch status {
+	case CopyWithdrawn:
+		return
+	case CopyAvailable:
+		a.Available += count
+	case CopyBorrowed:
+		a.Borrowed += count
+	case CopyOnHoldShelf:
+		a.OnHoldShelf += count
+	case CopyMaintenance:
+		a.Maintenance += count
+	case CopyLost:
+		a.Lost += count
+	case CopyInTransit:
+		a.InTransit += count
+	}
+	a.Total += count
+}
+
+// CopyStatusChange 副本状态变更记录
+type CopyStatusChange struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	BookCopyID  uint           `gorm:"not null;index" json:"book_copy_id"`
+	FromStatus  BookCopyStatus `gorm:"size:20;not null" json:"from_status"`
+	ToStatus    BookCopyStatus `gorm:"size:20;not null" json:"to_status"`
+	Reason      string         `gorm:"size:255;not null" json:"reason"`
+	ChangedByID uint           `gorm:"not null" json:"changed_by_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ChangedBy   User           `gorm:"foreignKey:ChangedByID" json:"changed_by,omitempty"`
+}
@@ -25,6 +25,8 @@ type Borrow struct {
 	DueDate       time.Time      `json:"due_date"`
 	ReturnDate    *time.Time     `json:"return_date,omitempty"`
 	Status        BorrowStatus   `gorm:"size:20;not null;default:active" json:"status"`
+	RenewalCount  int            `gorm:"not null;default:0" json:"renewal_count"`
+	LastRenewedAt *time.Time     `json:"last_renewed_at,omitempty"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Branch 分馆
+type Branch struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Code      string         `gorm:"size:20;not null" json:"code"`
+	Name      string         `gorm:"size:100;not null" json:"name"`
+	Address   string         `gorm:"size:255" json:"address,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ShelfLocation 分馆内的书架位置，Position 为巡架时经过的顺序
+type ShelfLocation struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	BranchID  uint           `gorm:"not null" json:"branch_id"`
+	Code      string         `gorm:"size:30;not null" json:"code"`
+	Name      string         `gorm:"size:100;not null" json:"name"`
+	Position  int            `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	Branch    *Branch        `gorm:"foreignKey:BranchID" json:"branch,omitempty"`
+}
+
+// BranchAvailability 图书在某个分馆的副本数量，BranchID 为空表示尚未分配分馆的副本
+type BranchAvailability struct {
+	BranchID   *uint  `json:"branch_id"`
+	BranchCode string `json:"branch_code,omitempty"`
+	BranchName string `json:"branch_name,omitempty"`
+	CopyAvailability
+}
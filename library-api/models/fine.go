@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FineType 定义费用类型
+type FineType string
+
+const (
+	FineOverdue FineType = "overdue" // 逾期罚款
+	FineLost    FineType = "lost"    // 丢失赔偿
+	FineDamaged FineType = "damaged" // 损坏赔偿
+	FineManual  FineType = "manual"  // 管理员手动收费
+)
+
+// FineStatus 定义费用状态
+type FineStatus string
+
+const (
+	FineOpen   FineStatus = "open"
+	FinePaid   FineStatus = "paid"
+	FineWaived FineStatus = "waived"
+)
+
+// Fine 读者费用记录，金额单位为分
+type Fine struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	UserID       uint           `gorm:"not null;index" json:"user_id"`
+	BorrowID     *uint          `gorm:"index" json:"borrow_id,omitempty"`
+	Type         FineType       `gorm:"size:20;not null" json:"type"`
+	Amount       int64          `gorm:"not null" json:"amount"`
+	PaidAmount   int64          `gorm:"not null;default:0" json:"paid_amount"`
+	WaivedAmount int64          `gorm:"not null;default:0" json:"waived_amount"`
+	Status       FineStatus     `gorm:"size:20;not null;default:open;index" json:"status"`
+	Description  string         `gorm:"size:255" json:"description,omitempty"`
+	CreatedByID  *uint          `json:"created_by_id,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	Payments     []FinePayment  `gorm:"foreignKey:FineID" json:"payments,omitempty"`
+}
+
+// Balance 返回未结清的金额
+func (f *Fine) Balance() int64 {
+	return f.Amount - f.PaidAmount - f.WaivedAmount
+}
+
+// FinePaymentKind 定义费用流水类型
+type FinePaymentKind string
+
+const (
+	PaymentKindPayment FinePaymentKind = "payment" // 缴费
+	PaymentKindWaiver  FinePaymentKind = "waiver"  // 减免
+)
+
+// FinePayment 费用缴纳或减免流水，金额单位为分
+type FinePayment struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	FineID       uint            `gorm:"not null;index" json:"fine_id"`
+	UserID       uint            `gorm:"not null;index" json:"user_id"`
+	Kind         FinePaymentKind `gorm:"size:20;not null" json:"kind"`
+	Amount       int64           `gorm:"not null" json:"amount"`
+	Method       string          `gorm:"size:30" json:"method,omitempty"`
+	Note         string          `gorm:"size:255" json:"note,omitempty"`
+	RecordedByID uint            `gorm:"not null" json:"recorded_by_id"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HoldStatus 定义预约状态
+type HoldStatus string
+
+const (
+	HoldPending   HoldStatus = "pending"    // 排队等待
+	HoldInTransit HoldStatus = "in_transit" // 副本正调拨到取书分馆
+	HoldReady     HoldStatus = "ready"      // 副本已放上预约书架，等待取书
+	HoldFulfilled HoldStatus = "fulfilled"  // 已取书借出
+	HoldCancelled HoldStatus = "cancelled"
+	HoldExpired   HoldStatus = "expired" // 超过取书期限未取
+)
+
+// Hold 预约模型，同一本书的待处理预约按Position先进先出。
+// AnyEdition 为 true 时，同一作品其他版本的副本在该版本没有排队读者时也可分配给此预约
+type Hold struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	UserID         uint           `gorm:"not null;index" json:"user_id"`
+	BookID         uint           `gorm:"not null;index" json:"book_id"`
+	BookCopyID     *uint          `json:"book_copy_id,omitempty"`
+	PickupBranchID *uint          `gorm:"index" json:"pickup_branch_id,omitempty"` // 取书分馆，为空时在副本所在分馆取书
+	AnyEdition     bool           `gorm:"not null;default:false" json:"any_edition"`
+	Position       int            `gorm:"not null" json:"position"`
+	Status         HoldStatus     `gorm:"size:20;not null;default:pending;index" json:"status"`
+	ReadyAt        *time.Time     `json:"ready_at,omitempty"`
+	ExpiresAt      *time.Time     `json:"expires_at,omitempty"`
+	FulfilledAt    *time.Time     `json:"fulfilled_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	User           User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Book           Book           `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	BookCopy       *BookCopy      `gorm:"foreignKey:BookCopyID" json:"book_copy,omitempty"`
+	PickupBranch   *Branch        `gorm:"foreignKey:PickupBranchID" json:"pickup_branch,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LoanPolicy 借阅规则，目前按用户角色区分
+type LoanPolicy struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	Role                UserRole  `gorm:"size:20;not null;uniqueIndex" json:"role"`
+	LoanDays            int       `gorm:"not null" json:"loan_days"`             // 默认借期
+	MaxLoanDays         int       `gorm:"not null" json:"max_loan_days"`         // 读者自选借期的上限
+	AllowCustomDuration bool      `gorm:"not null" json:"allow_custom_duration"` // 是否允许读者自选借期
+	MaxBorrows          int       `gorm:"not null" json:"max_borrows"`           // 同时在借数量上限，0表示不限
+	MaxRenewals         int       `gorm:"not null" json:"max_renewals"`
+	FineDailyRateCents  int64     `gorm:"not null" json:"fine_daily_rate_cents"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
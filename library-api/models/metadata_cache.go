@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// MetadataCache 外部书目数据的查询缓存，每个ISBN保存最近一次查询的结果。
+// Found 为 false 表示所有数据源都没有该ISBN，同样缓存以免重复查询
+type MetadataCache struct {
+	ISBN      string    `gorm:"primaryKey;size:20" json:"isbn"`
+	Source    string    `gorm:"size:30" json:"source"`
+	Found     bool      `gorm:"not null" json:"found"`
+	Data      string    `gorm:"type:text" json:"-"`
+	FetchedAt time.Time `gorm:"not null" json:"fetched_at"`
+}
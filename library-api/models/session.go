@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// SessionRevokeReason 定义会话撤销原因
+type SessionRevokeReason string
+
+const (
+	RevokeLogout SessionRevokeReason = "logout"  // 用户登出
+	RevokeUser   SessionRevokeReason = "revoked" // 用户或管理员撤销
+	RevokeReuse  SessionRevokeReason = "reuse"   // 已使用的刷新令牌被再次使用，令牌可能被盗用
+)
+
+// Session 登录会话，每次登录创建一个会话。会话中依次轮换的刷新令牌构成一个令牌族，
+// 会话撤销后其访问令牌和刷新令牌全部失效
+type Session struct {
+	ID           uint                `gorm:"primaryKey" json:"id"`
+	UserID       uint                `gorm:"not null;index" json:"user_id"`
+	UserAgent    string              `gorm:"size:255" json:"user_agent"`
+	IPAddress    string              `gorm:"size:45" json:"ip_address"`
+	ExpiresAt    time.Time           `gorm:"index" json:"expires_at"` // 当前刷新令牌的过期时间
+	LastUsedAt   time.Time           `json:"last_used_at"`
+	RevokedAt    *time.Time          `json:"revoked_at,omitempty"`
+	RevokeReason SessionRevokeReason `gorm:"size:20" json:"revoke_reason,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	Current      bool                `gorm:"-" json:"current,omitempty"` // 是否为发起请求的会话
+}
+
+// RefreshToken 刷新令牌，只保存令牌的SHA-256哈希。令牌使用一次后标记为已使用，
+// 已使用的令牌保留到过期，用于发现重复使用
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	SessionID uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken 单独撤销的访问令牌，按jti记录，保留到令牌过期
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
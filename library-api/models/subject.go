@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Category 主题分类，ParentID 为空表示顶级分类，同一父分类下名称不能重复
+type Category struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	ParentID    *uint          `gorm:"index" json:"parent_id"`
+	Name        string         `gorm:"size:100;not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	BookCount   *int64         `gorm:"-" json:"book_count,omitempty"`
+	Children    []*Category    `gorm:"-" json:"children,omitempty"`
+}
+
+// Tag 自由标签，名称统一为小写
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	BookCount *int64    `gorm:"-" json:"book_count,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// TransferStatus 定义调拨状态
+type TransferStatus string
+
+const (
+	TransferRequested TransferStatus = "requested"  // 已申请，等待原分馆发出
+	TransferInTransit TransferStatus = "in_transit" // 已发出，运送途中
+	TransferReceived  TransferStatus = "received"   // 目的分馆已接收
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+// Transfer 副本在分馆之间的调拨记录。为预约读者调拨时关联预约，
+// 接收后副本直接放上目的分馆的预约书架
+type Transfer struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	BookCopyID    uint           `gorm:"not null;index" json:"book_copy_id"`
+	FromBranchID  *uint          `gorm:"index" json:"from_branch_id,omitempty"`
+	ToBranchID    uint           `gorm:"not null;index" json:"to_branch_id"`
+	HoldID        *uint          `gorm:"index" json:"hold_id,omitempty"`
+	Status        TransferStatus `gorm:"size:20;not null;default:requested;index" json:"status"`
+	Note          string         `gorm:"size:255" json:"note,omitempty"`
+	RequestedByID *uint          `json:"requested_by_id,omitempty"` // 为空表示由预约自动发起
+	RequestedAt   time.Time      `json:"requested_at"`
+	ShippedByID   *uint          `json:"shipped_by_id,omitempty"`
+	ShippedAt     *time.Time     `json:"shipped_at,omitempty"`
+	ReceivedByID  *uint          `json:"received_by_id,omitempty"`
+	ReceivedAt    *time.Time     `json:"received_at,omitempty"`
+	CancelledByID *uint          `json:"cancelled_by_id,omitempty"`
+	CancelledAt   *time.Time     `json:"cancelled_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	BookCopy      *BookCopy      `gorm:"foreignKey:BookCopyID" json:"book_copy,omitempty"`
+	FromBranch    *Branch        `gorm:"foreignKey:FromBranchID" json:"from_branch,omitempty"`
+	ToBranch      *Branch        `gorm:"foreignKey:ToBranchID" json:"to_branch,omitempty"`
+}
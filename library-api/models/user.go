@@ -16,14 +16,28 @@ const (
 
 // User 用户模型
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	GoogleID  string         `gorm:"size:100;uniqueIndex" json:"google_id,omitempty"`
-	Username  string         `gorm:"size:50;not null;unique" json:"username"`
-	Email     string         `gorm:"size:100;not null;unique" json:"email"`
-	Password  string         `gorm:"size:100;not null" json:"-"` // 密码不返回给前端
-	Role      UserRole       `gorm:"size:20;not null;default:user" json:"role"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Borrows   []Borrow       `gorm:"foreignKey:UserID" json:"borrows,omitempty"`
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	GoogleID   string         `gorm:"size:100" json:"-"` // 已由 UserIdentity 取代，启动时迁移到 user_identities 后清空
+	Username   string         `gorm:"size:50;not null;unique" json:"username"`
+	Email      string         `gorm:"size:100;not null;unique" json:"email"`
+	Password   string         `gorm:"size:100;not null" json:"-"` // 密码不返回给前端
+	Role       UserRole       `gorm:"size:20;not null;default:user" json:"role"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	Borrows    []Borrow       `gorm:"foreignKey:UserID" json:"borrows,omitempty"`
+	Identities []UserIdentity `gorm:"foreignKey:UserID" json:"identities,omitempty"`
+}
+
+// UserIdentity 用户关联的外部身份（OpenID Connect 身份提供方中的账号）。
+// 同一外部账号只能关联一个用户，一个用户在每个身份提供方最多关联一个账号
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index;uniqueIndex:idx_user_identities_user_provider" json:"user_id"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_user_identities_provider_subject;uniqueIndex:idx_user_identities_user_provider" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_user_identities_provider_subject" json:"subject"`
+	Email       string     `gorm:"size:100" json:"email"` // 最近一次登录时身份提供方返回的邮箱
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BookFormat 定义图书的载体形态
+type BookFormat string
+
+const (
+	FormatHardcover  BookFormat = "hardcover"
+	FormatPaperback  BookFormat = "paperback"
+	FormatEbook      BookFormat = "ebook"
+	FormatAudiobook  BookFormat = "audiobook"
+	FormatLargePrint BookFormat = "large_print"
+	FormatOther      BookFormat = "other"
+)
+
+// Work 作品，同一作品的不同版本、译本和载体形态作为各自的图书记录归入同一作品
+type Work struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	Title            string         `gorm:"size:200;not null" json:"title"`
+	OriginalLanguage string         `gorm:"size:10" json:"original_language,omitempty"`
+	Description      string         `gorm:"type:text" json:"description,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	Editions         []Book         `gorm:"foreignKey:WorkID" json:"editions,omitempty"`
+}
+
+// Series 丛书，图书按 SeriesNumber 排列，同一卷的不同版本使用相同的卷号
+type Series struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"size:200;not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
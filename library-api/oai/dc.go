@@ -0,0 +1,36 @@
+package oai
+
+import (
+	"strconv"
+
+	"github.com/example/library-api/models"
+)
+
+// dublinCore 将图书映射为 oai_dc 元数据，需要预加载 Authors。
+// 出版日期只取年份，与 MARC 导出一致；ISBN 以 urn:isbn 形式作为标识符
+func dublinCore(book *models.Book) *DublinCore {
+	dc := &DublinCore{
+		XmlnsOAIDC:     oaiDCNamespace,
+		XmlnsDC:        dcNamespace,
+		XmlnsXsi:       xsiNamespace,
+		SchemaLocation: oaiDCNamespace + " " + oaiDCSchema,
+		Titles:         []string{book.Title},
+		Types:          []string{"Text"},
+	}
+	for _, author := range book.Authors {
+		dc.Creators = append(dc.Creators, author.Name)
+	}
+	if book.Publisher != "" {
+		dc.Publishers = []string{book.Publisher}
+	}
+	if !book.PublicationDate.IsZero() {
+		dc.Dates = []string{strconv.Itoa(book.PublicationDate.Year())}
+	}
+	if book.Description != "" {
+		dc.Descriptions = []string{book.Description}
+	}
+	if book.ISBN != "" {
+		dc.Identifiers = []string{"urn:isbn:" + book.ISBN}
+	}
+	return dc
+}
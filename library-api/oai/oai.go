@@ -0,0 +1,509 @@
+// Package oai 实现 OAI-PMH 2.0 数据提供者，供外部系统按日期增量采集馆藏书目
+//
+// 每本图书是一条记录，以 oai_dc（都柏林核心）格式发布，记录的时间戳为图书的更新时间。
+// 已软删除的图书作为删除记录持续保留，时间戳为删除时间。列表请求按图书ID分页，
+// 续传令牌记录采集条件和上一页最后的图书ID，服务端不保存状态。
+package oai
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/models"
+)
+
+// OAI-PMH 动词
+const (
+	VerbIdentify            = "Identify"
+	VerbListMetadataFormats = "ListMetadataFormats"
+	VerbListSets            = "ListSets"
+	VerbListIdentifiers     = "ListIdentifiers"
+	VerbListRecords         = "ListRecords"
+	VerbGetRecord           = "GetRecord"
+)
+
+// OAI-PMH 错误代码
+const (
+	ErrBadArgument             = "badArgument"
+	ErrBadResumptionToken      = "badResumptionToken"
+	ErrBadVerb                 = "badVerb"
+	ErrCannotDisseminateFormat = "cannotDisseminateFormat"
+	ErrIDDoesNotExist          = "idDoesNotExist"
+	ErrNoRecordsMatch          = "noRecordsMatch"
+	ErrNoSetHierarchy          = "noSetHierarchy"
+)
+
+var (
+	repositoryName       = "Library API"
+	repositoryIdentifier = "library.example.org"
+	adminEmail           = "admin@example.com"
+	baseURL              = ""
+	pageSize             = 100
+)
+
+// Init 根据配置初始化仓储信息和分页大小
+func Init(cfg *config.Config) {
+	repositoryName = cfg.OAIRepositoryName
+	repositoryIdentifier = cfg.OAIRepositoryIdentifier
+	adminEmail = cfg.OAIAdminEmail
+	baseURL = cfg.OAIBaseURL
+	pageSize = cfg.OAIPageSize
+}
+
+// BaseURL 返回配置的仓储基础地址，未配置时为空，由调用方根据请求地址确定
+func BaseURL() string {
+	return baseURL
+}
+
+// verbArguments 各动词允许的参数，true 表示必需。resumptionToken 是排他参数，单独处理
+var verbArguments = map[string]map[string]bool{
+	VerbIdentify:            {},
+	VerbListMetadataFormats: {"identifier": false},
+	VerbListSets:            {},
+	VerbListIdentifiers:     {"metadataPrefix": true, "from": false, "until": false, "set": false},
+	VerbListRecords:         {"metadataPrefix": true, "from": false, "until": false, "set": false},
+	VerbGetRecord:           {"identifier": true, "metadataPrefix": true},
+}
+
+// resumableVerbs 支持续传令牌的动词
+var resumableVerbs = map[string]bool{VerbListSets: true, VerbListIdentifiers: true, VerbListRecords: true}
+
+// protocolError 协议错误，写入响应的 error 元素
+type protocolError struct {
+	code    string
+	message string
+}
+
+func (e *protocolError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func fail(code, format string, args ...any) error {
+	return &protocolError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// newResponse 创建响应文档
+func newResponse(base string, now time.Time) *Response {
+	return &Response{
+		Xmlns:          namespace,
+		XmlnsXsi:       xsiNamespace,
+		SchemaLocation: schemaLocation,
+		ResponseDate:   now.UTC().Format(datestampLayout),
+		Request:        Request{BaseURL: base},
+	}
+}
+
+// ErrorResponse 创建只包含一个协议错误的响应，用于无法解析请求参数的情况
+func ErrorResponse(base, code, message string, now time.Time) *Response {
+	resp := newResponse(base, now)
+	resp.Errors = []Error{{Code: code, Message: message}}
+	return resp
+}
+
+// Handle 处理一次 OAI-PMH 请求。args 为合并后的查询参数和表单参数，base 为仓储基础地址。
+// 协议错误写入响应文档，只有数据库错误通过 error 返回
+func Handle(db *gorm.DB, base string, args url.Values, now time.Time) (*Response, error) {
+	resp := newResponse(base, now)
+
+	if err := checkArguments(args); err != nil {
+		// badVerb 和 badArgument 的响应不回显请求参数
+		return withError(resp, err)
+	}
+	resp.Request = Request{
+		Verb:            args.Get("verb"),
+		Identifier:      args.Get("identifier"),
+		MetadataPrefix:  args.Get("metadataPrefix"),
+		From:            args.Get("from"),
+		Until:           args.Get("until"),
+		Set:             args.Get("set"),
+		ResumptionToken: args.Get("resumptionToken"),
+		BaseURL:         base,
+	}
+
+	var err error
+	switch args.Get("verb") {
+	case VerbIdentify:
+		resp.Identify, err = identify(db, base)
+	case VerbListMetadataFormats:
+		resp.ListMetadataFormats, err = listMetadataFormats(db, args.Get("identifier"))
+	case VerbListSets:
+		if args.Has("resumptionToken") {
+			err = fail(ErrBadResumptionToken, "the repository has not issued any set tokens")
+		} else {
+			err = fail(ErrNoSetHierarchy, "the repository does not support sets")
+		}
+	case VerbListIdentifiers, VerbListRecords:
+		err = list(db, resp, args)
+	case VerbGetRecord:
+		resp.GetRecord, err = getRecord(db, args.Get("identifier"), args.Get("metadataPrefix"))
+	}
+	if err != nil {
+		return withError(resp, err)
+	}
+	return resp, nil
+}
+
+// withError 将协议错误写入响应，其他错误原样返回
+func withError(resp *Response, err error) (*Response, error) {
+	var pe *protocolError
+	if !errors.As(err, &pe) {
+		return nil, err
+	}
+	if pe.code == ErrBadVerb || pe.code == ErrBadArgument {
+		resp.Request = Request{BaseURL: resp.Request.BaseURL}
+	}
+	resp.Errors = append(resp.Errors, Error{Code: pe.code, Message: pe.message})
+	return resp, nil
+}
+
+// checkArguments 校验动词和参数：参数不能重复，不能缺少必需参数或出现多余参数，
+// resumptionToken 只能和 verb 一起出现
+func checkArguments(args url.Values) error {
+	verb := args.Get("verb")
+	if len(args["verb"]) != 1 {
+		return fail(ErrBadVerb, "exactly one verb argument is required")
+	}
+	allowed, ok := verbArguments[verb]
+	if !ok {
+		return fail(ErrBadVerb, "illegal verb %s", verb)
+	}
+
+	for name, values := range args {
+		if len(values) > 1 {
+			return fail(ErrBadArgument, "argument %s is repeated", name)
+		}
+	}
+
+	if args.Has("resumptionToken") {
+		if !resumableVerbs[verb] {
+			return fail(ErrBadArgument, "verb %s does not accept resumptionToken", verb)
+		}
+		if len(args) != 2 {
+			return fail(ErrBadArgument, "resumptionToken is an exclusive argument")
+		}
+		return nil
+	}
+
+	for name := range args {
+		if _, ok := allowed[name]; !ok && name != "verb" {
+			return fail(ErrBadArgument, "illegal argument %s for verb %s", name, verb)
+		}
+	}
+	for name, required := range allowed {
+		if required && !args.Has(name) {
+			return fail(ErrBadArgument, "missing required argument %s", name)
+		}
+	}
+	return nil
+}
+
+// identify 返回仓储信息，最早时间戳取所有记录中最早的时间戳，没有记录时为当前时间
+func identify(db *gorm.DB, base string) (*Identify, error) {
+	earliest := time.Now()
+	var book models.Book
+	err := db.Unscoped().Select("id", "updated_at", "deleted_at").
+		Order("COALESCE(deleted_at, updated_at)").Take(&book).Error
+	if err == nil {
+		earliest = datestamp(&book)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return &Identify{
+		RepositoryName:    repositoryName,
+		BaseURL:           base,
+		ProtocolVersion:   protocolVersion,
+		AdminEmail:        adminEmail,
+		EarliestDatestamp: earliest.UTC().Format(datestampLayout),
+		DeletedRecord:     deletedRecordMode,
+		Granularity:       "YYYY-MM-DDThh:mm:ssZ",
+		Description: &Description{OAIIdentifier: OAIIdentifier{
+			Xmlns:                identifierNS,
+			XmlnsXsi:             xsiNamespace,
+			SchemaLocation:       identifierNS + " " + identifierSchema,
+			Scheme:               "oai",
+			RepositoryIdentifier: repositoryIdentifier,
+			Delimiter:            ":",
+			SampleIdentifier:     identifier(1),
+		}},
+	}, nil
+}
+
+// metadataFormats 支持的元数据格式
+var metadataFormats = []MetadataFormat{
+	{MetadataPrefix: metadataPrefixDC, Schema: oaiDCSchema, MetadataNamespace: oaiDCNamespace},
+}
+
+// listMetadataFormats 返回支持的元数据格式，指定记录时先确认记录存在
+func listMetadataFormats(db *gorm.DB, id string) (*ListMetadataFormats, error) {
+	if id != "" {
+		if _, err := findBook(db, id, false); err != nil {
+			return nil, err
+		}
+	}
+	return &ListMetadataFormats{Formats: metadataFormats}, nil
+}
+
+// getRecord 返回单条记录
+func getRecord(db *gorm.DB, id, prefix string) (*GetRecord, error) {
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	book, err := findBook(db, id, true)
+	if err != nil {
+		return nil, err
+	}
+	return &GetRecord{Record: record(book)}, nil
+}
+
+// findBook 按 OAI 标识符查找图书，包括已删除的图书
+func findBook(db *gorm.DB, id string, withAuthors bool) (*models.Book, error) {
+	bookID, ok := parseIdentifier(id)
+	if !ok {
+		return nil, fail(ErrIDDoesNotExist, "unknown identifier %s", id)
+	}
+
+	query := db.Unscoped()
+	if withAuthors {
+		query = query.Preload("Authors", "authors.deleted_at IS NULL")
+	}
+	var book models.Book
+	if err := query.First(&book, bookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fail(ErrIDDoesNotExist, "unknown identifier %s", id)
+		}
+		return nil, err
+	}
+	return &book, nil
+}
+
+// checkPrefix 校验元数据格式
+func checkPrefix(prefix string) error {
+	for _, f := range metadataFormats {
+		if f.MetadataPrefix == prefix {
+			return nil
+		}
+	}
+	return fail(ErrCannotDisseminateFormat, "metadata format %s is not supported", prefix)
+}
+
+// list 处理 ListIdentifiers 和 ListRecords：按图书ID升序分页，
+// 还有后续记录时返回续传令牌，列表的最后一页返回空令牌
+func list(db *gorm.DB, resp *Response, args url.Values) error {
+	var h harvest
+	if args.Has("resumptionToken") {
+		token, err := decodeToken(args.Get("resumptionToken"))
+		if err != nil {
+			return err
+		}
+		h = token
+	} else {
+		if args.Has("set") {
+			return fail(ErrNoSetHierarchy, "the repository does not support sets")
+		}
+		h = harvest{prefix: args.Get("metadataPrefix"), from: args.Get("from"), until: args.Get("until")}
+	}
+	if err := checkPrefix(h.prefix); err != nil {
+		return err
+	}
+
+	query, err := h.query(db.Unscoped().Model(&models.Book{}))
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return err
+	}
+
+	page := query.Where("id > ?", h.lastID).Order("id").Limit(pageSize + 1)
+	if args.Get("verb") == VerbListRecords {
+		page = page.Preload("Authors", "authors.deleted_at IS NULL")
+	}
+	var books []models.Book
+	if err := page.Find(&books).Error; err != nil {
+		return err
+	}
+	if len(books) == 0 {
+		if h.cursor > 0 {
+			// 上一页之后的记录已被修改到采集范围之外
+			return fail(ErrBadResumptionToken, "the list has changed and cannot be resumed")
+		}
+		return fail(ErrNoRecordsMatch, "no records match the request")
+	}
+
+	var token *ResumptionToken
+	if len(books) > pageSize {
+		books = books[:pageSize]
+		next := h
+		next.lastID = books[len(books)-1].ID
+		next.cursor = h.cursor + len(books)
+		token = &ResumptionToken{CompleteListSize: total, Cursor: h.cursor, Value: next.encode()}
+	} else if h.cursor > 0 {
+		token = &ResumptionToken{CompleteListSize: total, Cursor: h.cursor}
+	}
+
+	if args.Get("verb") == VerbListRecords {
+		out := &ListRecords{ResumptionToken: token}
+		for i := range books {
+			out.Records = append(out.Records, record(&books[i]))
+		}
+		resp.ListRecords = out
+	} else {
+		out := &ListIdentifiers{ResumptionToken: token}
+		for i := range books {
+			out.Headers = append(out.Headers, header(&books[i]))
+		}
+		resp.ListIdentifiers = out
+	}
+	return nil
+}
+
+// harvest 列表请求的采集条件和分页位置
+type harvest struct {
+	prefix string
+	from   string
+	until  string
+	lastID uint
+	cursor int
+}
+
+// query 为查询加上时间范围条件。记录的时间戳是删除时间或更新时间，
+// 只到日期的 until 包含当天全部记录
+func (h harvest) query(db *gorm.DB) (*gorm.DB, error) {
+	from, fromDay, err := parseDatestamp(h.from)
+	if err != nil {
+		return nil, err
+	}
+	until, untilDay, err := parseDatestamp(h.until)
+	if err != nil {
+		return nil, err
+	}
+	if h.from != "" && h.until != "" {
+		if fromDay != untilDay {
+			return nil, fail(ErrBadArgument, "from and until must have the same granularity")
+		}
+		if from.After(until) {
+			return nil, fail(ErrBadArgument, "from must not be later than until")
+		}
+	}
+
+	// 时间以本地时区写入数据库，比较时使用相同的时区
+	if h.from != "" {
+		db = db.Where("COALESCE(deleted_at, updated_at) >= ?", from.In(time.Local))
+	}
+	if h.until != "" {
+		end := until.Add(time.Second)
+		if untilDay {
+			end = until.AddDate(0, 0, 1)
+		}
+		db = db.Where("COALESCE(deleted_at, updated_at) < ?", end.In(time.Local))
+	}
+	return db, nil
+}
+
+// parseDatestamp 解析 YYYY-MM-DD 或 YYYY-MM-DDThh:mm:ssZ 格式的时间，返回是否只有日期
+func parseDatestamp(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(dayLayout, value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(datestampLayout, value); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, fail(ErrBadArgument, "illegal datestamp %s", value)
+}
+
+// tokenSeparator 续传令牌各字段的分隔符
+const tokenSeparator = "|"
+
+// encode 将采集条件编码为续传令牌
+func (h harvest) encode() string {
+	return strings.Join([]string{
+		h.prefix, h.from, h.until,
+		strconv.FormatUint(uint64(h.lastID), 10),
+		strconv.Itoa(h.cursor),
+	}, tokenSeparator)
+}
+
+// decodeToken 解析续传令牌
+func decodeToken(raw string) (harvest, error) {
+	bad := fail(ErrBadResumptionToken, "invalid resumption token %s", raw)
+	parts := strings.Split(raw, tokenSeparator)
+	if len(parts) != 5 {
+		return harvest{}, bad
+	}
+	lastID, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		return harvest{}, bad
+	}
+	cursor, err := strconv.Atoi(parts[4])
+	if err != nil || cursor <= 0 {
+		return harvest{}, bad
+	}
+	h := harvest{prefix: parts[0], from: parts[1], until: parts[2], lastID: uint(lastID), cursor: cursor}
+	if _, _, err := parseDatestamp(h.from); err != nil {
+		return harvest{}, bad
+	}
+	if _, _, err := parseDatestamp(h.until); err != nil {
+		return harvest{}, bad
+	}
+	return h, nil
+}
+
+// identifier 返回图书的 OAI 标识符，如 oai:library.example.org:book/1
+func identifier(bookID uint) string {
+	return fmt.Sprintf("oai:%s:book/%d", repositoryIdentifier, bookID)
+}
+
+// parseIdentifier 从 OAI 标识符解析图书ID
+func parseIdentifier(id string) (uint, bool) {
+	local, ok := strings.CutPrefix(id, "oai:"+repositoryIdentifier+":book/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(local, 10, 64)
+	if err != nil || n == 0 {
+		return 0, false
+	}
+	return uint(n), true
+}
+
+// datestamp 返回记录的时间戳：已删除的图书为删除时间，否则为更新时间
+func datestamp(book *models.Book) time.Time {
+	if book.DeletedAt.Valid {
+		return book.DeletedAt.Time
+	}
+	return book.UpdatedAt
+}
+
+// header 返回图书的记录头
+func header(book *models.Book) Header {
+	h := Header{
+		Identifier: identifier(book.ID),
+		Datestamp:  datestamp(book).UTC().Format(datestampLayout),
+	}
+	if book.DeletedAt.Valid {
+		h.Status = "deleted"
+	}
+	return h
+}
+
+// record 返回图书的完整记录，已删除的图书只有记录头
+func record(book *models.Book) Record {
+	r := Record{Header: header(book)}
+	if !book.DeletedAt.Valid {
+		r.Metadata = &Metadata{DC: dublinCore(book)}
+	}
+	return r
+}
@@ -0,0 +1,332 @@
+package oai
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// createBook 创建图书并将更新时间设为 updatedAt
+func createBook(t *testing.T, db *gorm.DB, isbn string, updatedAt time.Time) models.Book {
+	t.Helper()
+
+	book := models.Book{Title: "Book " + isbn, ISBN: isbn}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	if err := db.Model(&book).UpdateColumn("updated_at", updatedAt.In(time.Local)).Error; err != nil {
+		t.Fatalf("set updated_at: %v", err)
+	}
+	book.UpdatedAt = updatedAt
+	return book
+}
+
+// setPageSize 临时修改分页大小
+func setPageSize(t *testing.T, n int) {
+	t.Helper()
+	previous := pageSize
+	pageSize = n
+	t.Cleanup(func() { pageSize = previous })
+}
+
+// errorCode 返回协议错误代码，没有错误时为空
+func errorCode(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var pe *protocolError
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %v, want protocol error", err)
+	}
+	return pe.code
+}
+
+// handle 以查询字符串发起请求，数据库错误时测试失败
+func handle(t *testing.T, db *gorm.DB, query string) *Response {
+	t.Helper()
+	args, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatalf("parse query: %v", err)
+	}
+	resp, err := Handle(db, "http://library.test/oai", args, time.Now())
+	if err != nil {
+		t.Fatalf("handle %s: %v", query, err)
+	}
+	return resp
+}
+
+// responseError 返回响应中的错误代码，没有错误时为空
+func responseError(resp *Response) string {
+	if len(resp.Errors) == 0 {
+		return ""
+	}
+	return resp.Errors[0].Code
+}
+
+func TestCheckArguments(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ErrBadVerb},
+		{"verb=Harvest", ErrBadVerb},
+		{"verb=Identify&verb=Identify", ErrBadVerb},
+		{"verb=Identify&verb=ListSets", ErrBadVerb},
+		{"verb=Identify", ""},
+		{"verb=Identify&identifier=x", ErrBadArgument},
+		{"verb=ListMetadataFormats", ""},
+		{"verb=ListMetadataFormats&identifier=x", ""},
+		{"verb=ListRecords", ErrBadArgument},
+		{"verb=ListRecords&metadataPrefix=oai_dc", ""},
+		{"verb=ListRecords&metadataPrefix=oai_dc&from=2024-01-01&until=2024-02-01&set=a", ""},
+		{"verb=ListRecords&metadataPrefix=oai_dc&metadataPrefix=oai_dc", ErrBadArgument},
+		{"verb=ListIdentifiers&metadataPrefix=oai_dc&identifier=x", ErrBadArgument},
+		{"verb=GetRecord&identifier=x", ErrBadArgument},
+		{"verb=GetRecord&identifier=x&metadataPrefix=oai_dc", ""},
+		// resumptionToken 是排他参数，只能与支持续传的动词一起出现
+		{"verb=ListRecords&resumptionToken=t", ""},
+		{"verb=ListSets&resumptionToken=t", ""},
+		{"verb=ListRecords&resumptionToken=t&metadataPrefix=oai_dc", ErrBadArgument},
+		{"verb=ListIdentifiers&resumptionToken=t&from=2024-01-01", ErrBadArgument},
+		{"verb=GetRecord&resumptionToken=t", ErrBadArgument},
+		{"verb=Identify&resumptionToken=t", ErrBadArgument},
+		{"verb=ListRecords&resumptionToken=t&resumptionToken=u", ErrBadArgument},
+		// 动词错误优先于参数错误
+		{"verb=Harvest&resumptionToken=t&metadataPrefix=oai_dc", ErrBadVerb},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			args, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parse query: %v", err)
+			}
+			if got := errorCode(t, checkArguments(args)); got != tt.want {
+				t.Errorf("checkArguments(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBadArgumentResponseOmitsRequest(t *testing.T) {
+	db := databasetest.Open(t)
+
+	resp := handle(t, db, "verb=ListRecords&metadataPrefix=oai_dc&bogus=1")
+	if got := responseError(resp); got != ErrBadArgument {
+		t.Fatalf("error = %q, want %q", got, ErrBadArgument)
+	}
+	if resp.Request.Verb != "" || resp.Request.MetadataPrefix != "" || resp.Request.BaseURL == "" {
+		t.Errorf("request = %+v, want only baseURL", resp.Request)
+	}
+}
+
+func TestResumptionToken(t *testing.T) {
+	h := harvest{prefix: "oai_dc", from: "2024-01-01", until: "2024-12-31", lastID: 42, cursor: 100}
+	got, err := decodeToken(h.encode())
+	if err != nil {
+		t.Fatalf("decode %q: %v", h.encode(), err)
+	}
+	if got != h {
+		t.Errorf("decode(encode(h)) = %+v, want %+v", got, h)
+	}
+
+	open := harvest{prefix: "oai_dc", lastID: 7, cursor: 5}
+	if got, err := decodeToken(open.encode()); err != nil || got != open {
+		t.Errorf("decode(%q) = %+v, %v, want %+v", open.encode(), got, err, open)
+	}
+
+	invalid := []string{
+		"",
+		"oai_dc",
+		"oai_dc|||7",
+		"oai_dc|||7|5|extra",
+		"oai_dc|||x|5",
+		"oai_dc|||-1|5",
+		"oai_dc|||7|x",
+		"oai_dc|||7|0",
+		"oai_dc|||7|-5",
+		"oai_dc|2024-13-01||7|5",
+		"oai_dc||yesterday|7|5",
+	}
+	for _, raw := range invalid {
+		if _, err := decodeToken(raw); errorCode(t, err) != ErrBadResumptionToken {
+			t.Errorf("decodeToken(%q) = %v, want %s", raw, err, ErrBadResumptionToken)
+		}
+	}
+}
+
+func TestParseDatestamp(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		dayOnly bool
+		wantErr bool
+	}{
+		{"", time.Time{}, false, false},
+		{"2024-03-05", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), true, false},
+		{"2024-03-05T10:20:30Z", time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC), false, false},
+		{"2024-03-05T10:20Z", time.Time{}, false, true},
+		{"2024-03-05T10:20:30+08:00", time.Time{}, false, true},
+		{"2024-3-5", time.Time{}, false, true},
+		{"20240305", time.Time{}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, dayOnly, err := parseDatestamp(tt.value)
+			if tt.wantErr {
+				if errorCode(t, err) != ErrBadArgument {
+					t.Fatalf("err = %v, want %s", err, ErrBadArgument)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDatestamp: %v", err)
+			}
+			if !got.Equal(tt.want) || dayOnly != tt.dayOnly {
+				t.Errorf("parseDatestamp(%q) = %v, %v, want %v, %v", tt.value, got, dayOnly, tt.want, tt.dayOnly)
+			}
+		})
+	}
+}
+
+func TestListGranularity(t *testing.T) {
+	db := databasetest.Open(t)
+
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	before := createBook(t, db, "9780000000001", day.Add(-time.Second))
+	morning := createBook(t, db, "9780000000002", day.Add(9*time.Hour))
+	night := createBook(t, db, "9780000000003", day.Add(24*time.Hour-time.Second))
+	after := createBook(t, db, "9780000000004", day.Add(24*time.Hour))
+
+	tests := []struct {
+		name      string
+		from      string
+		until     string
+		want      []uint
+		wantError string
+	}{
+		{"只到日期的 until 包含当天全部记录", "", "2024-03-05", []uint{before.ID, morning.ID, night.ID}, ""},
+		{"只到日期的 from 从当天零点开始", "2024-03-05", "", []uint{morning.ID, night.ID, after.ID}, ""},
+		{"同一天", "2024-03-05", "2024-03-05", []uint{morning.ID, night.ID}, ""},
+		{"精确到秒的范围包含两端", "2024-03-05T09:00:00Z", "2024-03-05T23:59:59Z", []uint{morning.ID, night.ID}, ""},
+		{"精确到秒的 until", "", "2024-03-05T09:00:00Z", []uint{before.ID, morning.ID}, ""},
+		{"粒度不同", "2024-03-05", "2024-03-06T00:00:00Z", nil, ErrBadArgument},
+		{"from 晚于 until", "2024-03-06", "2024-03-05", nil, ErrBadArgument},
+		{"时间格式错误", "2024-03-05T09:00", "", nil, ErrBadArgument},
+		{"没有匹配记录", "2025-01-01", "", nil, ErrNoRecordsMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "verb=ListIdentifiers&metadataPrefix=oai_dc"
+			if tt.from != "" {
+				query += "&from=" + url.QueryEscape(tt.from)
+			}
+			if tt.until != "" {
+				query += "&until=" + url.QueryEscape(tt.until)
+			}
+			resp := handle(t, db, query)
+			if got := responseError(resp); got != tt.wantError {
+				t.Fatalf("error = %q, want %q", got, tt.wantError)
+			}
+			if tt.wantError != "" {
+				return
+			}
+
+			var got []string
+			for _, h := range resp.ListIdentifiers.Headers {
+				got = append(got, h.Identifier)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("identifiers = %v, want books %v", got, tt.want)
+			}
+			for i, id := range tt.want {
+				if got[i] != identifier(id) {
+					t.Errorf("identifiers = %v, want books %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestDeletedRecords(t *testing.T) {
+	db := databasetest.Open(t)
+
+	updated := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	deleted := time.Date(2024, 4, 1, 12, 30, 0, 0, time.UTC)
+	kept := createBook(t, db, "9780000000001", updated)
+	removed := createBook(t, db, "9780000000002", updated)
+	if err := db.Model(&removed).UpdateColumn("deleted_at", deleted.In(time.Local)).Error; err != nil {
+		t.Fatalf("delete book: %v", err)
+	}
+
+	resp := handle(t, db, "verb=ListRecords&metadataPrefix=oai_dc")
+	if got := responseError(resp); got != "" {
+		t.Fatalf("error = %q", got)
+	}
+	records := resp.ListRecords.Records
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if h := records[0].Header; h.Identifier != identifier(kept.ID) || h.Status != "" || h.Datestamp != "2024-03-05T09:00:00Z" || records[0].Metadata == nil {
+		t.Errorf("kept record = %+v", records[0])
+	}
+	if h := records[1].Header; h.Identifier != identifier(removed.ID) || h.Status != "deleted" || h.Datestamp != "2024-04-01T12:30:00Z" || records[1].Metadata != nil {
+		t.Errorf("deleted record = %+v, want deleted header without metadata", records[1])
+	}
+
+	// 删除记录的时间戳是删除时间，按删除时间参与增量采集
+	resp = handle(t, db, "verb=ListIdentifiers&metadataPrefix=oai_dc&from=2024-04-01")
+	if headers := resp.ListIdentifiers.Headers; len(headers) != 1 || headers[0].Status != "deleted" {
+		t.Errorf("headers from deletion day = %+v, want only the deleted record", headers)
+	}
+
+	resp = handle(t, db, "verb=GetRecord&metadataPrefix=oai_dc&identifier="+url.QueryEscape(identifier(removed.ID)))
+	if rec := resp.GetRecord; rec == nil || rec.Record.Header.Status != "deleted" || rec.Record.Metadata != nil {
+		t.Errorf("GetRecord = %+v, want deleted header without metadata", rec)
+	}
+
+	resp = handle(t, db, "verb=Identify")
+	if got := resp.Identify.EarliestDatestamp; got != "2024-03-05T09:00:00Z" {
+		t.Errorf("earliestDatestamp = %s, want 2024-03-05T09:00:00Z", got)
+	}
+}
+
+func TestListResumption(t *testing.T) {
+	db := databasetest.Open(t)
+	setPageSize(t, 2)
+
+	stamp := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	for _, isbn := range []string{"9780000000001", "9780000000002", "9780000000003"} {
+		createBook(t, db, isbn, stamp)
+	}
+
+	resp := handle(t, db, "verb=ListIdentifiers&metadataPrefix=oai_dc&from=2024-03-05")
+	first := resp.ListIdentifiers
+	if len(first.Headers) != 2 || first.ResumptionToken == nil || first.ResumptionToken.Value == "" {
+		t.Fatalf("first page = %+v, want 2 headers and a token", first)
+	}
+	if tok := first.ResumptionToken; tok.CompleteListSize != 3 || tok.Cursor != 0 {
+		t.Errorf("first token = %+v, want size 3 cursor 0", tok)
+	}
+
+	resp = handle(t, db, "verb=ListIdentifiers&resumptionToken="+url.QueryEscape(first.ResumptionToken.Value))
+	last := resp.ListIdentifiers
+	if len(last.Headers) != 1 || last.ResumptionToken == nil {
+		t.Fatalf("last page = %+v, want 1 header and an empty token", last)
+	}
+	if tok := last.ResumptionToken; tok.Value != "" || tok.Cursor != 2 || tok.CompleteListSize != 3 {
+		t.Errorf("last token = %+v, want empty token at cursor 2", tok)
+	}
+
+	resp = handle(t, db, "verb=ListIdentifiers&resumptionToken=garbage")
+	if got := responseError(resp); got != ErrBadResumptionToken {
+		t.Errorf("error = %q, want %q", got, ErrBadResumptionToken)
+	}
+}
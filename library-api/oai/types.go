@@ -0,0 +1,153 @@
+package oai
+
+import "encoding/xml"
+
+// XML 命名空间和模式位置
+const (
+	namespace         = "http://www.openarchives.org/OAI/2.0/"
+	schemaLocation    = "http://www.openarchives.org/OAI/2.0/ http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd"
+	xsiNamespace      = "http://www.w3.org/2001/XMLSchema-instance"
+	oaiDCNamespace    = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	oaiDCSchema       = "http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+	dcNamespace       = "http://purl.org/dc/elements/1.1/"
+	identifierNS      = "http://www.openarchives.org/OAI/2.0/oai-identifier"
+	identifierSchema  = "http://www.openarchives.org/OAI/2.0/oai-identifier.xsd"
+	protocolVersion   = "2.0"
+	datestampLayout   = "2006-01-02T15:04:05Z"
+	dayLayout         = "2006-01-02"
+	metadataPrefixDC  = "oai_dc"
+	deletedRecordMode = "persistent"
+)
+
+// Response OAI-PMH 响应文档，每次只填充一个动词元素或错误
+type Response struct {
+	XMLName        xml.Name `xml:"OAI-PMH"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	XmlnsXsi       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	ResponseDate   string   `xml:"responseDate"`
+	Request        Request  `xml:"request"`
+
+	Errors              []Error              `xml:"error,omitempty"`
+	Identify            *Identify            `xml:"Identify,omitempty"`
+	ListMetadataFormats *ListMetadataFormats `xml:"ListMetadataFormats,omitempty"`
+	ListIdentifiers     *ListIdentifiers     `xml:"ListIdentifiers,omitempty"`
+	ListRecords         *ListRecords         `xml:"ListRecords,omitempty"`
+	GetRecord           *GetRecord           `xml:"GetRecord,omitempty"`
+}
+
+// Request 响应中回显的请求，参数错误时只包含 baseURL
+type Request struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	BaseURL         string `xml:",chardata"`
+}
+
+// Error 协议错误
+type Error struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+// Identify 仓储信息
+type Identify struct {
+	RepositoryName    string       `xml:"repositoryName"`
+	BaseURL           string       `xml:"baseURL"`
+	ProtocolVersion   string       `xml:"protocolVersion"`
+	AdminEmail        string       `xml:"adminEmail"`
+	EarliestDatestamp string       `xml:"earliestDatestamp"`
+	DeletedRecord     string       `xml:"deletedRecord"`
+	Granularity       string       `xml:"granularity"`
+	Description       *Description `xml:"description,omitempty"`
+}
+
+// Description Identify 的描述，声明 oai-identifier 标识符格式
+type Description struct {
+	OAIIdentifier OAIIdentifier `xml:"oai-identifier"`
+}
+
+// OAIIdentifier oai-identifier 标识符格式说明
+type OAIIdentifier struct {
+	Xmlns                string `xml:"xmlns,attr"`
+	XmlnsXsi             string `xml:"xmlns:xsi,attr"`
+	SchemaLocation       string `xml:"xsi:schemaLocation,attr"`
+	Scheme               string `xml:"scheme"`
+	RepositoryIdentifier string `xml:"repositoryIdentifier"`
+	Delimiter            string `xml:"delimiter"`
+	SampleIdentifier     string `xml:"sampleIdentifier"`
+}
+
+// ListMetadataFormats 支持的元数据格式
+type ListMetadataFormats struct {
+	Formats []MetadataFormat `xml:"metadataFormat"`
+}
+
+// MetadataFormat 元数据格式
+type MetadataFormat struct {
+	MetadataPrefix    string `xml:"metadataPrefix"`
+	Schema            string `xml:"schema"`
+	MetadataNamespace string `xml:"metadataNamespace"`
+}
+
+// ListIdentifiers 记录头列表
+type ListIdentifiers struct {
+	Headers         []Header         `xml:"header"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken,omitempty"`
+}
+
+// ListRecords 记录列表
+type ListRecords struct {
+	Records         []Record         `xml:"record"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken,omitempty"`
+}
+
+// GetRecord 单条记录
+type GetRecord struct {
+	Record Record `xml:"record"`
+}
+
+// Record 记录，已删除的记录没有元数据
+type Record struct {
+	Header   Header    `xml:"header"`
+	Metadata *Metadata `xml:"metadata,omitempty"`
+}
+
+// Header 记录头
+type Header struct {
+	Status     string `xml:"status,attr,omitempty"`
+	Identifier string `xml:"identifier"`
+	Datestamp  string `xml:"datestamp"`
+}
+
+// Metadata 记录元数据
+type Metadata struct {
+	DC *DublinCore `xml:"oai_dc:dc"`
+}
+
+// DublinCore oai_dc 格式的都柏林核心元数据。encoding/xml 不支持命名空间前缀，
+// 因此元素名直接写出前缀并声明对应的命名空间
+type DublinCore struct {
+	XmlnsOAIDC     string   `xml:"xmlns:oai_dc,attr"`
+	XmlnsDC        string   `xml:"xmlns:dc,attr"`
+	XmlnsXsi       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Titles         []string `xml:"dc:title"`
+	Creators       []string `xml:"dc:creator"`
+	Publishers     []string `xml:"dc:publisher"`
+	Dates          []string `xml:"dc:date"`
+	Descriptions   []string `xml:"dc:description"`
+	Types          []string `xml:"dc:type"`
+	Identifiers    []string `xml:"dc:identifier"`
+}
+
+// ResumptionToken 续传令牌，最后一页为空令牌
+type ResumptionToken struct {
+	CompleteListSize int64  `xml:"completeListSize,attr"`
+	Cursor           int    `xml:"cursor,attr"`
+	Value            string `xml:",chardata"`
+}
@@ -0,0 +1,273 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksRefreshInterval 遇到未知 kid 时重新获取 JWKS 的最小间隔，防止伪造的 kid 触发大量请求
+	jwksRefreshInterval = time.Minute
+	// clockSkew 验证 ID 令牌时间时允许的时钟偏差
+	clockSkew = time.Minute
+)
+
+// idTokenMethods ID 令牌可接受的签名算法
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Identity 身份提供方确认的用户身份
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+// verify 验证 ID 令牌的签名、签发方、受众、有效期和 nonce，返回其中的全部声明
+func (p *Provider) verify(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.keys.get(ctx, kid)
+		},
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithJSONNumber(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	// 多个受众时 azp 必须是本应用
+	if audience, _ := claims.GetAudience(); len(audience) > 1 && claimString(claims, "azp") != p.ClientID {
+		return nil, fmt.Errorf("%w: azp does not match client id", ErrInvalidIDToken)
+	}
+	if nonce == "" || claimString(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claimString(claims, "sub") == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// identity 按声明映射取出用户身份。没有邮箱验证声明时，只有配置为可信的身份提供方才视邮箱为已验证
+func (p *Provider) identity(claims map[string]interface{}) (*Identity, error) {
+	identity := &Identity{
+		Provider: p.Name,
+		Subject:  claimString(claims, p.Claims.Subject),
+		Email:    claimString(claims, p.Claims.Email),
+		Name:     claimString(claims, p.Claims.Name),
+		Username: claimString(claims, p.Claims.Username),
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject claim %q", ErrInvalidIDToken, p.Claims.Subject)
+	}
+
+	if verified, ok := claimBool(claims, p.Claims.EmailVerified); ok {
+		identity.EmailVerified = verified
+	} else {
+		identity.EmailVerified = p.TrustEmail
+	}
+	return identity, nil
+}
+
+// claimValue 按名称查找声明，名称中的点号表示嵌套对象
+func claimValue(claims map[string]interface{}, name string) (interface{}, bool) {
+	if name == "" {
+		return nil, false
+	}
+	if v, ok := claims[name]; ok {
+		return v, true
+	}
+
+	var current interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// claimString 返回字符串或数字形式的声明值
+func claimString(claims map[string]interface{}, name string) string {
+	v, _ := claimValue(claims, name)
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// claimBool 返回布尔声明值，兼容部分身份提供方以字符串 "true" 表示的布尔值
+func claimBool(claims map[string]interface{}, name string) (bool, bool) {
+	v, ok := claimValue(claims, name)
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		return v == "true", true
+	}
+	return false, ok && v != nil
+}
+
+// keySet 身份提供方的签名公钥，按 kid 缓存
+type keySet struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	url       string
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// setURL 设置 JWKS 地址，地址变化时清空缓存
+func (s *keySet) setURL(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.url != url {
+		s.url = url
+		s.keys = nil
+		s.fetchedAt = time.Time{}
+	}
+}
+
+// get 按 kid 返回公钥，缓存中没有时重新获取 JWKS。kid 为空且只有一个密钥时使用该密钥
+func (s *keySet) get(ctx context.Context, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := getJSON(ctx, s.client, s.url, &set); err != nil {
+		return nil, err
+	}
+	s.fetchedAt = time.Now()
+	s.keys = make(map[string]interface{}, len(set.Keys))
+	for _, raw := range set.Keys {
+		id, key, err := parseJWK(raw)
+		if err != nil {
+			// 跳过不支持的密钥类型（如加密用的密钥）
+			continue
+		}
+		s.keys[id] = key
+	}
+
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// lookup 在缓存中查找公钥，调用方需持有锁
+func (s *keySet) lookup(kid string) interface{} {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+// parseJWK 解析签名用的 JWK 公钥，支持 RSA、EC（P-256/384/521）和 Ed25519
+func parseJWK(raw json.RawMessage) (string, interface{}, error) {
+	var jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, errors.New("not a signing key")
+	}
+
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.Kid, &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return "", nil, errors.New("invalid Ed25519 key")
+		}
+		return jwk.Kid, ed25519.PublicKey(x), nil
+	default:
+		return "", nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
@@ -0,0 +1,227 @@
+// Package oidc 通过 OpenID Connect 身份提供方登录
+//
+// 登录流程使用授权码模式和 PKCE（S256）。每次登录生成随机的 state、nonce 和 PKCE 校验值，
+// 保存在由签名密钥签名的短期 cookie 中：回调时 state 必须与 cookie 一致，ID 令牌中的 nonce
+// 必须与 cookie 一致，换取令牌时提交 PKCE 校验值。ID 令牌按身份提供方公布的 JWKS 验证签名、
+// 签发方、受众和有效期。
+//
+// 身份提供方由配置注册，可以是 Google，也可以是 Keycloak、学校统一认证等任何提供发现文档的签发方；
+// 用户ID、邮箱、姓名等所在的声明可以按提供方分别配置。
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/keyring"
+)
+
+// 签名令牌的受众，区分登录流程状态、账号关联令牌和访问令牌
+const (
+	flowAudience = "library-api:oidc-flow"
+	linkAudience = "library-api:oidc-link"
+)
+
+const (
+	// FlowTTL 登录流程的有效期，超过后需要重新登录
+	FlowTTL = 10 * time.Minute
+	// LinkTTL 账号关联令牌的有效期
+	LinkTTL = 10 * time.Minute
+)
+
+var (
+	// ErrUnknownProvider 身份提供方不存在或未配置
+	ErrUnknownProvider = errors.New("identity provider is not configured")
+	// ErrDiscovery 无法获取或验证发现文档
+	ErrDiscovery = errors.New("identity provider discovery failed")
+	// ErrExchange 授权码换取令牌失败
+	ErrExchange = errors.New("authorization code exchange failed")
+	// ErrInvalidIDToken ID 令牌验证失败
+	ErrInvalidIDToken = errors.New("invalid id token")
+	// ErrInvalidState 登录流程状态不存在、已过期或与回调参数不符
+	ErrInvalidState = errors.New("invalid or expired login state")
+)
+
+var (
+	providers   = map[string]*Provider{}
+	order       []string // 按配置顺序排列的提供方名称
+	frontendURL = "http://localhost:3000/oauth/callback"
+)
+
+// Init 根据配置注册身份提供方。缺少签发方地址或客户端ID的提供方不注册并返回错误，其余提供方仍可使用
+func Init(cfg *config.Config) error {
+	providers = map[string]*Provider{}
+	order = nil
+	frontendURL = cfg.OAuthFrontendURL
+
+	var errs []error
+	for _, pc := range cfg.OIDCProviders {
+		if pc.IssuerURL == "" || pc.ClientID == "" {
+			errs = append(errs, fmt.Errorf("identity provider %q: issuer URL and client ID are required", pc.Name))
+			continue
+		}
+		providers[pc.Name] = NewProvider(pc)
+		order = append(order, pc.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// Get 按名称返回身份提供方
+func Get(name string) (*Provider, error) {
+	if p, ok := providers[name]; ok {
+		return p, nil
+	}
+	return nil, ErrUnknownProvider
+}
+
+// Providers 按配置顺序返回全部身份提供方
+func Providers() []*Provider {
+	list := make([]*Provider, 0, len(order))
+	for _, name := range order {
+		list = append(list, providers[name])
+	}
+	return list
+}
+
+// FrontendURL 登录完成后重定向到的前端地址
+func FrontendURL() string {
+	return frontendURL
+}
+
+// Flow 一次登录流程的状态
+type Flow struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// flowClaims 登录流程 cookie 的声明
+type flowClaims struct {
+	Flow
+	jwt.RegisteredClaims
+}
+
+// NewFlow 为身份提供方生成新的登录流程
+func NewFlow(provider string) (*Flow, error) {
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	return &Flow{
+		Provider: provider,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}, nil
+}
+
+// Seal 将登录流程签名为 cookie 值
+func (f *Flow) Seal(now time.Time) (string, error) {
+	return sign(&flowClaims{Flow: *f, RegisteredClaims: registered(flowAudience, now, FlowTTL)})
+}
+
+// OpenFlow 验证 cookie 签名和有效期并取出登录流程，state 必须与回调参数一致
+func OpenFlow(value, provider, state string) (*Flow, error) {
+	var claims flowClaims
+	if err := parse(value, flowAudience, &claims); err != nil {
+		return nil, ErrInvalidState
+	}
+	if claims.Provider != provider || state == "" || !equal(claims.State, state) {
+		return nil, ErrInvalidState
+	}
+	return &claims.Flow, nil
+}
+
+// linkClaims 账号关联令牌的声明
+type linkClaims struct {
+	Provider string `json:"provider"`
+	Email    string `json:"email"`
+	Name     string `json:"name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// SealLink 为已验证但尚未关联的外部身份签发关联令牌，用户提供密码确认后才能关联到已有账号
+func SealLink(identity *Identity, now time.Time) (string, error) {
+	claims := &linkClaims{
+		Provider:         identity.Provider,
+		Email:            identity.Email,
+		Name:             identity.Name,
+		RegisteredClaims: registered(linkAudience, now, LinkTTL),
+	}
+	claims.Subject = identity.Subject
+	return sign(claims)
+}
+
+// OpenLink 验证关联令牌并取出外部身份
+func OpenLink(token string) (*Identity, error) {
+	var claims linkClaims
+	if err := parse(token, linkAudience, &claims); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Provider:      claims.Provider,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: true,
+		Name:          claims.Name,
+	}, nil
+}
+
+// registered 返回带受众和有效期的标准声明
+func registered(audience string, now time.Time, ttl time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    "library-api",
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+}
+
+// sign 使用当前签名密钥签名
+func sign(claims jwt.Claims) (string, error) {
+	key, err := keyring.Current()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private())
+}
+
+// parse 验证签名、受众和有效期
+func parse(value, audience string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(value, claims, keyring.Keyfunc,
+		jwt.WithValidMethods(keyring.Methods()),
+		jwt.WithIssuer("library-api"),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
+
+// randomString 生成 URL 安全的随机字符串
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// equal 以恒定时间比较两个字符串
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,212 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/example/library-api/config"
+)
+
+const (
+	// discoveryTTL 发现文档的缓存时间
+	discoveryTTL = 24 * time.Hour
+	// httpTimeout 请求身份提供方的超时时间
+	httpTimeout = 10 * time.Second
+)
+
+// discovery OpenID Connect 发现文档中用到的字段
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider OpenID Connect 身份提供方。端点通过发现文档获取，ID 令牌按提供方公布的 JWKS 验证
+type Provider struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Claims       config.ClaimMapping
+	TrustEmail   bool
+
+	client *http.Client
+	keys   *keySet
+
+	mu           sync.Mutex
+	doc          *discovery
+	discoveredAt time.Time
+}
+
+// NewProvider 根据配置创建身份提供方，首次使用时才请求发现文档
+func NewProvider(cfg config.OIDCProvider) *Provider {
+	client := &http.Client{Timeout: httpTimeout}
+	return &Provider{
+		Name:         cfg.Name,
+		DisplayName:  cfg.DisplayName,
+		Issuer:       strings.TrimSuffix(cfg.IssuerURL, "/"),
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURI,
+		Scopes:       cfg.Scopes,
+		Claims:       cfg.Claims,
+		TrustEmail:   cfg.TrustEmail,
+		client:       client,
+		keys:         &keySet{client: client},
+	}
+}
+
+// AuthCodeURL 返回授权地址，携带 state、nonce 和 PKCE（S256）挑战值
+func (p *Provider) AuthCodeURL(ctx context.Context, flow *Flow) (string, error) {
+	conf, _, err := p.config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(flow.State,
+		oauth2.S256ChallengeOption(flow.Verifier),
+		oauth2.SetAuthURLParam("nonce", flow.Nonce),
+	), nil
+}
+
+// Exchange 用授权码换取令牌并验证 ID 令牌，返回用户身份。
+// ID 令牌中没有邮箱时从 userinfo 端点补充
+func (p *Provider) Exchange(ctx context.Context, flow *Flow, code string) (*Identity, error) {
+	conf, doc, err := p.config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+
+	claims, err := p.verify(ctx, rawIDToken, flow.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// 部分身份提供方只在 userinfo 中返回邮箱等信息，ID 令牌中已有的声明优先
+	if claimString(claims, p.Claims.Email) == "" && doc.UserinfoEndpoint != "" {
+		info, err := p.userinfo(ctx, doc.UserinfoEndpoint, token)
+		if err != nil {
+			return nil, err
+		}
+		if claimString(info, "sub") != claimString(claims, "sub") {
+			return nil, fmt.Errorf("%w: userinfo subject does not match id_token", ErrInvalidIDToken)
+		}
+		for k, v := range info {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+	return p.identity(claims)
+}
+
+// config 返回使用发现文档端点的 OAuth2 配置和所用的发现文档，
+// 发现文档可能被其他请求刷新，调用方只使用这里返回的文档
+func (p *Provider) config(ctx context.Context) (*oauth2.Config, *discovery, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       p.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  doc.AuthorizationEndpoint,
+			TokenURL: doc.TokenEndpoint,
+		},
+	}, doc, nil
+}
+
+// discover 获取并缓存发现文档，文档中的 issuer 必须与配置一致
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.doc != nil && time.Since(p.discoveredAt) < discoveryTTL {
+		return p.doc, nil
+	}
+
+	var doc discovery
+	if err := getJSON(ctx, p.client, p.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, doc.Issuer, p.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	p.doc = &doc
+	p.discoveredAt = time.Now()
+	p.keys.setURL(doc.JWKSURI)
+	return p.doc, nil
+}
+
+// userinfo 请求 userinfo 端点，返回其中的声明
+func (p *Provider) userinfo(ctx context.Context, endpoint string, token *oauth2.Token) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	token.SetAuthHeader(req)
+
+	var claims map[string]interface{}
+	if err := doJSON(p.client, req, &claims); err != nil {
+		return nil, fmt.Errorf("%w: userinfo: %v", ErrExchange, err)
+	}
+	return claims, nil
+}
+
+// getJSON 请求 URL 并解析 JSON 响应
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(client, req, v)
+}
+
+// doJSON 发送请求并解析 JSON 响应，非 2xx 状态码视为错误
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", req.URL.Redacted(), resp.Status)
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return errors.New("invalid JSON response from " + req.URL.Redacted())
+	}
+	return nil
+}
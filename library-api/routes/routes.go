@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/example/library-api/controllers"
+	"github.com/example/library-api/covers"
 	"github.com/example/library-api/middleware"
 	"github.com/gin-gonic/gin"
 )
@@ -14,11 +15,27 @@ func RegisterRoutes(router *gin.Engine) {
 		// 用户认证路由
 		auth := public.Group("auth")
 		{
-			public.GET("google/login", controllers.GoogleLogin)
-			public.GET("google/callback", controllers.GoogleLoginCallback)
+			auth.GET("providers", controllers.GetAuthProviders)
+			auth.GET(":provider/login", controllers.OAuthLogin)
+			auth.GET(":provider/callback", controllers.OAuthCallback)
+			auth.POST(":provider/link", controllers.LinkOAuthAccount)
 			auth.POST("register", controllers.Register)
 			auth.POST("login", controllers.Login)
+			auth.POST("refresh", controllers.RefreshToken)
+			auth.POST("logout", controllers.Logout)
 		}
+
+		// 访问令牌验证公钥
+		public.GET(".well-known/jwks.json", controllers.GetJWKS)
+
+		// OAI-PMH 采集接口，供外部系统采集书目，GET 和 POST 等价
+		public.GET("oai", controllers.OAIPMH)
+		public.POST("oai", controllers.OAIPMH)
+	}
+
+	// 本地存储的封面图片以静态文件提供
+	if path, dir, ok := covers.LocalMount(); ok {
+		router.Static(path, dir)
 	}
 
 	// 需要认证的路由
@@ -29,6 +46,13 @@ func RegisterRoutes(router *gin.Engine) {
 		user := api.Group("user")
 		{
 			user.GET("borrows", controllers.GetMyBorrows)
+			user.GET("holds", controllers.GetMyHolds)
+			user.GET("fines", controllers.GetMyFines)
+			user.GET("sessions", controllers.GetMySessions)
+			user.DELETE("sessions/:id", controllers.RevokeMySession)
+			user.GET("identities", controllers.GetMyIdentities)
+			user.POST("identities", controllers.LinkMyIdentity)
+			user.DELETE("identities/:id", controllers.UnlinkMyIdentity)
 		}
 
 		// 图书路由
@@ -37,18 +61,187 @@ func RegisterRoutes(router *gin.Engine) {
 			// 所有用户可访问的图书路由
 			books.GET("", controllers.GetBooks)
 			books.GET("/:id", controllers.GetBook)
+			books.GET("/:id/marc", controllers.GetBookMARC)
 			books.POST("borrow", controllers.BorrowBook)
 			books.POST("return", controllers.ReturnBook)
+			books.POST("renew", controllers.RenewBook)
+			books.POST("/:id/holds", controllers.PlaceHold)
+			books.DELETE("/:id/holds/:hold_id", controllers.CancelHold)
 
 			// 管理员路由
 			admin := books.Group("")
 			admin.Use(middleware.AdminRequired())
 			{
 				admin.POST("", controllers.CreateBook)
+				admin.POST("/import", controllers.ImportBooks)
+				admin.GET("/export", controllers.ExportCatalogue)
+				admin.GET("/lookup", controllers.LookupBook)
 				admin.PUT("/:id", controllers.UpdateBook)
 				admin.DELETE("/:id", controllers.DeleteBook)
+				admin.PUT("/:id/cover", controllers.UploadBookCover)
+				admin.DELETE("/:id/cover", controllers.DeleteBookCover)
+				admin.GET("/:id/copies", controllers.GetBookCopies)
 				admin.POST("/:id/copies", controllers.AddBookCopies)
+				admin.GET("/:id/holds", controllers.GetBookHolds)
+				admin.PUT("/:id/holds/:hold_id/position", controllers.MoveHold)
+			}
+		}
+
+		// 图书副本路由（管理员）
+		copies := api.Group("copies")
+		copies.Use(middleware.AdminRequired())
+		{
+			copies.GET("/barcode/:barcode", controllers.GetCopyByBarcode)
+			copies.PUT("/:id/status", controllers.UpdateCopyStatus)
+			copies.GET("/:id/history", controllers.GetCopyHistory)
+			copies.PUT("/:id/location", controllers.UpdateCopyLocation)
+			copies.POST("/:id/transfers", controllers.RequestTransfer)
+		}
+
+		// 分馆调拨路由（管理员）
+		transfers := api.Group("transfers")
+		transfers.Use(middleware.AdminRequired())
+		{
+			transfers.GET("", controllers.GetTransfers)
+			transfers.GET("/:id", controllers.GetTransfer)
+			transfers.POST("/:id/ship", controllers.ShipTransfer)
+			transfers.POST("/:id/receive", controllers.ReceiveTransfer)
+			transfers.POST("/:id/cancel", controllers.CancelTransfer)
+		}
+
+		// 分馆路由
+		branches := api.Group("branches")
+		{
+			// 所有用户可访问的分馆路由
+			branches.GET("", controllers.GetBranches)
+			branches.GET("/:id", controllers.GetBranch)
+			branches.GET("/:id/shelves", controllers.GetShelfLocations)
+
+			// 管理员路由
+			admin := branches.Group("")
+			admin.Use(middleware.AdminRequired())
+			{
+				admin.POST("", controllers.CreateBranch)
+				admin.PUT("/:id", controllers.UpdateBranch)
+				admin.DELETE("/:id", controllers.DeleteBranch)
+				admin.POST("/:id/shelves", controllers.CreateShelfLocation)
+				admin.PUT("/:id/shelves/:shelf_id", controllers.UpdateShelfLocation)
+				admin.DELETE("/:id/shelves/:shelf_id", controllers.DeleteShelfLocation)
+				admin.GET("/:id/shelf-list", controllers.GetShelfList)
+			}
+		}
+
+		// 全文检索路由
+		api.GET("search", controllers.SearchBooks)
+
+		// 作者路由
+		authors := api.Group("authors")
+		{
+			// 所有用户可访问的作者路由
+			authors.GET("", controllers.GetAuthors)
+			authors.GET("/:id", controllers.GetAuthor)
+			authors.GET("/:id/books", controllers.GetAuthorBooks)
+
+			// 管理员路由
+			admin := authors.Group("")
+			admin.Use(middleware.AdminRequired())
+			{
+				admin.POST("", controllers.CreateAuthor)
+				admin.PUT("/:id", controllers.UpdateAuthor)
+				admin.DELETE("/:id", controllers.DeleteAuthor)
+				admin.POST("/:id/merge", controllers.MergeAuthors)
+			}
+		}
+
+		// 主题分类路由
+		categories := api.Group("categories")
+		{
+			// 所有用户可访问的分类路由
+			categories.GET("", controllers.GetCategories)
+			categories.GET("/:id", controllers.GetCategory)
+
+			// 管理员路由
+			admin := categories.Group("")
+			admin.Use(middleware.AdminRequired())
+			{
+				admin.POST("", controllers.CreateCategory)
+				admin.PUT("/:id", controllers.UpdateCategory)
+				admin.DELETE("/:id", controllers.DeleteCategory)
+			}
+		}
+
+		// 标签路由
+		tags := api.Group("tags")
+		{
+			// 所有用户可访问的标签路由
+			tags.GET("", controllers.GetTags)
+
+			// 管理员路由
+			admin := tags.Group("")
+			admin.Use(middleware.AdminRequired())
+			{
+				admin.PUT("/:id", controllers.UpdateTag)
+				admin.DELETE("/:id", controllers.DeleteTag)
+				admin.POST("/:id/merge", controllers.MergeTags)
+			}
+		}
+
+		// 作品路由，作品把同一本书的不同版本归为一组
+		works := api.Group("works")
+		{
+			// 所有用户可访问的作品路由
+			works.GET("", controllers.GetWorks)
+			works.GET("/:id", controllers.GetWork)
+
+			// 管理员路由
+			admin := works.Group("")
+			admin.Use(middleware.AdminRequired())
+			{
+				admin.POST("", controllers.CreateWork)
+				admin.PUT("/:id", controllers.UpdateWork)
+				admin.DELETE("/:id", controllers.DeleteWork)
+				admin.POST("/:id/editions", controllers.AddWorkEditions)
+				admin.DELETE("/:id/editions/:book_id", controllers.RemoveWorkEdition)
+			}
+		}
+
+		// 丛书路由
+		series := api.Group("series")
+		{
+			// 所有用户可访问的丛书路由
+			series.GET("", controllers.GetSeriesList)
+			series.GET("/:id", controllers.GetSeries)
+			series.GET("/:id/books", controllers.GetSeriesBooks)
+
+			// 管理员路由
+			admin := series.Group("")
+			admin.Use(middleware.AdminRequired())
+			{
+				admin.POST("", controllers.CreateSeries)
+				admin.PUT("/:id", controllers.UpdateSeries)
+				admin.DELETE("/:id", controllers.DeleteSeries)
+				admin.PUT("/:id/books/:book_id", controllers.SetSeriesVolume)
+				admin.DELETE("/:id/books/:book_id", controllers.RemoveSeriesVolume)
 			}
 		}
+
+		// 系统管理路由
+		system := api.Group("admin")
+		system.Use(middleware.AdminRequired())
+		{
+			system.GET("jobs", controllers.GetJobs)
+			system.POST("jobs/:name/run", controllers.RunJob)
+
+			system.GET("fines", controllers.GetFines)
+			system.POST("fines/:id/payments", controllers.PayFine)
+			system.POST("fines/:id/waive", controllers.WaiveFine)
+			system.GET("users/:id/fines", controllers.GetUserFines)
+			system.POST("users/:id/fines", controllers.ChargeFine)
+			system.GET("users/:id/sessions", controllers.GetUserSessions)
+			system.DELETE("users/:id/sessions", controllers.RevokeUserSessions)
+
+			system.GET("loan-policies", controllers.GetLoanPolicies)
+			system.PUT("loan-policies/:role", controllers.UpdateLoanPolicy)
+		}
 	}
 }
@@ -0,0 +1,103 @@
+// 批量导入图书
+//
+// 用法: go run -tags sqlite_fts5 ./scripts/import [-format csv|jsonl|marc|marcxml] [-mode skip|update] [-batch 100] [-report report.json] <文件|->
+//
+// 与服务器使用同样的构建标签，导入的图书才会写入全文索引
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/example/library-api/accession"
+	"github.com/example/library-api/catalog"
+	"github.com/example/library-api/circulation"
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/database"
+	"github.com/example/library-api/isbn"
+	"github.com/example/library-api/search"
+)
+
+func main() {
+	format := flag.String("format", "", "文件格式 csv、jsonl、marc 或 marcxml，默认按扩展名判断")
+	mode := flag.String("mode", catalog.ModeSkip, "已存在ISBN的处理方式 skip 或 update")
+	batch := flag.Int("batch", 0, "每个事务处理的行数，默认使用 IMPORT_BATCH_SIZE")
+	reportPath := flag.String("report", "", "将完整的逐行报告写入JSON文件")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: import [-format csv|jsonl|marc|marcxml] [-mode skip|update] [-batch N] [-report report.json] <文件|->")
+		os.Exit(2)
+	}
+
+	// 加载配置
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	accession.Init(cfg)
+	circulation.Init(cfg)
+	catalog.Init(cfg)
+
+	// 初始化数据库连接
+	database.InitDB()
+	if _, err := accession.MigrateCopies(database.DB); err != nil {
+		log.Fatalf("副本登录号迁移失败: %v", err)
+	}
+	// 已有图书按规范的ISBN-13匹配导入记录
+	if _, err := isbn.MigrateBooks(database.DB); err != nil {
+		log.Fatalf("ISBN规范化迁移失败: %v", err)
+	}
+	// 初始化全文检索索引，不支持FTS5时移除索引同步触发器，否则写入图书会失败
+	if err := search.Init(database.DB); err != nil {
+		log.Printf("全文检索初始化失败: %v", err)
+	}
+
+	path := flag.Arg(0)
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("打开文件失败: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	detected, err := catalog.DetectFormat(*format, "", path)
+	if err != nil {
+		log.Fatalf("无法确定文件格式: %v", err)
+	}
+	reader, err := catalog.NewReader(detected, input)
+	if err != nil {
+		log.Fatalf("读取文件失败: %v", err)
+	}
+
+	report, importErr := catalog.Import(database.DB, reader, catalog.Options{Mode: *mode, BatchSize: *batch})
+	if report != nil {
+		for _, row := range report.Rows {
+			if row.Status == catalog.RowRejected {
+				log.Printf("第 %d 行被拒绝: %s", row.Row, row.Error)
+			}
+		}
+		log.Printf("共 %d 行：新建 %d，更新 %d，跳过 %d，拒绝 %d",
+			report.Total, report.Created, report.Updated, report.Skipped, report.Rejected)
+
+		if *reportPath != "" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				log.Fatalf("生成报告失败: %v", err)
+			}
+			if err := os.WriteFile(*reportPath, data, 0o644); err != nil {
+				log.Fatalf("写入报告失败: %v", err)
+			}
+		}
+	}
+	if importErr != nil {
+		log.Fatalf("导入中止: %v", importErr)
+	}
+}
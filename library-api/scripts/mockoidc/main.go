@@ -0,0 +1,297 @@
+// 本地开发和测试用的模拟 OpenID Connect 身份提供方
+//
+// 实现发现文档、授权（要求 PKCE S256）、令牌、userinfo 和 JWKS 端点，ID 令牌使用启动时生成的 RSA 密钥签名。
+// 授权页面可以填写任意用户身份；使用 -auto 时直接以命令行指定的身份授权，便于脚本测试。
+//
+// 用法: go run ./scripts/mockoidc [-addr :9000] [-client-id ID] [-client-secret SECRET] [-auto] [-sub S] [-email E] [-name N]
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// grant 已签发但尚未兑换的授权码
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        user
+	expiresAt   time.Time
+}
+
+// user 授权的用户身份
+type user struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+var (
+	issuer       string
+	clientID     string
+	clientSecret string
+	autoApprove  bool
+	defaultUser  user
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants = map[string]*grant{}
+	tokens = map[string]user{} // 访问令牌对应的用户
+)
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>模拟登录</title></head>
+<body>
+<h1>模拟身份提供方</h1>
+<form method="post" action="/authorize?{{.Query}}">
+<p><label>sub <input name="sub" value="{{.User.Subject}}"></label></p>
+<p><label>email <input name="email" value="{{.User.Email}}"></label></p>
+<p><label>name <input name="name" value="{{.User.Name}}"></label></p>
+<p><label><input type="checkbox" name="email_verified" value="true"{{if .User.EmailVerified}} checked{{end}}> 邮箱已验证</label></p>
+<p><button name="action" value="approve">授权</button> <button name="action" value="deny">拒绝</button></p>
+</form>
+</body></html>`))
+
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	flag.StringVar(&issuer, "issuer", "", "签发方地址，默认 http://localhost<addr>")
+	flag.StringVar(&clientID, "client-id", "mock-client", "客户端ID")
+	flag.StringVar(&clientSecret, "client-secret", "mock-secret", "客户端密钥")
+	flag.BoolVar(&autoApprove, "auto", false, "不显示授权页面，直接以命令行指定的身份授权")
+	flag.StringVar(&defaultUser.Subject, "sub", "mock-user-1", "用户ID")
+	flag.StringVar(&defaultUser.Email, "email", "mock.user@example.com", "用户邮箱")
+	flag.StringVar(&defaultUser.Name, "name", "Mock User", "用户姓名")
+	flag.BoolVar(&defaultUser.EmailVerified, "email-verified", true, "邮箱是否已验证")
+	flag.Parse()
+
+	if issuer == "" {
+		host := *addr
+		if strings.HasPrefix(host, ":") {
+			host = "localhost" + host
+		}
+		issuer = "http://" + host
+	}
+	issuer = strings.TrimRight(issuer, "/")
+
+	var err error
+	key, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("生成签名密钥失败: %v", err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", discovery)
+	http.HandleFunc("/authorize", authorize)
+	http.HandleFunc("/token", token)
+	http.HandleFunc("/userinfo", userinfo)
+	http.HandleFunc("/jwks", jwks)
+
+	log.Printf("模拟身份提供方 %s，客户端ID %s", issuer, clientID)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// discovery 发现文档
+func discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize 授权端点，GET 显示授权页面，POST 提交授权结果
+func authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("client_id") != clientID || redirectURI == "" {
+		http.Error(w, "unknown client_id or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		redirectError(w, r, redirectURI, q.Get("state"), "invalid_request")
+		return
+	}
+
+	u := defaultUser
+	switch {
+	case r.Method == http.MethodPost:
+		if r.FormValue("action") != "approve" {
+			redirectError(w, r, redirectURI, q.Get("state"), "access_denied")
+			return
+		}
+		u = user{
+			Subject:       r.FormValue("sub"),
+			Email:         r.FormValue("email"),
+			EmailVerified: r.FormValue("email_verified") == "true",
+			Name:          r.FormValue("name"),
+		}
+	case !autoApprove:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		authorizePage.Execute(w, map[string]interface{}{"Query": r.URL.RawQuery, "User": defaultUser})
+		return
+	}
+
+	code := randomString()
+	mu.Lock()
+	grants[code] = &grant{
+		redirectURI: redirectURI,
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		user:        u,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	mu.Unlock()
+
+	target, _ := url.Parse(redirectURI)
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// token 令牌端点，验证客户端凭据、回调地址和 PKCE 校验值，授权码只能使用一次
+func token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if id != clientID || subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.FormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	mu.Lock()
+	g := grants[r.FormValue("code")]
+	delete(grants, r.FormValue("code"))
+	mu.Unlock()
+
+	if g == nil || time.Now().After(g.expiresAt) || g.redirectURI != r.FormValue("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            issuer,
+		"sub":            g.user.Subject,
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          g.user.Email,
+		"email_verified": g.user.EmailVerified,
+		"name":           g.user.Name,
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = "mock"
+	signed, err := idToken.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken := randomString()
+	mu.Lock()
+	tokens[accessToken] = g.user
+	mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// userinfo 返回访问令牌对应的用户信息
+func userinfo(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	mu.Lock()
+	u, ok := tokens[accessToken]
+	mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// jwks 签名公钥
+func jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+// redirectError 带错误码重定向回客户端
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI, state, code string) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := target.Query()
+	params.Set("error", code)
+	params.Set("state", state)
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// randomString 生成随机字符串
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
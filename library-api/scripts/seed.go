@@ -4,6 +4,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/example/library-api/accession"
 	"github.com/example/library-api/config"
 	"github.com/example/library-api/database"
 	"github.com/example/library-api/models"
@@ -12,10 +13,11 @@ import (
 
 func main() {
 	// 加载配置
-	_, err := config.LoadConfig()
+	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
+	accession.Init(cfg)
 
 	// 初始化数据库连接
 	database.InitDB()
@@ -91,10 +93,14 @@ func main() {
 
 	// 创建图书副本
 	for bookID := 1; bookID <= 3; bookID++ {
+		codes, err := accession.Next(db, 3)
+		if err != nil {
+			log.Fatalf("分配副本登录号失败: %v", err)
+		}
 		for i := 1; i <= 3; i++ {
 			copy := models.BookCopy{
 				BookID:          uint(bookID),
-				CopyNumber:      string(i),
+				CopyNumber:      codes[i-1],
 				Status:          "available", // 假设书籍副本状态为字符串 "available"，请根据实际模型定义修改
 				AcquisitionDate: time.Now().AddDate(-i, 0, 0),
 			}
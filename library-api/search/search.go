@@ -0,0 +1,308 @@
+// Package search 基于SQLite FTS5的图书全文检索
+//
+// 索引表 book_search 的 rowid 与 books.id 一致，通过数据库触发器与 books、
+// authors、book_authors 三张表保持同步，因此任何写入路径（控制器、种子脚本、
+// 作者合并、软删除）都会自动更新索引。FTS5 需要使用 sqlite_fts5 构建标签编译：
+//
+//	go build -tags sqlite_fts5
+package search
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// ErrUnavailable 当前构建的SQLite不支持FTS5
+var ErrUnavailable = errors.New("full-text search is unavailable, build with -tags sqlite_fts5")
+
+// ErrEmptyQuery 查询中没有可检索的词
+var ErrEmptyQuery = errors.New("search query must contain at least one word")
+
+var enabled bool
+
+// triggerNames 同步索引所用的触发器
+var triggerNames = []string{
+	"book_search_books_ai",
+	"book_search_books_au",
+	"book_search_books_ad",
+	"book_search_book_authors_ai",
+	"book_search_book_authors_au",
+	"book_search_book_authors_ad",
+	"book_search_authors_au",
+	"book_search_authors_ad",
+}
+
+// refreshSQL 重新生成满足条件的图书索引行，cond 为作用于图书ID的条件，如 "= new.id"
+func refreshSQL(cond string) string {
+	return fmt.Sprintf(`DELETE FROM book_search WHERE rowid %[1]s;
+	INSERT INTO book_search(rowid, title, description, authors)
+	SELECT b.id, b.title, COALESCE(b.description, ''),
+		COALESCE((SELECT group_concat(a.name, ' ') FROM book_authors ba
+			JOIN authors a ON a.id = ba.author_id
+			WHERE ba.book_id = b.id AND a.deleted_at IS NULL), '')
+	FROM books b WHERE b.id %[1]s AND b.deleted_at IS NULL;`, cond)
+}
+
+// available 返回当前构建的SQLite是否编译了FTS5
+func available(db *gorm.DB) bool {
+	var used int
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used).Error; err != nil {
+		return false
+	}
+	return used == 1
+}
+
+// Init 创建FTS5索引表和同步触发器，并重建索引。当前构建不支持FTS5时移除
+// 支持FTS5的构建留下的触发器并返回 ErrUnavailable，否则写入图书会因找不到
+// fts5 模块而失败
+func Init(db *gorm.DB) error {
+	enabled = false
+	if !available(db) {
+		for _, name := range triggerNames {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + name).Error; err != nil {
+				return fmt.Errorf("drop trigger %s: %w", name, err)
+			}
+		}
+		log.Printf("全文检索不可用: %v", ErrUnavailable)
+		return ErrUnavailable
+	}
+
+	err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS book_search USING fts5(
+		title, description, authors,
+		tokenize = 'unicode61 remove_diacritics 2'
+	)`).Error
+	if err != nil {
+		return err
+	}
+
+	if err := createTriggers(db); err != nil {
+		return err
+	}
+
+	if err := Rebuild(db); err != nil {
+		return err
+	}
+
+	enabled = true
+	return nil
+}
+
+// createTriggers 重新创建同步索引的触发器
+func createTriggers(db *gorm.DB) error {
+	byAuthor := func(ref string) string {
+		return "IN (SELECT book_id FROM book_authors WHERE author_id = " + ref + ")"
+	}
+	triggers := map[string]string{
+		"book_search_books_ai":        "AFTER INSERT ON books BEGIN " + refreshSQL("= new.id") + " END",
+		"book_search_books_au":        "AFTER UPDATE ON books BEGIN " + refreshSQL("= new.id") + " END",
+		"book_search_books_ad":        "AFTER DELETE ON books BEGIN DELETE FROM book_search WHERE rowid = old.id; END",
+		"book_search_book_authors_ai": "AFTER INSERT ON book_authors BEGIN " + refreshSQL("= new.book_id") + " END",
+		"book_search_book_authors_au": "AFTER UPDATE ON book_authors BEGIN " + refreshSQL("= old.book_id") + refreshSQL("= new.book_id") + " END",
+		"book_search_book_authors_ad": "AFTER DELETE ON book_authors BEGIN " + refreshSQL("= old.book_id") + " END",
+		"book_search_authors_au":      "AFTER UPDATE OF name, deleted_at ON authors BEGIN " + refreshSQL(byAuthor("new.id")) + " END",
+		"book_search_authors_ad":      "AFTER DELETE ON authors BEGIN " + refreshSQL(byAuthor("old.id")) + " END",
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range triggerNames {
+			if err := tx.Exec("DROP TRIGGER IF EXISTS " + name).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("CREATE TRIGGER " + name + " " + triggers[name]).Error; err != nil {
+				return fmt.Errorf("create trigger %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Enabled 返回全文检索是否可用
+func Enabled() bool {
+	return enabled
+}
+
+// Rebuild 根据当前图书数据重建整个索引
+func Rebuild(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM book_search").Error; err != nil {
+			return err
+		}
+		return tx.Exec(refreshSQL("IN (SELECT id FROM books)")).Error
+	})
+}
+
+// MatchQuery 将用户输入转换为FTS5查询表达式，每个词都按前缀匹配且必须全部命中
+func MatchQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = `"` + w + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// Params 检索参数
+type Params struct {
+	Query     string
+	Publisher string
+	Year      string
+	Offset    int
+	Limit     int
+}
+
+// Highlights 命中词高亮后的字段内容，原文已做 HTML 转义，命中词用 <mark> 标签包裹
+type Highlights struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Authors     string `json:"authors,omitempty"`
+}
+
+// Hit 单条检索结果
+type Hit struct {
+	Book       models.Book `json:"book"`
+	Score      float64     `json:"score"`
+	Highlights Highlights  `json:"highlights"`
+}
+
+// FacetCount 分面统计项
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets 按出版社和出版年份的分面统计
+type Facets struct {
+	Publisher []FacetCount `json:"publisher"`
+	Year      []FacetCount `json:"year"`
+}
+
+// Result 检索结果
+type Result struct {
+	Hits   []Hit  `json:"data"`
+	Total  int64  `json:"total"`
+	Facets Facets `json:"facets"`
+}
+
+// 高亮的起止标记，转义原文后替换为 <mark> 标签
+const (
+	markOpen  = "\x01"
+	markClose = "\x02"
+)
+
+// markup 转义高亮结果中的原文，再将起止标记替换为 <mark> 标签，避免目录数据中的 HTML 被当作标记输出
+func markup(s string) string {
+	return strings.NewReplacer(markOpen, "<mark>", markClose, "</mark>").Replace(html.EscapeString(s))
+}
+
+// yearExpr 出版年份表达式，未填写出版日期的图书为零值时间
+const yearExpr = "substr(books.publication_date, 1, 4)"
+
+// Search 执行全文检索，结果按相关度排序，分面统计只受检索词影响
+func Search(db *gorm.DB, p Params) (*Result, error) {
+	if !enabled {
+		return nil, ErrUnavailable
+	}
+
+	match := MatchQuery(p.Query)
+	if match == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	// 命中检索词的未删除图书
+	matched := func() *gorm.DB {
+		return db.Table("book_search").
+			Joins("JOIN books ON books.id = book_search.rowid AND books.deleted_at IS NULL").
+			Where("book_search MATCH ?", match)
+	}
+
+	filtered := matched()
+	if p.Publisher != "" {
+		filtered = filtered.Where("books.publisher = ?", p.Publisher)
+	}
+	if p.Year != "" {
+		filtered = filtered.Where(yearExpr+" = ?", p.Year)
+	}
+
+	result := &Result{
+		Hits:   []Hit{},
+		Facets: Facets{Publisher: []FacetCount{}, Year: []FacetCount{}},
+	}
+	if err := filtered.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	// 标题权重最高，其次是作者和简介
+	var rows []struct {
+		ID          uint
+		Score       float64
+		Title       string
+		Description string
+		Authors     string
+	}
+	err := filtered.Select(`books.id AS id,
+			-bm25(book_search, 10.0, 2.0, 5.0) AS score,
+			highlight(book_search, 0, char(1), char(2)) AS title,
+			snippet(book_search, 1, char(1), char(2), '…', 24) AS description,
+			highlight(book_search, 2, char(1), char(2)) AS authors`).
+		Order("bm25(book_search, 10.0, 2.0, 5.0), books.id").
+		Offset(p.Offset).Limit(p.Limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) > 0 {
+		ids := make([]uint, len(rows))
+		for i, r := range rows {
+			ids[i] = r.ID
+		}
+
+		var books []models.Book
+		if err := db.Preload("Authors").Find(&books, ids).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]models.Book, len(books))
+		for _, b := range books {
+			byID[b.ID] = b
+		}
+
+		for _, r := range rows {
+			result.Hits = append(result.Hits, Hit{
+				Book:  byID[r.ID],
+				Score: r.Score,
+				Highlights: Highlights{
+					Title:       markup(r.Title),
+					Description: markup(r.Description),
+					Authors:     markup(r.Authors),
+				},
+			})
+		}
+	}
+
+	// 分面统计
+	if err := matched().Select("books.publisher AS value, COUNT(*) AS count").
+		Where("books.publisher <> ''").
+		Group("books.publisher").Order("count DESC, value").
+		Scan(&result.Facets.Publisher).Error; err != nil {
+		return nil, err
+	}
+	if err := matched().Select(yearExpr + " AS value, COUNT(*) AS count").
+		Where("books.publication_date IS NOT NULL AND " + yearExpr + " <> '0001'").
+		Group("value").Order("value DESC").
+		Scan(&result.Facets.Year).Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
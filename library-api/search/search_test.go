@@ -0,0 +1,126 @@
+//go:build sqlite_fts5
+
+package search
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// setupDB 使用临时数据库并创建全文索引
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := databasetest.Open(t)
+	if err := Init(db); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { enabled = false })
+	return db
+}
+
+// hitIDs 检索并返回命中的图书ID
+func hitIDs(t *testing.T, db *gorm.DB, q string) []uint {
+	t.Helper()
+
+	result, err := Search(db, Params{Query: q, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search(%q): %v", q, err)
+	}
+	ids := make([]uint, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.Book.ID
+	}
+	return ids
+}
+
+func TestTriggersKeepIndexInSync(t *testing.T) {
+	db := setupDB(t)
+
+	author := models.Author{Name: "Frank Herbert"}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatalf("create author: %v", err)
+	}
+	book := models.Book{Title: "Dune", ISBN: "9780441013593", Description: "Desert planet", Authors: []models.Author{author}}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+
+	// 新增的图书可以按标题、简介和作者检索
+	for _, q := range []string{"dune", "desert", "herbert"} {
+		if got := hitIDs(t, db, q); len(got) != 1 || got[0] != book.ID {
+			t.Errorf("after insert %q hits = %v, want [%d]", q, got, book.ID)
+		}
+	}
+
+	// 修改标题后旧标题不再命中
+	if err := db.Model(&book).Update("title", "Children of Dune").Error; err != nil {
+		t.Fatalf("update book: %v", err)
+	}
+	if got := hitIDs(t, db, "children"); len(got) != 1 || got[0] != book.ID {
+		t.Errorf("after update hits = %v, want [%d]", got, book.ID)
+	}
+	if err := db.Model(&book).Update("title", "Messiah").Error; err != nil {
+		t.Fatalf("update book: %v", err)
+	}
+	if got := hitIDs(t, db, "children"); len(got) != 0 {
+		t.Errorf("old title hits = %v, want none", got)
+	}
+
+	// 修改作者姓名同步到图书索引
+	if err := db.Model(&author).Update("name", "Brian Herbert").Error; err != nil {
+		t.Fatalf("update author: %v", err)
+	}
+	if got := hitIDs(t, db, "brian"); len(got) != 1 || got[0] != book.ID {
+		t.Errorf("after author rename hits = %v, want [%d]", got, book.ID)
+	}
+
+	// 软删除和删除的图书不再命中
+	if err := db.Delete(&book).Error; err != nil {
+		t.Fatalf("delete book: %v", err)
+	}
+	if got := hitIDs(t, db, "messiah"); len(got) != 0 {
+		t.Errorf("after soft delete hits = %v, want none", got)
+	}
+	if err := db.Unscoped().Delete(&book).Error; err != nil {
+		t.Fatalf("hard delete book: %v", err)
+	}
+	var rows int64
+	db.Raw(`SELECT COUNT(*) FROM book_search WHERE rowid = ?`, book.ID).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("index rows after delete = %d, want 0", rows)
+	}
+}
+
+func TestHighlightsEscapeCatalogueText(t *testing.T) {
+	db := setupDB(t)
+
+	book := models.Book{
+		Title:       "<script>alert(1)</script> Dune",
+		ISBN:        "9780441013593",
+		Description: "A <b>desert</b> & its sandworms",
+	}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+
+	result, err := Search(db, Params{Query: "dune desert", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Hits) != 1 {
+		t.Fatalf("hits = %d, want 1", len(result.Hits))
+	}
+
+	h := result.Hits[0].Highlights
+	if want := "&lt;script&gt;alert(1)&lt;/script&gt; <mark>Dune</mark>"; h.Title != want {
+		t.Errorf("title = %q, want %q", h.Title, want)
+	}
+	if want := "A &lt;b&gt;<mark>desert</mark>&lt;/b&gt; &amp; its sandworms"; h.Description != want {
+		t.Errorf("description = %q, want %q", h.Description, want)
+	}
+}
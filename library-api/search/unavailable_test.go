@@ -0,0 +1,39 @@
+//go:build !sqlite_fts5
+
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+func TestInitWithoutFTS5DropsTriggers(t *testing.T) {
+	db := databasetest.Open(t)
+
+	// 模拟支持FTS5的构建留下的触发器，此时写入图书会失败
+	if err := createTriggers(db); err != nil {
+		t.Fatalf("create triggers: %v", err)
+	}
+	if err := db.Create(&models.Book{Title: "Dune", ISBN: "9780441013593"}).Error; err == nil {
+		t.Fatal("create book with stale triggers succeeded, want error")
+	}
+
+	if err := Init(db); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Init err = %v, want ErrUnavailable", err)
+	}
+	if Enabled() {
+		t.Error("Enabled() = true, want false")
+	}
+
+	var triggers int64
+	db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'book_search_%'").Scan(&triggers)
+	if triggers != 0 {
+		t.Errorf("got %d search triggers, want none", triggers)
+	}
+	if err := db.Create(&models.Book{Title: "Dune", ISBN: "9780441013593"}).Error; err != nil {
+		t.Errorf("create book: %v", err)
+	}
+}
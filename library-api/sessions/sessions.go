@@ -0,0 +1,243 @@
+// Package sessions 登录会话与令牌管理。访问令牌短期有效，刷新令牌每次使用后轮换，
+// 服务端只保存刷新令牌的哈希值；已使用的刷新令牌再次出现时撤销整个会话
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/library-api/config"
+	"github.com/example/library-api/middleware"
+	"github.com/example/library-api/models"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌不存在、已过期或所属会话已撤销
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused 刷新令牌已被使用过，令牌可能被盗用，所属会话已撤销
+	ErrRefreshTokenReused = errors.New("refresh token has already been used, the session has been revoked")
+)
+
+var (
+	// accessTTL 访问令牌有效期
+	accessTTL = 15 * time.Minute
+	// refreshTTL 刷新令牌有效期，每次刷新后重新计算
+	refreshTTL = 30 * 24 * time.Hour
+)
+
+// Init 根据配置初始化令牌有效期
+func Init(cfg *config.Config) {
+	accessTTL = time.Duration(cfg.AccessTokenMinutes) * time.Minute
+	refreshTTL = time.Duration(cfg.RefreshTokenDays) * 24 * time.Hour
+}
+
+// Client 发起登录的客户端信息，用于在会话列表中区分设备
+type Client struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Tokens 签发给客户端的访问令牌和刷新令牌，过期时间为Unix时间戳（秒）
+type Tokens struct {
+	Token            string `json:"token"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+}
+
+// Start 为用户创建登录会话并签发第一组令牌
+func Start(db *gorm.DB, user *models.User, client Client, now time.Time) (*Tokens, error) {
+	var tokens *Tokens
+	err := db.Transaction(func(tx *gorm.DB) error {
+		session := models.Session{
+			UserID:     user.ID,
+			UserAgent:  truncate(client.UserAgent, 255),
+			IPAddress:  truncate(client.IPAddress, 45),
+			ExpiresAt:  now.Add(refreshTTL),
+			LastUsedAt: now,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		tokens, err = issue(tx, &session, user, now)
+		return err
+	})
+	return tokens, err
+}
+
+// Refresh 使用刷新令牌换取新的一组令牌，旧的刷新令牌随即失效。
+// 已使用过的刷新令牌再次使用时撤销整个会话并返回 ErrRefreshTokenReused
+func Refresh(db *gorm.DB, refreshToken string, client Client, now time.Time) (*Tokens, error) {
+	var tokens *Tokens
+	var reusedSession uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		var session models.Session
+		if err := tx.First(&session, token.SessionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		if session.RevokedAt != nil {
+			return ErrInvalidRefreshToken
+		}
+		if token.UsedAt != nil {
+			reusedSession = session.ID
+			return ErrRefreshTokenReused
+		}
+		if !now.Before(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		// 条件更新保证同一令牌并发使用时只有一个请求成功，其余请求视为重复使用
+		result := tx.Model(&token).Where("used_at IS NULL").Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reusedSession = session.ID
+			return ErrRefreshTokenReused
+		}
+
+		// 重新读取用户，角色变更在刷新后生效，已删除的用户不能再刷新
+		var user models.User
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		session.UserAgent = truncate(client.UserAgent, 255)
+		session.IPAddress = truncate(client.IPAddress, 45)
+		var err error
+		tokens, err = issue(tx, &session, &user, now)
+		return err
+	})
+
+	// 撤销在事务回滚之后进行，保证重复使用的令牌族一定被撤销
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if _, revokeErr := Revoke(db, reusedSession, models.RevokeReuse, now); revokeErr != nil {
+			return nil, revokeErr
+		}
+	}
+	return tokens, err
+}
+
+// Lookup 返回刷新令牌所属的会话ID，令牌不存在时返回 ErrInvalidRefreshToken
+func Lookup(db *gorm.DB, refreshToken string) (uint, error) {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(refreshToken)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrInvalidRefreshToken
+		}
+		return 0, err
+	}
+	return token.SessionID, nil
+}
+
+// Revoke 撤销会话，会话的访问令牌和刷新令牌全部失效。会话已撤销时返回 false
+func Revoke(db *gorm.DB, sessionID uint, reason models.SessionRevokeReason, now time.Time) (bool, error) {
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": reason})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeUser 撤销用户的全部会话，返回撤销的会话数
+func RevokeUser(db *gorm.DB, userID uint, reason models.SessionRevokeReason, now time.Time) (int64, error) {
+	result := db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeAccessToken 单独撤销一个访问令牌，记录保留到令牌过期
+func RevokeAccessToken(db *gorm.DB, jti string, expiresAt time.Time) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// Purge 删除已过期的刷新令牌、会话和撤销记录，过期的令牌本身已无法使用
+func Purge(db *gorm.DB, now time.Time) (int64, error) {
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.RefreshToken{}, &models.Session{}, &models.RevokedToken{}} {
+			result := tx.Where("expires_at < ?", now).Delete(model)
+			if result.Error != nil {
+				return result.Error
+			}
+			purged += result.RowsAffected
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// issue 为会话签发新的访问令牌和刷新令牌，并顺延会话的过期时间
+func issue(tx *gorm.DB, session *models.Session, user *models.User, now time.Time) (*Tokens, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+	refreshExpiresAt := now.Add(refreshTTL)
+
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: refreshExpiresAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	session.ExpiresAt = refreshExpiresAt
+	session.LastUsedAt = now
+	if err := tx.Save(session).Error; err != nil {
+		return nil, err
+	}
+
+	accessExpiresAt := now.Add(accessTTL)
+	accessToken, err := middleware.GenerateToken(user.ID, user.Role, session.ID, accessExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		Token:            accessToken,
+		ExpiresAt:        accessExpiresAt.Unix(),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt.Unix(),
+	}, nil
+}
+
+// hashToken 返回刷新令牌的SHA-256哈希。刷新令牌是高熵随机值，不需要加盐或慢哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate 按字节截断字符串，用于保存客户端提供的信息
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
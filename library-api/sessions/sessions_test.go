@@ -0,0 +1,260 @@
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/keyring/keyringtest"
+	"github.com/example/library-api/middleware"
+	"github.com/example/library-api/models"
+)
+
+var client = Client{UserAgent: "sessions-test", IPAddress: "127.0.0.1"}
+
+// setup 使用临时数据库和临时签名密钥，返回数据库和一个普通用户
+func setup(t *testing.T) (*gorm.DB, *models.User) {
+	t.Helper()
+
+	Init(keyringtest.Init(t))
+	db := databasetest.Use(t)
+
+	user := &models.User{Username: "reader", Email: "reader@example.com", Password: "secret", Role: models.RoleUser}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return db, user
+}
+
+// loadSession 读取会话记录
+func loadSession(t *testing.T, db *gorm.DB, id uint) models.Session {
+	t.Helper()
+
+	var session models.Session
+	if err := db.First(&session, id).Error; err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	return session
+}
+
+// sessionOf 解析访问令牌并返回其声明
+func sessionOf(t *testing.T, tokens *Tokens) *middleware.Claims {
+	t.Helper()
+
+	claims, err := middleware.ParseToken(tokens.Token)
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	return claims
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	db, user := setup(t)
+	now := time.Now()
+
+	first, err := Start(db, user, client, now)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	second, err := Refresh(db, first.RefreshToken, Client{UserAgent: "new-device", IPAddress: "10.0.0.1"}, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.Token == first.Token {
+		t.Error("refresh returned the same tokens")
+	}
+	if second.RefreshExpiresAt <= first.RefreshExpiresAt {
+		t.Errorf("refresh expiry = %d, want later than %d", second.RefreshExpiresAt, first.RefreshExpiresAt)
+	}
+
+	// 新旧访问令牌属于同一会话，会话记录更新客户端信息和使用时间
+	sessionID := sessionOf(t, first).SessionID
+	if got := sessionOf(t, second); got.SessionID != sessionID || got.UserID != user.ID {
+		t.Errorf("claims = %+v, want session %d of user %d", got, sessionID, user.ID)
+	}
+	session := loadSession(t, db, sessionID)
+	if session.UserAgent != "new-device" || session.IPAddress != "10.0.0.1" || !session.LastUsedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("session = %+v, want updated client and last use", session)
+	}
+
+	// 新的刷新令牌可以继续使用
+	if _, err := Refresh(db, second.RefreshToken, client, now.Add(2*time.Minute)); err != nil {
+		t.Errorf("second Refresh: %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	db, user := setup(t)
+	now := time.Now()
+
+	first, err := Start(db, user, client, now)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	second, err := Refresh(db, first.RefreshToken, client, now)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	if _, err := Refresh(db, first.RefreshToken, client, now); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse err = %v, want ErrRefreshTokenReused", err)
+	}
+	session := loadSession(t, db, sessionOf(t, second).SessionID)
+	if session.RevokedAt == nil || session.RevokeReason != models.RevokeReuse {
+		t.Errorf("session revoked_at = %v, reason = %q, want revoked for reuse", session.RevokedAt, session.RevokeReason)
+	}
+
+	// 整个令牌族失效，包括轮换后签发的令牌
+	if _, err := Refresh(db, second.RefreshToken, client, now); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh with rotated token err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshConcurrentUse(t *testing.T) {
+	db, user := setup(t)
+	now := time.Now()
+
+	tokens, err := Start(db, user, client, now)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	const n = 10
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = Refresh(db, tokens.RefreshToken, client, now)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	// 只有一个请求成功，其余请求视为重复使用，会话随即撤销
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrRefreshTokenReused), errors.Is(err, ErrInvalidRefreshToken):
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d refreshes succeeded, want 1", succeeded)
+	}
+
+	var issued int64
+	db.Model(&models.RefreshToken{}).Count(&issued)
+	if issued != 2 {
+		t.Errorf("%d refresh tokens issued, want 2", issued)
+	}
+	if session := loadSession(t, db, sessionOf(t, tokens).SessionID); session.RevokeReason != models.RevokeReuse {
+		t.Errorf("session revoke reason = %q, want %q", session.RevokeReason, models.RevokeReuse)
+	}
+}
+
+func TestRefreshInvalid(t *testing.T) {
+	db, user := setup(t)
+	now := time.Now()
+
+	tokens, err := Start(db, user, client, now)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if _, err := Refresh(db, "unknown", client, now); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := Refresh(db, tokens.RefreshToken, client, now.Add(refreshTTL)); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expired token err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// 过期检查不消耗令牌，用户删除后令牌同样失效
+	if err := db.Delete(user).Error; err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if _, err := Refresh(db, tokens.RefreshToken, client, now); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("deleted user err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestJWTMiddlewareRejectsRevokedTokens(t *testing.T) {
+	db, user := setup(t)
+	now := time.Now()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/me", middleware.JWTMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	first, err := Start(db, user, client, now)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	second, err := Refresh(db, first.RefreshToken, client, now)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if got := status(first.Token); got != http.StatusOK {
+		t.Fatalf("valid token status = %d, want 200", got)
+	}
+
+	// 会话ID属于其他用户的令牌被拒绝
+	forged, err := middleware.GenerateToken(user.ID+1, user.Role, sessionOf(t, second).SessionID, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if got := status(forged); got != http.StatusUnauthorized {
+		t.Errorf("token for another user status = %d, want 401", got)
+	}
+
+	// 单独撤销的访问令牌被拒绝，同一会话的其他令牌不受影响
+	claims := sessionOf(t, first)
+	if err := RevokeAccessToken(db, claims.ID, claims.ExpiresAt.Time); err != nil {
+		t.Fatalf("RevokeAccessToken: %v", err)
+	}
+	if got := status(first.Token); got != http.StatusUnauthorized {
+		t.Errorf("revoked jti status = %d, want 401", got)
+	}
+	if got := status(second.Token); got != http.StatusOK {
+		t.Errorf("other token status = %d, want 200", got)
+	}
+
+	// 撤销会话后会话的全部访问令牌被拒绝
+	if revoked, err := Revoke(db, claims.SessionID, models.RevokeLogout, now); err != nil || !revoked {
+		t.Fatalf("Revoke = %v, %v", revoked, err)
+	}
+	if got := status(second.Token); got != http.StatusUnauthorized {
+		t.Errorf("revoked session status = %d, want 401", got)
+	}
+
+	// 没有会话的令牌被拒绝
+	legacy, err := middleware.GenerateToken(user.ID, user.Role, 0, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if got := status(legacy); got != http.StatusUnauthorized {
+		t.Errorf("token without session status = %d, want 401", got)
+	}
+}
@@ -0,0 +1,131 @@
+// Package subjects 图书的主题分类树和自由标签
+//
+// 分类以 parent_id 组成树，按分类浏览和统计时包含全部后代分类，通过 SQLite 的递归 CTE 查询；
+// 标签不分层级，名称统一为小写，为图书设置标签时不存在的标签自动创建。
+package subjects
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/models"
+)
+
+// MaxTagLength 标签名称的最大字符数
+const MaxTagLength = 50
+
+// ErrInvalidTag 标签名称为空或过长
+var ErrInvalidTag = errors.New("tags must be 1 to 50 characters")
+
+// SubtreeSQL 查询分类及其全部后代分类ID的子查询，参数为分类ID。
+// 使用 UNION 去重，即使数据中出现环也不会无限递归
+const SubtreeSQL = `WITH RECURSIVE subtree(id) AS (
+	SELECT ?
+	UNION
+	SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+	WHERE categories.deleted_at IS NULL
+) SELECT id FROM subtree`
+
+// countSQL 统计每个分类及其后代分类下未删除的图书数，同一本书在多个后代分类中只计一次
+const countSQL = `WITH RECURSIVE tree(root, id) AS (
+	SELECT id, id FROM categories WHERE deleted_at IS NULL
+	UNION
+	SELECT tree.root, categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+	WHERE categories.deleted_at IS NULL
+)
+SELECT tree.root AS category_id, COUNT(DISTINCT book_categories.book_id) AS book_count
+FROM tree
+JOIN book_categories ON book_categories.category_id = tree.id
+JOIN books ON books.id = book_categories.book_id AND books.deleted_at IS NULL
+GROUP BY tree.root`
+
+// NormalizeTag 去除首尾空白、合并连续空白并转换为小写
+func NormalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" || utf8.RuneCountInString(name) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+	return name, nil
+}
+
+// NormalizeTags 规范化并去重标签名称，保持原有顺序
+func NormalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// ResolveTags 按规范化后的名称查找标签，不存在的标签自动创建
+func ResolveTags(db *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		var tag models.Tag
+		if err := db.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// Tree 返回按名称排序的分类树和按ID索引的全部分类。
+// 每个分类的 BookCount 为该分类及其后代分类下的图书数
+func Tree(db *gorm.DB) ([]*models.Category, map[uint]*models.Category, error) {
+	var categories []*models.Category
+	if err := db.Order("name ASC, id ASC").Find(&categories).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var counts []struct {
+		CategoryID uint
+		BookCount  int64
+	}
+	if err := db.Raw(countSQL).Scan(&counts).Error; err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[uint]*models.Category, len(categories))
+	for _, category := range categories {
+		var zero int64
+		category.BookCount = &zero
+		byID[category.ID] = category
+	}
+	for _, count := range counts {
+		if category, ok := byID[count.CategoryID]; ok {
+			n := count.BookCount
+			category.BookCount = &n
+		}
+	}
+
+	roots := []*models.Category{}
+	for _, category := range categories {
+		if category.ParentID != nil {
+			if parent, ok := byID[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, category)
+				continue
+			}
+		}
+		roots = append(roots, category)
+	}
+	return roots, byID, nil
+}
+
+// InSubtree 判断 id 是否为 rootID 本身或其后代分类，用于移动分类时防止形成环
+func InSubtree(db *gorm.DB, rootID, id uint) (bool, error) {
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM ("+SubtreeSQL+") WHERE id = ?", rootID, id).Scan(&count).Error
+	return count > 0, err
+}
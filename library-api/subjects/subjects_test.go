@@ -0,0 +1,159 @@
+package subjects
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/example/library-api/database/databasetest"
+	"github.com/example/library-api/models"
+)
+
+// createCategory 创建分类，parent 为 nil 时为顶级分类
+func createCategory(t *testing.T, db *gorm.DB, name string, parent *models.Category) models.Category {
+	t.Helper()
+
+	category := models.Category{Name: name}
+	if parent != nil {
+		category.ParentID = &parent.ID
+	}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	return category
+}
+
+// createBook 创建属于指定分类的图书
+func createBook(t *testing.T, db *gorm.DB, isbn string, categories ...models.Category) models.Book {
+	t.Helper()
+
+	book := models.Book{Title: isbn, ISBN: isbn, Categories: categories}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	return book
+}
+
+// subtree 返回分类及其全部后代分类的ID
+func subtree(t *testing.T, db *gorm.DB, id uint) []uint {
+	t.Helper()
+
+	var ids []uint
+	if err := db.Raw(SubtreeSQL, id).Scan(&ids).Error; err != nil {
+		t.Fatalf("subtree: %v", err)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestSubtree(t *testing.T) {
+	db := databasetest.Open(t)
+	fiction := createCategory(t, db, "Fiction", nil)
+	scifi := createCategory(t, db, "Science Fiction", &fiction)
+	space := createCategory(t, db, "Space Opera", &scifi)
+	history := createCategory(t, db, "History", nil)
+	deleted := createCategory(t, db, "Westerns", &fiction)
+	createCategory(t, db, "Revisionist", &deleted)
+	if err := db.Delete(&deleted).Error; err != nil {
+		t.Fatalf("delete category: %v", err)
+	}
+
+	// 已删除的分类及其子分类不在子树中
+	if got, want := subtree(t, db, fiction.ID), []uint{fiction.ID, scifi.ID, space.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fiction subtree = %v, want %v", got, want)
+	}
+	if got, want := subtree(t, db, space.ID), []uint{space.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("leaf subtree = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		root, id uint
+		want     bool
+	}{
+		{"自身", scifi.ID, scifi.ID, true},
+		{"子分类", fiction.ID, scifi.ID, true},
+		{"孙分类", fiction.ID, space.ID, true},
+		{"父分类", space.ID, fiction.ID, false},
+		{"其他分类", fiction.ID, history.ID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InSubtree(db, tt.root, tt.id)
+			if err != nil {
+				t.Fatalf("InSubtree: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("InSubtree(%d, %d) = %v, want %v", tt.root, tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtreeStopsOnCycle(t *testing.T) {
+	db := databasetest.Open(t)
+	a := createCategory(t, db, "A", nil)
+	b := createCategory(t, db, "B", &a)
+	// 绕过接口校验直接写入环
+	if err := db.Model(&a).Update("parent_id", b.ID).Error; err != nil {
+		t.Fatalf("update category: %v", err)
+	}
+
+	if got, want := subtree(t, db, a.ID), []uint{a.ID, b.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subtree = %v, want %v", got, want)
+	}
+}
+
+func TestTreeCountsBooksOncePerSubtree(t *testing.T) {
+	db := databasetest.Open(t)
+	fiction := createCategory(t, db, "Fiction", nil)
+	scifi := createCategory(t, db, "Science Fiction", &fiction)
+	space := createCategory(t, db, "Space Opera", &scifi)
+	history := createCategory(t, db, "History", nil)
+
+	// 同一本书属于多个后代分类时，祖先分类只计一次
+	createBook(t, db, "9780441013593", scifi, space)
+	createBook(t, db, "9780306406157", fiction)
+	gone := createBook(t, db, "9780141439587", space)
+	if err := db.Delete(&gone).Error; err != nil {
+		t.Fatalf("delete book: %v", err)
+	}
+
+	roots, byID, err := Tree(db)
+	if err != nil {
+		t.Fatalf("Tree: %v", err)
+	}
+	want := map[uint]int64{fiction.ID: 2, scifi.ID: 1, space.ID: 1, history.ID: 0}
+	for id, n := range want {
+		if c := byID[id]; c == nil || c.BookCount == nil || *c.BookCount != n {
+			t.Errorf("category %d book count = %v, want %d", id, c, n)
+		}
+	}
+
+	if len(roots) != 2 || roots[0].ID != fiction.ID || roots[1].ID != history.ID {
+		t.Fatalf("roots = %v, want Fiction and History", roots)
+	}
+	if children := roots[0].Children; len(children) != 1 || children[0].ID != scifi.ID ||
+		len(children[0].Children) != 1 || children[0].Children[0].ID != space.ID {
+		t.Errorf("fiction children = %v, want Science Fiction > Space Opera", children)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := NormalizeTags([]string{"  Space   Opera ", "space opera", "Classics"})
+	if err != nil {
+		t.Fatalf("NormalizeTags: %v", err)
+	}
+	if want := []string{"space opera", "classics"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+
+	for _, name := range []string{"   ", strings.Repeat("标", MaxTagLength+1)} {
+		if _, err := NormalizeTag(name); err != ErrInvalidTag {
+			t.Errorf("NormalizeTag(%q) error = %v, want ErrInvalidTag", name, err)
+		}
+	}
+}